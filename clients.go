@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/go-redis/redis"
+)
+
+const clientsUsage = "usage: clients create -id id -grant-types types [-redirect-uri uris] [-scope scope] [-public] | list | disable <id> | rotate [-overlap duration] <id> | api-keys create -client id -name name [-scope scope] | api-keys list | api-keys revoke <key-hash>"
+
+// runClients runs the clients command, which creates, lists and disables the
+// OAuth clients, rotates their secrets and manages their API keys. It returns
+// the exit code.
+func runClients(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, clientsUsage)
+		return 2
+	}
+
+	command := args[0]
+	if command == "api-keys" && len(args) > 1 {
+		command, args = command+" "+args[1], args[1:]
+	}
+
+	request := oauth.ClientRequest{}
+	fs := flag.NewFlagSet("clients "+command, flag.ContinueOnError)
+	fs.StringVar(&request.ClientID, "id", "", "ID of the client, for create")
+	fs.StringVar(&request.GrantTypes, "grant-types", "", "space separated grant types of the client, for create")
+	fs.StringVar(&request.RedirectURI, "redirect-uri", "", "space separated redirect URIs of the client, for create")
+	fs.StringVar(&request.Scope, "scope", "", "space separated scopes of the client, for create, or of the API key, for api-keys create")
+	fs.BoolVar(&request.Public, "public", false, "create a public client, without secret, for single page and mobile apps")
+	clientID := fs.String("client", "", "ID of the client of the API key, for api-keys create")
+	name := fs.String("name", "", "name of the API key, for api-keys create")
+	overlap := fs.Duration("overlap", 24*time.Hour, "how long the former secret keeps working, for rotate")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	// stdout carries the secrets alone
+	logger.SetOutput(config, os.Stderr)
+
+	db := infras.CreateMySQLWriteConn(*config)
+	defer db.Close()
+
+	// Redis is only needed to revoke the tokens it keeps
+	var cache *redis.Client
+	if config.OAuth.TokenStore == oauth.TokenStoreRedis {
+		var err error
+		cache, err = infras.ProvideRedis(config)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer cache.Close()
+	}
+	clients := oauth.NewClients(db, oauth.NewTokenStore(config.OAuth.TokenStore, db, cache))
+	ctx := context.Background()
+
+	var err error
+
+	switch {
+	case command == "create":
+		err = createClient(ctx, clients, request)
+	case command == "list":
+		err = printClients(ctx, clients)
+	case command == "disable" && fs.NArg() == 1:
+		err = clients.Disable(ctx, fs.Arg(0))
+	case command == "rotate" && fs.NArg() == 1:
+		err = rotateClientSecret(ctx, clients, fs.Arg(0), *overlap)
+	case command == "api-keys create":
+		err = createAPIKey(ctx, clients, *clientID, *name, request.Scope)
+	case command == "api-keys list":
+		err = printAPIKeys(ctx, clients)
+	case command == "api-keys revoke" && fs.NArg() == 1:
+		err = clients.RevokeAPIKey(ctx, fs.Arg(0))
+	default:
+		fmt.Fprintln(os.Stderr, clientsUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func createClient(ctx context.Context, clients *oauth.Clients, request oauth.ClientRequest) error {
+	client, secret, err := clients.Create(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("client_id:", client.ClientID)
+	if secret != "" {
+		fmt.Println("client_secret:", secret)
+		fmt.Fprintln(os.Stderr, "Store the secret now, it can't be shown again.")
+	}
+	return nil
+}
+
+func rotateClientSecret(ctx context.Context, clients *oauth.Clients, clientID string, overlap time.Duration) error {
+	secret, err := clients.RotateSecret(ctx, clientID, overlap)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("client_secret:", secret)
+	fmt.Fprintf(os.Stderr, "Store the secret now, it can't be shown again. The former secret works until %s.\n", time.Now().Add(overlap).Format(time.RFC3339))
+	return nil
+}
+
+func printClients(ctx context.Context, clients *oauth.Clients) error {
+	list, err := clients.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLIENT_ID\tGRANT_TYPES\tSCOPE\tSTATUS")
+	for _, client := range list {
+		status := "active"
+		switch {
+		case client.DisabledAt.Valid:
+			status = "disabled " + client.DisabledAt.Time.Format(time.RFC3339)
+		case client.Public():
+			status = "active, public"
+		case client.PreviousClientSecretExpires.Valid && time.Now().Before(client.PreviousClientSecretExpires.Time):
+			status = "active, former secret until " + client.PreviousClientSecretExpires.Time.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", client.ClientID, client.GrantTypes, client.Scope.String, status)
+	}
+	return w.Flush()
+}
+
+func createAPIKey(ctx context.Context, clients *oauth.Clients, clientID string, name string, scope string) error {
+	apiKey, key, err := clients.CreateAPIKey(ctx, clientID, name, scope)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("api_key:", key)
+	fmt.Println("key_hash:", apiKey.KeyHash)
+	fmt.Println("scope:", apiKey.Scope.String)
+	fmt.Fprintln(os.Stderr, "Store the key now, it can't be shown again. Revoke it by its hash.")
+	return nil
+}
+
+func printAPIKeys(ctx context.Context, clients *oauth.Clients) error {
+	list, err := clients.ListAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY_HASH\tCLIENT_ID\tNAME\tSCOPE\tCREATED_AT\tSTATUS")
+	for _, apiKey := range list {
+		status := "active"
+		if apiKey.RevokedAt.Valid {
+			status = "revoked " + apiKey.RevokedAt.Time.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", apiKey.KeyHash, apiKey.ClientID, apiKey.Name, apiKey.Scope.String, apiKey.CreatedAt.Time.Format(time.RFC3339), status)
+	}
+	return w.Flush()
+}
@@ -1,15 +1,13 @@
 package configs
 
-import (
-	"sync"
+import "strings"
 
-	"github.com/rs/zerolog/log"
-	"github.com/spf13/viper"
-)
-
-// Config is a struct that will receive configuration options via environment
-// variables.
+// Config is a struct that will receive configuration options from defaults,
+// an optional .env file, environment variables and flags, see Load.
 type Config struct {
+	// Features are feature toggles, set as FEATURES.<NAME>=true.
+	Features map[string]bool `mapstructure:"FEATURES"`
+
 	App struct {
 		CORS struct {
 			AllowCredentials bool     `mapstructure:"ALLOW_CREDENTIALS"`
@@ -19,11 +17,19 @@ type Config struct {
 			Enable           bool     `mapstructure:"ENABLE"`
 			MaxAgeSeconds    int      `mapstructure:"MAX_AGE_SECONDS"`
 		}
-		Name     string `mapstructure:"NAME"`
-		Revision string `mapstructure:"REVISION"`
-		URL      string `mapstructure:"URL"`
+		Name string `mapstructure:"NAME"`
+		// ProblemDetails sends errors as RFC 7807 problem details.
+		ProblemDetails bool   `mapstructure:"PROBLEM_DETAILS"`
+		Revision       string `mapstructure:"REVISION"`
+		URL            string `mapstructure:"URL"`
 	}
 
+	Auth struct {
+		// Strategies are the credentials accepted by Authenticate, tried in
+		// order: jwt, oauth and api_key.
+		Strategies []string `mapstructure:"STRATEGIES"`
+	} `mapstructure:"AUTH"`
+
 	Cache struct {
 		Redis struct {
 			Primary struct {
@@ -32,6 +38,10 @@ type Config struct {
 				Password string `mapstructure:"PASSWORD"`
 			}
 		}
+		Product struct {
+			TTLSeconds     int64 `mapstructure:"TTL_SECONDS"`
+			ListTTLSeconds int64 `mapstructure:"LIST_TTL_SECONDS"`
+		}
 	}
 
 	DB struct {
@@ -52,6 +62,15 @@ type Config struct {
 				Name     string `mapstructure:"NAME"`
 				Timezone string `mapstructure:"TIMEZONE"`
 			}
+			ReadYourWrites struct {
+				WindowSeconds        int64 `mapstructure:"WINDOW_SECONDS"`
+				MaxLagSeconds        int64 `mapstructure:"MAX_LAG_SECONDS"`
+				CheckIntervalSeconds int64 `mapstructure:"CHECK_INTERVAL_SECONDS"`
+			} `mapstructure:"READ_YOUR_WRITES"`
+			Migrate struct {
+				OnStartup          bool  `mapstructure:"ON_STARTUP"`
+				LockTimeoutSeconds int64 `mapstructure:"LOCK_TIMEOUT_SECONDS"`
+			}
 		}
 	}
 
@@ -92,38 +111,88 @@ type Config struct {
 		}
 	}
 
+	OAuth struct {
+		// AccessTokenTTLSeconds is the lifetime of the access tokens issued
+		// by the token endpoint.
+		AccessTokenTTLSeconds int64 `mapstructure:"ACCESS_TOKEN_TTL_SECONDS"`
+		// RefreshTokenTTLSeconds is the lifetime of each refresh token,
+		// renewed whenever a refresh token is rotated.
+		RefreshTokenTTLSeconds int64 `mapstructure:"REFRESH_TOKEN_TTL_SECONDS"`
+		// AuthorizationCodeTTLSeconds is the lifetime of the single use
+		// authorization codes, kept short as they travel in redirects.
+		AuthorizationCodeTTLSeconds int64 `mapstructure:"AUTHORIZATION_CODE_TTL_SECONDS"`
+		// TokenStore keeps the access tokens: mysql, or redis where they
+		// expire on their own.
+		TokenStore string `mapstructure:"TOKEN_STORE"`
+		// PurgeIntervalSeconds is how often the expired tokens and codes are
+		// deleted from MySQL, never if 0.
+		PurgeIntervalSeconds int64 `mapstructure:"PURGE_INTERVAL_SECONDS"`
+		// Introspection is the RFC 7662 endpoint validating the bearer
+		// tokens of ValidateJWTMiddleware, called as an OAuth client.
+		Introspection struct {
+			URL             string `mapstructure:"URL"`
+			ClientID        string `mapstructure:"CLIENT_ID"`
+			ClientSecret    string `mapstructure:"CLIENT_SECRET"`
+			CacheTTLSeconds int64  `mapstructure:"CACHE_TTL_SECONDS"`
+			TimeoutSeconds  int64  `mapstructure:"TIMEOUT_SECONDS"`
+			// CacheSize caps how many active tokens are cached.
+			CacheSize int `mapstructure:"CACHE_SIZE"`
+		}
+	} `mapstructure:"OAUTH"`
+
+	RateLimit struct {
+		Enabled bool `mapstructure:"ENABLED"`
+		// Store keeps the token buckets: redis, shared by every replica, or
+		// memory. Redis falls back to memory while it's unreachable.
+		Store string `mapstructure:"STORE"`
+		// TrustForwardedFor identifies anonymous clients by the address the
+		// load balancer appends to X-Forwarded-For instead of the peer's.
+		TrustForwardedFor bool `mapstructure:"TRUST_FORWARDED_FOR"`
+
+		Default  RateLimitPolicy `mapstructure:"DEFAULT"`
+		Login    RateLimitPolicy `mapstructure:"LOGIN"`
+		Register RateLimitPolicy `mapstructure:"REGISTER"`
+		Checkout RateLimitPolicy `mapstructure:"CHECKOUT"`
+		// Introspection limits token introspection and revocation, which
+		// authenticate the client with a costly secret hash.
+		Introspection RateLimitPolicy `mapstructure:"INTROSPECTION"`
+	} `mapstructure:"RATE_LIMIT"`
+
 	Server struct {
-		Env      string `mapstructure:"ENV"`
-		LogLevel string `mapstructure:"LOG_LEVEL"`
-		Port     string `mapstructure:"PORT"`
-		Shutdown struct {
+		Env       string `mapstructure:"ENV"`
+		LogFormat string `mapstructure:"LOG_FORMAT"`
+		LogLevel  string `mapstructure:"LOG_LEVEL"`
+		Port      string `mapstructure:"PORT"`
+		// MetricsPort serves the Prometheus metrics, apart from the API so it
+		// can be kept internal.
+		MetricsPort           string `mapstructure:"METRICS_PORT"`
+		ReadTimeoutSeconds    int64  `mapstructure:"READ_TIMEOUT_SECONDS"`
+		WriteTimeoutSeconds   int64  `mapstructure:"WRITE_TIMEOUT_SECONDS"`
+		IdleTimeoutSeconds    int64  `mapstructure:"IDLE_TIMEOUT_SECONDS"`
+		RequestTimeoutSeconds int64  `mapstructure:"REQUEST_TIMEOUT_SECONDS"`
+		Shutdown              struct {
 			CleanupPeriodSeconds int64 `mapstructure:"CLEANUP_PERIOD_SECONDS"`
 			GracePeriodSeconds   int64 `mapstructure:"GRACE_PERIOD_SECONDS"`
 		}
 	}
-}
-
-var (
-	conf Config
-	once sync.Once
-)
-
-// Get are responsible to load env and get data an return the struct
-func Get() *Config {
-	viper.SetConfigFile(".env")
-	err := viper.ReadInConfig()
 
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed reading config file")
+	Tracing struct {
+		// Exporter is where spans go: none, stdout or otlp.
+		Exporter string `mapstructure:"EXPORTER"`
+		// Endpoint is the host:port of the OTLP/HTTP collector.
+		Endpoint    string  `mapstructure:"ENDPOINT"`
+		Insecure    bool    `mapstructure:"INSECURE"`
+		SampleRatio float64 `mapstructure:"SAMPLE_RATIO"`
 	}
+}
 
-	once.Do(func() {
-		log.Info().Msg("Service configuration initialized.")
-		err = viper.Unmarshal(&conf)
-		if err != nil {
-			log.Fatal().Err(err).Msg("")
-		}
-	})
+// RateLimitPolicy allows bursts of Requests, refilled over PeriodSeconds.
+type RateLimitPolicy struct {
+	Requests      int   `mapstructure:"REQUESTS"`
+	PeriodSeconds int64 `mapstructure:"PERIOD_SECONDS"`
+}
 
-	return &conf
+// FeatureEnabled checks whether a feature toggle is on.
+func (c *Config) FeatureEnabled(name string) bool {
+	return c.Features[strings.ToLower(name)]
 }
@@ -0,0 +1,138 @@
+package configs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/stretchr/testify/assert"
+)
+
+const envFile = `APP.CORS.ENABLE=true
+APP.CORS.ALLOWED_ORIGINS=http://localhost:8080,http://127.0.0.1:8080
+DB.MYSQL.READ.NAME=shop
+DB.MYSQL.READ.USER=reader
+DB.MYSQL.WRITE.NAME=shop
+DB.MYSQL.WRITE.USER=writer
+DB.MYSQL.WRITE.PASSWORD=from-file
+FEATURES.NEW_CHECKOUT=true
+SERVER.LOG_LEVEL=debug
+SERVER.PORT=9090
+`
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func setenv(t *testing.T, key, value string) {
+	os.Setenv(key, value)
+	t.Cleanup(func() { os.Unsetenv(key) })
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("Defaults And File", func(t *testing.T) {
+		config, err := configs.Load(configs.Options{File: writeFile(t, "test.env", envFile)})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "9090", config.Server.Port)
+		assert.Equal(t, "debug", config.Server.LogLevel)
+		assert.Equal(t, "3306", config.DB.MySQL.Read.Port)
+		assert.Equal(t, int64(30), config.Server.RequestTimeoutSeconds)
+		assert.Equal(t, []string{"http://localhost:8080", "http://127.0.0.1:8080"}, config.App.CORS.AllowedOrigins)
+		assert.Equal(t, []string{"jwt", "oauth", "api_key"}, config.Auth.Strategies)
+		assert.True(t, config.FeatureEnabled("new_checkout"))
+		assert.False(t, config.FeatureEnabled("unknown"))
+	})
+
+	t.Run("Environment Overrides File And Flags Override Environment", func(t *testing.T) {
+		setenv(t, "SERVER_PORT", "7070")
+		setenv(t, "SERVER_LOG_LEVEL", "warn")
+		setenv(t, "FEATURES_DARK_MODE", "true")
+
+		config, err := configs.Load(configs.Options{
+			File:      writeFile(t, "test.env", envFile),
+			Overrides: []string{"SERVER.LOG_LEVEL=error"},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "7070", config.Server.Port)
+		assert.Equal(t, "error", config.Server.LogLevel)
+		assert.True(t, config.FeatureEnabled("dark_mode"))
+	})
+
+	t.Run("Secret File", func(t *testing.T) {
+		setenv(t, "DB_MYSQL_WRITE_PASSWORD_FILE", writeFile(t, "password", "s3cret\n"))
+
+		config, err := configs.Load(configs.Options{File: writeFile(t, "test.env", envFile)})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "s3cret", config.DB.MySQL.Write.Password)
+	})
+
+	t.Run("Missing Optional File", func(t *testing.T) {
+		setenv(t, "DB_MYSQL_READ_NAME", "shop")
+		setenv(t, "DB_MYSQL_READ_USER", "reader")
+		setenv(t, "DB_MYSQL_WRITE_NAME", "shop")
+		setenv(t, "DB_MYSQL_WRITE_USER", "writer")
+
+		config, err := configs.Load(configs.Options{File: filepath.Join(t.TempDir(), ".env")})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "8080", config.Server.Port)
+		assert.Equal(t, "8081", config.Server.MetricsPort)
+	})
+
+	t.Run("Missing Required File", func(t *testing.T) {
+		_, err := configs.Load(configs.Options{File: filepath.Join(t.TempDir(), "prod.env"), RequireFile: true})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("Aggregate Validation Errors", func(t *testing.T) {
+		_, err := configs.Load(configs.Options{
+			File:      filepath.Join(t.TempDir(), ".env"),
+			Overrides: []string{"SERVER.PORT=http", "APP.URL=localhost", "SERVER.LOG_LEVEL=loud", "AUTH.STRATEGIES=oauth,session", "SERVER.METRICS_PORT=http", "CACHE.PRODUCT.LIST_TTL_SECONDS=0"},
+		})
+
+		validationErr, ok := err.(configs.ValidationError)
+		assert.True(t, ok)
+		assert.Contains(t, validationErr, `APP.URL must be an absolute URL, got "localhost"`)
+		assert.Contains(t, validationErr, `SERVER.PORT must be a port between 1 and 65535, got "http"`)
+		assert.Contains(t, validationErr, `SERVER.LOG_LEVEL must be a log level, got "loud"`)
+		assert.Contains(t, validationErr, `SERVER.METRICS_PORT must differ from SERVER.PORT, got "http"`)
+		assert.Contains(t, validationErr, `AUTH.STRATEGIES must list jwt, oauth or api_key, got "session"`)
+		assert.Contains(t, validationErr, "CACHE.PRODUCT.LIST_TTL_SECONDS must be positive, got 0")
+		assert.Contains(t, validationErr, "DB.MYSQL.WRITE.USER is required")
+	})
+}
+
+func TestReload(t *testing.T) {
+	path := writeFile(t, "test.env", envFile)
+	_, err := configs.Init([]string{"-config", path})
+	assert.NoError(t, err)
+
+	var reloaded *configs.Config
+	configs.OnReload(func(config *configs.Config) { reloaded = config })
+
+	changed := envFile + "SERVER.LOG_LEVEL=warn\nSERVER.PORT=7070\nFEATURES.NEW_CHECKOUT=false\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(changed), 0600))
+	assert.NoError(t, configs.Reload())
+
+	// only the settings that are safe to change while running are reloaded
+	assert.Equal(t, reloaded, configs.Current())
+	assert.Equal(t, "warn", configs.Current().Server.LogLevel)
+	assert.False(t, configs.Current().FeatureEnabled("new_checkout"))
+	assert.Equal(t, "9090", configs.Current().Server.Port)
+	assert.Equal(t, "debug", configs.Get().Server.LogLevel)
+
+	// an invalid config is not applied
+	assert.NoError(t, ioutil.WriteFile(path, []byte(envFile+"SERVER.LOG_LEVEL=loud\n"), 0600))
+	assert.Error(t, configs.Reload())
+	assert.Equal(t, "warn", configs.Current().Server.LogLevel)
+}
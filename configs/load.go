@@ -0,0 +1,221 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// defaults are the values of the settings that aren't configured anywhere.
+var defaults = map[string]interface{}{
+	"APP.CORS.ALLOW_CREDENTIALS": true,
+	"APP.CORS.ALLOWED_HEADERS":   "Accept,Authorization,Content-Type,X-API-Key",
+	"APP.CORS.ALLOWED_METHODS":   "GET,PUT,POST,PATCH,DELETE,OPTIONS",
+	"APP.CORS.ENABLE":            false,
+	"APP.CORS.MAX_AGE_SECONDS":   300,
+	"APP.NAME":                   "evm/boilerplate-go",
+	"APP.PROBLEM_DETAILS":        false,
+	"APP.URL":                    "http://localhost:8080",
+
+	"AUTH.STRATEGIES": "jwt,oauth,api_key",
+
+	"CACHE.REDIS.PRIMARY.HOST":       "localhost",
+	"CACHE.REDIS.PRIMARY.PORT":       "6379",
+	"CACHE.PRODUCT.TTL_SECONDS":      300,
+	"CACHE.PRODUCT.LIST_TTL_SECONDS": 60,
+
+	"DB.MYSQL.READ.HOST":                               "localhost",
+	"DB.MYSQL.READ.PORT":                               "3306",
+	"DB.MYSQL.READ.TIMEZONE":                           "UTC",
+	"DB.MYSQL.WRITE.HOST":                              "localhost",
+	"DB.MYSQL.WRITE.PORT":                              "3306",
+	"DB.MYSQL.WRITE.TIMEZONE":                          "UTC",
+	"DB.MYSQL.READ_YOUR_WRITES.WINDOW_SECONDS":         5,
+	"DB.MYSQL.READ_YOUR_WRITES.MAX_LAG_SECONDS":        10,
+	"DB.MYSQL.READ_YOUR_WRITES.CHECK_INTERVAL_SECONDS": 5,
+	"DB.MYSQL.MIGRATE.ON_STARTUP":                      false,
+	"DB.MYSQL.MIGRATE.LOCK_TIMEOUT_SECONDS":            60,
+
+	"EVENT.CONSUMER.SQS.BACKOFF_SECONDS":     3,
+	"EVENT.CONSUMER.SQS.MAX_MESSAGE":         10,
+	"EVENT.CONSUMER.SQS.MAX_RETRIES":         3,
+	"EVENT.CONSUMER.SQS.MAX_RETRIES_CONSUME": 3,
+	"EVENT.CONSUMER.SQS.REGION":              "ap-southeast-1",
+	"EVENT.CONSUMER.SQS.WAIT_TIME_SECONDS":   10,
+	"EVENT.PRODUCER.SNS.MAX_RETRIES":         3,
+	"EVENT.PRODUCER.SNS.REGION":              "ap-southeast-1",
+
+	"OAUTH.ACCESS_TOKEN_TTL_SECONDS":        3600,
+	"OAUTH.REFRESH_TOKEN_TTL_SECONDS":       2592000,
+	"OAUTH.AUTHORIZATION_CODE_TTL_SECONDS":  60,
+	"OAUTH.TOKEN_STORE":                     "mysql",
+	"OAUTH.PURGE_INTERVAL_SECONDS":          3600,
+	"OAUTH.INTROSPECTION.URL":               "http://localhost:8080/oauth/introspect",
+	"OAUTH.INTROSPECTION.CACHE_TTL_SECONDS": 30,
+	"OAUTH.INTROSPECTION.TIMEOUT_SECONDS":   5,
+	"OAUTH.INTROSPECTION.CACHE_SIZE":        10000,
+
+	"RATE_LIMIT.ENABLED":                      true,
+	"RATE_LIMIT.STORE":                        "redis",
+	"RATE_LIMIT.TRUST_FORWARDED_FOR":          false,
+	"RATE_LIMIT.DEFAULT.REQUESTS":             60,
+	"RATE_LIMIT.DEFAULT.PERIOD_SECONDS":       60,
+	"RATE_LIMIT.LOGIN.REQUESTS":               5,
+	"RATE_LIMIT.LOGIN.PERIOD_SECONDS":         60,
+	"RATE_LIMIT.REGISTER.REQUESTS":            3,
+	"RATE_LIMIT.REGISTER.PERIOD_SECONDS":      600,
+	"RATE_LIMIT.CHECKOUT.REQUESTS":            10,
+	"RATE_LIMIT.CHECKOUT.PERIOD_SECONDS":      60,
+	"RATE_LIMIT.INTROSPECTION.REQUESTS":       600,
+	"RATE_LIMIT.INTROSPECTION.PERIOD_SECONDS": 60,
+
+	"SERVER.ENV":                             "development",
+	"SERVER.LOG_FORMAT":                      "console",
+	"SERVER.LOG_LEVEL":                       "info",
+	"SERVER.PORT":                            "8080",
+	"SERVER.METRICS_PORT":                    "8081",
+	"SERVER.READ_TIMEOUT_SECONDS":            15,
+	"SERVER.WRITE_TIMEOUT_SECONDS":           35,
+	"SERVER.IDLE_TIMEOUT_SECONDS":            120,
+	"SERVER.REQUEST_TIMEOUT_SECONDS":         30,
+	"SERVER.SHUTDOWN.CLEANUP_PERIOD_SECONDS": 15,
+	"SERVER.SHUTDOWN.GRACE_PERIOD_SECONDS":   15,
+
+	"TRACING.EXPORTER":     "none",
+	"TRACING.ENDPOINT":     "localhost:4318",
+	"TRACING.INSECURE":     false,
+	"TRACING.SAMPLE_RATIO": 1.0,
+}
+
+const (
+	// secretFileSuffix marks a setting read from the file at the given path,
+	// such as DB_MYSQL_WRITE_PASSWORD_FILE=/run/secrets/db-password.
+	secretFileSuffix = "_FILE"
+	featuresKey      = "FEATURES"
+)
+
+// Options tells where to load the configuration from.
+type Options struct {
+	// File is the path of the configuration file, .env if empty.
+	File string
+	// RequireFile fails loading when the file doesn't exist, instead of
+	// carrying on with the other layers.
+	RequireFile bool
+	// Overrides are KEY=VALUE pairs taking precedence over everything else.
+	Overrides []string
+}
+
+// Load loads the configuration in layers, each overriding the previous one:
+// the defaults, the configuration file, the environment variables and the
+// overrides. Environment variables are named after the keys, either as is or
+// with underscores instead of dots, such as DB_MYSQL_WRITE_PASSWORD. Every
+// setting can also be read from a file, named by the setting with a _FILE
+// suffix. The loaded configuration is validated.
+func Load(opts Options) (*Config, error) {
+	v := viper.New()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+
+	file := opts.File
+	if file == "" {
+		file = ".env"
+	}
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		if !errors.Is(err, os.ErrNotExist) || opts.RequireFile {
+			return nil, fmt.Errorf("failed reading config file %s: %w", file, err)
+		}
+		log.Info().Str("file", file).Msg("No config file, using defaults and environment variables")
+	}
+
+	for _, key := range keys(reflect.TypeOf(Config{}), "") {
+		if err := loadSecretFile(v, key, v.GetString(key+secretFileSuffix)); err != nil {
+			return nil, err
+		}
+		if value, ok := lookupEnv(key); ok {
+			v.Set(key, value)
+		}
+		if path, ok := lookupEnv(key + secretFileSuffix); ok {
+			if err := loadSecretFile(v, key, path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	loadFeatureEnv(v)
+
+	for _, override := range opts.Overrides {
+		pair := strings.SplitN(override, "=", 2)
+		if len(pair) != 2 || pair[0] == "" {
+			return nil, fmt.Errorf("invalid override %q, expected KEY=VALUE", override)
+		}
+		v.Set(pair[0], pair[1])
+	}
+
+	config := &Config{}
+	if err := v.Unmarshal(config); err != nil {
+		return nil, err
+	}
+
+	return config, config.Validate()
+}
+
+// keys lists the keys of the settings of a configuration struct.
+func keys(t reflect.Type, prefix string) (result []string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" {
+			name = strings.ToUpper(field.Name)
+		}
+
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			result = append(result, keys(field.Type, prefix+name+".")...)
+		case reflect.Map:
+			// maps have no fixed keys, see loadFeatureEnv
+		default:
+			result = append(result, prefix+name)
+		}
+	}
+	return
+}
+
+// lookupEnv looks up the environment variable of a key.
+func lookupEnv(key string) (string, bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+	return os.LookupEnv(strings.ReplaceAll(key, ".", "_"))
+}
+
+func loadSecretFile(v *viper.Viper, key, path string) error {
+	if path == "" {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed reading %s%s: %w", key, secretFileSuffix, err)
+	}
+	v.Set(key, strings.TrimRight(string(content), "\r\n"))
+	return nil
+}
+
+// loadFeatureEnv loads the feature toggles set as environment variables.
+func loadFeatureEnv(v *viper.Viper) {
+	for _, env := range os.Environ() {
+		pair := strings.SplitN(env, "=", 2)
+		for _, prefix := range []string{featuresKey + ".", featuresKey + "_"} {
+			if name := strings.TrimPrefix(pair[0], prefix); name != pair[0] && name != "" {
+				v.Set(featuresKey+"."+name, pair[1])
+			}
+		}
+	}
+}
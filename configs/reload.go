@@ -0,0 +1,142 @@
+package configs
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	mu        sync.Mutex
+	conf      *Config
+	options   Options
+	current   atomic.Value
+	listeners []func(*Config)
+)
+
+// overrides collects the repeatable -set flag.
+type overrides []string
+
+func (o *overrides) String() string {
+	return ""
+}
+
+func (o *overrides) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+// Init loads the configuration using the config flags at the start of the
+// arguments, -config <file> and -set KEY=VALUE, and returns the remaining
+// arguments. The file can also be set with the CONFIG_FILE environment
+// variable.
+func Init(args []string) ([]string, error) {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "path of the configuration file, .env if empty")
+	var sets overrides
+	fs.Var(&sets, "set", "KEY=VALUE overriding a setting, can be repeated")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	opts := Options{File: *file, RequireFile: *file != "", Overrides: sets}
+	loaded, err := Load(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	conf, options = loaded, opts
+	snapshot := *loaded
+	current.Store(&snapshot)
+	log.Info().Msg("Service configuration initialized.")
+
+	return fs.Args(), nil
+}
+
+// Get returns the configuration loaded at startup, loading it without flags
+// if Init wasn't called. Settings reloaded later are only seen through
+// Current.
+func Get() *Config {
+	mu.Lock()
+	loaded := conf
+	mu.Unlock()
+
+	if loaded == nil {
+		if _, err := Init(nil); err != nil {
+			log.Fatal().Msg(err.Error())
+		}
+		mu.Lock()
+		loaded = conf
+		mu.Unlock()
+	}
+	return loaded
+}
+
+// Current returns the configuration including the settings reloaded since
+// startup.
+func Current() *Config {
+	if config, ok := current.Load().(*Config); ok {
+		return config
+	}
+	return Get()
+}
+
+// OnReload registers a function called with the new configuration whenever
+// it is reloaded.
+func OnReload(fn func(*Config)) {
+	mu.Lock()
+	defer mu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+// Reload loads the configuration again from the same layers. Only the
+// settings that are safe to change while running are applied: the log level,
+// CORS and the feature toggles. Anything else needs a restart. The current
+// configuration is kept when the new one is invalid.
+func Reload() error {
+	mu.Lock()
+	opts := options
+	mu.Unlock()
+
+	loaded, err := Load(opts)
+	if err != nil {
+		return err
+	}
+
+	next := *Current()
+	next.Server.LogLevel = loaded.Server.LogLevel
+	next.App.CORS = loaded.App.CORS
+	next.Features = loaded.Features
+	current.Store(&next)
+
+	mu.Lock()
+	registered := append([]func(*Config){}, listeners...)
+	mu.Unlock()
+	for _, fn := range registered {
+		fn(&next)
+	}
+
+	log.Info().Msg("Service configuration reloaded.")
+	return nil
+}
+
+// WatchReload reloads the configuration whenever the process receives a
+// SIGHUP.
+func WatchReload() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			if err := Reload(); err != nil {
+				log.Error().Err(err).Msg("Failed reloading configuration, keeping the current one")
+			}
+		}
+	}()
+}
@@ -0,0 +1,157 @@
+package configs
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e, "\n  - ")
+}
+
+// Validate checks the required settings, ports and URLs, and reports all the
+// problems at once.
+func (c *Config) Validate() error {
+	var problems ValidationError
+	required := func(key, value string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, key+" is required")
+		}
+	}
+	port := func(key, value string) {
+		if number, err := strconv.Atoi(value); err != nil || number < 1 || number > 65535 {
+			problems = append(problems, fmt.Sprintf("%s must be a port between 1 and 65535, got %q", key, value))
+		}
+	}
+	absoluteURL := func(key, value string) {
+		if parsed, err := url.Parse(value); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			problems = append(problems, fmt.Sprintf("%s must be an absolute URL, got %q", key, value))
+		}
+	}
+
+	required("APP.NAME", c.App.Name)
+	absoluteURL("APP.URL", c.App.URL)
+	for _, origin := range c.App.CORS.AllowedOrigins {
+		if origin != "*" {
+			absoluteURL("APP.CORS.ALLOWED_ORIGINS", origin)
+		}
+	}
+
+	if len(c.Auth.Strategies) == 0 {
+		problems = append(problems, "AUTH.STRATEGIES must list at least one of jwt, oauth and api_key")
+	}
+	seen := make(map[string]bool)
+	for _, strategy := range c.Auth.Strategies {
+		switch {
+		case strategy != "jwt" && strategy != "oauth" && strategy != "api_key":
+			problems = append(problems, fmt.Sprintf("AUTH.STRATEGIES must list jwt, oauth or api_key, got %q", strategy))
+		case seen[strategy]:
+			problems = append(problems, fmt.Sprintf("AUTH.STRATEGIES lists %q twice", strategy))
+		}
+		seen[strategy] = true
+	}
+
+	required("CACHE.REDIS.PRIMARY.HOST", c.Cache.Redis.Primary.Host)
+	port("CACHE.REDIS.PRIMARY.PORT", c.Cache.Redis.Primary.Port)
+	if ttl := c.Cache.Product.TTLSeconds; ttl <= 0 {
+		problems = append(problems, fmt.Sprintf("CACHE.PRODUCT.TTL_SECONDS must be positive, got %d", ttl))
+	}
+	if ttl := c.Cache.Product.ListTTLSeconds; ttl <= 0 {
+		problems = append(problems, fmt.Sprintf("CACHE.PRODUCT.LIST_TTL_SECONDS must be positive, got %d", ttl))
+	}
+
+	for name, db := range map[string]struct{ Host, Port, Username, Name string }{
+		"READ":  {c.DB.MySQL.Read.Host, c.DB.MySQL.Read.Port, c.DB.MySQL.Read.Username, c.DB.MySQL.Read.Name},
+		"WRITE": {c.DB.MySQL.Write.Host, c.DB.MySQL.Write.Port, c.DB.MySQL.Write.Username, c.DB.MySQL.Write.Name},
+	} {
+		required("DB.MYSQL."+name+".HOST", db.Host)
+		port("DB.MYSQL."+name+".PORT", db.Port)
+		required("DB.MYSQL."+name+".USER", db.Username)
+		required("DB.MYSQL."+name+".NAME", db.Name)
+	}
+
+	if sqsURL := c.Event.Consumer.SQS.Topics.FooBarBaz.URL; sqsURL != "" {
+		absoluteURL("EVENT.CONSUMER.SQS.TOPICS.FOOBARBAZ.URL", sqsURL)
+	}
+
+	if ttl := c.OAuth.AccessTokenTTLSeconds; ttl <= 0 {
+		problems = append(problems, fmt.Sprintf("OAUTH.ACCESS_TOKEN_TTL_SECONDS must be positive, got %d", ttl))
+	}
+	if ttl := c.OAuth.RefreshTokenTTLSeconds; ttl <= 0 {
+		problems = append(problems, fmt.Sprintf("OAUTH.REFRESH_TOKEN_TTL_SECONDS must be positive, got %d", ttl))
+	}
+	if ttl := c.OAuth.AuthorizationCodeTTLSeconds; ttl <= 0 || ttl > 600 {
+		problems = append(problems, fmt.Sprintf("OAUTH.AUTHORIZATION_CODE_TTL_SECONDS must be between 1 and 600, got %d", ttl))
+	}
+	if store := c.OAuth.TokenStore; store != "mysql" && store != "redis" {
+		problems = append(problems, fmt.Sprintf("OAUTH.TOKEN_STORE must be mysql or redis, got %q", store))
+	}
+	if interval := c.OAuth.PurgeIntervalSeconds; interval < 0 {
+		problems = append(problems, fmt.Sprintf("OAUTH.PURGE_INTERVAL_SECONDS must not be negative, got %d", interval))
+	}
+	absoluteURL("OAUTH.INTROSPECTION.URL", c.OAuth.Introspection.URL)
+	if ttl := c.OAuth.Introspection.CacheTTLSeconds; ttl < 0 {
+		problems = append(problems, fmt.Sprintf("OAUTH.INTROSPECTION.CACHE_TTL_SECONDS must not be negative, got %d", ttl))
+	}
+	if timeout := c.OAuth.Introspection.TimeoutSeconds; timeout <= 0 {
+		problems = append(problems, fmt.Sprintf("OAUTH.INTROSPECTION.TIMEOUT_SECONDS must be positive, got %d", timeout))
+	}
+	if size := c.OAuth.Introspection.CacheSize; size < 0 {
+		problems = append(problems, fmt.Sprintf("OAUTH.INTROSPECTION.CACHE_SIZE must not be negative, got %d", size))
+	}
+
+	if store := c.RateLimit.Store; store != "redis" && store != "memory" {
+		problems = append(problems, fmt.Sprintf("RATE_LIMIT.STORE must be redis or memory, got %q", store))
+	}
+	for name, policy := range map[string]RateLimitPolicy{
+		"DEFAULT":       c.RateLimit.Default,
+		"LOGIN":         c.RateLimit.Login,
+		"REGISTER":      c.RateLimit.Register,
+		"CHECKOUT":      c.RateLimit.Checkout,
+		"INTROSPECTION": c.RateLimit.Introspection,
+	} {
+		if policy.Requests > 0 && policy.PeriodSeconds <= 0 {
+			problems = append(problems, fmt.Sprintf("RATE_LIMIT.%s.PERIOD_SECONDS must be positive, got %d", name, policy.PeriodSeconds))
+		}
+	}
+
+	port("SERVER.PORT", c.Server.Port)
+	port("SERVER.METRICS_PORT", c.Server.MetricsPort)
+	if c.Server.MetricsPort == c.Server.Port {
+		problems = append(problems, fmt.Sprintf("SERVER.METRICS_PORT must differ from SERVER.PORT, got %q", c.Server.MetricsPort))
+	}
+	if write, request := c.Server.WriteTimeoutSeconds, c.Server.RequestTimeoutSeconds; write > 0 && request > 0 && write <= request {
+		problems = append(problems, fmt.Sprintf("SERVER.WRITE_TIMEOUT_SECONDS must be longer than SERVER.REQUEST_TIMEOUT_SECONDS, got %d", write))
+	}
+	if format := c.Server.LogFormat; format != "console" && format != "json" {
+		problems = append(problems, fmt.Sprintf("SERVER.LOG_FORMAT must be console or json, got %q", format))
+	}
+	if _, err := zerolog.ParseLevel(c.Server.LogLevel); err != nil {
+		problems = append(problems, fmt.Sprintf("SERVER.LOG_LEVEL must be a log level, got %q", c.Server.LogLevel))
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		required("TRACING.ENDPOINT", c.Tracing.Endpoint)
+	default:
+		problems = append(problems, fmt.Sprintf("TRACING.EXPORTER must be none, stdout or otlp, got %q", c.Tracing.Exporter))
+	}
+	if ratio := c.Tracing.SampleRatio; ratio < 0 || ratio > 1 {
+		problems = append(problems, fmt.Sprintf("TRACING.SAMPLE_RATIO must be between 0 and 1, got %v", ratio))
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return problems
+	}
+	return nil
+}
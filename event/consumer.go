@@ -1,7 +1,11 @@
 package event
 
 import (
+	"context"
+
+	"github.com/evermos/boilerplate-go/event/consumer"
 	"github.com/evermos/boilerplate-go/event/domain/foobarbaz"
+	fooBarBazDomain "github.com/evermos/boilerplate-go/internal/domain/foobarbaz"
 )
 
 // Consumers is the wrapper to contain all event consumers.
@@ -20,3 +24,17 @@ func ProvideConsumers(fooBarBaz foobarbaz.ConsumerImpl) Consumers {
 func (c *Consumers) Start() {
 	c.FooBarBaz.Start()
 }
+
+// Stop stops all domains event consumer, waiting for the messages they
+// received to be processed.
+func (c *Consumers) Stop(ctx context.Context) error {
+	return c.FooBarBaz.Stop(ctx)
+}
+
+// Processes returns the process function of every domain consumer, keyed by
+// the event type it consumes.
+func (c *Consumers) Processes() map[string]consumer.Process {
+	return map[string]consumer.Process{
+		fooBarBazDomain.FooBarBazEventType: c.FooBarBaz.ProcessEvent,
+	}
+}
@@ -1,5 +1,8 @@
 package consumer
 
+import "context"
+
 type Consumer interface {
 	Listen(url string)
+	Stop(ctx context.Context) error
 }
@@ -0,0 +1,28 @@
+package consumer
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	sqsReceives = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqs_receives_total",
+		Help: "Number of SQS receive calls, by queue and outcome.",
+	}, []string{"queue", "outcome"})
+	sqsMessagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqs_messages_received_total",
+		Help: "Number of messages received from SQS, by queue.",
+	}, []string{"queue"})
+	sqsMessagesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqs_messages_processed_total",
+		Help: "Number of SQS messages processed, by queue and outcome.",
+	}, []string{"queue", "outcome"})
+)
+
+func outcome(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
@@ -1,6 +1,9 @@
 package consumer
 
 import (
+	"context"
+	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -8,11 +11,15 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/shared/tracing"
 	"github.com/rs/zerolog/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Process represents the processing function of the message consumer.
-type Process func(e []byte) error
+type Process func(ctx context.Context, e []byte) error
 
 // SQSConfig represents an SQS configuration object.
 type SQSConfig struct {
@@ -32,7 +39,8 @@ func (m *SQSConfig) Retrieve() (credentials.Value, error) {
 	}, nil
 }
 
-func createSQSConfig(config *configs.Config) (*session.Session, error) {
+// NewSQSSession creates an AWS session for the SQS consumer configuration.
+func NewSQSSession(config *configs.Config) (*session.Session, error) {
 	sqsConfig := SQSConfig{Config: *config}
 	return session.NewSession(&aws.Config{
 		Region:      &config.Event.Consumer.SQS.Region,
@@ -46,28 +54,58 @@ type SQSConsumer struct {
 	Process Process
 	config  *configs.Config
 	sqs     *sqs.SQS
+
+	quit      chan struct{}
+	stopOnce  sync.Once
+	listening sync.WaitGroup
 }
 
 // NewSQSConsumer create object Consumer
 func NewSQSConsumer(config *configs.Config) *SQSConsumer {
-	sess, err := createSQSConfig(config)
+	sess, err := NewSQSSession(config)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed creating sqs config")
 	}
-	return &SQSConsumer{config: config, sqs: sqs.New(sess)}
+	return &SQSConsumer{config: config, sqs: sqs.New(sess), quit: make(chan struct{})}
 }
 
-// Listen is a function to listen new message from sqs queue
+// Listen is a function to listen new message from sqs queue. It returns once
+// the consumer is stopped, after processing the messages it already received.
 func (p *SQSConsumer) Listen(url string) {
+	p.listening.Add(1)
+	defer p.listening.Done()
 	log.Info().Str("url", url).Msg("SQS Consumer will start polling.")
 
+	// stopping cancels a receive waiting for messages
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	retries := 0
 	for {
-		receiveResp, err := p.sqs.ReceiveMessage(&sqs.ReceiveMessageInput{
-			QueueUrl:            aws.String(url),
-			MaxNumberOfMessages: aws.Int64(p.config.Event.Consumer.SQS.MaxMessage),
-			WaitTimeSeconds:     aws.Int64(p.config.Event.Consumer.SQS.WaitTimeSeconds),
+		select {
+		case <-p.quit:
+			log.Info().Str("url", url).Msg("SQS Consumer stopped polling.")
+			return
+		default:
+		}
+
+		receiveResp, err := p.sqs.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(url),
+			MaxNumberOfMessages:   aws.Int64(p.config.Event.Consumer.SQS.MaxMessage),
+			WaitTimeSeconds:       aws.Int64(p.config.Event.Consumer.SQS.WaitTimeSeconds),
+			MessageAttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameAll}),
 		})
+		if ctx.Err() != nil {
+			continue
+		}
+		sqsReceives.WithLabelValues(url, outcome(err)).Inc()
 		if err != nil {
 			if retries == p.config.Event.Consumer.SQS.MaxRetriesConsume {
 				log.Error().Err(err).Int("retries", retries).Msg("failed receiving message after maximum retries, failing permanently")
@@ -82,14 +120,19 @@ func (p *SQSConsumer) Listen(url string) {
 				Int("backoffSeconds", p.config.Event.Consumer.SQS.BackoffSeconds).
 				Msg("failed receiving message, will retry")
 			retries++
-			time.Sleep(time.Duration(p.config.Event.Consumer.SQS.BackoffSeconds) * time.Second)
+			select {
+			case <-p.quit:
+			case <-time.After(time.Duration(p.config.Event.Consumer.SQS.BackoffSeconds) * time.Second):
+			}
 			continue
 		} else {
 			retries = 0
 		}
 
+		sqsMessagesReceived.WithLabelValues(url).Add(float64(len(receiveResp.Messages)))
 		for _, message := range receiveResp.Messages {
-			err := p.Process([]byte(*message.Body))
+			err := p.process(url, message)
+			sqsMessagesProcessed.WithLabelValues(url, outcome(err)).Inc()
 			if err != nil {
 				log.Error().Err(err).Msg("failed processing message")
 			}
@@ -102,6 +145,66 @@ func (p *SQSConsumer) Listen(url string) {
 	}
 }
 
+// Stop stops polling and waits until the messages already received have been
+// processed, or the context is done.
+func (p *SQSConsumer) Stop(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.quit) })
+
+	stopped := make(chan struct{})
+	go func() {
+		p.listening.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// process hands a message to Process in a consumer span, continuing the trace
+// of its publisher.
+func (p *SQSConsumer) process(url string, message *sqs.Message) (err error) {
+	body := []byte(aws.StringValue(message.Body))
+	ctx := tracing.ExtractAttributes(context.Background(), messageAttributes(message, body))
+	ctx, span := tracing.Tracer().Start(ctx, url+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("sqs"),
+			semconv.MessagingDestinationKindQueue,
+			semconv.MessagingDestinationKey.String(url),
+			semconv.MessagingOperationProcess,
+			semconv.MessagingMessageIDKey.String(aws.StringValue(message.MessageId)),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return p.Process(ctx, body)
+}
+
+// messageAttributes returns the attributes of a message. Unless SNS delivers
+// raw messages, the attributes it was published with are in the notification
+// wrapping it.
+func messageAttributes(message *sqs.Message, body []byte) map[string]string {
+	attributes := make(map[string]string)
+
+	var notification model.SNSMessage
+	if json.Unmarshal(body, &notification) == nil {
+		for name, attribute := range notification.MessageAttributes {
+			attributes[name] = attribute.Value
+		}
+	}
+	for name, attribute := range message.MessageAttributes {
+		if attribute.StringValue != nil {
+			attributes[name] = *attribute.StringValue
+		}
+	}
+
+	return attributes
+}
+
 func (p *SQSConsumer) deleteMessage(msg *sqs.Message, url string) error {
 	output, err := p.sqs.DeleteMessage(&sqs.DeleteMessageInput{
 		QueueUrl:      &url,
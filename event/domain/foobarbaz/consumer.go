@@ -1,6 +1,7 @@
 package foobarbaz
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 
@@ -10,7 +11,6 @@ import (
 	"github.com/evermos/boilerplate-go/internal/domain/foobarbaz"
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/evermos/boilerplate-go/shared/logger"
-	"github.com/rs/zerolog/log"
 )
 
 // ConsumerImpl is the SQS consumer implementation for this domain.
@@ -27,7 +27,7 @@ func ProvideConsumerImpl(config *configs.Config, service foobarbaz.FooService) C
 	c.Service = service
 
 	sqsConsumer := consumer.NewSQSConsumer(config)
-	sqsConsumer.Process = c.processEvent
+	sqsConsumer.Process = c.ProcessEvent
 	c.Consumer = sqsConsumer
 
 	return c
@@ -40,7 +40,13 @@ func (c *ConsumerImpl) Start() {
 	}
 }
 
-func (c *ConsumerImpl) processEvent(value []byte) (err error) {
+// Stop stops the SQS subscriber once it processed the messages it received.
+func (c *ConsumerImpl) Stop(ctx context.Context) error {
+	return c.Consumer.Stop(ctx)
+}
+
+// ProcessEvent processes an SNS message received from SQS.
+func (c *ConsumerImpl) ProcessEvent(ctx context.Context, value []byte) (err error) {
 	snsMessage := model.SNSMessage{}
 	err = json.Unmarshal(value, &snsMessage)
 	if err != nil {
@@ -48,7 +54,12 @@ func (c *ConsumerImpl) processEvent(value []byte) (err error) {
 		return
 	}
 
-	log.
+	// keep correlating the logs with the request that published the event
+	if requestID := snsMessage.Attribute(model.AttributeRequestID); requestID != "" {
+		ctx = logger.WithRequest(ctx, requestID, nil)
+	}
+
+	logger.Ctx(ctx).
 		Info().
 		Str("topicARN", snsMessage.TopicARN).
 		Interface("value", snsMessage).
@@ -57,28 +68,25 @@ func (c *ConsumerImpl) processEvent(value []byte) (err error) {
 	requestFormat := foobarbaz.FooRequestFormat{}
 	err = json.Unmarshal([]byte(snsMessage.Message), &requestFormat)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
-	_, err = c.Service.Create(requestFormat, snsMessage.MessageID)
+	_, err = c.Service.Create(ctx, requestFormat, snsMessage.MessageID)
 	if err != nil {
-		err = c.checkError(err)
+		err = c.checkError(ctx, err)
 	}
 
 	return
 }
 
-func (c *ConsumerImpl) checkError(err error) error {
-	f, ok := err.(*failure.Failure)
-	if ok {
-		if f.Code == http.StatusBadRequest {
-			err = nil
-		}
+func (c *ConsumerImpl) checkError(ctx context.Context, err error) error {
+	if failure.GetCode(err) == http.StatusBadRequest {
+		err = nil
 	}
 
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return err
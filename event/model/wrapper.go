@@ -7,18 +7,40 @@ import (
 	"github.com/gofrs/uuid"
 )
 
+const (
+	// AttributeEventType is the message attribute carrying the event type of
+	// published messages.
+	AttributeEventType = "event_type"
+	// AttributeRequestID is the message attribute carrying the ID of the
+	// request that published the message, to correlate logs across services.
+	AttributeRequestID = "request_id"
+)
+
 // SNSMessage is a wrapper struct for messages received in SQS that originated
 // from SNS.
 type SNSMessage struct {
-	Type             string    `json:"Type"`
-	MessageID        uuid.UUID `json:"MessageId"`
-	TopicARN         string    `json:"TopicArn"`
-	Message          string    `json:"Message"`
-	Timestamp        string    `json:"Timestamp"`
-	SignatureVersion string    `json:"SignatureVersion"`
-	Signature        string    `json:"Signature"`
-	SigningCertURL   string    `json:"SigningCertURL"`
-	UnsubscribeURL   string    `json:"UnsubscribeURL"`
+	Type              string                         `json:"Type"`
+	MessageID         uuid.UUID                      `json:"MessageId"`
+	TopicARN          string                         `json:"TopicArn"`
+	Message           string                         `json:"Message"`
+	Timestamp         string                         `json:"Timestamp"`
+	SignatureVersion  string                         `json:"SignatureVersion"`
+	Signature         string                         `json:"Signature"`
+	SigningCertURL    string                         `json:"SigningCertURL"`
+	UnsubscribeURL    string                         `json:"UnsubscribeURL"`
+	MessageAttributes map[string]SNSMessageAttribute `json:"MessageAttributes,omitempty"`
+}
+
+// SNSMessageAttribute is a message attribute of an SNSMessage.
+type SNSMessageAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+// Attribute returns the value of a message attribute, or an empty string if
+// the message doesn't have it.
+func (m SNSMessage) Attribute(name string) string {
+	return m.MessageAttributes[name].Value
 }
 
 // EventWrapper is the wrapper object for events.
@@ -0,0 +1,84 @@
+package producer
+
+import (
+	"context"
+	"time"
+
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	insertOutboxQuery = `
+		INSERT INTO event_outbox (entity_id, event_type, topic, payload)
+		VALUES (?, ?, ?, ?)`
+
+	markOutboxPublishedQuery = `
+		UPDATE event_outbox
+		SET published = ?
+		WHERE entity_id = ?`
+)
+
+// Outbox is a Producer recording every event in the event_outbox table before
+// publishing it. Events published within a transaction are recorded with the
+// changes that caused them and published once it commits, so rolled back
+// changes publish nothing. Events that failed to publish are left without a
+// published time, to be replayed from the outbox.
+type Outbox struct {
+	db       *infras.MySQLConn
+	producer Producer
+}
+
+// ProvideOutbox is the provider for Outbox, publishing through SNS.
+func ProvideOutbox(db *infras.MySQLConn, producer *SNSProducer) *Outbox {
+	return NewOutbox(db, producer)
+}
+
+// NewOutbox creates an Outbox publishing through the producer.
+func NewOutbox(db *infras.MySQLConn, producer Producer) *Outbox {
+	return &Outbox{db: db, producer: producer}
+}
+
+// Publish records the event, and publishes it once the transaction of the
+// context commits, or right away if there is none. Only failing to record
+// the event is returned; failing to publish it is logged.
+func (o *Outbox) Publish(ctx context.Context, request model.PublishRequest) error {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+
+	_, err = o.db.Writer(ctx).ExecContext(ctx, insertOutboxQuery,
+		id.String(), request.Event.EventType, request.Topic, request.Event.Data.Value)
+	if err != nil {
+		return err
+	}
+
+	infras.AfterCommit(ctx, func() {
+		o.publish(ctx, id, request)
+	})
+	return nil
+}
+
+// Ping checks the producer the events are published through.
+func (o *Outbox) Ping(ctx context.Context) error {
+	if pinger, ok := o.producer.(interface{ Ping(context.Context) error }); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (o *Outbox) publish(ctx context.Context, id uuid.UUID, request model.PublishRequest) {
+	if err := o.producer.Publish(ctx, request); err != nil {
+		log.Warn().Err(err).Str("outboxID", id.String()).Str("topic", request.Topic).Msg("Event left in the outbox")
+		return
+	}
+
+	// the transaction is over, so the row is marked on the write connection
+	_, err := o.db.Write.ExecContext(ctx, markOutboxPublishedQuery, time.Now(), id.String())
+	if err != nil {
+		log.Warn().Err(err).Str("outboxID", id.String()).Msg("Failed marking outbox event published")
+	}
+}
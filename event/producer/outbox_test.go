@@ -0,0 +1,102 @@
+package producer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/event/producer"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	queryInsertOutbox  = `INSERT INTO event_outbox`
+	queryMarkPublished = `UPDATE event_outbox\s+SET published = \?`
+)
+
+// producerFunc is a Producer recording what it publishes.
+type producerFunc func(request model.PublishRequest) error
+
+func (f producerFunc) Publish(ctx context.Context, request model.PublishRequest) error {
+	return f(request)
+}
+
+func newOutbox(t *testing.T, publish producerFunc) (*producer.Outbox, *infras.MySQLConn, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	conn := infras.OpenMock(db)
+	return producer.NewOutbox(conn, publish), conn, mock
+}
+
+func TestOutbox(t *testing.T) {
+	request := model.PublishRequest{
+		Event: model.EventWrapper{EventType: "foo.created", Data: model.Data{Value: []byte(`{"name":"foo"}`)}},
+		Topic: "arn:aws:sns:foo",
+	}
+
+	t.Run("Publish After Commit", func(t *testing.T) {
+		var published []model.PublishRequest
+		outbox, conn, mock := newOutbox(t, func(request model.PublishRequest) error {
+			published = append(published, request)
+			return nil
+		})
+		mock.ExpectBegin()
+		mock.ExpectExec(queryInsertOutbox).
+			WithArgs(sqlmock.AnyArg(), "foo.created", "arn:aws:sns:foo", []byte(`{"name":"foo"}`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectExec(queryMarkPublished).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := conn.WithTx(context.Background(), func(ctx context.Context, tx *sqlx.Tx) error {
+			if err := outbox.Publish(ctx, request); err != nil {
+				return err
+			}
+			assert.Empty(t, published, "nothing is published before the commit")
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []model.PublishRequest{request}, published)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Nothing Published On Rollback", func(t *testing.T) {
+		outbox, conn, mock := newOutbox(t, func(request model.PublishRequest) error {
+			t.Error("published a rolled back event")
+			return nil
+		})
+		mock.ExpectBegin()
+		mock.ExpectExec(queryInsertOutbox).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectRollback()
+
+		err := conn.WithTx(context.Background(), func(ctx context.Context, tx *sqlx.Tx) error {
+			if err := outbox.Publish(ctx, request); err != nil {
+				return err
+			}
+			return errors.New("failed")
+		})
+
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Kept Unpublished On Failure", func(t *testing.T) {
+		outbox, _, mock := newOutbox(t, func(request model.PublishRequest) error {
+			return errors.New("sns unavailable")
+		})
+		mock.ExpectExec(queryInsertOutbox).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := outbox.Publish(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -1,8 +1,42 @@
 package producer
 
-import "github.com/evermos/boilerplate-go/event/model"
+import (
+	"context"
+
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
 
 // Producer represents an event producer interface.
 type Producer interface {
-	Publish(request model.PublishRequest) error
+	Publish(ctx context.Context, request model.PublishRequest) error
+}
+
+// startPublishSpan starts a producer span for publishing to the topic.
+func startPublishSpan(ctx context.Context, request model.PublishRequest) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, request.Topic+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("sns"),
+			semconv.MessagingDestinationKindTopic,
+			semconv.MessagingDestinationKey.String(request.Topic),
+		),
+	)
+}
+
+// messageAttributes returns the attributes published along with a message:
+// the event type, so consumers and dead-letter tooling can tell events apart,
+// the ID of the request publishing it, and the trace context.
+func messageAttributes(ctx context.Context, request model.PublishRequest) map[string]string {
+	attributes := tracing.InjectAttributes(ctx)
+	if request.Event.EventType != "" {
+		attributes[model.AttributeEventType] = request.Event.EventType
+	}
+	if requestID := logger.RequestID(ctx); requestID != "" {
+		attributes[model.AttributeRequestID] = requestID
+	}
+	return attributes
 }
@@ -1,12 +1,15 @@
 package producer
 
 import (
+	"context"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sns"
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/shared/tracing"
 	"github.com/rs/zerolog/log"
 )
 
@@ -53,18 +56,43 @@ func NewSNSProducer(config *configs.Config) *SNSProducer {
 }
 
 // Publish publishes a message to SNS.
-func (p *SNSProducer) Publish(request model.PublishRequest) error {
-	err := p.sendMessage(&sns.PublishInput{
-		Message:        aws.String(string(request.Event.Data.Value)),
-		MessageGroupId: request.MessageGroupID,
-		TopicArn:       &request.Topic,
+func (p *SNSProducer) Publish(ctx context.Context, request model.PublishRequest) (err error) {
+	ctx, span := startPublishSpan(ctx, request)
+	defer func() { tracing.End(span, err) }()
+
+	err = p.sendMessage(ctx, &sns.PublishInput{
+		Message:           aws.String(string(request.Event.Data.Value)),
+		MessageAttributes: createMessageAttributes(ctx, request),
+		MessageGroupId:    request.MessageGroupID,
+		TopicArn:          &request.Topic,
 	})
 
 	return err
 }
 
-func (p *SNSProducer) sendMessage(msg *sns.PublishInput) error {
-	resp, err := p.sns.Publish(msg)
+// Ping checks SNS is reachable with the configured credentials.
+func (p *SNSProducer) Ping(ctx context.Context) error {
+	_, err := p.sns.ListTopicsWithContext(ctx, &sns.ListTopicsInput{})
+	return err
+}
+
+func createMessageAttributes(ctx context.Context, request model.PublishRequest) map[string]*sns.MessageAttributeValue {
+	attributes := make(map[string]*sns.MessageAttributeValue)
+	for name, value := range messageAttributes(ctx, request) {
+		attributes[name] = &sns.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+
+	if len(attributes) == 0 {
+		return nil
+	}
+	return attributes
+}
+
+func (p *SNSProducer) sendMessage(ctx context.Context, msg *sns.PublishInput) error {
+	resp, err := p.sns.PublishWithContext(ctx, msg)
 	if err != nil {
 		log.Err(err).Interface("output", *msg).Msg("failed publishing message")
 		return err
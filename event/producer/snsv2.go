@@ -9,9 +9,11 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
 
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/shared/tracing"
 
 	"github.com/rs/zerolog/log"
 )
@@ -46,18 +48,32 @@ func NewSnsProducerV2(cfg *configs.Config, httpClient *http.Client) SNSProducerV
 	}
 }
 
-func (s *SNSProducerV2) Publish(request model.PublishRequest) error {
-	return s.publish(request)
+func (s *SNSProducerV2) Publish(ctx context.Context, request model.PublishRequest) (err error) {
+	ctx, span := startPublishSpan(ctx, request)
+	defer func() { tracing.End(span, err) }()
+
+	return s.publish(ctx, request)
 }
 
-func (s *SNSProducerV2) publish(request model.PublishRequest) error {
+func (s *SNSProducerV2) publish(ctx context.Context, request model.PublishRequest) error {
 	msg := &sns.PublishInput{
 		Message:        aws.String(string(request.Event.Data.Value)),
 		MessageGroupId: request.MessageGroupID,
 		TopicArn:       &request.Topic,
 	}
 
-	resp, err := s.client.Publish(context.TODO(), msg)
+	attributes := make(map[string]types.MessageAttributeValue)
+	for name, value := range messageAttributes(ctx, request) {
+		attributes[name] = types.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+	if len(attributes) > 0 {
+		msg.MessageAttributes = attributes
+	}
+
+	resp, err := s.client.Publish(ctx, msg)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,136 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrSkipped is returned by the replayer to a source for events that weren't
+// replayed, either because they didn't match the filter or because of a dry
+// run. Sources must leave skipped events where they are.
+var ErrSkipped = errors.New("replay: event skipped")
+
+// Event is a single event read from a source.
+type Event struct {
+	ID        string
+	EventType string
+	Topic     string
+	Timestamp time.Time
+	// Payload is the event as it was published, i.e. the SNS message body.
+	Payload []byte
+}
+
+// Source reads events to be replayed.
+type Source interface {
+	// Events calls handle for every event in the source, in order. A source
+	// may only remove an event once handle returned nil for it.
+	Events(ctx context.Context, handle func(Event) error) error
+}
+
+// Target receives the replayed events.
+type Target interface {
+	Replay(ctx context.Context, event Event) error
+}
+
+// Filter selects the events to replay. Zero values match everything.
+type Filter struct {
+	EventTypes []string
+	From       time.Time
+	To         time.Time
+}
+
+// Match checks whether an event is selected by the filter. Events without a
+// timestamp only match filters without a time range.
+func (f Filter) Match(event Event) bool {
+	if len(f.EventTypes) > 0 && !contains(f.EventTypes, event.EventType) {
+		return false
+	}
+
+	if !f.From.IsZero() && (event.Timestamp.IsZero() || event.Timestamp.Before(f.From)) {
+		return false
+	}
+
+	if !f.To.IsZero() && (event.Timestamp.IsZero() || !event.Timestamp.Before(f.To)) {
+		return false
+	}
+
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Options configures a replay run.
+type Options struct {
+	Filter Filter
+	// DryRun reports the matching events without replaying them.
+	DryRun bool
+	// ProgressEvery logs the progress after that many scanned events. Zero
+	// disables progress reporting.
+	ProgressEvery int
+}
+
+// Report summarizes a replay run.
+type Report struct {
+	Scanned  int
+	Matched  int
+	Replayed int
+	Failed   int
+}
+
+// Run replays the events of the source matching the filter to the target.
+// Failing events are logged and counted, and stay in the source so they can
+// be replayed again.
+func Run(ctx context.Context, source Source, target Target, opts Options) (report Report, err error) {
+	err = source.Events(ctx, func(event Event) error {
+		report.Scanned++
+		if opts.ProgressEvery > 0 && report.Scanned%opts.ProgressEvery == 0 {
+			report.log("Replay in progress")
+		}
+
+		if !opts.Filter.Match(event) {
+			return ErrSkipped
+		}
+		report.Matched++
+
+		if opts.DryRun {
+			log.Info().
+				Str("id", event.ID).
+				Str("eventType", event.EventType).
+				Str("topic", event.Topic).
+				Time("timestamp", event.Timestamp).
+				Msg("Would replay event")
+			return ErrSkipped
+		}
+
+		if err := target.Replay(ctx, event); err != nil {
+			report.Failed++
+			log.Error().Err(err).Str("id", event.ID).Str("eventType", event.EventType).Msg("Failed replaying event")
+			return err
+		}
+
+		report.Replayed++
+		return nil
+	})
+
+	report.log("Replay finished")
+	return
+}
+
+func (r Report) log(msg string) {
+	log.Info().
+		Int("scanned", r.Scanned).
+		Int("matched", r.Matched).
+		Int("replayed", r.Replayed).
+		Int("failed", r.Failed).
+		Msg(msg)
+}
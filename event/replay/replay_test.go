@@ -0,0 +1,207 @@
+package replay_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/evermos/boilerplate-go/event/consumer"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/event/replay"
+	"github.com/stretchr/testify/assert"
+)
+
+const dump = `{"id":"0a3a7e8e-8c1e-4a0e-9d6b-2b1a7f0f8f11","event_type":"foo.created","topic":"arn:foo","timestamp":"2021-06-01T10:00:00Z","payload":{"name":"first"}}
+
+{"id":"1b4b8f9f-9d2f-4b1f-8e7c-3c2b8a1a9a22","event_type":"bar.created","topic":"arn:bar","timestamp":"2021-06-02T10:00:00Z","payload":{"name":"second"}}
+{"id":"2c5c9a0a-ae3a-4c2a-9f8d-4d3c9b2bab33","event_type":"foo.created","topic":"arn:foo","timestamp":"2021-06-03T10:00:00Z","payload":"plain text"}
+`
+
+type recordingTarget struct {
+	events []replay.Event
+	err    error
+}
+
+func (t *recordingTarget) Replay(ctx context.Context, event replay.Event) error {
+	t.events = append(t.events, event)
+	return t.err
+}
+
+func TestRun(t *testing.T) {
+	t.Run("Replay Every Event", func(t *testing.T) {
+		target := &recordingTarget{}
+
+		report, err := replay.Run(context.Background(), replay.NewJSONLSource(strings.NewReader(dump)), target, replay.Options{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, replay.Report{Scanned: 3, Matched: 3, Replayed: 3}, report)
+		assert.Equal(t, `{"name":"first"}`, string(target.events[0].Payload))
+		assert.Equal(t, "plain text", string(target.events[2].Payload))
+	})
+
+	t.Run("Filter By Event Type And Time Range", func(t *testing.T) {
+		target := &recordingTarget{}
+		filter := replay.Filter{
+			EventTypes: []string{"foo.created"},
+			From:       time.Date(2021, 6, 2, 0, 0, 0, 0, time.UTC),
+			To:         time.Date(2021, 6, 4, 0, 0, 0, 0, time.UTC),
+		}
+
+		report, err := replay.Run(context.Background(), replay.NewJSONLSource(strings.NewReader(dump)), target, replay.Options{Filter: filter})
+
+		assert.NoError(t, err)
+		assert.Equal(t, replay.Report{Scanned: 3, Matched: 1, Replayed: 1}, report)
+		assert.Equal(t, "2c5c9a0a-ae3a-4c2a-9f8d-4d3c9b2bab33", target.events[0].ID)
+	})
+
+	t.Run("Dry Run", func(t *testing.T) {
+		target := &recordingTarget{}
+
+		report, err := replay.Run(context.Background(), replay.NewJSONLSource(strings.NewReader(dump)), target, replay.Options{DryRun: true})
+
+		assert.NoError(t, err)
+		assert.Equal(t, replay.Report{Scanned: 3, Matched: 3}, report)
+		assert.Empty(t, target.events)
+	})
+
+	t.Run("Count Failures", func(t *testing.T) {
+		target := &recordingTarget{err: errors.New("failed")}
+
+		report, err := replay.Run(context.Background(), replay.NewJSONLSource(strings.NewReader(dump)), target, replay.Options{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, replay.Report{Scanned: 3, Matched: 3, Failed: 3}, report)
+	})
+
+	t.Run("Invalid Line", func(t *testing.T) {
+		_, err := replay.Run(context.Background(), replay.NewJSONLSource(strings.NewReader("not json\n")), &recordingTarget{}, replay.Options{})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestConsumerTarget(t *testing.T) {
+	event := replay.Event{
+		ID:        "0a3a7e8e-8c1e-4a0e-9d6b-2b1a7f0f8f11",
+		EventType: "foo.created",
+		Topic:     "arn:foo",
+		Timestamp: time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC),
+		Payload:   []byte(`{"name":"first"}`),
+	}
+
+	t.Run("Wrap Event In SNS Message", func(t *testing.T) {
+		var actual model.SNSMessage
+		target := replay.NewConsumerTarget(map[string]consumer.Process{
+			"foo.created": func(ctx context.Context, value []byte) error {
+				return json.Unmarshal(value, &actual)
+			},
+		}, "")
+
+		assert.NoError(t, target.Replay(context.Background(), event))
+		assert.Equal(t, event.ID, actual.MessageID.String())
+		assert.Equal(t, "arn:foo", actual.TopicARN)
+		assert.Equal(t, `{"name":"first"}`, actual.Message)
+		assert.Equal(t, "2021-06-01T10:00:00Z", actual.Timestamp)
+		assert.Equal(t, "foo.created", actual.Attribute(model.AttributeEventType))
+	})
+
+	t.Run("Fallback Consumer", func(t *testing.T) {
+		called := false
+		target := replay.NewConsumerTarget(map[string]consumer.Process{
+			"foo.created": func(ctx context.Context, value []byte) error {
+				called = true
+				return nil
+			},
+		}, "foo.created")
+
+		assert.NoError(t, target.Replay(context.Background(), replay.Event{Payload: event.Payload}))
+		assert.True(t, called)
+	})
+
+	t.Run("Unknown Event Type", func(t *testing.T) {
+		target := replay.NewConsumerTarget(map[string]consumer.Process{}, "")
+
+		assert.Error(t, target.Replay(context.Background(), event))
+	})
+}
+
+// fakeSQS is a queue holding messages until they are deleted or released.
+type fakeSQS struct {
+	sqsiface.SQSAPI
+	messages []*sqs.Message
+	received bool
+	deleted  []string
+	released []string
+}
+
+func (f *fakeSQS) ReceiveMessageWithContext(ctx aws.Context, input *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	if f.received {
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+	f.received = true
+	return &sqs.ReceiveMessageOutput{Messages: f.messages}, nil
+}
+
+func (f *fakeSQS) DeleteMessageWithContext(ctx aws.Context, input *sqs.DeleteMessageInput, opts ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	f.deleted = append(f.deleted, aws.StringValue(input.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (f *fakeSQS) ChangeMessageVisibilityBatchWithContext(ctx aws.Context, input *sqs.ChangeMessageVisibilityBatchInput, opts ...request.Option) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	for _, entry := range input.Entries {
+		if aws.Int64Value(entry.VisibilityTimeout) == 0 {
+			f.released = append(f.released, aws.StringValue(entry.ReceiptHandle))
+		}
+	}
+	return &sqs.ChangeMessageVisibilityBatchOutput{}, nil
+}
+
+// failingTarget fails replaying a single event.
+type failingTarget struct {
+	id string
+}
+
+func (t failingTarget) Replay(ctx context.Context, event replay.Event) error {
+	if event.ID == t.id {
+		return errors.New("consumer failed")
+	}
+	return nil
+}
+
+func TestSQSSource(t *testing.T) {
+	newQueue := func() *fakeSQS {
+		return &fakeSQS{messages: []*sqs.Message{
+			{MessageId: aws.String("m1"), ReceiptHandle: aws.String("r1"), Body: aws.String(`{"name":"first"}`)},
+			{MessageId: aws.String("m2"), ReceiptHandle: aws.String("r2"), Body: aws.String(`{"name":"second"}`)},
+		}}
+	}
+
+	t.Run("Dry Run Leaves Queue As It Was", func(t *testing.T) {
+		queue := newQueue()
+
+		report, err := replay.Run(context.Background(), replay.NewSQSSource(queue, "dlq", 900), &recordingTarget{}, replay.Options{DryRun: true})
+
+		assert.NoError(t, err)
+		assert.Equal(t, replay.Report{Scanned: 2, Matched: 2}, report)
+		assert.Empty(t, queue.deleted)
+		assert.Equal(t, []string{"r1", "r2"}, queue.released)
+	})
+
+	t.Run("Delete Replayed And Release Failed Messages", func(t *testing.T) {
+		queue := newQueue()
+
+		report, err := replay.Run(context.Background(), replay.NewSQSSource(queue, "dlq", 900), failingTarget{id: "m2"}, replay.Options{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, replay.Report{Scanned: 2, Matched: 2, Replayed: 1, Failed: 1}, report)
+		assert.Equal(t, []string{"r1"}, queue.deleted)
+		assert.Equal(t, []string{"r2"}, queue.released)
+	})
+}
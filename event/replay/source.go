@@ -0,0 +1,267 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+// maxLineSize is the longest line accepted in a JSONL dump.
+const maxLineSize = 4 * 1024 * 1024
+
+// jsonlEvent is a line of a JSONL dump.
+type jsonlEvent struct {
+	ID        string          `json:"id"`
+	EventType string          `json:"event_type"`
+	Topic     string          `json:"topic"`
+	Timestamp time.Time       `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// JSONLSource reads events from a JSON Lines dump, one event per line.
+type JSONLSource struct {
+	reader io.Reader
+}
+
+// NewJSONLSource creates a JSONLSource reading from the reader.
+func NewJSONLSource(reader io.Reader) *JSONLSource {
+	return &JSONLSource{reader: reader}
+}
+
+// Events reads the dump line by line. Blank lines are ignored.
+func (s *JSONLSource) Events(ctx context.Context, handle func(Event) error) error {
+	scanner := bufio.NewScanner(s.reader)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var record jsonlEvent
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		// string payloads are message bodies that aren't JSON themselves
+		payload := []byte(record.Payload)
+		var body string
+		if json.Unmarshal(record.Payload, &body) == nil {
+			payload = []byte(body)
+		}
+
+		// a dump has nowhere to remember replayed events, so errors are only
+		// reported by the replayer
+		_ = handle(Event{
+			ID:        record.ID,
+			EventType: record.EventType,
+			Topic:     record.Topic,
+			Timestamp: record.Timestamp,
+			Payload:   payload,
+		})
+	}
+
+	return scanner.Err()
+}
+
+// outboxEvent is a row of the event_outbox table.
+type outboxEvent struct {
+	ID        string    `db:"entity_id"`
+	EventType string    `db:"event_type"`
+	Topic     string    `db:"topic"`
+	Payload   []byte    `db:"payload"`
+	Created   time.Time `db:"created"`
+}
+
+const selectOutboxQuery = `
+	SELECT
+		entity_id,
+		event_type,
+		topic,
+		payload,
+		created
+	FROM event_outbox
+	WHERE 1 = 1`
+
+// OutboxSource reads events from the event_outbox table.
+type OutboxSource struct {
+	db     *sqlx.DB
+	filter Filter
+}
+
+// NewOutboxSource creates an OutboxSource. The filter is applied in the query,
+// so only matching rows are read.
+func NewOutboxSource(db *sqlx.DB, filter Filter) *OutboxSource {
+	return &OutboxSource{db: db, filter: filter}
+}
+
+// Events reads the outbox in the order the events were created. Rows are kept
+// after being replayed.
+func (s *OutboxSource) Events(ctx context.Context, handle func(Event) error) error {
+	query := selectOutboxQuery
+	args := []interface{}{}
+
+	if len(s.filter.EventTypes) > 0 {
+		query += " AND event_type IN (?)"
+		args = append(args, s.filter.EventTypes)
+	}
+	if !s.filter.From.IsZero() {
+		query += " AND created >= ?"
+		args = append(args, s.filter.From)
+	}
+	if !s.filter.To.IsZero() {
+		query += " AND created < ?"
+		args = append(args, s.filter.To)
+	}
+	query += " ORDER BY created"
+
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.QueryxContext(ctx, s.db.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row outboxEvent
+		if err := rows.StructScan(&row); err != nil {
+			return err
+		}
+
+		_ = handle(Event{
+			ID:        row.ID,
+			EventType: row.EventType,
+			Topic:     row.Topic,
+			Timestamp: row.Created,
+			Payload:   row.Payload,
+		})
+	}
+
+	return rows.Err()
+}
+
+// SQSSource reads events from an SQS queue, usually the dead-letter queue of
+// a consumer. Replayed messages are deleted, while skipped and failed ones
+// are made visible in the queue again once the run ends, so a dry run leaves
+// the queue as it was.
+type SQSSource struct {
+	sqs               sqsiface.SQSAPI
+	url               string
+	visibilityTimeout int64
+}
+
+// NewSQSSource creates an SQSSource. The visibility timeout, in seconds, must
+// be long enough to go through the whole queue, otherwise messages that were
+// already skipped are read again.
+func NewSQSSource(client sqsiface.SQSAPI, url string, visibilityTimeout int64) *SQSSource {
+	return &SQSSource{sqs: client, url: url, visibilityTimeout: visibilityTimeout}
+}
+
+// Events receives messages until the queue is drained.
+func (s *SQSSource) Events(ctx context.Context, handle func(Event) error) error {
+	var kept []*sqs.Message
+	defer func() { s.release(kept) }()
+
+	for {
+		resp, err := s.sqs.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(s.url),
+			MaxNumberOfMessages: aws.Int64(10),
+			VisibilityTimeout:   aws.Int64(s.visibilityTimeout),
+			WaitTimeSeconds:     aws.Int64(1),
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.Messages) == 0 {
+			return nil
+		}
+
+		for _, msg := range resp.Messages {
+			if handle(messageEvent(msg)) != nil {
+				kept = append(kept, msg)
+				continue
+			}
+
+			_, err := s.sqs.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(s.url),
+				ReceiptHandle: msg.ReceiptHandle,
+			})
+			if err != nil {
+				log.Error().Err(err).Str("messageID", aws.StringValue(msg.MessageId)).Msg("Failed deleting replayed message")
+			}
+		}
+	}
+}
+
+// release makes the messages visible in the queue again, in batches of the
+// size SQS allows. It doesn't use the context of the run, so interrupted runs
+// release their messages too.
+func (s *SQSSource) release(messages []*sqs.Message) {
+	for start := 0; start < len(messages); start += 10 {
+		end := start + 10
+		if end > len(messages) {
+			end = len(messages)
+		}
+
+		entries := make([]*sqs.ChangeMessageVisibilityBatchRequestEntry, 0, end-start)
+		for i, msg := range messages[start:end] {
+			entries = append(entries, &sqs.ChangeMessageVisibilityBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				ReceiptHandle:     msg.ReceiptHandle,
+				VisibilityTimeout: aws.Int64(0),
+			})
+		}
+
+		resp, err := s.sqs.ChangeMessageVisibilityBatchWithContext(context.Background(), &sqs.ChangeMessageVisibilityBatchInput{
+			QueueUrl: aws.String(s.url),
+			Entries:  entries,
+		})
+		if err != nil {
+			log.Error().Err(err).Int("messages", len(entries)).Msg("Failed releasing messages, they stay hidden until the visibility timeout")
+			continue
+		}
+		for _, failed := range resp.Failed {
+			log.Error().Str("code", aws.StringValue(failed.Code)).Str("message", aws.StringValue(failed.Message)).Msg("Failed releasing message, it stays hidden until the visibility timeout")
+		}
+	}
+}
+
+// messageEvent converts an SQS message to an Event. Messages delivered by SNS
+// are unwrapped, anything else is replayed as is.
+func messageEvent(msg *sqs.Message) Event {
+	body := aws.StringValue(msg.Body)
+
+	var snsMessage model.SNSMessage
+	if err := json.Unmarshal([]byte(body), &snsMessage); err != nil || snsMessage.Type == "" {
+		return Event{ID: aws.StringValue(msg.MessageId), Payload: []byte(body)}
+	}
+
+	timestamp, _ := time.Parse(time.RFC3339, snsMessage.Timestamp)
+	return Event{
+		ID:        snsMessage.MessageID.String(),
+		EventType: snsMessage.Attribute(model.AttributeEventType),
+		Topic:     snsMessage.TopicARN,
+		Timestamp: timestamp,
+		Payload:   []byte(snsMessage.Message),
+	}
+}
@@ -0,0 +1,102 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/evermos/boilerplate-go/event/consumer"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/event/producer"
+	"github.com/gofrs/uuid"
+)
+
+// ConsumerTarget replays events directly into the process functions of the
+// domain consumers, as if they were received from SQS.
+type ConsumerTarget struct {
+	processes map[string]consumer.Process
+	fallback  string
+}
+
+// NewConsumerTarget creates a ConsumerTarget routing events by event type.
+// Events without a registered event type go to the fallback event type's
+// consumer, if there is one.
+func NewConsumerTarget(processes map[string]consumer.Process, fallback string) *ConsumerTarget {
+	return &ConsumerTarget{processes: processes, fallback: fallback}
+}
+
+// Replay wraps the event in an SNS envelope and processes it.
+func (t *ConsumerTarget) Replay(ctx context.Context, event Event) error {
+	process, ok := t.processes[event.EventType]
+	if !ok {
+		process, ok = t.processes[t.fallback]
+	}
+	if !ok {
+		return fmt.Errorf("no consumer registered for event type %q", event.EventType)
+	}
+
+	messageID, err := uuid.FromString(event.ID)
+	if err != nil {
+		messageID, _ = uuid.NewV4()
+	}
+
+	timestamp := event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	envelope := model.SNSMessage{
+		Type:      "Notification",
+		MessageID: messageID,
+		TopicARN:  event.Topic,
+		Message:   string(event.Payload),
+		Timestamp: timestamp.UTC().Format(time.RFC3339),
+	}
+	if event.EventType != "" {
+		envelope.MessageAttributes = map[string]model.SNSMessageAttribute{
+			model.AttributeEventType: {Type: "String", Value: event.EventType},
+		}
+	}
+
+	value, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	return process(ctx, value)
+}
+
+// ProducerTarget republishes events through a producer.
+type ProducerTarget struct {
+	producer producer.Producer
+	topic    string
+}
+
+// NewProducerTarget creates a ProducerTarget. Events are published to their
+// original topic unless the topic is set.
+func NewProducerTarget(producer producer.Producer, topic string) *ProducerTarget {
+	return &ProducerTarget{producer: producer, topic: topic}
+}
+
+// Replay publishes the event.
+func (t *ProducerTarget) Replay(ctx context.Context, event Event) error {
+	topic := t.topic
+	if topic == "" {
+		topic = event.Topic
+	}
+	if topic == "" {
+		return fmt.Errorf("event %s has no topic to publish to", event.ID)
+	}
+
+	return t.producer.Publish(ctx, model.PublishRequest{
+		Event: model.EventWrapper{
+			EventType: event.EventType,
+			Data: model.Data{
+				Timestamp: event.Timestamp,
+				Value:     event.Payload,
+			},
+		},
+		Topic: topic,
+	})
+}
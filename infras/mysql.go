@@ -1,17 +1,25 @@
 package infras
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/tracing"
 
 	"github.com/evermos/boilerplate-go/configs"
-	// use MySQL driver
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/rs/zerolog/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -19,21 +27,84 @@ const (
 	maxOpenConnection = 10
 )
 
-// Block contains a transaction block
-type Block func(db *sqlx.Tx, c chan error)
+const (
+	// MySQL error numbers of transactions that lost a race with another one.
+	errLockWaitTimeout = 1205
+	errLockDeadlock    = 1213
+
+	maxTxAttempts = 3
+	txRetryDelay  = 50 * time.Millisecond
+)
+
+// TxFunc is a unit of work run in a transaction. The context carries the
+// transaction and must be passed on to the repositories it calls.
+type TxFunc func(ctx context.Context, tx *sqlx.Tx) error
+
+// Transactor runs units of work in a transaction.
+type Transactor interface {
+	WithTx(ctx context.Context, fn TxFunc) error
+}
+
+type txKey struct{}
+
+// txState is the transaction carried in a context.
+type txState struct {
+	tx          *sqlx.Tx
+	depth       int
+	afterCommit []func()
+}
+
+// InTx checks whether the context carries a transaction.
+func InTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*txState)
+	return ok
+}
+
+// AfterCommit runs the function once the transaction carried by the context
+// is committed, or right away if there is none. It isn't run when the
+// transaction is rolled back.
+func AfterCommit(ctx context.Context, fn func()) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		state.afterCommit = append(state.afterCommit, fn)
+		return
+	}
+	fn()
+}
 
 // MySQLConn wraps a pair of read/write MySQL connections.
 type MySQLConn struct {
 	Read  *sqlx.DB
 	Write *sqlx.DB
+
+	router *readRouter
 }
 
 // ProvideMySQLConn is the provider for MySQLConn.
 func ProvideMySQLConn(config *configs.Config) *MySQLConn {
-	return &MySQLConn{
-		Read:  CreateMySQLReadConn(*config),
-		Write: CreateMySQLWriteConn(*config),
+	conn := &MySQLConn{
+		Read:   CreateMySQLReadConn(*config),
+		Write:  CreateMySQLWriteConn(*config),
+		router: newReadRouter(NewRouterConfig(*config)),
+	}
+	go conn.router.monitor(conn.Read)
+
+	if err := conn.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+		log.Warn().Err(err).Msg("Failed registering database metrics")
 	}
+
+	return conn
+}
+
+// RegisterMetrics exports the connection pool stats of the read and write
+// connections, labelled read and write.
+func (m *MySQLConn) RegisterMetrics(registerer prometheus.Registerer) error {
+	if err := registerer.Register(collectors.NewDBStatsCollector(m.Write.DB, "write")); err != nil {
+		return err
+	}
+	if m.Read == m.Write {
+		return nil
+	}
+	return registerer.Register(collectors.NewDBStatsCollector(m.Read.DB, "read"))
 }
 
 // CreateMySQLWriteConn creates a database connection for write access.
@@ -72,7 +143,7 @@ func CreateDBConnection(name, username, password, host, port, dbName, timeZone s
 		port,
 		dbName,
 		url.QueryEscape(timeZone))
-	db, err := sqlx.Connect("mysql", descriptor)
+	db, err := openDB(descriptor, dbName)
 	if err != nil {
 		log.
 			Fatal().
@@ -97,6 +168,25 @@ func CreateDBConnection(name, username, password, host, port, dbName, timeZone s
 	return db
 }
 
+// openDB opens a traced database connection and checks it is reachable.
+func openDB(descriptor, dbName string) (*sqlx.DB, error) {
+	mysqlConfig, err := mysql.ParseDSN(descriptor)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := mysql.NewConnector(mysqlConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	db := sqlx.NewDb(sql.OpenDB(traceConnector(connector, dbName)), "mysql")
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
 // OpenMock opens a database connection for mocking purposes.
 func OpenMock(db *sql.DB) *MySQLConn {
 	conn := sqlx.NewDb(db, "mysql")
@@ -106,21 +196,199 @@ func OpenMock(db *sql.DB) *MySQLConn {
 	}
 }
 
-// WithTransaction performs queries with transaction
-func (m *MySQLConn) WithTransaction(block Block) (err error) {
-	e := make(chan error)
-	tx, err := m.Write.Beginx()
+// OpenTracedMock opens a traced database connection for mocking purposes. The
+// mock must be opened with sqlmock.NewWithDSN and the same DSN.
+func OpenTracedMock(mock *sql.DB, dsn string) *MySQLConn {
+	conn := sqlx.NewDb(sql.OpenDB(traceConnector(dsnConnector{driver: mock.Driver(), dsn: dsn}, "")), "mysql")
+	return &MySQLConn{
+		Write: conn,
+		Read:  conn,
+	}
+}
+
+// OpenReplicatedMock opens a pair of primary/replica database connections
+// with read-your-writes routing for mocking purposes. The replica isn't
+// monitored in the background; call CheckReplica instead.
+func OpenReplicatedMock(primary, replica *sql.DB, config RouterConfig) *MySQLConn {
+	return &MySQLConn{
+		Write:  sqlx.NewDb(primary, "mysql"),
+		Read:   sqlx.NewDb(replica, "mysql"),
+		router: newReadRouter(config),
+	}
+}
+
+// CheckReplica checks the health and replication lag of the replica right
+// away, instead of waiting for the next periodic check.
+func (m *MySQLConn) CheckReplica() {
+	if m.router != nil {
+		m.router.checkReplica(m.Read)
+	}
+}
+
+// Close stops monitoring the replica and closes both connections.
+func (m *MySQLConn) Close() error {
+	if m.router != nil {
+		m.router.close()
+	}
+
+	errWrite := m.Write.Close()
+	if m.Read == m.Write {
+		return errWrite
+	}
+	if err := m.Read.Close(); err != nil {
+		return err
+	}
+	return errWrite
+}
+
+// Executor runs queries either directly on a connection or inside a
+// transaction. It is implemented by both *sqlx.DB and *sqlx.Tx.
+type Executor interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+	PrepareNamedContext(ctx context.Context, query string) (*sqlx.NamedStmt, error)
+	PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error)
+}
+
+// Reader returns the transaction carried by the context, or the read
+// connection if there is none, so reads inside a transaction see its writes.
+// Reads also go to the write connection when the replica is unhealthy, after
+// the session of the context wrote, or within the read-your-writes window
+// after the user of the context wrote.
+func (m *MySQLConn) Reader(ctx context.Context) Executor {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx
+	}
+	if m.router == nil {
+		return m.Read
+	}
+
+	primary := m.router.usePrimary(ctx)
+	countRead(primary)
+	if primary {
+		return m.Write
+	}
+	return m.Read
+}
+
+// Writer returns the transaction carried by the context, or the write
+// connection if there is none.
+func (m *MySQLConn) Writer(ctx context.Context) Executor {
+	if m.router != nil {
+		m.router.markWrite(ctx)
+	}
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx
+	}
+	return m.Write
+}
+
+// WithTx runs the function as a unit of work in a transaction, which is
+// committed when the function returns nil and rolled back when it returns an
+// error or panics. Panics are propagated after rolling back.
+//
+// The context passed to the function carries the transaction, so repositories
+// called with it join the transaction. Nested calls use a savepoint, so a
+// failing inner unit of work only undoes its own changes. Deadlocks and
+// serialization failures retry the whole outermost transaction.
+func (m *MySQLConn) WithTx(ctx context.Context, fn TxFunc) (err error) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.savepoint(ctx, fn)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = m.runTx(ctx, fn)
+		if err == nil || !isRetryableTxError(err) || attempt == maxTxAttempts {
+			return
+		}
+
+		log.Warn().Err(err).Int("attempt", attempt).Msg("Retrying transaction")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * txRetryDelay):
+		}
+	}
+}
+
+func (m *MySQLConn) runTx(ctx context.Context, fn TxFunc) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "TRANSACTION", trace.WithAttributes(semconv.DBSystemMySQL))
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := m.Write.BeginTxx(ctx, nil)
 	if err != nil {
 		return
 	}
-	go block(tx, e)
-	err = <-e
-	if err != nil {
-		if errTx := tx.Rollback(); errTx != nil {
-			err = failure.InternalError(errTx)
+
+	defer func() {
+		if p := recover(); p != nil {
+			rollback(tx.Rollback())
+			panic(p)
+		}
+	}()
+
+	state := &txState{tx: tx}
+	if err = fn(context.WithValue(ctx, txKey{}, state), tx); err != nil {
+		rollback(tx.Rollback())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	for _, fn := range state.afterCommit {
+		fn()
+	}
+	return nil
+}
+
+// savepoint runs a nested unit of work inside the current transaction.
+func (s *txState) savepoint(ctx context.Context, fn TxFunc) (err error) {
+	s.depth++
+	defer func() { s.depth-- }()
+	name := fmt.Sprintf("sp_%d", s.depth)
+	// callbacks registered by the unit of work are dropped with its changes
+	n := len(s.afterCommit)
+
+	if _, err = s.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_, errSp := s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			rollback(errSp)
+			s.afterCommit = s.afterCommit[:n]
+			panic(p)
 		}
+	}()
+
+	if err = fn(ctx, s.tx); err != nil {
+		_, errSp := s.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+		rollback(errSp)
+		s.afterCommit = s.afterCommit[:n]
 		return
 	}
-	err = tx.Commit()
+
+	_, err = s.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
 	return
 }
+
+func rollback(err error) {
+	if err != nil && err != sql.ErrTxDone {
+		logger.ErrorWithStack(failure.InternalError(err))
+	}
+}
+
+// isRetryableTxError checks whether the transaction failed because it lost a
+// race with another one, in which case running it again may succeed.
+func isRetryableTxError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+
+	return mysqlErr.Number == errLockDeadlock || mysqlErr.Number == errLockWaitTimeout
+}
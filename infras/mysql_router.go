@@ -0,0 +1,274 @@
+package infras
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+var errReplicationStopped = errors.New("replication is not running")
+
+var (
+	replicaLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "mysql_replica_lag_seconds",
+		Help: "Replication lag of the read replica, as last checked.",
+	})
+	replicaHealth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "mysql_replica_healthy",
+		Help: "Whether reads may go to the read replica, 1 or 0.",
+	})
+	routedReads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mysql_routed_reads_total",
+		Help: "Number of reads, by the connection they were routed to.",
+	}, []string{"connection"})
+)
+
+// RouterConfig configures how reads are routed between the primary and the
+// replica.
+type RouterConfig struct {
+	// Window is how long the reads of a user go to the primary after they
+	// wrote. Zero keeps only the reads of the same request on the primary.
+	Window time.Duration
+	// MaxLag is the replication lag after which the replica is considered
+	// unhealthy. Zero disables the lag check.
+	MaxLag time.Duration
+	// CheckInterval is how often the replica health and lag are checked.
+	CheckInterval time.Duration
+}
+
+// NewRouterConfig creates a RouterConfig from the service configuration.
+func NewRouterConfig(config configs.Config) RouterConfig {
+	rc := config.DB.MySQL.ReadYourWrites
+	routerConfig := RouterConfig{
+		Window:        time.Duration(rc.WindowSeconds) * time.Second,
+		MaxLag:        time.Duration(rc.MaxLagSeconds) * time.Second,
+		CheckInterval: time.Duration(rc.CheckIntervalSeconds) * time.Second,
+	}
+	if routerConfig.CheckInterval <= 0 {
+		routerConfig.CheckInterval = 5 * time.Second
+	}
+	return routerConfig
+}
+
+type sessionKey struct{}
+
+// session records whether a request wrote to the primary.
+type session struct {
+	wrote int32
+}
+
+// WithSession starts a read-your-writes session, usually one per request.
+// Once anything is written with the returned context, every later read made
+// with it goes to the primary.
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+type primaryKey struct{}
+
+// WithPrimary sends every read made with the returned context to the primary,
+// for reads that must not see a lagging replica, such as the ones filling a
+// cache.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// readRouter decides whether reads go to the primary or the replica.
+type readRouter struct {
+	config RouterConfig
+
+	mu         sync.Mutex
+	userWrites map[string]time.Time
+
+	// replicaHealthy is 1 while the replica is reachable and not lagging.
+	replicaHealthy int32
+	quit           chan struct{}
+	closeOnce      sync.Once
+}
+
+func newReadRouter(config RouterConfig) *readRouter {
+	replicaHealth.Set(1)
+	return &readRouter{
+		config:         config,
+		userWrites:     make(map[string]time.Time),
+		replicaHealthy: 1,
+		quit:           make(chan struct{}),
+	}
+}
+
+// usePrimary checks whether a read made with the context must go to the
+// primary, as asked with WithPrimary, to see earlier writes, or because the
+// replica is unhealthy.
+func (r *readRouter) usePrimary(ctx context.Context) bool {
+	if ctx.Value(primaryKey{}) != nil || atomic.LoadInt32(&r.replicaHealthy) == 0 {
+		return true
+	}
+
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok && atomic.LoadInt32(&s.wrote) == 1 {
+		return true
+	}
+
+	if r.config.Window <= 0 {
+		return false
+	}
+
+	user, ok := userFromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wrote, ok := r.userWrites[user]
+	return ok && time.Since(wrote) < r.config.Window
+}
+
+// markWrite records a write made with the context.
+func (r *readRouter) markWrite(ctx context.Context) {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		atomic.StoreInt32(&s.wrote, 1)
+	}
+
+	if r.config.Window <= 0 {
+		return
+	}
+
+	if user, ok := userFromContext(ctx); ok {
+		r.mu.Lock()
+		r.userWrites[user] = time.Now()
+		r.mu.Unlock()
+	}
+}
+
+// monitor periodically checks the replica and forgets writes that are older
+// than the window.
+func (r *readRouter) monitor(replica *sqlx.DB) {
+	ticker := time.NewTicker(r.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.quit:
+			return
+		case <-ticker.C:
+		}
+
+		r.checkReplica(replica)
+		r.forgetWrites()
+	}
+}
+
+func (r *readRouter) checkReplica(replica *sqlx.DB) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.config.CheckInterval)
+	defer cancel()
+
+	healthy := true
+	lag, err := replicationLag(ctx, replica)
+	if err != nil {
+		log.Warn().Err(err).Msg("Read replica is unhealthy, reading from the primary")
+		healthy = false
+	} else {
+		replicaLag.Set(lag.Seconds())
+		if r.config.MaxLag > 0 && lag > r.config.MaxLag {
+			log.Warn().Dur("lag", lag).Msg("Read replica is lagging, reading from the primary")
+			healthy = false
+		}
+	}
+
+	if healthy {
+		atomic.StoreInt32(&r.replicaHealthy, 1)
+		replicaHealth.Set(1)
+	} else {
+		atomic.StoreInt32(&r.replicaHealthy, 0)
+		replicaHealth.Set(0)
+	}
+}
+
+func (r *readRouter) forgetWrites() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for user, wrote := range r.userWrites {
+		if time.Since(wrote) >= r.config.Window {
+			delete(r.userWrites, user)
+		}
+	}
+}
+
+func (r *readRouter) close() {
+	r.closeOnce.Do(func() { close(r.quit) })
+}
+
+// replicationLag returns how far the replica is behind the primary. A server
+// that isn't replicating, such as a primary used as its own replica, has no
+// lag.
+func replicationLag(ctx context.Context, replica *sqlx.DB) (time.Duration, error) {
+	rows, err := replica.QueryxContext(ctx, "SHOW SLAVE STATUS")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+
+	status := make(map[string]interface{})
+	if err := rows.MapScan(status); err != nil {
+		return 0, err
+	}
+
+	value, ok := status["Seconds_Behind_Master"]
+	if !ok {
+		value = status["Seconds_Behind_Source"]
+	}
+	if value == nil {
+		return 0, errReplicationStopped
+	}
+
+	seconds, err := strconv.ParseFloat(string(toBytes(value)), 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func toBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case int64:
+		return []byte(strconv.FormatInt(v, 10))
+	default:
+		return nil
+	}
+}
+
+// userFromContext returns the authenticated user of a request.
+func userFromContext(ctx context.Context) (string, bool) {
+	id, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return id.String(), true
+}
+
+func countRead(primary bool) {
+	if primary {
+		routedReads.WithLabelValues("primary").Inc()
+	} else {
+		routedReads.WithLabelValues("replica").Inc()
+	}
+}
@@ -0,0 +1,442 @@
+package infras_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/go-sql-driver/mysql"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newMockConn(t *testing.T) (*infras.MySQLConn, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return infras.OpenMock(db), mock
+}
+
+func TestWithTx(t *testing.T) {
+	t.Run("Commit And Join Transaction", func(t *testing.T) {
+		conn, mock := newMockConn(t)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE product").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := conn.WithTx(context.Background(), func(ctx context.Context, tx *sqlx.Tx) error {
+			// a repository writing with the context joins the transaction
+			_, err := conn.Writer(ctx).ExecContext(ctx, "UPDATE product SET stock = 1")
+			return err
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Rollback On Error", func(t *testing.T) {
+		conn, mock := newMockConn(t)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		expected := errors.New("failed")
+
+		err := conn.WithTx(context.Background(), func(ctx context.Context, tx *sqlx.Tx) error {
+			return expected
+		})
+
+		assert.Equal(t, expected, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Rollback On Panic", func(t *testing.T) {
+		conn, mock := newMockConn(t)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		assert.PanicsWithValue(t, "boom", func() {
+			_ = conn.WithTx(context.Background(), func(ctx context.Context, tx *sqlx.Tx) error {
+				panic("boom")
+			})
+		})
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Nested Savepoint", func(t *testing.T) {
+		conn, mock := newMockConn(t)
+		mock.ExpectBegin()
+		mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("RELEASE SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		expected := errors.New("failed")
+
+		err := conn.WithTx(context.Background(), func(ctx context.Context, tx *sqlx.Tx) error {
+			// a failing inner unit of work only undoes its own changes
+			err := conn.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+				return expected
+			})
+			assert.Equal(t, expected, err)
+
+			return conn.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+				return nil
+			})
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Retry Deadlock", func(t *testing.T) {
+		conn, mock := newMockConn(t)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE product").WillReturnError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found"})
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE product").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		attempts := 0
+		err := conn.WithTx(context.Background(), func(ctx context.Context, tx *sqlx.Tx) error {
+			attempts++
+			_, err := conn.Writer(ctx).ExecContext(ctx, "UPDATE product SET stock = 1")
+			return err
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func newReplicatedMockConn(t *testing.T, config infras.RouterConfig) (*infras.MySQLConn, sqlmock.Sqlmock, sqlmock.Sqlmock) {
+	primary, primaryMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { primary.Close() })
+
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { replica.Close() })
+
+	return infras.OpenReplicatedMock(primary, replica, config), primaryMock, replicaMock
+}
+
+func TestReadYourWrites(t *testing.T) {
+	t.Run("Read From Replica", func(t *testing.T) {
+		conn, primary, replica := newReplicatedMockConn(t, infras.RouterConfig{})
+		replica.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(1))
+
+		var stock int
+		err := conn.Reader(infras.WithSession(context.Background())).GetContext(context.Background(), &stock, "SELECT stock FROM product")
+
+		assert.NoError(t, err)
+		assert.NoError(t, primary.ExpectationsWereMet())
+		assert.NoError(t, replica.ExpectationsWereMet())
+	})
+
+	t.Run("Read From Primary After Write In Session", func(t *testing.T) {
+		conn, primary, replica := newReplicatedMockConn(t, infras.RouterConfig{})
+		primary.ExpectExec("UPDATE product").WillReturnResult(sqlmock.NewResult(0, 1))
+		primary.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(1))
+		ctx := infras.WithSession(context.Background())
+
+		_, err := conn.Writer(ctx).ExecContext(ctx, "UPDATE product SET stock = 1")
+		assert.NoError(t, err)
+
+		var stock int
+		err = conn.Reader(ctx).GetContext(ctx, &stock, "SELECT stock FROM product")
+
+		assert.NoError(t, err)
+		assert.NoError(t, primary.ExpectationsWereMet())
+		assert.NoError(t, replica.ExpectationsWereMet())
+	})
+
+	t.Run("Read From Primary When Asked", func(t *testing.T) {
+		conn, primary, replica := newReplicatedMockConn(t, infras.RouterConfig{})
+		primary.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(1))
+		ctx := infras.WithPrimary(context.Background())
+
+		var stock int
+		err := conn.Reader(ctx).GetContext(ctx, &stock, "SELECT stock FROM product")
+
+		assert.NoError(t, err)
+		assert.NoError(t, primary.ExpectationsWereMet())
+		assert.NoError(t, replica.ExpectationsWereMet())
+	})
+
+	t.Run("Read From Primary Within Window After User Wrote", func(t *testing.T) {
+		conn, primary, replica := newReplicatedMockConn(t, infras.RouterConfig{Window: time.Minute})
+		primary.ExpectExec("UPDATE product").WillReturnResult(sqlmock.NewResult(0, 1))
+		primary.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(1))
+		replica.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(1))
+		user := auth.WithPrincipal(context.Background(), auth.Principal{UserID: uuid.Must(uuid.NewV4())})
+		other := auth.WithPrincipal(context.Background(), auth.Principal{UserID: uuid.Must(uuid.NewV4())})
+
+		_, err := conn.Writer(infras.WithSession(user)).ExecContext(user, "UPDATE product SET stock = 1")
+		assert.NoError(t, err)
+
+		var stock int
+		// a later request of the same user reads its write
+		assert.NoError(t, conn.Reader(infras.WithSession(user)).GetContext(user, &stock, "SELECT stock FROM product"))
+		// other users keep reading from the replica
+		assert.NoError(t, conn.Reader(infras.WithSession(other)).GetContext(other, &stock, "SELECT stock FROM product"))
+
+		assert.NoError(t, primary.ExpectationsWereMet())
+		assert.NoError(t, replica.ExpectationsWereMet())
+	})
+
+	t.Run("Read From Primary When Replica Is Lagging", func(t *testing.T) {
+		conn, primary, replica := newReplicatedMockConn(t, infras.RouterConfig{MaxLag: 10 * time.Second, CheckInterval: time.Second})
+		replica.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(sqlmock.NewRows([]string{"Seconds_Behind_Master"}).AddRow("30"))
+		primary.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(1))
+		replica.ExpectQuery("SHOW SLAVE STATUS").WillReturnRows(sqlmock.NewRows([]string{"Seconds_Behind_Master"}).AddRow("0"))
+		replica.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(1))
+		ctx := context.Background()
+
+		var stock int
+		conn.CheckReplica()
+		assert.NoError(t, conn.Reader(ctx).GetContext(ctx, &stock, "SELECT stock FROM product"))
+		assert.NoError(t, testutil.GatherAndCompare(prometheus.DefaultGatherer, strings.NewReader(`
+# HELP mysql_replica_healthy Whether reads may go to the read replica, 1 or 0.
+# TYPE mysql_replica_healthy gauge
+mysql_replica_healthy 0
+# HELP mysql_replica_lag_seconds Replication lag of the read replica, as last checked.
+# TYPE mysql_replica_lag_seconds gauge
+mysql_replica_lag_seconds 30
+`), "mysql_replica_healthy", "mysql_replica_lag_seconds"))
+
+		// reads go back to the replica once it caught up
+		conn.CheckReplica()
+		assert.NoError(t, conn.Reader(ctx).GetContext(ctx, &stock, "SELECT stock FROM product"))
+
+		assert.NoError(t, primary.ExpectationsWereMet())
+		assert.NoError(t, replica.ExpectationsWereMet())
+	})
+
+	t.Run("Read From Primary When Replica Is Down", func(t *testing.T) {
+		conn, primary, replica := newReplicatedMockConn(t, infras.RouterConfig{CheckInterval: time.Second})
+		replica.ExpectQuery("SHOW SLAVE STATUS").WillReturnError(errors.New("connection refused"))
+		primary.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(1))
+		ctx := context.Background()
+
+		var stock int
+		conn.CheckReplica()
+		assert.NoError(t, conn.Reader(ctx).GetContext(ctx, &stock, "SELECT stock FROM product"))
+
+		assert.NoError(t, primary.ExpectationsWereMet())
+		assert.NoError(t, replica.ExpectationsWereMet())
+	})
+}
+
+func TestAfterCommit(t *testing.T) {
+	t.Run("Run After Commit", func(t *testing.T) {
+		conn, mock := newMockConn(t)
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+		called := false
+
+		err := conn.WithTx(context.Background(), func(ctx context.Context, tx *sqlx.Tx) error {
+			assert.True(t, infras.InTx(ctx))
+			infras.AfterCommit(ctx, func() { called = true })
+			assert.False(t, called)
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, called)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Skip On Rollback", func(t *testing.T) {
+		conn, mock := newMockConn(t)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		called := false
+
+		_ = conn.WithTx(context.Background(), func(ctx context.Context, tx *sqlx.Tx) error {
+			infras.AfterCommit(ctx, func() { called = true })
+			return errors.New("failed")
+		})
+
+		assert.False(t, called)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Skip On Savepoint Rollback", func(t *testing.T) {
+		conn, mock := newMockConn(t)
+		mock.ExpectBegin()
+		mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		var called []string
+
+		err := conn.WithTx(context.Background(), func(ctx context.Context, tx *sqlx.Tx) error {
+			infras.AfterCommit(ctx, func() { called = append(called, "outer") })
+
+			_ = conn.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+				infras.AfterCommit(ctx, func() { called = append(called, "failed") })
+				return errors.New("failed")
+			})
+
+			assert.Panics(t, func() {
+				_ = conn.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+					infras.AfterCommit(ctx, func() { called = append(called, "panicked") })
+					panic("boom")
+				})
+			})
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"outer"}, called)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Run Right Away Without Transaction", func(t *testing.T) {
+		called := false
+
+		infras.AfterCommit(context.Background(), func() { called = true })
+
+		assert.False(t, infras.InTx(context.Background()))
+		assert.True(t, called)
+	})
+}
+
+func TestTracing(t *testing.T) {
+	newTracedMockConn := func(t *testing.T) (*infras.MySQLConn, sqlmock.Sqlmock) {
+		db, mock, err := sqlmock.NewWithDSN(t.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		return infras.OpenTracedMock(db, t.Name()), mock
+	}
+
+	newTraceExporter := func() *tracetest.InMemoryExporter {
+		exporter := tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+		return exporter
+	}
+
+	t.Run("Span Per Query", func(t *testing.T) {
+		exporter := newTraceExporter()
+		conn, mock := newTracedMockConn(t)
+		mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"stock"}).AddRow(1))
+		ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+
+		var stock int
+		err := conn.Reader(ctx).GetContext(ctx, &stock, "SELECT stock FROM product")
+		parent.End()
+
+		assert.NoError(t, err)
+		spans := exporter.GetSpans()
+		if assert.Len(t, spans, 2) {
+			assert.Equal(t, "SELECT", spans[0].Name)
+			assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+			assert.Contains(t, spans[0].Attributes, attribute.String("db.statement", "SELECT stock FROM product"))
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Queries In Transaction Span", func(t *testing.T) {
+		exporter := newTraceExporter()
+		conn, mock := newTracedMockConn(t)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE product").WillReturnError(errors.New("failed"))
+		mock.ExpectRollback()
+
+		err := conn.WithTx(context.Background(), func(ctx context.Context, tx *sqlx.Tx) error {
+			_, err := tx.ExecContext(ctx, "UPDATE product SET stock = 1")
+			return err
+		})
+
+		assert.Error(t, err)
+		spans := exporter.GetSpans()
+		if assert.Len(t, spans, 2) {
+			update, transaction := spans[0], spans[1]
+			assert.Equal(t, "UPDATE", update.Name)
+			assert.Equal(t, codes.Error, update.Status.Code)
+			assert.Equal(t, "TRANSACTION", transaction.Name)
+			assert.Equal(t, transaction.SpanContext.SpanID(), update.Parent.SpanID())
+			assert.Equal(t, codes.Error, transaction.Status.Code)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Discard Invalid Connections", func(t *testing.T) {
+		db := sql.OpenDB(invalidConnector{})
+		t.Cleanup(func() { db.Close() })
+		conn := infras.OpenTracedMock(db, t.Name())
+
+		c, err := conn.Write.Conn(context.Background())
+		assert.NoError(t, err)
+		assert.NoError(t, c.Close())
+
+		assert.Equal(t, 0, conn.Write.Stats().Idle)
+	})
+
+	t.Run("Skip Queries Outside Trace", func(t *testing.T) {
+		exporter := newTraceExporter()
+		conn, mock := newTracedMockConn(t)
+		mock.ExpectExec("UPDATE product").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		_, err := conn.Writer(context.Background()).ExecContext(context.Background(), "UPDATE product SET stock = 1")
+
+		assert.NoError(t, err)
+		assert.Empty(t, exporter.GetSpans())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// invalidConnector opens connections that report themselves as broken.
+type invalidConnector struct{}
+
+func (c invalidConnector) Connect(context.Context) (driver.Conn, error) { return invalidConn{}, nil }
+func (c invalidConnector) Driver() driver.Driver                        { return c }
+func (c invalidConnector) Open(string) (driver.Conn, error)             { return invalidConn{}, nil }
+
+type invalidConn struct{}
+
+func (invalidConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (invalidConn) Close() error                        { return nil }
+func (invalidConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+func (invalidConn) IsValid() bool                       { return false }
+
+func TestRegisterMetrics(t *testing.T) {
+	t.Run("Pool Stats Of Both Connections", func(t *testing.T) {
+		conn, _, _ := newReplicatedMockConn(t, infras.RouterConfig{})
+		registry := prometheus.NewRegistry()
+
+		err := conn.RegisterMetrics(registry)
+
+		assert.NoError(t, err)
+		count, err := testutil.GatherAndCount(registry, "go_sql_open_connections")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+}
@@ -0,0 +1,222 @@
+package infras
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+
+	"github.com/evermos/boilerplate-go/shared/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceConnector wraps a connector, so every statement run on its connections
+// starts a client span. Statements run outside of a trace, such as the
+// replica health checks, aren't traced.
+func traceConnector(connector driver.Connector, dbName string) driver.Connector {
+	return &tracedConnector{
+		Connector: connector,
+		attributes: []attribute.KeyValue{
+			semconv.DBSystemMySQL,
+			semconv.DBNameKey.String(dbName),
+		},
+	}
+}
+
+type tracedConnector struct {
+	driver.Connector
+	attributes []attribute.KeyValue
+}
+
+func (c *tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn, attributes: c.attributes}, nil
+}
+
+// dsnConnector opens connections of a driver with a fixed DSN.
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// tracedConn passes everything on to the wrapped connection, starting a span
+// for every statement. Optional interfaces the wrapped connection lacks are
+// skipped, so database/sql falls back as it would without the wrapper.
+type tracedConn struct {
+	driver.Conn
+	attributes []attribute.KeyValue
+}
+
+func (c *tracedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{Stmt: stmt, query: query, attributes: c.attributes}, nil
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (result driver.Result, err error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startQuerySpan(ctx, query, c.attributes)
+	defer func() { endQuerySpan(span, err) }()
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startQuerySpan(ctx, query, c.attributes)
+	defer func() { endQuerySpan(span, err) }()
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid lets database/sql discard connections the driver marked as bad
+// instead of returning them to the pool.
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+// tracedStmt starts a span for every execution of a prepared statement.
+type tracedStmt struct {
+	driver.Stmt
+	query      string
+	attributes []attribute.KeyValue
+}
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (result driver.Result, err error) {
+	ctx, span := startQuerySpan(ctx, s.query, s.attributes)
+	defer func() { endQuerySpan(span, err) }()
+
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values)
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
+	ctx, span := startQuerySpan(ctx, s.query, s.attributes)
+	defer func() { endQuerySpan(span, err) }()
+
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(values)
+}
+
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("mysql: driver does not support named arguments")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+// startQuerySpan starts a span named after the operation of the query, such
+// as SELECT, if the context is part of a trace.
+func startQuerySpan(ctx context.Context, query string, attributes []attribute.KeyValue) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	operation := queryOperation(query)
+	return tracing.Tracer().Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...),
+		trace.WithAttributes(
+			semconv.DBOperationKey.String(operation),
+			semconv.DBStatementKey.String(query),
+		),
+	)
+}
+
+func endQuerySpan(span trace.Span, err error) {
+	if !span.IsRecording() {
+		return
+	}
+	if err == driver.ErrSkip {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+func queryOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
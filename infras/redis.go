@@ -1,24 +1,30 @@
-package infras
-
-import (
-	"fmt"
-
-	"github.com/evermos/boilerplate-go/configs"
-	"github.com/go-redis/redis"
-)
-
-//RedisNewClient create new instance of redis
-func RedisNewClient(config configs.Config) *redis.Client {
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", config.Cache.Redis.Primary.Host, config.Cache.Redis.Primary.Port),
-		Password: config.Cache.Redis.Primary.Password,
-	})
-
-	pong, err := client.Ping().Result()
-	if err != nil {
-		panic(err)
-	}
-	fmt.Println(pong, err)
-
-	return client
-}
+package infras
+
+import (
+	"fmt"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/go-redis/redis"
+)
+
+// RedisNewClient creates a client of the primary Redis, and checks that it is
+// reachable.
+func RedisNewClient(config configs.Config) (*redis.Client, error) {
+	addr := fmt.Sprintf("%s:%s", config.Cache.Redis.Primary.Host, config.Cache.Redis.Primary.Port)
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: config.Cache.Redis.Primary.Password,
+	})
+
+	if err := client.Ping().Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("connecting to Redis at %s: %w", addr, err)
+	}
+
+	return client, nil
+}
+
+// ProvideRedis is the provider for the primary Redis client.
+func ProvideRedis(config *configs.Config) (*redis.Client, error) {
+	return RedisNewClient(*config)
+}
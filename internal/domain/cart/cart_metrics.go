@@ -0,0 +1,48 @@
+package cart
+
+import (
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Reasons of failing checkouts.
+const (
+	CheckoutFailureCartNotFound = "cart_not_found"
+	CheckoutFailureEmptyCart    = "empty_cart"
+	CheckoutFailureOutOfStock   = "out_of_stock"
+	CheckoutFailureInternal     = "internal"
+)
+
+var (
+	cartsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cart_carts_created_total",
+		Help: "Number of carts created.",
+	})
+	checkouts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cart_checkouts_total",
+		Help: "Number of successful checkouts.",
+	})
+	checkoutFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cart_checkout_failures_total",
+		Help: "Number of failed checkouts, by reason.",
+	}, []string{"reason"})
+	revenue = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "cart_revenue_total",
+		Help: "Total price of the orders checked out.",
+	})
+)
+
+// checkoutFailureReason returns why a checkout failed.
+func checkoutFailureReason(err error) string {
+	switch failure.GetErrorCode(err) {
+	case failure.CodeCartNotFound:
+		return CheckoutFailureCartNotFound
+	case failure.CodeCartEmpty:
+		return CheckoutFailureEmptyCart
+	case failure.CodeCartItemOutOfStock:
+		return CheckoutFailureOutOfStock
+	default:
+		return CheckoutFailureInternal
+	}
+}
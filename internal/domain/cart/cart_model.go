@@ -63,11 +63,11 @@ type (
 
 type (
 	AddToCartRequestFormat struct {
-		ProductID uuid.UUID `json:"productID"`
-		Quantity  float64   `json:"quantity"`
+		ProductID uuid.UUID `json:"productID" validate:"required"`
+		Quantity  float64   `json:"quantity" validate:"required,gt=0"`
 	}
 	CheckoutRequestFormat struct {
-		Items []uuid.UUID `json:"items"`
+		Items []uuid.UUID `json:"items" validate:"dive,required"`
 	}
 )
 
@@ -3,13 +3,13 @@ package cart
 //go:generate go run github.com/golang/mock/mockgen -source cart_repository.go -destination mock/cart_repository_mock.go -package cart_mock
 
 import (
+	"context"
 	"database/sql"
 	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/evermos/boilerplate-go/shared/logger"
 	"github.com/gofrs/uuid"
 	"github.com/jmoiron/sqlx"
-	"github.com/rs/zerolog/log"
 )
 
 var (
@@ -114,17 +114,17 @@ var (
 )
 
 type CartRepository interface {
-	CreateCart(cart Cart) (err error)
-	CreateCartItems(cartItems CartItems) (err error)
-	CreateOrder(order Order) (err error)
-	CreateOrderItem(orderItem OrderItem) (err error)
-	ClearCart(cartID uuid.UUID) (err error)
-	ExistsByID(id uuid.UUID) (exists bool, err error)
-	ResolveCartByID(userID uuid.UUID) (cart Cart, err error)
-	ResolveCartItemsByCartID(cartID uuid.UUID) (cartItem []CartItems, err error)
-	ResolveCartItemByProduct(cartID uuid.UUID, productID uuid.UUID) (cartItems []CartItems, err error)
-	UpdateCartItem(cartItems CartItems) (err error)
-	RemoveItemFromCart(cartItems CartItems) (err error)
+	CreateCart(ctx context.Context, cart Cart) (err error)
+	CreateCartItems(ctx context.Context, cartItems CartItems) (err error)
+	CreateOrder(ctx context.Context, order Order) (err error)
+	CreateOrderItem(ctx context.Context, orderItem OrderItem) (err error)
+	ClearCart(ctx context.Context, cartID uuid.UUID) (err error)
+	ExistsByID(ctx context.Context, id uuid.UUID) (exists bool, err error)
+	ResolveCartByID(ctx context.Context, userID uuid.UUID) (cart Cart, err error)
+	ResolveCartItemsByCartID(ctx context.Context, cartID uuid.UUID) (cartItem []CartItems, err error)
+	ResolveCartItemByProduct(ctx context.Context, cartID uuid.UUID, productID uuid.UUID) (cartItems []CartItems, err error)
+	UpdateCartItem(ctx context.Context, cartItems CartItems) (err error)
+	RemoveItemFromCart(ctx context.Context, cartItems CartItems) (err error)
 }
 type CartRepositoryMySQL struct {
 	DB *infras.MySQLConn
@@ -134,212 +134,175 @@ func ProvideCartRepositoryMySQL(db *infras.MySQLConn) *CartRepositoryMySQL {
 	return &CartRepositoryMySQL{DB: db}
 }
 
-func (c *CartRepositoryMySQL) CreateCart(cart Cart) (err error) {
-	exists, err := c.ExistsByID(cart.CartID)
+func (c *CartRepositoryMySQL) CreateCart(ctx context.Context, cart Cart) (err error) {
+	exists, err := c.ExistsByID(ctx, cart.CartID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
 	if exists {
-		err = failure.Conflict("create", "carts", "already exists")
-		logger.ErrorWithStack(err)
+		err = failure.New(failure.CodeCartAlreadyExists, "cart already exists")
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
-	return c.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
-		if err := c.txCreateCart(tx, cart); err != nil {
-			e <- err
-			return
-		}
-
-		e <- nil
+	return c.DB.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		return c.txCreateCart(ctx, tx, cart)
 	})
 }
-func (c *CartRepositoryMySQL) CreateCartItems(cartItems CartItems) (err error) {
-	return c.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
-		if err := c.txCreateCartItems(tx, cartItems); err != nil {
-			e <- err
-			return
-		}
-		e <- nil
+func (c *CartRepositoryMySQL) CreateCartItems(ctx context.Context, cartItems CartItems) (err error) {
+	return c.DB.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		return c.txCreateCartItems(ctx, tx, cartItems)
 	})
 }
-func (c *CartRepositoryMySQL) CreateOrder(order Order) (err error) {
-	return c.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
-		if err := c.txCreateOrder(tx, order); err != nil {
-			e <- err
-			return
-		}
-
-		e <- nil
+func (c *CartRepositoryMySQL) CreateOrder(ctx context.Context, order Order) (err error) {
+	return c.DB.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		return c.txCreateOrder(ctx, tx, order)
 	})
 }
-func (c *CartRepositoryMySQL) CreateOrderItem(orderItem OrderItem) (err error) {
-	return c.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
-		if err := c.txCreateOrderItems(tx, orderItem); err != nil {
-			e <- err
-			return
-		}
-		e <- nil
+func (c *CartRepositoryMySQL) CreateOrderItem(ctx context.Context, orderItem OrderItem) (err error) {
+	return c.DB.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		return c.txCreateOrderItems(ctx, tx, orderItem)
 	})
 }
-func (c *CartRepositoryMySQL) ClearCart(cartID uuid.UUID) (err error) {
-	return c.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
-		if err := c.txDeleteCart(tx, cartID); err != nil {
-			e <- err
-			return
-		}
-		e <- nil
+func (c *CartRepositoryMySQL) ClearCart(ctx context.Context, cartID uuid.UUID) (err error) {
+	return c.DB.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		return c.txDeleteCart(ctx, tx, cartID)
 	})
 }
-func (c *CartRepositoryMySQL) ExistsByID(id uuid.UUID) (exists bool, err error) {
-	err = c.DB.Read.Get(
+func (c *CartRepositoryMySQL) ExistsByID(ctx context.Context, id uuid.UUID) (exists bool, err error) {
+	err = c.DB.Reader(ctx).GetContext(
+		ctx,
 		&exists,
 		"SELECT COUNT(id) FROM carts WHERE carts.cart_id = ?",
 		id.String())
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 	return
 }
-func (c *CartRepositoryMySQL) ResolveCartByID(userID uuid.UUID) (cart Cart, err error) {
-	err = c.DB.Read.Get(&cart, cartQueries.selectCarts+" WHERE c.user_id = ?", userID)
+func (c *CartRepositoryMySQL) ResolveCartByID(ctx context.Context, userID uuid.UUID) (cart Cart, err error) {
+	err = c.DB.Reader(ctx).GetContext(ctx, &cart, cartQueries.selectCarts+" WHERE c.user_id = ?", userID)
 	if err != nil && err == sql.ErrNoRows {
-		// err = failure.NotFound("cart")
-		log.Info().Msg("error solvecart")
-		logger.ErrorWithStack(err)
+		err = failure.New(failure.CodeCartNotFound, "cart")
 		return
 	}
 	return
 }
-func (c *CartRepositoryMySQL) ResolveCartItemsByCartID(cartID uuid.UUID) (cartItems []CartItems, err error) {
+func (c *CartRepositoryMySQL) ResolveCartItemsByCartID(ctx context.Context, cartID uuid.UUID) (cartItems []CartItems, err error) {
 	query, args, err := sqlx.In(cartQueries.selectCartItems+" WHERE ci.cart_id = ?", cartID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
-	err = c.DB.Read.Select(&cartItems, query, args...)
+	err = c.DB.Reader(ctx).SelectContext(ctx, &cartItems, query, args...)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
 	return
 }
-func (c *CartRepositoryMySQL) ResolveCartItemByProduct(cartID uuid.UUID, productID uuid.UUID) (cartItems []CartItems, err error) {
+func (c *CartRepositoryMySQL) ResolveCartItemByProduct(ctx context.Context, cartID uuid.UUID, productID uuid.UUID) (cartItems []CartItems, err error) {
 	query, args, err := sqlx.In(cartQueries.selectCartItems+" WHERE ci.cart_id = ? AND ci.product_id = ? ", cartID, productID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
-	err = c.DB.Read.Select(&cartItems, query, args...)
+	err = c.DB.Reader(ctx).SelectContext(ctx, &cartItems, query, args...)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
 	return
 }
-func (c *CartRepositoryMySQL) UpdateCartItem(cart CartItems) (err error) {
-	return c.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
-		if err := c.txUpdateCartItems(tx, cart); err != nil {
-			e <- err
-			return
-		}
-		e <- nil
+func (c *CartRepositoryMySQL) UpdateCartItem(ctx context.Context, cart CartItems) (err error) {
+	return c.DB.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		return c.txUpdateCartItems(ctx, tx, cart)
 	})
 }
-func (c *CartRepositoryMySQL) RemoveItemFromCart(cart CartItems) (err error) {
-	return c.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
-		if err := c.txDeleteCartItems(tx, cart); err != nil {
-			e <- err
-			return
-		}
-		e <- err
+func (c *CartRepositoryMySQL) RemoveItemFromCart(ctx context.Context, cart CartItems) (err error) {
+	return c.DB.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		return c.txDeleteCartItems(ctx, tx, cart)
 	})
 }
 
-func (c *CartRepositoryMySQL) txCreateCart(tx *sqlx.Tx, cart Cart) (err error) {
-	stmt, err := tx.PrepareNamed(cartQueries.insertCart)
+func (c *CartRepositoryMySQL) txCreateCart(ctx context.Context, tx *sqlx.Tx, cart Cart) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, cartQueries.insertCart)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(cart)
+	_, err = stmt.ExecContext(ctx, cart)
 	if err != nil {
-		tx.Rollback()
-		logger.ErrorWithStack(err)
-	}
-	err = tx.Commit()
-	if err != nil {
-		tx.Rollback()
-		return err
+		logger.ErrorWithStackContext(ctx, err)
 	}
 	return
 }
-func (c *CartRepositoryMySQL) txCreateCartItems(tx *sqlx.Tx, cartItems CartItems) (err error) {
-	stmt, err := tx.PrepareNamed(cartQueries.insertCartItems)
+func (c *CartRepositoryMySQL) txCreateCartItems(ctx context.Context, tx *sqlx.Tx, cartItems CartItems) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, cartQueries.insertCartItems)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(cartItems)
+	_, err = stmt.ExecContext(ctx, cartItems)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 	return
 }
-func (c *CartRepositoryMySQL) txUpdateCartItems(tx *sqlx.Tx, cartItems CartItems) (err error) {
-	stmt, err := tx.PrepareNamed(cartQueries.updateCartItems)
+func (c *CartRepositoryMySQL) txUpdateCartItems(ctx context.Context, tx *sqlx.Tx, cartItems CartItems) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, cartQueries.updateCartItems)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(cartItems)
+	_, err = stmt.ExecContext(ctx, cartItems)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return err
 	}
 
 	return
 }
-func (c *CartRepositoryMySQL) txCreateOrder(tx *sqlx.Tx, order Order) (err error) {
-	stmt, err := tx.PrepareNamed(cartQueries.insertOrder)
+func (c *CartRepositoryMySQL) txCreateOrder(ctx context.Context, tx *sqlx.Tx, order Order) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, cartQueries.insertOrder)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(order)
+	_, err = stmt.ExecContext(ctx, order)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 	return
 }
-func (c *CartRepositoryMySQL) txCreateOrderItems(tx *sqlx.Tx, orderItems OrderItem) (err error) {
-	stmt, err := tx.PrepareNamed(cartQueries.insertOrderItems)
+func (c *CartRepositoryMySQL) txCreateOrderItems(ctx context.Context, tx *sqlx.Tx, orderItems OrderItem) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, cartQueries.insertOrderItems)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(orderItems)
+	_, err = stmt.ExecContext(ctx, orderItems)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 	return
 }
-func (c *CartRepositoryMySQL) txDeleteCart(tx *sqlx.Tx, cartID uuid.UUID) (err error) {
-	_, err = tx.Exec("DELETE FROM cart_items WHERE cart_id = ?", cartID.String())
+func (c *CartRepositoryMySQL) txDeleteCart(ctx context.Context, tx *sqlx.Tx, cartID uuid.UUID) (err error) {
+	_, err = tx.ExecContext(ctx, "DELETE FROM cart_items WHERE cart_id = ?", cartID.String())
 	return
 }
-func (c *CartRepositoryMySQL) txDeleteCartItems(tx *sqlx.Tx, cartItems CartItems) (err error) {
-	_, err = tx.Exec("DELETE FROM cart_items WHERE cart_id = ? AND product_id = ?", cartItems.CartID, cartItems.ProductID)
+func (c *CartRepositoryMySQL) txDeleteCartItems(ctx context.Context, tx *sqlx.Tx, cartItems CartItems) (err error) {
+	_, err = tx.ExecContext(ctx, "DELETE FROM cart_items WHERE cart_id = ? AND product_id = ?", cartItems.CartID, cartItems.ProductID)
 	if err != nil {
 		return err
 	}
@@ -3,67 +3,80 @@ package cart
 //go:generate go run github.com/golang/mock/mockgen -source cart_service.go -destination mock/cart_service_mock.go -package cart_mock
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/internal/domain/product"
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/evermos/boilerplate-go/shared/logger"
 	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
 	"github.com/rs/zerolog/log"
 	"time"
 )
 
 type CartService interface {
-	CheckoutCarts(requestFormat CheckoutRequestFormat, userID uuid.UUID) (orders OrderResponse, err error)
-	AddItemToCart(requestFormat AddToCartRequestFormat, userID uuid.UUID) (cart Cart, err error)
-	ResolveCartByID(cartID uuid.UUID, userID uuid.UUID) (cart Cart, err error)
+	CheckoutCarts(ctx context.Context, requestFormat CheckoutRequestFormat, userID uuid.UUID) (orders OrderResponse, err error)
+	AddItemToCart(ctx context.Context, requestFormat AddToCartRequestFormat, userID uuid.UUID) (cart Cart, err error)
+	ResolveCartByID(ctx context.Context, cartID uuid.UUID, userID uuid.UUID) (cart Cart, err error)
 }
 
 type CartServiceImpl struct {
 	CartRepository    CartRepository
 	ProductRepository product.ProductRepository
+	Transactor        infras.Transactor
 	Config            *configs.Config
 }
 
-func ProvideCarServiceImpl(cartRepository CartRepository, productRepository product.ProductRepository, config *configs.Config) *CartServiceImpl {
-	return &CartServiceImpl{CartRepository: cartRepository, ProductRepository: productRepository, Config: config}
+func ProvideCarServiceImpl(cartRepository CartRepository, productRepository product.ProductRepository, transactor infras.Transactor, config *configs.Config) *CartServiceImpl {
+	return &CartServiceImpl{CartRepository: cartRepository, ProductRepository: productRepository, Transactor: transactor, Config: config}
 }
 
-func (c *CartServiceImpl) AddItemToCart(req AddToCartRequestFormat, userID uuid.UUID) (cart Cart, err error) {
-	product, err := c.ProductRepository.ResolveByID(req.ProductID)
+// AddItemToCart adds an item to the user's cart, creating the cart if needed,
+// in a single transaction.
+func (c *CartServiceImpl) AddItemToCart(ctx context.Context, req AddToCartRequestFormat, userID uuid.UUID) (cart Cart, err error) {
+	err = c.Transactor.WithTx(ctx, func(ctx context.Context, _ *sqlx.Tx) (err error) {
+		cart, err = c.addItemToCart(ctx, req, userID)
+		return
+	})
+	return
+}
+
+func (c *CartServiceImpl) addItemToCart(ctx context.Context, req AddToCartRequestFormat, userID uuid.UUID) (cart Cart, err error) {
+	product, err := c.ProductRepository.ResolveByID(ctx, req.ProductID)
 	if err != nil {
 		return
 	}
 
 	if product.Stock < req.Quantity {
 		log.Info().Msg("Insufficient stock")
-		return cart, failure.InternalError(err)
+		return cart, failure.New(failure.CodeCartItemOutOfStock, fmt.Sprintf("product '%s' is out of stock", product.Name))
 	}
 
-	cart, err = c.getOrCreateCart(userID)
+	cart, err = c.getOrCreateCart(ctx, userID)
 	if err != nil {
 		return
 	}
 
-	existingItem, err := c.CartRepository.ResolveCartItemByProduct(cart.CartID, req.ProductID)
+	existingItem, err := c.CartRepository.ResolveCartItemByProduct(ctx, cart.CartID, req.ProductID)
 	if err != nil {
 		return
 	}
 
 	if existingItem == nil {
-		err = c.createCartItem(cart.CartID, userID, req.ProductID, req.Quantity)
+		err = c.createCartItem(ctx, cart.CartID, userID, req.ProductID, req.Quantity)
 	} else {
 		existingItem[0].Quantity += req.Quantity
 		existingItem[0].CreatedAt = time.Now()
-		err = c.CartRepository.UpdateCartItem(existingItem[0])
+		err = c.CartRepository.UpdateCartItem(ctx, existingItem[0])
 	}
 
 	if err != nil {
 		return
 	}
 
-	items, err := c.CartRepository.ResolveCartItemsByCartID(cart.CartID)
+	items, err := c.CartRepository.ResolveCartItemsByCartID(ctx, cart.CartID)
 	if err != nil {
 		return
 	}
@@ -71,15 +84,15 @@ func (c *CartServiceImpl) AddItemToCart(req AddToCartRequestFormat, userID uuid.
 	return
 }
 
-func (c *CartServiceImpl) ResolveCartByID(cartID uuid.UUID, userID uuid.UUID) (cart Cart, err error) {
-	cart, err = c.CartRepository.ResolveCartByID(userID)
+func (c *CartServiceImpl) ResolveCartByID(ctx context.Context, cartID uuid.UUID, userID uuid.UUID) (cart Cart, err error) {
+	cart, err = c.CartRepository.ResolveCartByID(ctx, userID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return cart, err
 	}
-	cartItems, err := c.CartRepository.ResolveCartItemsByCartID(cartID)
+	cartItems, err := c.CartRepository.ResolveCartItemsByCartID(ctx, cartID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return cart, err
 	}
 
@@ -87,62 +100,75 @@ func (c *CartServiceImpl) ResolveCartByID(cartID uuid.UUID, userID uuid.UUID) (c
 	return
 }
 
-func (c *CartServiceImpl) CheckoutCarts(_ CheckoutRequestFormat, userID uuid.UUID) (OrderResponse, error) {
-	cart, err := c.CartRepository.ResolveCartByID(userID)
+// CheckoutCarts turns the user's cart into an order in a single transaction,
+// so a failing item leaves neither a partial order nor changed stock behind.
+func (c *CartServiceImpl) CheckoutCarts(ctx context.Context, requestFormat CheckoutRequestFormat, userID uuid.UUID) (order OrderResponse, err error) {
+	err = c.Transactor.WithTx(ctx, func(ctx context.Context, _ *sqlx.Tx) (err error) {
+		order, err = c.checkoutCarts(ctx, requestFormat, userID)
+		return
+	})
 	if err != nil {
-		logger.ErrorWithStack(err)
-		return OrderResponse{}, err
+		checkoutFailures.WithLabelValues(checkoutFailureReason(err)).Inc()
+		return
 	}
 
-	if cart.CartID == uuid.Nil {
-		return OrderResponse{}, fmt.Errorf("CartID not found for user %s", userID)
+	checkouts.Inc()
+	revenue.Add(order.TotalPrice)
+	return
+}
+
+func (c *CartServiceImpl) checkoutCarts(ctx context.Context, _ CheckoutRequestFormat, userID uuid.UUID) (OrderResponse, error) {
+	cart, err := c.CartRepository.ResolveCartByID(ctx, userID)
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+		return OrderResponse{}, err
 	}
 
-	cartItems, err := c.CartRepository.ResolveCartItemsByCartID(cart.CartID)
+	cartItems, err := c.CartRepository.ResolveCartItemsByCartID(ctx, cart.CartID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return OrderResponse{}, err
 	}
 
 	if len(cartItems) == 0 {
-		return OrderResponse{}, fmt.Errorf("No items in the cartItems list")
+		return OrderResponse{}, failure.New(failure.CodeCartEmpty, "cart has no items")
 	}
 
-	totalAmount, items, err := c.calculateTotalAndItems(cartItems)
+	totalAmount, items, err := c.calculateTotalAndItems(ctx, cartItems)
 	if err != nil {
 		return OrderResponse{}, err
 	}
 
-	order, err := c.createOrder(userID, totalAmount)
+	order, err := c.createOrder(ctx, userID, totalAmount)
 	if err != nil {
 		return OrderResponse{}, err
 	}
 
 	for _, cartItem := range cartItems {
-		product, err := c.ProductRepository.ResolveByID(cartItem.ProductID)
+		product, err := c.ProductRepository.ResolveByID(ctx, cartItem.ProductID)
 		if err != nil {
 			return OrderResponse{}, err
 		}
 
 		if cartItem.Quantity > product.Stock {
-			return OrderResponse{}, fmt.Errorf("product '%s' is out of stock", product.Name)
+			return OrderResponse{}, failure.New(failure.CodeCartItemOutOfStock, fmt.Sprintf("product '%s' is out of stock", product.Name))
 		}
 
 		totalAmount += float64(cartItem.Quantity) * product.Price
 
-		if err := c.processOrderItemsAndStock(order, []CartItems{cartItem}); err != nil {
+		if err := c.processOrderItemsAndStock(ctx, order, []CartItems{cartItem}); err != nil {
 			return OrderResponse{}, err
 		}
 	}
 
-	if err := c.CartRepository.ClearCart(cart.CartID); err != nil {
+	if err := c.CartRepository.ClearCart(ctx, cart.CartID); err != nil {
 		return OrderResponse{}, err
 	}
 
 	return order.BuildOrderResponse(order, items), nil
 }
 
-func (c *CartServiceImpl) createOrder(userID uuid.UUID, totalAmount float64) (Order, error) {
+func (c *CartServiceImpl) createOrder(ctx context.Context, userID uuid.UUID, totalAmount float64) (Order, error) {
 	orderID, err := uuid.NewV4()
 	if err != nil {
 		return Order{}, err
@@ -154,15 +180,15 @@ func (c *CartServiceImpl) createOrder(userID uuid.UUID, totalAmount float64) (Or
 		CreatedAt:   time.Now(),
 		CreatedBy:   userID,
 	}
-	if err := c.CartRepository.CreateOrder(order); err != nil {
+	if err := c.CartRepository.CreateOrder(ctx, order); err != nil {
 		return Order{}, err
 	}
 	return order, nil
 }
-func (c *CartServiceImpl) calculateTotalAmount(cartItems []CartItems) (float64, error) {
+func (c *CartServiceImpl) calculateTotalAmount(ctx context.Context, cartItems []CartItems) (float64, error) {
 	var totalAmount float64
 	for _, cartItem := range cartItems {
-		product, err := c.ProductRepository.ResolveByID(cartItem.ProductID)
+		product, err := c.ProductRepository.ResolveByID(ctx, cartItem.ProductID)
 		if err != nil {
 			return 0, err
 		}
@@ -170,32 +196,32 @@ func (c *CartServiceImpl) calculateTotalAmount(cartItems []CartItems) (float64,
 	}
 	return totalAmount, nil
 }
-func (c *CartServiceImpl) checkProductStock(cartItems []CartItems) error {
+func (c *CartServiceImpl) checkProductStock(ctx context.Context, cartItems []CartItems) error {
 	for _, cartItem := range cartItems {
-		product, err := c.ProductRepository.ResolveByID(cartItem.ProductID)
+		product, err := c.ProductRepository.ResolveByID(ctx, cartItem.ProductID)
 		if err != nil {
 			return err
 		}
 		if product.Stock < cartItem.Quantity {
-			return fmt.Errorf("insufficient stock for product: %s", product.Name)
+			return failure.New(failure.CodeCartItemOutOfStock, fmt.Sprintf("product '%s' is out of stock", product.Name))
 		}
 	}
 	return nil
 }
-func (c *CartServiceImpl) processOrderItemsAndStock(order Order, cartItems []CartItems) error {
+func (c *CartServiceImpl) processOrderItemsAndStock(ctx context.Context, order Order, cartItems []CartItems) error {
 	for _, cartItem := range cartItems {
-		product, err := c.ProductRepository.ResolveByID(cartItem.ProductID)
+		product, err := c.ProductRepository.ResolveByID(ctx, cartItem.ProductID)
 		if err != nil {
-			logger.ErrorWithStack(err)
+			logger.ErrorWithStackContext(ctx, err)
 			return err
 		}
 		if product.Stock < cartItem.Quantity {
-			return fmt.Errorf("insufficient stock for product: %s", product.Name)
+			return failure.New(failure.CodeCartItemOutOfStock, fmt.Sprintf("product '%s' is out of stock", product.Name))
 		}
 
 		stock := product.Stock - cartItem.Quantity
-		if err := c.ProductRepository.UpdateProductStock(cartItem.ProductID, stock); err != nil {
-			logger.ErrorWithStack(err)
+		if err := c.ProductRepository.UpdateProductStock(ctx, cartItem.ProductID, stock); err != nil {
+			logger.ErrorWithStackContext(ctx, err)
 			return err
 		}
 
@@ -203,7 +229,7 @@ func (c *CartServiceImpl) processOrderItemsAndStock(order Order, cartItems []Car
 		if err != nil {
 			return err
 		}
-		if err := c.CartRepository.CreateOrderItem(OrderItem{
+		if err := c.CartRepository.CreateOrderItem(ctx, OrderItem{
 			OrderItemID: orderItemID,
 			OrderID:     order.OrderID,
 			ProductID:   cartItem.ProductID,
@@ -216,12 +242,12 @@ func (c *CartServiceImpl) processOrderItemsAndStock(order Order, cartItems []Car
 	}
 	return nil
 }
-func (c *CartServiceImpl) calculateTotalAndItems(cartItems []CartItems) (float64, []OrderItemInfo, error) {
+func (c *CartServiceImpl) calculateTotalAndItems(ctx context.Context, cartItems []CartItems) (float64, []OrderItemInfo, error) {
 	var totalAmount float64
 	orderItemsInfo := make([]OrderItemInfo, 0)
 
 	for _, cartItem := range cartItems {
-		product, err := c.ProductRepository.ResolveByID(cartItem.ProductID)
+		product, err := c.ProductRepository.ResolveByID(ctx, cartItem.ProductID)
 		if err != nil {
 			return 0, nil, err
 		}
@@ -251,32 +277,33 @@ func (c *CartServiceImpl) calculateTotalAndItems(cartItems []CartItems) (float64
 
 	return totalAmount, orderItemsInfo, nil
 }
-func (c *CartServiceImpl) getOrCreateCart(userID uuid.UUID) (cart Cart, err error) {
-	cart, err = c.CartRepository.ResolveCartByID(userID)
-	if err == sql.ErrNoRows {
+func (c *CartServiceImpl) getOrCreateCart(ctx context.Context, userID uuid.UUID) (cart Cart, err error) {
+	cart, err = c.CartRepository.ResolveCartByID(ctx, userID)
+	if failure.HasCode(err, failure.CodeCartNotFound) {
 		cartID, err := uuid.NewV4()
 		if err != nil {
 			return cart, err
 		}
-		if err := c.CartRepository.CreateCart(Cart{
+		cart = Cart{
 			CartID:    cartID,
 			UserID:    userID,
 			CreatedAt: time.Now(),
 			CreatedBy: userID,
-		}); err != nil {
+		}
+		if err := c.CartRepository.CreateCart(ctx, cart); err != nil {
 			return cart, err
 		}
-	} else if err != nil {
-		return
+		infras.AfterCommit(ctx, cartsCreated.Inc)
+		return cart, nil
 	}
 	return
 }
-func (c *CartServiceImpl) createCartItem(cartID, userID, productID uuid.UUID, quantity float64) (err error) {
+func (c *CartServiceImpl) createCartItem(ctx context.Context, cartID, userID, productID uuid.UUID, quantity float64) (err error) {
 	cartItemID, err := uuid.NewV4()
 	if err != nil {
 		return err
 	}
-	return c.CartRepository.CreateCartItems(CartItems{
+	return c.CartRepository.CreateCartItems(ctx, CartItems{
 		CartItemID: cartItemID,
 		CartID:     cartID,
 		ProductID:  productID,
@@ -1,11 +1,17 @@
 package cart_test
 
 import (
+	"context"
+	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/internal/domain/cart"
 	"github.com/evermos/boilerplate-go/internal/domain/product"
+	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/gofrs/uuid"
 	"github.com/golang/mock/gomock"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"net/http"
 	"testing"
 
 	cart_mock "github.com/evermos/boilerplate-go/internal/domain/cart/mock"
@@ -24,45 +30,38 @@ func uuidFromString(s string) uuid.UUID {
 
 func TestCartService(t *testing.T) {
 	t.Run("ResolveCartByID", func(t *testing.T) {
+		cartID := uuidFromString("72af6db9-4cbc-4214-839c-a05a0de951f1")
+		userID := getRandomUUID()
+
 		tests := []struct {
 			name            string
 			cartID          uuid.UUID
 			userID          uuid.UUID
-			setupMock       func(*cart_mock.MockCartRepository, *product_mock.MockProductRepository, uuid.UUID, cart.Cart, []cart.CartItems, error)
+			setupMock       func(*cart_mock.MockCartRepository, *product_mock.MockProductRepository, uuid.UUID, uuid.UUID, cart.Cart, []cart.CartItems, error)
 			returns         cart.Cart
 			returnCartItems []cart.CartItems
 			err             error
-			returnProduct   product.Product
 		}{
 			{
 				name:   "Default",
-				cartID: getRandomUUID(),
-				setupMock: func(mockCartRepo *cart_mock.MockCartRepository, mockProductRepo *product_mock.MockProductRepository, id uuid.UUID, cart cart.Cart, cartItems []cart.CartItems, err error) {
-					mockCartRepo.EXPECT().ResolveCartByID(id).Return(cart, err)
-					mockCartRepo.EXPECT().ResolveCartItemsByCartID(id).Return(cartItems, err)
-
-					// Set up behavior for ProductRepository if needed
-					mockProductRepo.EXPECT().ResolveByID(gomock.Any()).Return(product.Product{}, nil)
+				cartID: cartID,
+				userID: userID,
+				setupMock: func(mockCartRepo *cart_mock.MockCartRepository, mockProductRepo *product_mock.MockProductRepository, cartID uuid.UUID, userID uuid.UUID, cart cart.Cart, cartItems []cart.CartItems, err error) {
+					// carts are resolved by their owner, items by the cart
+					mockCartRepo.EXPECT().ResolveCartByID(gomock.Any(), userID).Return(cart, err)
+					mockCartRepo.EXPECT().ResolveCartItemsByCartID(gomock.Any(), cartID).Return(cartItems, err)
 				},
 				returns: cart.Cart{
-					CartID: getRandomUUID(),
-					UserID: getRandomUUID(),
+					CartID: cartID,
+					UserID: userID,
 				},
 				returnCartItems: []cart.CartItems{
 					{
 						CartItemID: getRandomUUID(),
-						CartID:     getRandomUUID(),
+						CartID:     cartID,
 						ProductID:  getRandomUUID(),
 					},
 				},
-				returnProduct: product.Product{
-					ProductID:   getRandomUUID(),
-					CategoryID:  getRandomUUID(),
-					Name:        "Iphone XX",
-					Description: "The New Iphone",
-					Price:       float64(65000),
-					Stock:       float64(650),
-				},
 				err: nil,
 			},
 		}
@@ -74,14 +73,125 @@ func TestCartService(t *testing.T) {
 			t.Run(test.name, func(t *testing.T) {
 				mockCartRepo := cart_mock.NewMockCartRepository(ctrl)
 				mockProductRepo := product_mock.NewMockProductRepository(ctrl)
-				service := cart.ProvideCarServiceImpl(mockCartRepo, mockProductRepo, nil) // Ganti nil dengan mock config sesuai kebutuhan
-				test.setupMock(mockCartRepo, mockProductRepo, test.cartID, test.returns, test.returnCartItems, test.err)
-				_, err := service.ResolveCartByID(test.cartID, test.userID)
+				service := cart.ProvideCarServiceImpl(mockCartRepo, mockProductRepo, nil, nil) // Ganti nil dengan mock config sesuai kebutuhan
+				test.setupMock(mockCartRepo, mockProductRepo, test.cartID, test.userID, test.returns, test.returnCartItems, test.err)
+				got, err := service.ResolveCartByID(context.Background(), test.cartID, test.userID)
 
 				assert.Equal(t, test.err, err)
-				assert.Equal(t, test.returnProduct.Price, nil)
-				// assert.Equal(t, len(test.returnCartItems), len(gotCartItems)) // Komentari karena ini hanya mengembalikan CartItems
+				assert.Equal(t, test.returns.CartID, got.CartID)
+				assert.Equal(t, len(test.returnCartItems), len(got.Items))
 			})
 		}
 	})
 }
+
+// transactor runs units of work without a database.
+type transactor struct{}
+
+func (transactor) WithTx(ctx context.Context, fn infras.TxFunc) error {
+	return fn(ctx, (*sqlx.Tx)(nil))
+}
+
+// counterValue returns the value of a counter in the default registry.
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestCheckoutMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := getRandomUUID()
+	cartID := getRandomUUID()
+
+	t.Run("Count Checkout And Revenue", func(t *testing.T) {
+		mockCartRepo := cart_mock.NewMockCartRepository(ctrl)
+		mockProductRepo := product_mock.NewMockProductRepository(ctrl)
+		service := cart.ProvideCarServiceImpl(mockCartRepo, mockProductRepo, transactor{}, nil)
+		item := cart.CartItems{CartItemID: getRandomUUID(), CartID: cartID, ProductID: getRandomUUID(), Quantity: 2}
+		mockCartRepo.EXPECT().ResolveCartByID(gomock.Any(), userID).Return(cart.Cart{CartID: cartID, UserID: userID}, nil)
+		mockCartRepo.EXPECT().ResolveCartItemsByCartID(gomock.Any(), cartID).Return([]cart.CartItems{item}, nil)
+		mockProductRepo.EXPECT().ResolveByID(gomock.Any(), item.ProductID).Return(product.Product{ProductID: item.ProductID, Price: 150, Stock: 5}, nil).AnyTimes()
+		mockCartRepo.EXPECT().CreateOrder(gomock.Any(), gomock.Any()).Return(nil)
+		mockProductRepo.EXPECT().UpdateProductStock(gomock.Any(), item.ProductID, float64(3)).Return(nil)
+		mockCartRepo.EXPECT().CreateOrderItem(gomock.Any(), gomock.Any()).Return(nil)
+		mockCartRepo.EXPECT().ClearCart(gomock.Any(), cartID).Return(nil)
+		checkouts := counterValue(t, "cart_checkouts_total", nil)
+		revenue := counterValue(t, "cart_revenue_total", nil)
+
+		order, err := service.CheckoutCarts(context.Background(), cart.CheckoutRequestFormat{}, userID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, float64(300), order.TotalPrice)
+		assert.Equal(t, checkouts+1, counterValue(t, "cart_checkouts_total", nil))
+		assert.Equal(t, revenue+300, counterValue(t, "cart_revenue_total", nil))
+	})
+
+	t.Run("Refuse Items Out Of Stock", func(t *testing.T) {
+		mockCartRepo := cart_mock.NewMockCartRepository(ctrl)
+		mockProductRepo := product_mock.NewMockProductRepository(ctrl)
+		service := cart.ProvideCarServiceImpl(mockCartRepo, mockProductRepo, transactor{}, nil)
+		item := cart.CartItems{CartItemID: getRandomUUID(), CartID: cartID, ProductID: getRandomUUID(), Quantity: 6}
+		mockCartRepo.EXPECT().ResolveCartByID(gomock.Any(), userID).Return(cart.Cart{CartID: cartID, UserID: userID}, nil)
+		mockCartRepo.EXPECT().ResolveCartItemsByCartID(gomock.Any(), cartID).Return([]cart.CartItems{item}, nil)
+		mockProductRepo.EXPECT().ResolveByID(gomock.Any(), item.ProductID).Return(product.Product{ProductID: item.ProductID, Name: "Mug", Price: 150, Stock: 5}, nil).AnyTimes()
+		mockCartRepo.EXPECT().CreateOrder(gomock.Any(), gomock.Any()).Return(nil)
+		labels := map[string]string{"reason": cart.CheckoutFailureOutOfStock}
+		failures := counterValue(t, "cart_checkout_failures_total", labels)
+
+		_, err := service.CheckoutCarts(context.Background(), cart.CheckoutRequestFormat{}, userID)
+
+		assert.Equal(t, failure.CodeCartItemOutOfStock, failure.GetErrorCode(err))
+		assert.Equal(t, http.StatusConflict, failure.GetCode(err))
+		assert.Equal(t, failures+1, counterValue(t, "cart_checkout_failures_total", labels))
+	})
+
+	t.Run("Refuse User Without Cart", func(t *testing.T) {
+		mockCartRepo := cart_mock.NewMockCartRepository(ctrl)
+		mockProductRepo := product_mock.NewMockProductRepository(ctrl)
+		service := cart.ProvideCarServiceImpl(mockCartRepo, mockProductRepo, transactor{}, nil)
+		mockCartRepo.EXPECT().ResolveCartByID(gomock.Any(), userID).Return(cart.Cart{}, failure.New(failure.CodeCartNotFound, "cart"))
+		labels := map[string]string{"reason": cart.CheckoutFailureCartNotFound}
+		failures := counterValue(t, "cart_checkout_failures_total", labels)
+
+		_, err := service.CheckoutCarts(context.Background(), cart.CheckoutRequestFormat{}, userID)
+
+		assert.Equal(t, failure.CodeCartNotFound, failure.GetErrorCode(err))
+		assert.Equal(t, http.StatusNotFound, failure.GetCode(err))
+		assert.Equal(t, failures+1, counterValue(t, "cart_checkout_failures_total", labels))
+	})
+
+	t.Run("Count Failure Reason", func(t *testing.T) {
+		mockCartRepo := cart_mock.NewMockCartRepository(ctrl)
+		mockProductRepo := product_mock.NewMockProductRepository(ctrl)
+		service := cart.ProvideCarServiceImpl(mockCartRepo, mockProductRepo, transactor{}, nil)
+		mockCartRepo.EXPECT().ResolveCartByID(gomock.Any(), userID).Return(cart.Cart{CartID: cartID, UserID: userID}, nil)
+		mockCartRepo.EXPECT().ResolveCartItemsByCartID(gomock.Any(), cartID).Return(nil, nil)
+		labels := map[string]string{"reason": cart.CheckoutFailureEmptyCart}
+		failures := counterValue(t, "cart_checkout_failures_total", labels)
+
+		_, err := service.CheckoutCarts(context.Background(), cart.CheckoutRequestFormat{}, userID)
+
+		assert.Equal(t, failure.CodeCartEmpty, failure.GetErrorCode(err))
+		assert.Equal(t, failures+1, counterValue(t, "cart_checkout_failures_total", labels))
+	})
+}
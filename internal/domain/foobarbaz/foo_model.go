@@ -125,7 +125,7 @@ func (f *Foo) Recalculate() {
 // properties of a Foo.
 func (f *Foo) SoftDelete(userID uuid.UUID) (err error) {
 	if f.IsDeleted() {
-		return failure.Conflict("softDelete", "foo", "already marked as deleted")
+		return failure.New(failure.CodeFooAlreadyDeleted, "foo is already marked as deleted")
 	}
 
 	f.Deleted = null.TimeFrom(time.Now())
@@ -198,10 +198,9 @@ func (f *Foo) Update(req FooRequestFormat, userID uuid.UUID) (err error) {
 // 6. Delivered --> this is a final state, no change allowed
 // 7. FailedToDeliver --> this is a final state, no change allowed
 func (f *Foo) UpdateStatus(newStatus FooStatus) (err error) {
-	stateChangeNotAllowedError := failure.Conflict(
-		"stateChange",
-		"foo",
-		fmt.Sprintf("cannot change from %s to %s", f.Status, newStatus))
+	stateChangeNotAllowedError := failure.New(
+		failure.CodeFooStatusChangeNotAllowed,
+		fmt.Sprintf("cannot change foo from %s to %s", f.Status, newStatus))
 
 	switch f.Status {
 	case FooStatusNew:
@@ -3,6 +3,7 @@ package foobarbaz
 //go:generate go run github.com/golang/mock/mockgen -source foo_repository.go -destination mock/foo_repository_mock.go -package foobarbaz_mock
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -132,11 +133,11 @@ var (
 
 // FooRepository is the repository for Foo data.
 type FooRepository interface {
-	Create(foo Foo) (err error)
-	ExistsByID(id uuid.UUID) (exists bool, err error)
-	ResolveByID(id uuid.UUID) (foo Foo, err error)
-	ResolveItemsByFooIDs(ids []uuid.UUID) (fooItems []FooItem, err error)
-	Update(foo Foo) (err error)
+	Create(ctx context.Context, foo Foo) (err error)
+	ExistsByID(ctx context.Context, id uuid.UUID) (exists bool, err error)
+	ResolveByID(ctx context.Context, id uuid.UUID) (foo Foo, err error)
+	ResolveItemsByFooIDs(ctx context.Context, ids []uuid.UUID) (fooItems []FooItem, err error)
+	Update(ctx context.Context, foo Foo) (err error)
 }
 
 // FooRepositoryMySQL is the MySQL-backed implementation of FooRepository.
@@ -152,76 +153,76 @@ func ProvideFooRepositoryMySQL(db *infras.MySQLConn) *FooRepositoryMySQL {
 }
 
 // Create creates a new Foo.
-func (r *FooRepositoryMySQL) Create(foo Foo) (err error) {
-	exists, err := r.ExistsByID(foo.ID)
+func (r *FooRepositoryMySQL) Create(ctx context.Context, foo Foo) (err error) {
+	exists, err := r.ExistsByID(ctx, foo.ID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
 	if exists {
-		err = failure.Conflict("create", "foo", "already exists")
-		logger.ErrorWithStack(err)
+		err = failure.New(failure.CodeFooAlreadyExists, "foo already exists")
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
-	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
-		if err := r.txCreate(tx, foo); err != nil {
-			e <- err
-			return
+	return r.DB.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		if err := r.txCreate(ctx, tx, foo); err != nil {
+			return err
 		}
 
-		if err := r.txCreateItems(tx, foo.Items); err != nil {
-			e <- err
-			return
+		if err := r.txCreateItems(ctx, tx, foo.Items); err != nil {
+			return err
 		}
 
-		e <- nil
+		return nil
 	})
 }
 
 // ExistsByID checks the existence of a Foo by its ID.
-func (r *FooRepositoryMySQL) ExistsByID(id uuid.UUID) (exists bool, err error) {
-	err = r.DB.Read.Get(
+func (r *FooRepositoryMySQL) ExistsByID(ctx context.Context, id uuid.UUID) (exists bool, err error) {
+	err = r.DB.Reader(ctx).GetContext(
+		ctx,
 		&exists,
 		"SELECT COUNT(entity_id) FROM foo WHERE foo.entity_id = ?",
 		id.String())
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
 }
 
 // ResolveByID resolves a Foo by its ID
-func (r *FooRepositoryMySQL) ResolveByID(id uuid.UUID) (foo Foo, err error) {
-	err = r.DB.Read.Get(
+func (r *FooRepositoryMySQL) ResolveByID(ctx context.Context, id uuid.UUID) (foo Foo, err error) {
+	err = r.DB.Reader(ctx).GetContext(
+		ctx,
 		&foo,
 		fooQueries.selectFoo+" WHERE foo.entity_id = ?",
 		id.String())
 	if err != nil && err == sql.ErrNoRows {
-		err = failure.NotFound("foo")
-		logger.ErrorWithStack(err)
+		err = failure.New(failure.CodeFooNotFound, "foo")
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	return
 }
 
 // ResolveItemsByFooIDs resolves FooItems based on a set of FooIDs.
-func (r *FooRepositoryMySQL) ResolveItemsByFooIDs(ids []uuid.UUID) (fooItems []FooItem, err error) {
+func (r *FooRepositoryMySQL) ResolveItemsByFooIDs(ctx context.Context, ids []uuid.UUID) (fooItems []FooItem, err error) {
 	if len(ids) == 0 {
 		return
 	}
 
 	query, args, err := sqlx.In(fooQueries.selectFooItem+" WHERE foo_item.foo_id IN (?)", ids)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
-	err = r.DB.Read.Select(&fooItems, query, args...)
+	err = r.DB.Reader(ctx).SelectContext(ctx, &fooItems, query, args...)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
@@ -229,16 +230,16 @@ func (r *FooRepositoryMySQL) ResolveItemsByFooIDs(ids []uuid.UUID) (fooItems []F
 }
 
 // Update updates a Foo.
-func (r *FooRepositoryMySQL) Update(foo Foo) (err error) {
-	exists, err := r.ExistsByID(foo.ID)
+func (r *FooRepositoryMySQL) Update(ctx context.Context, foo Foo) (err error) {
+	exists, err := r.ExistsByID(ctx, foo.ID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
 	if !exists {
-		err = failure.NotFound("foo")
-		logger.ErrorWithStack(err)
+		err = failure.New(failure.CodeFooNotFound, "foo")
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
@@ -247,23 +248,20 @@ func (r *FooRepositoryMySQL) Update(foo Foo) (err error) {
 	// 1. delete all the Foo's items
 	// 2. create a new set of Foo's items
 	// 3. update the Foo
-	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
-		if err := r.txDeleteItems(tx, foo.ID); err != nil {
-			e <- err
-			return
+	return r.DB.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		if err := r.txDeleteItems(ctx, tx, foo.ID); err != nil {
+			return err
 		}
 
-		if err := r.txCreateItems(tx, foo.Items); err != nil {
-			e <- err
-			return
+		if err := r.txCreateItems(ctx, tx, foo.Items); err != nil {
+			return err
 		}
 
-		if err := r.txUpdate(tx, foo); err != nil {
-			e <- err
-			return
+		if err := r.txUpdate(ctx, tx, foo); err != nil {
+			return err
 		}
 
-		e <- nil
+		return nil
 	})
 }
 
@@ -296,24 +294,24 @@ func (r *FooRepositoryMySQL) composeBulkInsertItemQuery(fooItems []FooItem) (que
 }
 
 // txCreate creates a Foo transactionally given the *sqlx.Tx param.
-func (r *FooRepositoryMySQL) txCreate(tx *sqlx.Tx, foo Foo) (err error) {
-	stmt, err := tx.PrepareNamed(fooQueries.insertFoo)
+func (r *FooRepositoryMySQL) txCreate(ctx context.Context, tx *sqlx.Tx, foo Foo) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, fooQueries.insertFoo)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(foo)
+	_, err = stmt.ExecContext(ctx, foo)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
 }
 
 // txCreateItems create FooItems transactionally given the *sqlx.Tx param.
-func (r *FooRepositoryMySQL) txCreateItems(tx *sqlx.Tx, fooItems []FooItem) (err error) {
+func (r *FooRepositoryMySQL) txCreateItems(ctx context.Context, tx *sqlx.Tx, fooItems []FooItem) (err error) {
 	if len(fooItems) == 0 {
 		return
 	}
@@ -323,38 +321,38 @@ func (r *FooRepositoryMySQL) txCreateItems(tx *sqlx.Tx, fooItems []FooItem) (err
 		return
 	}
 
-	stmt, err := tx.Preparex(query)
+	stmt, err := tx.PreparexContext(ctx, query)
 	if err != nil {
 		return
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Stmt.Exec(args...)
+	_, err = stmt.Stmt.ExecContext(ctx, args...)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
 }
 
 // txDeleteeItems deletes FooItems based on their FooID transactionally given the *sqlx.Tx param.
-func (r *FooRepositoryMySQL) txDeleteItems(tx *sqlx.Tx, fooID uuid.UUID) (err error) {
-	_, err = tx.Exec("DELETE FROM foo_item WHERE foo_id = ?", fooID.String())
+func (r *FooRepositoryMySQL) txDeleteItems(ctx context.Context, tx *sqlx.Tx, fooID uuid.UUID) (err error) {
+	_, err = tx.ExecContext(ctx, "DELETE FROM foo_item WHERE foo_id = ?", fooID.String())
 	return
 }
 
 // txUpdate updates a Foo transactionally, given the *sqlx.Tx param.
-func (r *FooRepositoryMySQL) txUpdate(tx *sqlx.Tx, foo Foo) (err error) {
-	stmt, err := tx.PrepareNamed(fooQueries.updateFoo)
+func (r *FooRepositoryMySQL) txUpdate(ctx context.Context, tx *sqlx.Tx, foo Foo) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, fooQueries.updateFoo)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(foo)
+	_, err = stmt.ExecContext(ctx, foo)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
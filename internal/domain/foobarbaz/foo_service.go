@@ -3,76 +3,81 @@ package foobarbaz
 //go:generate go run github.com/golang/mock/mockgen -source foo_service.go -destination mock/foo_service_mock.go -package foobarbaz_mock
 
 import (
+	"context"
+
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/event/model"
 	"github.com/evermos/boilerplate-go/event/producer"
+	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
 // FooService is the service interface for Foo entities.
 type FooService interface {
-	Create(requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error)
-	ResolveByID(id uuid.UUID, withItems bool) (foo Foo, err error)
-	SoftDelete(id uuid.UUID, userID uuid.UUID) (foo Foo, err error)
-	Update(id uuid.UUID, requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error)
+	Create(ctx context.Context, requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error)
+	ResolveByID(ctx context.Context, id uuid.UUID, withItems bool) (foo Foo, err error)
+	SoftDelete(ctx context.Context, id uuid.UUID, userID uuid.UUID) (foo Foo, err error)
+	Update(ctx context.Context, id uuid.UUID, requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error)
 }
 
 // FooServiceImpl is the service implementation for Foo entities.
 type FooServiceImpl struct {
 	FooRepository FooRepository
 	Producer      producer.Producer
+	Transactor    infras.Transactor
 	Config        *configs.Config
 }
 
 // ProvideFooServiceImpl is the provider for this service.
-func ProvideFooServiceImpl(fooRepository FooRepository, producer producer.Producer, config *configs.Config) *FooServiceImpl {
+func ProvideFooServiceImpl(fooRepository FooRepository, producer producer.Producer, transactor infras.Transactor, config *configs.Config) *FooServiceImpl {
 	s := new(FooServiceImpl)
 	s.FooRepository = fooRepository
 	s.Config = config
 	s.Producer = producer
+	s.Transactor = transactor
 
 	return s
 }
 
 // Create creates a new Foo.
-func (s *FooServiceImpl) Create(requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error) {
+func (s *FooServiceImpl) Create(ctx context.Context, requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error) {
 	foo, err = foo.NewFromRequestFormat(requestFormat, userID)
 	if err != nil {
-		return
-	}
-
-	if err != nil {
-		return foo, failure.BadRequest(err)
+		return foo, failure.New(failure.CodeFooInvalid, err.Error())
 	}
 
-	err = s.FooRepository.Create(foo)
+	// the event is published with the Foo, so neither is kept without the other
+	err = s.Transactor.WithTx(ctx, func(ctx context.Context, _ *sqlx.Tx) error {
+		if err := s.FooRepository.Create(ctx, foo); err != nil {
+			return err
+		}
 
-	if err != nil {
-		return
-	}
+		if !s.Config.Event.Producer.SNS.Topics.FooCreated.Enabled {
+			return nil
+		}
 
-	if s.Config.Event.Producer.SNS.Topics.FooCreated.Enabled {
 		e := model.NewEvent(FooBarBazEventType, requestFormat)
-		s.Producer.Publish(model.PublishRequest{
+		return s.Producer.Publish(ctx, model.PublishRequest{
 			Event: e,
 			Topic: s.Config.Event.Producer.SNS.Topics.FooCreated.ARN,
 		})
-	}
+	})
 
 	return
 }
 
 // ResolveByID resolves a Foo by its ID.
-func (s *FooServiceImpl) ResolveByID(id uuid.UUID, withItems bool) (foo Foo, err error) {
-	foo, err = s.FooRepository.ResolveByID(id)
+func (s *FooServiceImpl) ResolveByID(ctx context.Context, id uuid.UUID, withItems bool) (foo Foo, err error) {
+	foo, err = s.FooRepository.ResolveByID(ctx, id)
 
 	if foo.IsDeleted() {
-		return foo, failure.NotFound("foo")
+		return foo, failure.New(failure.CodeFooNotFound, "foo")
 	}
 
 	if withItems {
-		items, err := s.FooRepository.ResolveItemsByFooIDs([]uuid.UUID{foo.ID})
+		items, err := s.FooRepository.ResolveItemsByFooIDs(ctx, []uuid.UUID{foo.ID})
 		if err != nil {
 			return foo, err
 		}
@@ -84,14 +89,14 @@ func (s *FooServiceImpl) ResolveByID(id uuid.UUID, withItems bool) (foo Foo, err
 }
 
 // SoftDelete marks a Foo as deleted by setting its `deleted` and `deletedBy` properties.
-func (s *FooServiceImpl) SoftDelete(id uuid.UUID, userID uuid.UUID) (foo Foo, err error) {
-	foo, err = s.FooRepository.ResolveByID(id)
+func (s *FooServiceImpl) SoftDelete(ctx context.Context, id uuid.UUID, userID uuid.UUID) (foo Foo, err error) {
+	foo, err = s.FooRepository.ResolveByID(ctx, id)
 	if err != nil {
 		return
 	}
 
 	// need to get the items so they don't get deleted
-	items, err := s.FooRepository.ResolveItemsByFooIDs([]uuid.UUID{foo.ID})
+	items, err := s.FooRepository.ResolveItemsByFooIDs(ctx, []uuid.UUID{foo.ID})
 	if err != nil {
 		return foo, err
 	}
@@ -103,13 +108,13 @@ func (s *FooServiceImpl) SoftDelete(id uuid.UUID, userID uuid.UUID) (foo Foo, er
 		return
 	}
 
-	err = s.FooRepository.Update(foo)
+	err = s.FooRepository.Update(ctx, foo)
 	return
 }
 
 // Update updates a Foo.
-func (s *FooServiceImpl) Update(id uuid.UUID, requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error) {
-	foo, err = s.FooRepository.ResolveByID(id)
+func (s *FooServiceImpl) Update(ctx context.Context, id uuid.UUID, requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error) {
+	foo, err = s.FooRepository.ResolveByID(ctx, id)
 	if err != nil {
 		return
 	}
@@ -119,6 +124,6 @@ func (s *FooServiceImpl) Update(id uuid.UUID, requestFormat FooRequestFormat, us
 		return
 	}
 
-	err = s.FooRepository.Update(foo)
+	err = s.FooRepository.Update(ctx, foo)
 	return
 }
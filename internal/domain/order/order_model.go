@@ -19,7 +19,7 @@ type (
 		UpdatedBy   nuuid.NUUID `db:"updated_by"`
 		DeletedAt   null.Time   `db:"deleted_at"`
 		DeletedBy   nuuid.NUUID `db:"deleted_by"`
-		Items       []OrderItem `db"-"`
+		Items       []OrderItem `db:"-"`
 	}
 
 	OrderItem struct {
@@ -1,6 +1,7 @@
 package order
 
 import (
+	"context"
 	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/shared/logger"
 	"github.com/gofrs/uuid"
@@ -71,10 +72,10 @@ var (
 )
 
 type OrderRepository interface {
-	CreateOrder(order Order) (err error)
-	CreateOrderItem(orderItem OrderItem) (err error)
-	ResolveAllOrderByUserID(userID uuid.UUID, limit, page int) ([]Order, error)
-	ResolveOrderItemsByOrderID(orderID uuid.UUID) ([]OrderItemInfo, error)
+	CreateOrder(ctx context.Context, order Order) (err error)
+	CreateOrderItem(ctx context.Context, orderItem OrderItem) (err error)
+	ResolveAllOrderByUserID(ctx context.Context, userID uuid.UUID, limit, page int) ([]Order, error)
+	ResolveOrderItemsByOrderID(ctx context.Context, orderID uuid.UUID) ([]OrderItemInfo, error)
 }
 
 type OrderRepositoryMySQL struct {
@@ -85,68 +86,60 @@ func ProvideOrderRepositoryMySQL(db *infras.MySQLConn) *OrderRepositoryMySQL {
 	return &OrderRepositoryMySQL{DB: db}
 }
 
-func (o *OrderRepositoryMySQL) CreateOrder(order Order) (err error) {
-	return o.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
-		if err := o.txCreateOrder(tx, order); err != nil {
-			e <- err
-			return
-		}
-		e <- nil
+func (o *OrderRepositoryMySQL) CreateOrder(ctx context.Context, order Order) (err error) {
+	return o.DB.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		return o.txCreateOrder(ctx, tx, order)
 	})
 }
-func (o *OrderRepositoryMySQL) CreateOrderItem(orderItem OrderItem) (err error) {
-	return o.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
-		if err := o.txCreateOrderItems(tx, orderItem); err != nil {
-			e <- err
-			return
-		}
-		e <- nil
+func (o *OrderRepositoryMySQL) CreateOrderItem(ctx context.Context, orderItem OrderItem) (err error) {
+	return o.DB.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		return o.txCreateOrderItems(ctx, tx, orderItem)
 	})
 }
-func (o *OrderRepositoryMySQL) ResolveAllOrderByUserID(userID uuid.UUID, limit, page int) ([]Order, error) {
-	query := o.DB.Read.Rebind(orderQueries.selectOrder + " WHERE o.user_id = ? LIMIT ? OFFSET ?")
+func (o *OrderRepositoryMySQL) ResolveAllOrderByUserID(ctx context.Context, userID uuid.UUID, limit, page int) ([]Order, error) {
+	query := o.DB.Reader(ctx).Rebind(orderQueries.selectOrder + " WHERE o.user_id = ? LIMIT ? OFFSET ?")
 	var orders []Order
-	err := o.DB.Read.Select(&orders, query, userID, limit, page)
+	err := o.DB.Reader(ctx).SelectContext(ctx, &orders, query, userID, limit, page)
 	if err != nil {
 		return nil, err
 	}
 	return orders, nil
 }
 
-func (o *OrderRepositoryMySQL) ResolveOrderItemsByOrderID(orderID uuid.UUID) ([]OrderItemInfo, error) {
-	query := o.DB.Read.Rebind(orderQueries.selectOrderItems + " WHERE oi.order_id = ?")
+func (o *OrderRepositoryMySQL) ResolveOrderItemsByOrderID(ctx context.Context, orderID uuid.UUID) ([]OrderItemInfo, error) {
+	query := o.DB.Reader(ctx).Rebind(orderQueries.selectOrderItems + " WHERE oi.order_id = ?")
 	var orderItems []OrderItemInfo
-	err := o.DB.Read.Select(&orderItems, query, orderID)
+	err := o.DB.Reader(ctx).SelectContext(ctx, &orderItems, query, orderID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return nil, err
 	}
 	return orderItems, nil
 }
 
-func (o *OrderRepositoryMySQL) txCreateOrder(tx *sqlx.Tx, order Order) (err error) {
-	stmt, err := tx.PrepareNamed(orderQueries.insertOrder)
+func (o *OrderRepositoryMySQL) txCreateOrder(ctx context.Context, tx *sqlx.Tx, order Order) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, orderQueries.insertOrder)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(order)
+	_, err = stmt.ExecContext(ctx, order)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 	return
 }
-func (o *OrderRepositoryMySQL) txCreateOrderItems(tx *sqlx.Tx, orderItems OrderItem) (err error) {
-	stmt, err := tx.PrepareNamed(orderQueries.insertOrderItems)
+func (o *OrderRepositoryMySQL) txCreateOrderItems(ctx context.Context, tx *sqlx.Tx, orderItems OrderItem) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, orderQueries.insertOrderItems)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(orderItems)
+	_, err = stmt.ExecContext(ctx, orderItems)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 	return
 }
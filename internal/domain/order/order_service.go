@@ -1,6 +1,7 @@
 package order
 
 import (
+	"context"
 	"fmt"
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/internal/domain/cart"
@@ -10,7 +11,7 @@ import (
 )
 
 type OrderService interface {
-	ResolveAllCart(userID uuid.UUID, limit, page int) ([]OrderResponse, error)
+	ResolveAllCart(ctx context.Context, userID uuid.UUID, limit, page int) ([]OrderResponse, error)
 }
 
 type OrderServiceImpl struct {
@@ -24,17 +25,17 @@ func ProvideOrderServiceImpl(orderRepository OrderRepository, config *configs.Co
 	return &OrderServiceImpl{OrderRepository: orderRepository, Config: config}
 }
 
-func (o *OrderServiceImpl) ResolveAllCart(userID uuid.UUID, limit, page int) ([]OrderResponse, error) {
-	orders, err := o.OrderRepository.ResolveAllOrderByUserID(userID, limit, page)
+func (o *OrderServiceImpl) ResolveAllCart(ctx context.Context, userID uuid.UUID, limit, page int) ([]OrderResponse, error) {
+	orders, err := o.OrderRepository.ResolveAllOrderByUserID(ctx, userID, limit, page)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch orders: %w", err)
 	}
 
 	var resp []OrderResponse
 	for _, order := range orders {
-		orderItems, err := o.OrderRepository.ResolveOrderItemsByOrderID(order.OrderID)
+		orderItems, err := o.OrderRepository.ResolveOrderItemsByOrderID(ctx, order.OrderID)
 		if err != nil {
-			logger.ErrorWithStack(err)
+			logger.ErrorWithStackContext(ctx, err)
 			return nil, fmt.Errorf("failed to fetch order items: %w", err)
 		}
 
@@ -56,8 +56,8 @@ type (
 		CreatedBy   uuid.UUID `json:"createdBy"`
 	}
 	CategoriesRequestFormat struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
+		Name        string `json:"name" validate:"required,max=255"`
+		Description string `json:"description" validate:"required"`
 	}
 	CategoryResponseFormat struct {
 		ID           uuid.UUID `json:"categoryID,omitempty"`
@@ -3,6 +3,7 @@ package product
 //go:generate go run github.com/golang/mock/mockgen -source product_repository.go -destination mock/product_repository_mock.go -package product_mock
 
 import (
+	"context"
 	"database/sql"
 	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/shared/failure"
@@ -84,13 +85,13 @@ var (
 )
 
 type ProductRepository interface {
-	Create(product Product) (err error)
-	CreateCategory(category ProductCategories) (err error)
-	ExistsByID(id uuid.UUID) (exists bool, err error)
-	ResolveByID(productID uuid.UUID) (product Product, err error)
-	ResolveProduct(limit, page int) (product []Product, err error)
-	ResolveProductByCategory(limit, page int, categoryName string) (product []Product, err error)
-	UpdateProductStock(productID uuid.UUID, stock float64) (err error)
+	Create(ctx context.Context, product Product) (err error)
+	CreateCategory(ctx context.Context, category ProductCategories) (err error)
+	ExistsByID(ctx context.Context, id uuid.UUID) (exists bool, err error)
+	ResolveByID(ctx context.Context, productID uuid.UUID) (product Product, err error)
+	ResolveProduct(ctx context.Context, limit, page int) (product []Product, err error)
+	ResolveProductByCategory(ctx context.Context, limit, page int, categoryName string) (product []Product, err error)
+	UpdateProductStock(ctx context.Context, productID uuid.UUID, stock float64) (err error)
 }
 
 type ProductRepositoryMySQL struct {
@@ -101,78 +102,79 @@ func ProvideProductRepository(db *infras.MySQLConn) *ProductRepositoryMySQL {
 	return &ProductRepositoryMySQL{DB: db}
 }
 
-func (p *ProductRepositoryMySQL) Create(product Product) (err error) {
-	stmt, err := p.DB.Write.PrepareNamed(productQueries.insertProduct)
+func (p *ProductRepositoryMySQL) Create(ctx context.Context, product Product) (err error) {
+	stmt, err := p.DB.Writer(ctx).PrepareNamedContext(ctx, productQueries.insertProduct)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(product)
+	_, err = stmt.ExecContext(ctx, product)
 	return err
 }
-func (u *ProductRepositoryMySQL) ExistsByID(id uuid.UUID) (exists bool, err error) {
-	err = u.DB.Read.Get(
+func (u *ProductRepositoryMySQL) ExistsByID(ctx context.Context, id uuid.UUID) (exists bool, err error) {
+	err = u.DB.Reader(ctx).GetContext(
+		ctx,
 		&exists,
 		"SELECT COUNT(product_id) FROM product p WHERE p.product_id = ?",
 		id.String())
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
 }
-func (p *ProductRepositoryMySQL) ResolveProduct(limit, page int) (product []Product, err error) {
+func (p *ProductRepositoryMySQL) ResolveProduct(ctx context.Context, limit, page int) (product []Product, err error) {
 	query, args, err := sqlx.In(productQueries.selectProduct+" LIMIT ? OFFSET ?", limit, page)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return nil, err
 	}
-	query = p.DB.Read.Rebind(query)
+	query = p.DB.Reader(ctx).Rebind(query)
 	var products []Product
-	err = p.DB.Read.Select(&products, query, args...)
+	err = p.DB.Reader(ctx).SelectContext(ctx, &products, query, args...)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return nil, err
 	}
 	return products, nil
 }
-func (p *ProductRepositoryMySQL) ResolveProductByCategory(limit, page int, categoryName string) (product []Product, err error) {
+func (p *ProductRepositoryMySQL) ResolveProductByCategory(ctx context.Context, limit, page int, categoryName string) (product []Product, err error) {
 	query, args, err := sqlx.In(productQueries.selectProduct+" WHERE category_id = (SELECT category_id FROM product_categories WHERE name = ? ) LIMIT ? OFFSET ?", categoryName, limit, limit*page)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return nil, err
 	}
-	query = p.DB.Read.Rebind(query)
+	query = p.DB.Reader(ctx).Rebind(query)
 	var products []Product
-	err = p.DB.Read.Select(&products, query, args...)
+	err = p.DB.Reader(ctx).SelectContext(ctx, &products, query, args...)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return nil, err
 	}
 	return products, nil
 }
-func (p *ProductRepositoryMySQL) ResolveByID(productID uuid.UUID) (product Product, err error) {
-	err = p.DB.Read.Get(&product, productQueries.selectProduct+" WHERE product_id = ?", productID.String())
+func (p *ProductRepositoryMySQL) ResolveByID(ctx context.Context, productID uuid.UUID) (product Product, err error) {
+	err = p.DB.Reader(ctx).GetContext(ctx, &product, productQueries.selectProduct+" WHERE product_id = ?", productID.String())
 	if err != nil && err == sql.ErrNoRows {
-		err = failure.NotFound("product")
-		logger.ErrorWithStack(err)
+		err = failure.New(failure.CodeProductNotFound, "product")
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	return
 }
-func (p *ProductRepositoryMySQL) CreateCategory(category ProductCategories) (err error) {
-	stmt, err := p.DB.Write.PrepareNamed(productQueries.insertCategory)
+func (p *ProductRepositoryMySQL) CreateCategory(ctx context.Context, category ProductCategories) (err error) {
+	stmt, err := p.DB.Writer(ctx).PrepareNamedContext(ctx, productQueries.insertCategory)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
-	_, err = stmt.Exec(category)
+	_, err = stmt.ExecContext(ctx, category)
 	return err
 }
-func (p *ProductRepositoryMySQL) UpdateProductStock(productID uuid.UUID, stock float64) (err error) {
-	_, err = p.DB.Write.Exec("UPDATE product SET stock = ? WHERE product_id = ?", stock, productID)
+func (p *ProductRepositoryMySQL) UpdateProductStock(ctx context.Context, productID uuid.UUID, stock float64) (err error) {
+	_, err = p.DB.Writer(ctx).ExecContext(ctx, "UPDATE product SET stock = ? WHERE product_id = ?", stock, productID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return err
 	}
 	return nil
@@ -0,0 +1,224 @@
+package product
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/go-redis/redis"
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	productCacheKey        = "product:%s"
+	productListCacheKey    = "product:list:%d:%d:%d"
+	productCategoryListKey = "product:category:%d:%s:%d:%d"
+	// productGenKey is bumped whenever the product is evicted, so a load that
+	// started earlier doesn't cache it again.
+	productGenKey = "product:gen:%s"
+	// productListGenKey is bumped on every write, so cached list pages of
+	// older generations are no longer read and expire on their own.
+	productListGenKey = "product:list:gen"
+
+	// cacheLoadTimeout bounds the loads shared by concurrent misses, which
+	// don't stop when one of the callers waiting for them gives up.
+	cacheLoadTimeout = 10 * time.Second
+)
+
+// setIfGeneration caches an entry unless its generation, KEYS[2], changed
+// since ARGV[1] was read before loading it.
+var setIfGeneration = redis.NewScript(`
+local gen = redis.call("GET", KEYS[2]) or "0"
+if gen ~= ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+return 1
+`)
+
+// ProductRepositoryRedis is a read-through cache in front of another
+// ProductRepository. Products are cached by ID, and list pages by their
+// limit, page and category. Writes invalidate the affected entries once
+// committed. Misses are loaded from the primary, so a lagging replica can't
+// be cached, and entries are only cached if they weren't invalidated while
+// loading. Reads made inside a transaction bypass the cache, as they may need
+// to see uncommitted or locked rows. Redis failures fall back to the
+// underlying repository.
+type ProductRepositoryRedis struct {
+	Repository ProductRepository
+	Client     *redis.Client
+	TTL        time.Duration
+	ListTTL    time.Duration
+
+	group singleflight.Group
+}
+
+// ProvideProductRepositoryRedis is the provider for ProductRepositoryRedis,
+// caching the products read from MySQL.
+func ProvideProductRepositoryRedis(repository *ProductRepositoryMySQL, client *redis.Client, config *configs.Config) *ProductRepositoryRedis {
+	return &ProductRepositoryRedis{
+		Repository: repository,
+		Client:     client,
+		TTL:        time.Duration(config.Cache.Product.TTLSeconds) * time.Second,
+		ListTTL:    time.Duration(config.Cache.Product.ListTTLSeconds) * time.Second,
+	}
+}
+
+func (p *ProductRepositoryRedis) Create(ctx context.Context, product Product) (err error) {
+	if err = p.Repository.Create(ctx, product); err != nil {
+		return
+	}
+	p.invalidate(ctx, product.ProductID)
+	return
+}
+
+func (p *ProductRepositoryRedis) CreateCategory(ctx context.Context, category ProductCategories) (err error) {
+	return p.Repository.CreateCategory(ctx, category)
+}
+
+func (p *ProductRepositoryRedis) ExistsByID(ctx context.Context, id uuid.UUID) (exists bool, err error) {
+	return p.Repository.ExistsByID(ctx, id)
+}
+
+func (p *ProductRepositoryRedis) ResolveByID(ctx context.Context, productID uuid.UUID) (product Product, err error) {
+	if infras.InTx(ctx) {
+		return p.Repository.ResolveByID(ctx, productID)
+	}
+
+	key := fmt.Sprintf(productCacheKey, productID)
+	err = p.readThrough(ctx, key, fmt.Sprintf(productGenKey, productID), p.TTL, &product, func(ctx context.Context) (interface{}, error) {
+		return p.Repository.ResolveByID(ctx, productID)
+	})
+	return
+}
+
+func (p *ProductRepositoryRedis) ResolveProduct(ctx context.Context, limit, page int) (product []Product, err error) {
+	if infras.InTx(ctx) {
+		return p.Repository.ResolveProduct(ctx, limit, page)
+	}
+
+	key := fmt.Sprintf(productListCacheKey, p.generation(ctx, productListGenKey), limit, page)
+	err = p.readThrough(ctx, key, productListGenKey, p.ListTTL, &product, func(ctx context.Context) (interface{}, error) {
+		return p.Repository.ResolveProduct(ctx, limit, page)
+	})
+	return
+}
+
+func (p *ProductRepositoryRedis) ResolveProductByCategory(ctx context.Context, limit, page int, categoryName string) (product []Product, err error) {
+	if infras.InTx(ctx) {
+		return p.Repository.ResolveProductByCategory(ctx, limit, page, categoryName)
+	}
+
+	key := fmt.Sprintf(productCategoryListKey, p.generation(ctx, productListGenKey), categoryName, limit, page)
+	err = p.readThrough(ctx, key, productListGenKey, p.ListTTL, &product, func(ctx context.Context) (interface{}, error) {
+		return p.Repository.ResolveProductByCategory(ctx, limit, page, categoryName)
+	})
+	return
+}
+
+func (p *ProductRepositoryRedis) UpdateProductStock(ctx context.Context, productID uuid.UUID, stock float64) (err error) {
+	if err = p.Repository.UpdateProductStock(ctx, productID, stock); err != nil {
+		return
+	}
+	p.invalidate(ctx, productID)
+	return
+}
+
+// readThrough reads the key into dest, or loads and caches it on a miss.
+// Concurrent misses of the same key share a single load, made from the
+// primary with a context of its own, so a caller giving up doesn't fail the
+// others. The entry isn't cached when its generation, genKey, was bumped
+// while loading.
+func (p *ProductRepositoryRedis) readThrough(ctx context.Context, key, genKey string, ttl time.Duration, dest interface{}, load func(ctx context.Context) (interface{}, error)) error {
+	cached, err := p.Client.WithContext(ctx).Get(key).Bytes()
+	if err == nil {
+		if err = gob.NewDecoder(bytes.NewReader(cached)).Decode(dest); err == nil {
+			return nil
+		}
+	}
+	if err != redis.Nil {
+		log.Warn().Err(err).Str("key", key).Msg("Failed reading product cache")
+	}
+
+	loaded := p.group.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(infras.WithPrimary(detached{ctx}), cacheLoadTimeout)
+		defer cancel()
+
+		gen := p.generation(ctx, genKey)
+		value, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// gob keeps every field, unlike the JSON encoding of the responses
+		var encoded bytes.Buffer
+		if err := gob.NewEncoder(&encoded).Encode(value); err != nil {
+			return nil, err
+		}
+		err = setIfGeneration.Run(p.Client.WithContext(ctx), []string{key, genKey}, gen, encoded.Bytes(), ttl.Milliseconds()).Err()
+		if err != nil {
+			log.Warn().Err(err).Str("key", key).Msg("Failed writing product cache")
+		}
+		return encoded.Bytes(), nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case result := <-loaded:
+		if result.Err != nil {
+			return result.Err
+		}
+		return gob.NewDecoder(bytes.NewReader(result.Val.([]byte))).Decode(dest)
+	}
+}
+
+// generation returns the current generation of cached entries.
+func (p *ProductRepositoryRedis) generation(ctx context.Context, genKey string) int64 {
+	gen, err := p.Client.WithContext(ctx).Get(genKey).Int64()
+	if err != nil && err != redis.Nil {
+		log.Warn().Err(err).Str("key", genKey).Msg("Failed reading product cache generation")
+	}
+	return gen
+}
+
+// invalidate evicts the product and every cached list page, bumping their
+// generations so loads already running don't cache them again. It runs right
+// away and again once the transaction of the context commits, so a read
+// racing the transaction can't leave the old product cached.
+func (p *ProductRepositoryRedis) invalidate(ctx context.Context, productID uuid.UUID) {
+	evict := func() {
+		genKey := fmt.Sprintf(productGenKey, productID)
+		_, err := p.Client.WithContext(context.Background()).TxPipelined(func(pipe redis.Pipeliner) error {
+			pipe.Del(fmt.Sprintf(productCacheKey, productID))
+			pipe.Incr(genKey)
+			pipe.Expire(genKey, p.TTL)
+			pipe.Incr(productListGenKey)
+			return nil
+		})
+		if err != nil {
+			log.Warn().Err(err).Str("productID", productID.String()).Msg("Failed evicting product cache")
+		}
+	}
+
+	evict()
+	if infras.InTx(ctx) {
+		infras.AfterCommit(ctx, evict)
+	}
+}
+
+// detached keeps the values of a context, such as its trace, without its
+// deadline and cancellation.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
@@ -0,0 +1,177 @@
+package product_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evermos/boilerplate-go/internal/domain/product"
+	"github.com/go-redis/redis"
+	"github.com/gofrs/uuid"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	product_mock "github.com/evermos/boilerplate-go/internal/domain/product/mock"
+)
+
+func newCachedRepository(t *testing.T) (*product.ProductRepositoryRedis, *product_mock.MockProductRepository, *miniredis.Miniredis) {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	mockRepo := product_mock.NewMockProductRepository(ctrl)
+	return &product.ProductRepositoryRedis{
+		Repository: mockRepo,
+		Client:     redis.NewClient(&redis.Options{Addr: server.Addr()}),
+		TTL:        time.Minute,
+		ListTTL:    time.Minute,
+	}, mockRepo, server
+}
+
+func TestProductRepositoryRedis(t *testing.T) {
+	productID := uuid.Must(uuid.NewV4())
+	stored := product.Product{ProductID: productID, Name: "Product", Price: 10000, Stock: 5}
+
+	t.Run("Cache By ID", func(t *testing.T) {
+		repo, mockRepo, server := newCachedRepository(t)
+		mockRepo.EXPECT().ResolveByID(gomock.Any(), productID).Return(stored, nil).Times(1)
+
+		first, err := repo.ResolveByID(context.Background(), productID)
+		assert.NoError(t, err)
+		second, err := repo.ResolveByID(context.Background(), productID)
+		assert.NoError(t, err)
+
+		assert.Equal(t, stored.Name, first.Name)
+		assert.Equal(t, first, second)
+		assert.Equal(t, time.Minute, server.TTL("product:"+productID.String()))
+	})
+
+	t.Run("Single Flight", func(t *testing.T) {
+		repo, mockRepo, _ := newCachedRepository(t)
+		release := make(chan struct{})
+		mockRepo.EXPECT().ResolveByID(gomock.Any(), productID).DoAndReturn(func(ctx context.Context, id uuid.UUID) (product.Product, error) {
+			<-release
+			return stored, nil
+		}).Times(1)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				actual, err := repo.ResolveByID(context.Background(), productID)
+				assert.NoError(t, err)
+				assert.Equal(t, stored.Name, actual.Name)
+			}()
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+	})
+
+	t.Run("Invalidate On Stock Change", func(t *testing.T) {
+		repo, mockRepo, server := newCachedRepository(t)
+		updated := stored
+		updated.Stock = 4
+		gomock.InOrder(
+			mockRepo.EXPECT().ResolveByID(gomock.Any(), productID).Return(stored, nil),
+			mockRepo.EXPECT().UpdateProductStock(gomock.Any(), productID, float64(4)).Return(nil),
+			mockRepo.EXPECT().ResolveByID(gomock.Any(), productID).Return(updated, nil),
+		)
+
+		_, err := repo.ResolveByID(context.Background(), productID)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.UpdateProductStock(context.Background(), productID, 4))
+		assert.False(t, server.Exists("product:"+productID.String()))
+
+		actual, err := repo.ResolveByID(context.Background(), productID)
+		assert.NoError(t, err)
+		assert.Equal(t, float64(4), actual.Stock)
+	})
+
+	t.Run("Skip Caching Loads Invalidated Meanwhile", func(t *testing.T) {
+		repo, mockRepo, server := newCachedRepository(t)
+		mockRepo.EXPECT().ResolveByID(gomock.Any(), productID).DoAndReturn(func(ctx context.Context, id uuid.UUID) (product.Product, error) {
+			// the stock changes while the old product is being loaded
+			_, err := server.Incr("product:gen:"+productID.String(), 1)
+			return stored, err
+		})
+
+		actual, err := repo.ResolveByID(context.Background(), productID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, stored.Name, actual.Name)
+		assert.False(t, server.Exists("product:"+productID.String()))
+	})
+
+	t.Run("Load Outlives Cancelled Caller", func(t *testing.T) {
+		repo, mockRepo, _ := newCachedRepository(t)
+		release := make(chan struct{})
+		mockRepo.EXPECT().ResolveByID(gomock.Any(), productID).DoAndReturn(func(ctx context.Context, id uuid.UUID) (product.Product, error) {
+			<-release
+			return stored, ctx.Err()
+		}).Times(1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		first := make(chan error)
+		go func() {
+			_, err := repo.ResolveByID(ctx, productID)
+			first <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		second := make(chan product.Product)
+		go func() {
+			actual, err := repo.ResolveByID(context.Background(), productID)
+			assert.NoError(t, err)
+			second <- actual
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		cancel()
+		assert.ErrorIs(t, <-first, context.Canceled)
+		close(release)
+		assert.Equal(t, stored.Name, (<-second).Name)
+	})
+
+	t.Run("Invalidate List Pages On Create", func(t *testing.T) {
+		repo, mockRepo, _ := newCachedRepository(t)
+		created := product.Product{ProductID: uuid.Must(uuid.NewV4()), Name: "New Product"}
+		gomock.InOrder(
+			mockRepo.EXPECT().ResolveProduct(gomock.Any(), 10, 0).Return([]product.Product{stored}, nil),
+			mockRepo.EXPECT().Create(gomock.Any(), created).Return(nil),
+			mockRepo.EXPECT().ResolveProduct(gomock.Any(), 10, 0).Return([]product.Product{stored, created}, nil),
+		)
+
+		first, err := repo.ResolveProduct(context.Background(), 10, 0)
+		assert.NoError(t, err)
+		cached, err := repo.ResolveProduct(context.Background(), 10, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, first, cached)
+
+		assert.NoError(t, repo.Create(context.Background(), created))
+
+		actual, err := repo.ResolveProduct(context.Background(), 10, 0)
+		assert.NoError(t, err)
+		assert.Len(t, actual, 2)
+	})
+
+	t.Run("Fall Back When Redis Is Down", func(t *testing.T) {
+		repo, mockRepo, server := newCachedRepository(t)
+		server.Close()
+		mockRepo.EXPECT().ResolveByID(gomock.Any(), productID).Return(stored, nil).Times(2)
+
+		for i := 0; i < 2; i++ {
+			actual, err := repo.ResolveByID(context.Background(), productID)
+			assert.NoError(t, err)
+			assert.Equal(t, stored.Name, actual.Name)
+		}
+	})
+}
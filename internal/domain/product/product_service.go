@@ -3,6 +3,7 @@ package product
 //go:generate go run github.com/golang/mock/mockgen -source product_service.go -destination mock/product_service_mock.go -package product_mock
 
 import (
+	"context"
 	"fmt"
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/shared/failure"
@@ -10,10 +11,10 @@ import (
 )
 
 type ProductService interface {
-	Create(requestFormat ProductRequestFormat, userID uuid.UUID) (product Product, err error)
-	CreateCategory(requestFormat CategoriesRequestFormat, userID uuid.UUID) (prodCategory ProductCategories, err error)
-	ResolveProduct(limit, page int) (product []Product, err error)
-	ResolveProductByCategory(limit, page int, categoryName string) (product []Product, err error)
+	Create(ctx context.Context, requestFormat ProductRequestFormat, userID uuid.UUID) (product Product, err error)
+	CreateCategory(ctx context.Context, requestFormat CategoriesRequestFormat, userID uuid.UUID) (prodCategory ProductCategories, err error)
+	ResolveProduct(ctx context.Context, limit, page int) (product []Product, err error)
+	ResolveProductByCategory(ctx context.Context, limit, page int, categoryName string) (product []Product, err error)
 }
 
 type ProductServiceImpl struct {
@@ -25,47 +26,40 @@ func ProvideProductServiceImpl(productRepository ProductRepository, config *conf
 	return &ProductServiceImpl{ProductRepository: productRepository, Config: config}
 }
 
-func (p *ProductServiceImpl) Create(requestFormat ProductRequestFormat, userID uuid.UUID) (product Product, err error) {
+func (p *ProductServiceImpl) Create(ctx context.Context, requestFormat ProductRequestFormat, userID uuid.UUID) (product Product, err error) {
 	product, err = product.ProductRequestFormat(requestFormat, userID)
 	if err != nil {
-		return
-	}
-	if err != nil {
-		return product, failure.BadRequest(err)
+		return product, failure.InternalError(err)
 	}
-	err = p.ProductRepository.Create(product)
+	err = p.ProductRepository.Create(ctx, product)
 	if err != nil {
 		return
 	}
 	return
 }
 
-func (p *ProductServiceImpl) ResolveProduct(limit, page int) (product []Product, err error) {
-	product, err = p.ProductRepository.ResolveProduct(limit, page)
+func (p *ProductServiceImpl) ResolveProduct(ctx context.Context, limit, page int) (product []Product, err error) {
+	product, err = p.ProductRepository.ResolveProduct(ctx, limit, page)
 	if err != nil {
 		return nil, err
 	}
 	return
 }
 
-func (p *ProductServiceImpl) ResolveProductByCategory(limit, page int, categoryName string) (product []Product, err error) {
-	product, err = p.ProductRepository.ResolveProductByCategory(limit, page, categoryName)
+func (p *ProductServiceImpl) ResolveProductByCategory(ctx context.Context, limit, page int, categoryName string) (product []Product, err error) {
+	product, err = p.ProductRepository.ResolveProductByCategory(ctx, limit, page, categoryName)
 	if err != nil {
-
-		return nil, fmt.Errorf("data Tidak %s", err)
+		return nil, fmt.Errorf("failed resolving products of category %s: %w", categoryName, err)
 	}
 	return
 }
 
-func (p *ProductServiceImpl) CreateCategory(requestFormat CategoriesRequestFormat, userID uuid.UUID) (prodCategory ProductCategories, err error) {
+func (p *ProductServiceImpl) CreateCategory(ctx context.Context, requestFormat CategoriesRequestFormat, userID uuid.UUID) (prodCategory ProductCategories, err error) {
 	prodCategory, err = prodCategory.CategoryRequestFormat(requestFormat, userID)
 	if err != nil {
-		return
-	}
-	if err != nil {
-		return prodCategory, failure.BadRequest(err)
+		return prodCategory, failure.InternalError(err)
 	}
-	err = p.ProductRepository.CreateCategory(prodCategory)
+	err = p.ProductRepository.CreateCategory(ctx, prodCategory)
 	if err != nil {
 		return
 	}
@@ -27,13 +27,13 @@ type Users struct {
 
 type (
 	UserRequestFormat struct {
-		Username string `json:"username"  validate:"required"`
-		Email    string `json:"email"  validate:"required"`
-		Password string `json:"password"  validate:"required"`
-		Role     string `json:"role"  validate:"required"`
+		Username string `json:"username"  validate:"required,max=255"`
+		Email    string `json:"email"  validate:"required,email,max=255"`
+		Password string `json:"password"  validate:"required,min=8,max=72"`
+		Role     string `json:"role"  validate:"required,max=20"`
 	}
 	LoginRequestFormat struct {
-		Email    string `json:"email" validate:"required"`
+		Email    string `json:"email" validate:"required,email"`
 		Password string `json:"password" validate:"required"`
 	}
 	UserResponseFormat struct {
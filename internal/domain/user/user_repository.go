@@ -1,6 +1,7 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/shared/failure"
@@ -40,9 +41,9 @@ var (
 )
 
 type UserRepository interface {
-	Create(user Users) (err error)
-	ExistsByID(id uuid.UUID) (exists bool, err error)
-	ResolveByEmail(email string) (user Users, err error)
+	Create(ctx context.Context, user Users) (err error)
+	ExistsByID(ctx context.Context, id uuid.UUID) (exists bool, err error)
+	ResolveByEmail(ctx context.Context, email string) (user Users, err error)
 }
 
 type UserRepositoryMySQL struct {
@@ -53,67 +54,69 @@ func ProvideUserRepositoryMysql(db *infras.MySQLConn) *UserRepositoryMySQL {
 	return &UserRepositoryMySQL{DB: db}
 }
 
-func (u *UserRepositoryMySQL) Create(user Users) (err error) {
-	exists, err := u.ExistsByID(user.ID)
+func (u *UserRepositoryMySQL) Create(ctx context.Context, user Users) (err error) {
+	exists, err := u.ExistsByID(ctx, user.ID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	if exists {
-		err = failure.NotFound("users")
-		logger.ErrorWithStack(err)
+		err = failure.New(failure.CodeUserAlreadyExists, "user already exists")
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
-	isAvailble, err := u.checkEmail(user.Email)
+	isAvailble, err := u.checkEmail(ctx, user.Email)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	if !isAvailble {
 		log.Info().Msg("Email has been used")
-		return
+		return failure.New(failure.CodeUserEmailTaken, "email is already registered")
 	}
 
 	if !isValidEmail(user.Email) {
 		log.Info().Msg("Invalid Email")
-		return
+		return failure.BadRequestFromString("email is invalid")
 	}
 
-	err = u.insertUser(user)
+	err = u.insertUser(ctx, user)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
 }
 
-func (u *UserRepositoryMySQL) ExistsByID(id uuid.UUID) (exists bool, err error) {
-	err = u.DB.Read.Get(
+func (u *UserRepositoryMySQL) ExistsByID(ctx context.Context, id uuid.UUID) (exists bool, err error) {
+	err = u.DB.Reader(ctx).GetContext(
+		ctx,
 		&exists,
 		"SELECT COUNT(user_id) FROM users u WHERE u.user_id = ?",
 		id.String())
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
 }
 
-func (u *UserRepositoryMySQL) ResolveByEmail(email string) (user Users, err error) {
-	err = u.DB.Read.Get(
+func (u *UserRepositoryMySQL) ResolveByEmail(ctx context.Context, email string) (user Users, err error) {
+	err = u.DB.Reader(ctx).GetContext(
+		ctx,
 		&user,
 		usersQueries.selectUsers+" WHERE u.email = ?", email)
 	if err != nil && err == sql.ErrNoRows {
-		err = failure.NotFound("users")
-		logger.ErrorWithStack(err)
+		err = failure.New(failure.CodeUserNotFound, "user")
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	return
 }
 
-func (u *UserRepositoryMySQL) checkEmail(email string) (bool, error) {
+func (u *UserRepositoryMySQL) checkEmail(ctx context.Context, email string) (bool, error) {
 	var count int
-	err := u.DB.Read.Get(&count, "SELECT COUNT(*) FROM users WHERE email = ?", email)
+	err := u.DB.Reader(ctx).GetContext(ctx, &count, "SELECT COUNT(*) FROM users WHERE email = ?", email)
 	if err != nil {
 		return false, err
 	}
@@ -131,13 +134,13 @@ func isValidEmail(email string) bool {
 	return regex.MatchString(email)
 }
 
-func (u *UserRepositoryMySQL) insertUser(user Users) error {
-	stmt, err := u.DB.Write.PrepareNamed(usersQueries.insertUsers)
+func (u *UserRepositoryMySQL) insertUser(ctx context.Context, user Users) error {
+	stmt, err := u.DB.Writer(ctx).PrepareNamedContext(ctx, usersQueries.insertUsers)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(user)
+	_, err = stmt.ExecContext(ctx, user)
 	return err
 }
@@ -1,14 +1,15 @@
 package user
 
 import (
+	"context"
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/gofrs/uuid"
 )
 
 type UserService interface {
-	Create(requestFormat UserRequestFormat, userID uuid.UUID) (user Users, err error)
-	Login(requestFormat LoginRequestFormat) (user Users, err error)
+	Create(ctx context.Context, requestFormat UserRequestFormat, userID uuid.UUID) (user Users, err error)
+	Login(ctx context.Context, requestFormat LoginRequestFormat) (user Users, err error)
 }
 
 type UserServiceImpl struct {
@@ -20,29 +21,25 @@ func ProvideUserServiceImpl(userRepository UserRepository, config *configs.Confi
 	return &UserServiceImpl{UserRepository: userRepository, Config: config}
 }
 
-func (u *UserServiceImpl) Create(requestFormat UserRequestFormat, userID uuid.UUID) (user Users, err error) {
+func (u *UserServiceImpl) Create(ctx context.Context, requestFormat UserRequestFormat, userID uuid.UUID) (user Users, err error) {
 	user, err = user.UsersRequestFormat(requestFormat, userID)
 	if err != nil {
-		return
+		return user, failure.InternalError(err)
 	}
-	if err != nil {
-		return user, failure.BadRequest(err)
-
-	}
-	err = u.UserRepository.Create(user)
+	err = u.UserRepository.Create(ctx, user)
 	if err != nil {
 		return
 	}
 	return
 }
-func (u *UserServiceImpl) Login(requestFormat LoginRequestFormat) (user Users, err error) {
+func (u *UserServiceImpl) Login(ctx context.Context, requestFormat LoginRequestFormat) (user Users, err error) {
 	user, err = user.LoginRequestFormat(requestFormat)
 	if err != nil {
 		return
 	}
-	user, err = u.UserRepository.ResolveByEmail(user.Email)
-	if err != nil {
-		return user, failure.BadRequest(err)
+	user, err = u.UserRepository.ResolveByEmail(ctx, user.Email)
+	if failure.HasCode(err, failure.CodeUserNotFound) {
+		return user, failure.Wrap(err, failure.CodeUserInvalidCredentials, "invalid email or password")
 	}
 	return
 }
@@ -1,11 +1,11 @@
 package handlers
 
 import (
-	"encoding/json"
 	"github.com/evermos/boilerplate-go/internal/domain/cart"
-	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/auth"
 	"github.com/evermos/boilerplate-go/shared/failure"
-	"github.com/evermos/boilerplate-go/shared/jwt"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/evermos/boilerplate-go/transport/http/request"
 	"github.com/evermos/boilerplate-go/transport/http/response"
 	"github.com/go-chi/chi"
 	"github.com/gofrs/uuid"
@@ -13,18 +13,20 @@ import (
 )
 
 type CartHandler struct {
-	CartService cart.CartService
+	CartService    cart.CartService
+	Authentication *middleware.Authentication
+	RateLimiter    *middleware.RateLimiter
 }
 
-func ProvideCartHandler(cartService cart.CartService) CartHandler {
-	return CartHandler{CartService: cartService}
+func ProvideCartHandler(cartService cart.CartService, authentication *middleware.Authentication, rateLimiter *middleware.RateLimiter) CartHandler {
+	return CartHandler{CartService: cartService, Authentication: authentication, RateLimiter: rateLimiter}
 }
 
 func (h *CartHandler) Router(r chi.Router) {
 	r.Route("/cart", func(r chi.Router) {
-		r.Use(jwt.AuthMiddleware)
+		r.Use(h.Authentication.Authenticate, middleware.RequireUser)
 		r.Post("/add", h.AddToCart)
-		r.Post("/checkout", h.Checkout)
+		r.With(h.RateLimiter.Limit(middleware.RateLimitCheckout)).Post("/checkout", h.Checkout)
 		r.Get("/{id}", h.GetCartByID)
 
 	})
@@ -43,25 +45,18 @@ func (h *CartHandler) Router(r chi.Router) {
 // @Failure 500 {object} response.Base
 // @Router /v1/cart/add [post]
 func (h *CartHandler) AddToCart(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
 	var requestFormat cart.AddToCartRequestFormat
-	err := decoder.Decode(&requestFormat)
+	err := request.Decode(w, r, &requestFormat)
 	if err != nil {
-		response.WithError(w, failure.BadRequest(err))
-		return
-	}
-
-	err = shared.GetValidator().Struct(requestFormat)
-	if err != nil {
-		response.WithError(w, failure.BadRequest(err))
+		response.WithError(w, err)
 		return
 	}
-	claims, ok := r.Context().Value("claims").(*jwt.Claims)
+	userID, ok := auth.UserIDFromContext(r.Context())
 	if !ok {
-		http.Error(w, "Error Claims", http.StatusUnauthorized)
+		response.WithError(w, failure.Unauthorized("user is not authenticated"))
 		return
 	}
-	cart, err := h.CartService.AddItemToCart(requestFormat, claims.ID)
+	cart, err := h.CartService.AddItemToCart(r.Context(), requestFormat, userID)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -79,22 +74,22 @@ func (h *CartHandler) AddToCart(w http.ResponseWriter, r *http.Request) {
 // @Success 201 {object} response.Base{data=cart.OrderResponse}
 // @Failure 400 {object} response.Base
 // @Failure 409 {object} response.Base
+// @Failure 429 {object} response.Base
 // @Failure 500 {object} response.Base
 // @Router /v1/cart/checkout [post]
 func (h *CartHandler) Checkout(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
 	var requestFormat cart.CheckoutRequestFormat
-	err := decoder.Decode(&requestFormat)
+	err := request.Decode(w, r, &requestFormat)
 	if err != nil {
-		response.WithError(w, failure.BadRequest(err))
+		response.WithError(w, err)
 		return
 	}
-	claims, ok := r.Context().Value("claims").(*jwt.Claims)
+	userID, ok := auth.UserIDFromContext(r.Context())
 	if !ok {
-		http.Error(w, "Error Claims", http.StatusUnauthorized)
+		response.WithError(w, failure.Unauthorized("user is not authenticated"))
 		return
 	}
-	checkout, err := h.CartService.CheckoutCarts(requestFormat, claims.ID)
+	checkout, err := h.CartService.CheckoutCarts(r.Context(), requestFormat, userID)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -123,13 +118,14 @@ func (h *CartHandler) GetCartByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, ok := r.Context().Value("claims").(*jwt.Claims)
+	userID, ok := auth.UserIDFromContext(r.Context())
 	if !ok {
-		http.Error(w, "Error claims", http.StatusUnauthorized)
+		response.WithError(w, failure.Unauthorized("user is not authenticated"))
 		return
 	}
-	cart, err := h.CartService.ResolveCartByID(id, claims.ID)
+	cart, err := h.CartService.ResolveCartByID(r.Context(), id, userID)
 	if err != nil {
+		response.WithError(w, err)
 		return
 	}
 
@@ -1,14 +1,15 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
 	"strconv"
 
 	"github.com/evermos/boilerplate-go/internal/domain/foobarbaz"
-	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/auth"
 	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/oauth"
 	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/evermos/boilerplate-go/transport/http/request"
 	"github.com/evermos/boilerplate-go/transport/http/response"
 	"github.com/go-chi/chi"
 	"github.com/gofrs/uuid"
@@ -33,11 +34,12 @@ func (h *FooBarBazHandler) Router(r chi.Router) {
 	r.Route("/foobarbaz", func(r chi.Router) {
 		r.Group(func(r chi.Router) {
 			r.Use(h.AuthMiddleware.ClientCredential)
-			r.Get("/foo/{id}", h.ResolveFooByID)
+			r.With(middleware.RequireScopes(oauth.ScopeFooRead)).Get("/foo/{id}", h.ResolveFooByID)
 		})
 
 		r.Group(func(r chi.Router) {
 			r.Use(h.AuthMiddleware.Password)
+			r.Use(middleware.RequireScopes(oauth.ScopeFooWrite))
 			r.Post("/foo", h.CreateFoo)
 			r.Delete("/foo/{id}", h.SoftDeleteFoo)
 			r.Put("/foo/{id}", h.UpdateFoo)
@@ -55,27 +57,25 @@ func (h *FooBarBazHandler) Router(r chi.Router) {
 // @Produce json
 // @Success 201 {object} response.Base{data=foobarbaz.FooResponseFormat}
 // @Failure 400 {object} response.Base
+// @Failure 403 {object} response.Base
 // @Failure 409 {object} response.Base
 // @Failure 500 {object} response.Base
 // @Router /v1/foobarbaz/foo [post]
 func (h *FooBarBazHandler) CreateFoo(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
 	var requestFormat foobarbaz.FooRequestFormat
-	err := decoder.Decode(&requestFormat)
+	err := request.Decode(w, r, &requestFormat)
 	if err != nil {
-		response.WithError(w, failure.BadRequest(err))
+		response.WithError(w, err)
 		return
 	}
 
-	err = shared.GetValidator().Struct(requestFormat)
-	if err != nil {
-		response.WithError(w, failure.BadRequest(err))
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("user is not authenticated"))
 		return
 	}
 
-	userID, _ := uuid.NewV4() // TODO: read from context
-
-	foo, err := h.FooService.Create(requestFormat, userID)
+	foo, err := h.FooService.Create(r.Context(), requestFormat, userID)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -94,6 +94,7 @@ func (h *FooBarBazHandler) CreateFoo(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Success 200 {object} response.Base{data=foobarbaz.FooResponseFormat}
 // @Failure 400 {object} response.Base
+// @Failure 403 {object} response.Base
 // @Failure 404 {object} response.Base
 // @Failure 500 {object} response.Base
 // @Router /v1/foobarbaz/foo/{id} [get]
@@ -107,7 +108,7 @@ func (h *FooBarBazHandler) ResolveFooByID(w http.ResponseWriter, r *http.Request
 
 	withItems, _ := strconv.ParseBool(r.URL.Query().Get("withItems"))
 
-	foo, err := h.FooService.ResolveByID(id, withItems)
+	foo, err := h.FooService.ResolveByID(r.Context(), id, withItems)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -126,6 +127,7 @@ func (h *FooBarBazHandler) ResolveFooByID(w http.ResponseWriter, r *http.Request
 // @Produce json
 // @Success 200 {object} response.Base{data=foobarbaz.FooResponseFormat}
 // @Failure 400 {object} response.Base
+// @Failure 403 {object} response.Base
 // @Failure 409 {object} response.Base
 // @Failure 500 {object} response.Base
 // @Router /v1/foobarbaz/foo/{id} [delete]
@@ -137,9 +139,13 @@ func (h *FooBarBazHandler) SoftDeleteFoo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userID, _ := uuid.NewV4() // TODO: read from context
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("user is not authenticated"))
+		return
+	}
 
-	foo, err := h.FooService.SoftDelete(id, userID)
+	foo, err := h.FooService.SoftDelete(r.Context(), id, userID)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -158,6 +164,7 @@ func (h *FooBarBazHandler) SoftDeleteFoo(w http.ResponseWriter, r *http.Request)
 // @Produce json
 // @Success 200 {object} response.Base{data=foobarbaz.FooResponseFormat}
 // @Failure 400 {object} response.Base
+// @Failure 403 {object} response.Base
 // @Failure 409 {object} response.Base
 // @Failure 500 {object} response.Base
 // @Router /v1/foobarbaz/foo/{id} [put]
@@ -169,23 +176,20 @@ func (h *FooBarBazHandler) UpdateFoo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	var requestFormat foobarbaz.FooRequestFormat
-	err = decoder.Decode(&requestFormat)
+	err = request.Decode(w, r, &requestFormat)
 	if err != nil {
-		response.WithError(w, failure.BadRequest(err))
+		response.WithError(w, err)
 		return
 	}
 
-	err = shared.GetValidator().Struct(requestFormat)
-	if err != nil {
-		response.WithError(w, failure.BadRequest(err))
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("user is not authenticated"))
 		return
 	}
 
-	userID, _ := uuid.NewV4() // TODO: read from context
-
-	foo, err := h.FooService.Update(id, requestFormat, userID)
+	foo, err := h.FooService.Update(r.Context(), id, requestFormat, userID)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/evermos/boilerplate-go/shared/jwt"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/evermos/boilerplate-go/transport/http/request"
+	"github.com/go-chi/chi"
+	"github.com/go-redis/redis"
+)
+
+const contentTypeForm = "application/x-www-form-urlencoded"
+
+// OAuthHandler is the authorization server of RFC 6749, issuing tokens to
+// the clients of oauth_clients, and introspecting and revoking them and the
+// JWTs of the users for the other services.
+type OAuthHandler struct {
+	Token       *oauth.Token
+	Revocations *jwt.RevocationList
+	RateLimiter *middleware.RateLimiter
+}
+
+func ProvideOAuthHandler(db *infras.MySQLConn, cache *redis.Client, tokens oauth.TokenStore, config *configs.Config, rateLimiter *middleware.RateLimiter) OAuthHandler {
+	token := oauth.New(db.Write, tokens, oauth.Config{
+		Expiration:        config.OAuth.AccessTokenTTLSeconds,
+		RefreshExpiration: config.OAuth.RefreshTokenTTLSeconds,
+		CodeExpiration:    config.OAuth.AuthorizationCodeTTLSeconds,
+	})
+	return OAuthHandler{Token: token, Revocations: jwt.NewRevocationList(cache), RateLimiter: rateLimiter}
+}
+
+func (h *OAuthHandler) Router(r chi.Router) {
+	r.Route("/oauth", func(r chi.Router) {
+		// users authorize clients signing in with their password, or through
+		// an app holding their JWT, never the clients themselves
+		r.Get("/authorize", h.Authorize)
+		r.With(h.RateLimiter.Limit(middleware.RateLimitLogin)).Post("/authorize", h.Authorize)
+		r.With(h.RateLimiter.Limit(middleware.RateLimitLogin)).Post("/token", h.CreateToken)
+		// introspection and revocation authenticate the client as well, so
+		// they're limited against brute force and hashing load
+		limitIntrospection := h.RateLimiter.Limit(middleware.RateLimitIntrospection)
+		r.With(limitIntrospection).Post("/introspect", h.Introspect)
+		r.With(limitIntrospection).Post("/revoke", h.Revoke)
+	})
+}
+
+// CreateToken issues an access token
+// @Summary Issue an access token
+// @Description this endpoint issues an access token following RFC 6749, authenticating the client with HTTP Basic or the client_id and client_secret parameters. Password grants also issue a refresh token to the clients allowed the refresh_token grant, rotated on use
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Param grant_type formData string true "client_credentials, password, refresh_token or authorization_code"
+// @Param client_id formData string false "The client, unless authenticated with HTTP Basic"
+// @Param client_secret formData string false "The secret of the client, unless authenticated with HTTP Basic"
+// @Param username formData string false "The email of the user, for the password grant"
+// @Param password formData string false "The password of the user, for the password grant"
+// @Param refresh_token formData string false "The refresh token, for the refresh_token grant"
+// @Param code formData string false "The authorization code, for the authorization_code grant"
+// @Param redirect_uri formData string false "The redirect URI of the authorization request, if it had one"
+// @Param code_verifier formData string false "The PKCE code verifier, for the authorization_code grant"
+// @Produce json
+// @Success 200 {object} oauth.TokenResponse
+// @Failure 400 {object} oauth.Error
+// @Failure 401 {object} oauth.Error
+// @Failure 429 {object} response.Base
+// @Failure 500 {object} oauth.Error
+// @Router /oauth/token [post]
+func (h *OAuthHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	credential, err := tokenCredential(w, r)
+	if err != nil {
+		withOAuthError(w, r, err)
+		return
+	}
+
+	token, err := h.Token.Create(r.Context(), credential)
+	if err != nil {
+		withOAuthError(w, r, err)
+		return
+	}
+
+	withOAuthJSON(w, http.StatusOK, token)
+}
+
+// Authorize issues an authorization code
+// @Summary Authorize a client
+// @Description this endpoint issues an authorization code of a user to a client following RFC 6749 with PKCE S256, redirecting the user to the redirect URI of the client with the code or the error. Browsers get a page showing the client and its scopes, where the user signs in with their email and password to consent, posted back to the same URL. Apps holding the JWT of the user may send it as a bearer token instead. Invalid clients and redirect URIs are not redirected to
+// @Tags oauth
+// @Security JWTAuthentication
+// @Accept x-www-form-urlencoded
+// @Param response_type query string true "code"
+// @Param client_id query string true "The client"
+// @Param redirect_uri query string false "One of the redirect URIs of the client, required if it has several"
+// @Param scope query string false "The space separated scopes, the scopes of the client if omitted"
+// @Param state query string false "Sent back to the client as is"
+// @Param code_challenge query string true "The PKCE code challenge"
+// @Param code_challenge_method query string true "S256"
+// @Param email formData string false "The email of the user signing in"
+// @Param password formData string false "The password of the user signing in"
+// @Produce html
+// @Success 200 {string} string "The sign in page"
+// @Success 302
+// @Failure 400 {object} oauth.Error
+// @Failure 401 {string} string "The sign in page, with the error"
+// @Failure 429 {object} response.Base
+// @Failure 500 {object} oauth.Error
+// @Router /oauth/authorize [get]
+// @Router /oauth/authorize [post]
+func (h *OAuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		withOAuthError(w, r, oauth.NewError(oauth.ErrorCodeInvalidRequest, "malformed request"))
+		return
+	}
+	for name, values := range r.Form {
+		if len(values) > 1 {
+			withOAuthError(w, r, oauth.NewError(oauth.ErrorCodeInvalidRequest, name+" is repeated"))
+			return
+		}
+	}
+
+	// the authorization is read from the query only, as the sign in page
+	// posts the credentials of the user back to the same URL
+	query := r.URL.Query()
+	authorization, err := h.Token.ValidateAuthorization(r.Context(), oauth.AuthorizationRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	})
+	if authorization.RedirectURI == "" {
+		withOAuthError(w, r, err)
+		return
+	}
+	if err != nil {
+		redirectAuthorization(w, r, authorization.RedirectURI, "", err)
+		return
+	}
+
+	userID, ok, err := h.authenticateUser(r)
+	if err != nil {
+		withOAuthError(w, r, err)
+		return
+	}
+	if !ok {
+		status, message := http.StatusOK, ""
+		if r.Method == http.MethodPost {
+			status, message = http.StatusUnauthorized, "Invalid email or password."
+		}
+		withSignInPage(w, status, signInPage{Authorization: authorization, Email: r.PostForm.Get("email"), Error: message})
+		return
+	}
+
+	code, err := h.Token.Authorize(r.Context(), authorization, userID)
+	redirectAuthorization(w, r, authorization.RedirectURI, code, err)
+}
+
+// authenticateUser authenticates the user authorizing a client, by the JWT
+// of an app acting for them or by the credentials posted from the sign in
+// page. Neither being valid isn't an error.
+func (h *OAuthHandler) authenticateUser(r *http.Request) (userID string, ok bool, err error) {
+	principal, err := jwt.NewAuthenticator(h.Revocations).Authenticate(r)
+	switch {
+	case err == nil && principal.HasUser():
+		return principal.UserID.String(), true, nil
+	case err != nil && !errors.Is(err, auth.ErrNoCredentials) && !errors.Is(err, auth.ErrInvalidCredentials):
+		return "", false, err
+	}
+
+	if r.Method != http.MethodPost {
+		return "", false, nil
+	}
+
+	userID, err = h.Token.AuthenticateUser(r.Context(), r.PostForm.Get("email"), r.PostForm.Get("password"))
+	if err != nil {
+		if oauth.AsError(err).Code == oauth.ErrorCodeServerError {
+			return "", false, err
+		}
+		return "", false, nil
+	}
+	return userID, true, nil
+}
+
+// redirectAuthorization redirects the user to the client with the code, or
+// with the error.
+func redirectAuthorization(w http.ResponseWriter, r *http.Request, redirectURI string, code string, err error) {
+	params := url.Values{}
+	if err != nil {
+		oauthErr := oauth.AsError(err)
+		if oauthErr.Code == oauth.ErrorCodeServerError {
+			logger.ErrorWithStackContext(r.Context(), err)
+		}
+		params.Set("error", oauthErr.Code)
+		params.Set("error_description", oauthErr.Description)
+	} else {
+		params.Set("code", code)
+	}
+	if state := r.URL.Query().Get("state"); state != "" {
+		params.Set("state", state)
+	}
+
+	location, err := url.Parse(redirectURI)
+	if err != nil {
+		withOAuthError(w, r, err)
+		return
+	}
+	query := location.Query()
+	for name := range params {
+		query.Set(name, params.Get(name))
+	}
+	location.RawQuery = query.Encode()
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, location.String(), http.StatusFound)
+}
+
+// signInPage is where the user signs in to authorize a client.
+type signInPage struct {
+	oauth.Authorization
+	Email string
+	Error string
+}
+
+// Scopes lists the scopes asked by the client.
+func (p signInPage) Scopes() []string {
+	return oauth.ParseScopes(p.Scope)
+}
+
+// signInTemplate posts the credentials back to the URL of the authorization
+// request, as the form has no action.
+var signInTemplate = template.Must(template.New("sign-in").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Authorize {{.ClientID}}</title>
+</head>
+<body>
+<main>
+<h1>Authorize {{.ClientID}}</h1>
+<p>Sign in to let {{.ClientID}} access your account.</p>
+{{- with .Scopes}}
+<p>It asks for:</p>
+<ul>
+{{- range .}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- with .Error}}
+<p role="alert">{{.}}</p>
+{{- end}}
+<form method="post">
+<label>Email <input type="email" name="email" value="{{.Email}}" autocomplete="username" required></label>
+<label>Password <input type="password" name="password" autocomplete="current-password" required></label>
+<button type="submit">Sign in and authorize</button>
+</form>
+</main>
+</body>
+</html>
+`))
+
+// withSignInPage sends the sign in page, which must never be cached nor
+// framed by other sites.
+func withSignInPage(w http.ResponseWriter, code int, page signInPage) {
+	var body bytes.Buffer
+	if err := signInTemplate.Execute(&body, page); err != nil {
+		logger.ErrorWithStack(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(code)
+	_, err := w.Write(body.Bytes())
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+}
+
+// Introspect tells the state of a token
+// @Summary Introspect a token
+// @Description this endpoint tells whether an access token, a refresh token or a JWT is active following RFC 7662, for the clients authenticated with HTTP Basic or the client_id and client_secret parameters
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Param token formData string true "The token to introspect"
+// @Param token_type_hint formData string false "access_token or refresh_token"
+// @Produce json
+// @Success 200 {object} oauth.Introspection
+// @Failure 400 {object} oauth.Error
+// @Failure 401 {object} oauth.Error
+// @Failure 429 {object} response.Base
+// @Failure 500 {object} oauth.Error
+// @Router /oauth/introspect [post]
+func (h *OAuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	client, token, err := h.authenticateTokenRequest(w, r)
+	if err != nil {
+		withOAuthError(w, r, err)
+		return
+	}
+
+	// only the resource servers, which keep a secret, may introspect tokens
+	if client.Public() {
+		withOAuthError(w, r, oauth.NewError(oauth.ErrorCodeUnauthorizedClient, oauth.ErrorUnauthorizedClient))
+		return
+	}
+
+	introspection, err := h.introspect(r, token)
+	if err != nil {
+		withOAuthError(w, r, err)
+		return
+	}
+
+	withOAuthJSON(w, http.StatusOK, introspection)
+}
+
+// Revoke revokes a token
+// @Summary Revoke a token
+// @Description this endpoint revokes an access token, a refresh token with its family, or a JWT following RFC 7009, for the clients authenticated with HTTP Basic or the client_id and client_secret parameters. Unknown tokens are ignored
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Param token formData string true "The token to revoke"
+// @Param token_type_hint formData string false "access_token or refresh_token"
+// @Success 200
+// @Failure 400 {object} oauth.Error
+// @Failure 401 {object} oauth.Error
+// @Failure 429 {object} response.Base
+// @Failure 500 {object} oauth.Error
+// @Router /oauth/revoke [post]
+func (h *OAuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	client, token, err := h.authenticateTokenRequest(w, r)
+	if err != nil {
+		withOAuthError(w, r, err)
+		return
+	}
+
+	if claims, jwtErr := jwt.ValidateToken(token); jwtErr == nil {
+		err = h.Revocations.Revoke(r.Context(), token, claims)
+	} else {
+		err = h.Token.Revoke(r.Context(), client, token, r.PostForm.Get("token_type_hint"))
+	}
+	if err != nil {
+		withOAuthError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// introspect introspects a JWT, or an access token or refresh token.
+func (h *OAuthHandler) introspect(r *http.Request, token string) (oauth.Introspection, error) {
+	claims, err := jwt.ValidateToken(token)
+	if err != nil {
+		return h.Token.Introspect(r.Context(), token, r.PostForm.Get("token_type_hint"))
+	}
+
+	revoked, err := h.Revocations.Revoked(r.Context(), token)
+	if err != nil || revoked {
+		return oauth.Introspection{}, err
+	}
+
+	return oauth.Introspection{
+		Active:    true,
+		Username:  claims.Email,
+		TokenType: string(oauth.Bearer),
+		Exp:       claims.ExpiresAt,
+		Sub:       claims.ID.String(),
+		Role:      claims.Role,
+	}, nil
+}
+
+// authenticateTokenRequest authenticates the client of an introspection or
+// revocation request, and reads the token of the request.
+func (h *OAuthHandler) authenticateTokenRequest(w http.ResponseWriter, r *http.Request) (client oauth.OauthClient, token string, err error) {
+	err = parseOAuthForm(w, r)
+	if err != nil {
+		return
+	}
+
+	credential, err := clientCredential(r)
+	if err != nil {
+		return
+	}
+
+	client, err = h.Token.AuthenticateClient(r.Context(), credential)
+	if err != nil {
+		return
+	}
+
+	token = r.PostForm.Get("token")
+	if token == "" {
+		err = oauth.NewError(oauth.ErrorCodeInvalidRequest, "token is required")
+	}
+
+	return
+}
+
+// tokenCredential reads the credential of a token request from its form
+// encoded body, and the client from HTTP Basic or the body.
+func tokenCredential(w http.ResponseWriter, r *http.Request) (credential oauth.Credential, err error) {
+	err = parseOAuthForm(w, r)
+	if err != nil {
+		return
+	}
+
+	credential, err = clientCredential(r)
+	if err != nil {
+		return
+	}
+
+	credential.GrantType = oauth.GrantType(r.PostForm.Get("grant_type"))
+	credential.Username = r.PostForm.Get("username")
+	credential.Password = r.PostForm.Get("password")
+	credential.Scope = r.PostForm.Get("scope")
+	credential.RefreshToken = r.PostForm.Get("refresh_token")
+	credential.Code = r.PostForm.Get("code")
+	credential.RedirectURI = r.PostForm.Get("redirect_uri")
+	credential.CodeVerifier = r.PostForm.Get("code_verifier")
+	if credential.GrantType == "" {
+		err = oauth.NewError(oauth.ErrorCodeInvalidRequest, "grant_type is required")
+	}
+
+	return
+}
+
+// parseOAuthForm parses the form encoded body of an OAuth request.
+func parseOAuthForm(w http.ResponseWriter, r *http.Request) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(request.HeaderContentType))
+	if mediaType != contentTypeForm {
+		return oauth.NewError(oauth.ErrorCodeInvalidRequest, "Content-Type must be "+contentTypeForm)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, request.MaxBodyBytes)
+	if err := r.ParseForm(); err != nil {
+		return oauth.NewError(oauth.ErrorCodeInvalidRequest, "malformed request body")
+	}
+
+	// parameters must not be repeated, RFC 6749 section 3.2
+	for name, values := range r.PostForm {
+		if len(values) > 1 {
+			return oauth.NewError(oauth.ErrorCodeInvalidRequest, name+" is repeated")
+		}
+	}
+
+	return nil
+}
+
+// clientCredential reads the client of a parsed OAuth request from HTTP
+// Basic or the body, which must not be used both.
+func clientCredential(r *http.Request) (credential oauth.Credential, err error) {
+	credential = oauth.Credential{
+		ClientID:     r.PostForm.Get("client_id"),
+		ClientSecret: r.PostForm.Get("client_secret"),
+	}
+
+	if r.Header.Get(middleware.HeaderAuthorization) == "" {
+		return
+	}
+
+	clientID, clientSecret, ok := basicAuth(r)
+	switch {
+	case !ok:
+		err = oauth.NewError(oauth.ErrorCodeInvalidClient, oauth.ErrorInvalidClient)
+	case credential.ClientSecret != "":
+		err = oauth.NewError(oauth.ErrorCodeInvalidRequest, "the client must authenticate with a single method")
+	case credential.ClientID != "" && credential.ClientID != clientID:
+		err = oauth.NewError(oauth.ErrorCodeInvalidRequest, "client_id doesn't match the authenticated client")
+	default:
+		credential.ClientID = clientID
+		credential.ClientSecret = clientSecret
+	}
+
+	return
+}
+
+// basicAuth returns the client of HTTP Basic authentication, whose ID and
+// secret are form encoded, RFC 6749 section 2.3.1.
+func basicAuth(r *http.Request) (clientID string, clientSecret string, ok bool) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", "", false
+	}
+
+	clientID, err := url.QueryUnescape(username)
+	if err != nil {
+		return "", "", false
+	}
+	clientSecret, err = url.QueryUnescape(password)
+	if err != nil {
+		return "", "", false
+	}
+
+	return clientID, clientSecret, true
+}
+
+// withOAuthError sends an OAuth error response, RFC 6749 section 5.2. Any
+// other error is logged and sent as a server_error.
+func withOAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	oauthErr := oauth.AsError(err)
+	if oauthErr.Code == oauth.ErrorCodeServerError {
+		logger.ErrorWithStackContext(r.Context(), err)
+	}
+
+	if oauthErr.Code == oauth.ErrorCodeInvalidClient && r.Header.Get(middleware.HeaderAuthorization) != "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+
+	withOAuthJSON(w, oauthErr.Status(), oauthErr)
+}
+
+// withOAuthJSON sends an OAuth response, which must never be cached.
+func withOAuthJSON(w http.ResponseWriter, code int, payload interface{}) {
+	body, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(code)
+	_, err := w.Write(body)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+}
@@ -0,0 +1,860 @@
+package handlers_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/internal/handlers"
+	"github.com/evermos/boilerplate-go/shared/jwt"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/go-chi/chi"
+	"github.com/go-redis/redis"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	queryClient      = `FROM\s+oauth_clients\s+WHERE client_id = \?`
+	queryUser        = `FROM\s+users\s+WHERE email = \?`
+	queryInsertToken = `INSERT INTO oauth_access_tokens`
+
+	queryRefreshToken        = `FROM\s+oauth_refresh_tokens\s+WHERE refresh_token = \?`
+	queryInsertRefreshToken  = `INSERT INTO oauth_refresh_tokens`
+	queryUseRefreshToken     = `UPDATE oauth_refresh_tokens\s+SET used_at`
+	queryRevokeRefreshTokens = `UPDATE oauth_refresh_tokens\s+SET revoked_at`
+	queryFamilyAccessTokens  = `SELECT access_token FROM oauth_refresh_tokens WHERE family_id = \?`
+
+	queryAccessToken        = `FROM\s+oauth_access_tokens\s+WHERE access_token = \?`
+	queryDeleteAccessTokens = `DELETE FROM oauth_access_tokens WHERE access_token IN \(`
+)
+
+// hashed returns the SHA-256 hash a token or a code is stored as.
+func hashed(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newOAuthHandler(t *testing.T) (handlers.OAuthHandler, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { cache.Close() })
+
+	sqlxdb := sqlx.NewDb(db, "mysql")
+	token := oauth.New(sqlxdb, oauth.NewMySQLTokenStore(sqlxdb), oauth.Config{Expiration: 3600, RefreshExpiration: 86400, CodeExpiration: 60})
+	return handlers.OAuthHandler{
+		Token:       token,
+		Revocations: jwt.NewRevocationList(cache),
+		RateLimiter: middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(), &configs.Config{}),
+	}, mock
+}
+
+// clientSecretHash is the bcrypt hash of 3v3rm0s, the secret of client_web.
+var clientSecretHash, _ = bcrypt.GenerateFromPassword([]byte("3v3rm0s"), bcrypt.MinCost)
+
+func expectClient(mock sqlmock.Sqlmock, grantTypes string) {
+	mock.ExpectQuery(queryClient).WithArgs("client_web").WillReturnRows(
+		sqlmock.NewRows([]string{"client_id", "client_secret", "redirect_uri", "grant_types", "scope"}).
+			AddRow("client_web", string(clientSecretHash), "https://evermos.com/", grantTypes, "user"))
+}
+
+// expectPublicClient expects the query of a single page app, without secret.
+func expectPublicClient(mock sqlmock.Sqlmock, grantTypes string) {
+	mock.ExpectQuery(queryClient).WithArgs("client_spa").WillReturnRows(
+		sqlmock.NewRows([]string{"client_id", "client_secret", "redirect_uri", "grant_types", "scope"}).
+			AddRow("client_spa", "", "https://shop.evermos.com/callback https://shop.evermos.com/silent", grantTypes, "user orders"))
+}
+
+func requestToken(h handlers.OAuthHandler, form url.Values, prepare func(r *http.Request)) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if prepare != nil {
+		prepare(r)
+	}
+
+	w := httptest.NewRecorder()
+	h.CreateToken(w, r)
+	return w
+}
+
+func oauthError(t *testing.T, w *httptest.ResponseRecorder) string {
+	var body oauth.Error
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body.Code
+}
+
+func TestCreateToken(t *testing.T) {
+	t.Run("Client Credentials With HTTP Basic", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectClient(mock, "client_credentials password")
+		mock.ExpectExec(queryInsertToken).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := requestToken(h, url.Values{"grant_type": {"client_credentials"}}, func(r *http.Request) {
+			r.SetBasicAuth("client_web", "3v3rm0s")
+		})
+
+		var token oauth.TokenResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.Len(t, token.AccessToken, 40)
+		assert.Equal(t, "Bearer", token.TokenType)
+		assert.Equal(t, int64(3600), token.ExpiresIn)
+		assert.Equal(t, "user", token.Scope, "the scopes of the client by default")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Scopes Narrowed To Client", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectClient(mock, "client_credentials")
+		mock.ExpectExec(queryInsertToken).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := requestToken(h, url.Values{"grant_type": {"client_credentials"}, "scope": {"admin user"}}, func(r *http.Request) {
+			r.SetBasicAuth("client_web", "3v3rm0s")
+		})
+
+		var token oauth.TokenResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "user", token.Scope)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Scope Not Allowed For Client", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectClient(mock, "client_credentials")
+
+		w := requestToken(h, url.Values{"grant_type": {"client_credentials"}, "scope": {"admin"}}, func(r *http.Request) {
+			r.SetBasicAuth("client_web", "3v3rm0s")
+		})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, oauth.ErrorCodeInvalidScope, oauthError(t, w))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Password With Client In Body", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		hash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+		expectClient(mock, "client_credentials password")
+		mock.ExpectQuery(queryUser).WithArgs("budi@example.com").WillReturnRows(
+			sqlmock.NewRows([]string{"user_id", "username", "password"}).
+				AddRow("6ba7b810-9dad-11d1-80b4-00c04fd430c8", "budi", string(hash)))
+		mock.ExpectExec(queryInsertToken).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := requestToken(h, url.Values{
+			"grant_type":    {"password"},
+			"client_id":     {"client_web"},
+			"client_secret": {"3v3rm0s"},
+			"username":      {"budi@example.com"},
+			"password":      {"secret-password"},
+		}, nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid Password", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		hash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+		expectClient(mock, "password")
+		mock.ExpectQuery(queryUser).WillReturnRows(
+			sqlmock.NewRows([]string{"user_id", "username", "password"}).
+				AddRow("6ba7b810-9dad-11d1-80b4-00c04fd430c8", "budi", string(hash)))
+
+		w := requestToken(h, url.Values{
+			"grant_type": {"password"},
+			"username":   {"budi@example.com"},
+			"password":   {"wrong-password"},
+		}, func(r *http.Request) {
+			r.SetBasicAuth("client_web", "3v3rm0s")
+		})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, oauth.ErrorCodeInvalidGrant, oauthError(t, w))
+	})
+
+	t.Run("Invalid Client", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectClient(mock, "client_credentials")
+
+		w := requestToken(h, url.Values{"grant_type": {"client_credentials"}}, func(r *http.Request) {
+			r.SetBasicAuth("client_web", "wrong")
+		})
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Basic realm="oauth"`, w.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, oauth.ErrorCodeInvalidClient, oauthError(t, w))
+	})
+
+	t.Run("Previous Secret During Rotation Overlap", func(t *testing.T) {
+		for name, test := range map[string]struct {
+			expires time.Time
+			status  int
+		}{
+			"Overlapping": {time.Now().Add(time.Hour), http.StatusOK},
+			"Expired":     {time.Now().Add(-time.Second), http.StatusUnauthorized},
+		} {
+			h, mock := newOAuthHandler(t)
+			newSecretHash, _ := bcrypt.GenerateFromPassword([]byte("n3w-s3cr3t"), bcrypt.MinCost)
+			mock.ExpectQuery(queryClient).WithArgs("client_web").WillReturnRows(
+				sqlmock.NewRows([]string{"client_id", "client_secret", "previous_client_secret", "previous_client_secret_expires", "grant_types", "scope"}).
+					AddRow("client_web", string(newSecretHash), string(clientSecretHash), test.expires, "client_credentials", "user"))
+			mock.ExpectExec(queryInsertToken).WillReturnResult(sqlmock.NewResult(0, 1))
+
+			w := requestToken(h, url.Values{"grant_type": {"client_credentials"}}, func(r *http.Request) {
+				r.SetBasicAuth("client_web", "3v3rm0s")
+			})
+
+			assert.Equal(t, test.status, w.Code, name)
+		}
+	})
+
+	t.Run("Clear Text Secret Hashed On Use", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		mock.ExpectQuery(queryClient).WithArgs("client_web").WillReturnRows(
+			sqlmock.NewRows([]string{"client_id", "client_secret", "grant_types", "scope"}).
+				AddRow("client_web", "3v3rm0s", "client_credentials", "user"))
+		mock.ExpectExec(`UPDATE oauth_clients\s+SET client_secret = \?`).
+			WithArgs(sqlmock.AnyArg(), "client_web", "3v3rm0s").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(queryInsertToken).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := requestToken(h, url.Values{"grant_type": {"client_credentials"}}, func(r *http.Request) {
+			r.SetBasicAuth("client_web", "3v3rm0s")
+		})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Grant Type Not Allowed For Client", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectClient(mock, "client_credentials")
+
+		w := requestToken(h, url.Values{
+			"grant_type": {"password"},
+			"username":   {"budi@example.com"},
+			"password":   {"secret-password"},
+		}, func(r *http.Request) {
+			r.SetBasicAuth("client_web", "3v3rm0s")
+		})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, oauth.ErrorCodeUnauthorizedClient, oauthError(t, w))
+	})
+
+	t.Run("Unsupported Grant Type", func(t *testing.T) {
+		h, _ := newOAuthHandler(t)
+
+		w := requestToken(h, url.Values{"grant_type": {"implicit"}}, func(r *http.Request) {
+			r.SetBasicAuth("client_web", "3v3rm0s")
+		})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, oauth.ErrorCodeUnsupportedGrantType, oauthError(t, w))
+	})
+
+	t.Run("Invalid Requests", func(t *testing.T) {
+		h, _ := newOAuthHandler(t)
+		basic := func(r *http.Request) { r.SetBasicAuth("client_web", "3v3rm0s") }
+
+		for name, w := range map[string]*httptest.ResponseRecorder{
+			"Missing Grant Type": requestToken(h, url.Values{}, basic),
+			"Repeated Parameter": requestToken(h, url.Values{"grant_type": {"client_credentials", "password"}}, basic),
+			"Two Client Methods": requestToken(h, url.Values{"grant_type": {"client_credentials"}, "client_secret": {"3v3rm0s"}}, basic),
+			"JSON Body":          requestToken(h, url.Values{"grant_type": {"client_credentials"}}, func(r *http.Request) { r.Header.Set("Content-Type", "application/json") }),
+			"Mismatched Client":  requestToken(h, url.Values{"grant_type": {"client_credentials"}, "client_id": {"client_app"}}, basic),
+		} {
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+			assert.Equal(t, oauth.ErrorCodeInvalidRequest, oauthError(t, w), name)
+		}
+	})
+}
+
+func expectRefreshToken(mock sqlmock.Sqlmock, expires time.Time, usedAt interface{}) {
+	mock.ExpectQuery(queryRefreshToken).WithArgs(hashed("old-refresh-token")).WillReturnRows(
+		sqlmock.NewRows([]string{"refresh_token", "family_id", "access_token", "client_id", "user_id", "scope", "expires", "used_at", "revoked_at"}).
+			AddRow(hashed("old-refresh-token"), "family", hashed("old-access-token"), "client_web", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", nil, expires, usedAt, nil))
+}
+
+func expectFamilyRevoked(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec(queryRevokeRefreshTokens).WithArgs(sqlmock.AnyArg(), "family").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(queryFamilyAccessTokens).WithArgs("family").WillReturnRows(
+		sqlmock.NewRows([]string{"access_token"}).AddRow(hashed("first-access-token")).AddRow(hashed("old-access-token")))
+	mock.ExpectCommit()
+	mock.ExpectExec(queryDeleteAccessTokens).WithArgs(hashed("first-access-token"), hashed("old-access-token")).WillReturnResult(sqlmock.NewResult(0, 2))
+}
+
+func refresh(h handlers.OAuthHandler) *httptest.ResponseRecorder {
+	return requestToken(h, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"old-refresh-token"}}, func(r *http.Request) {
+		r.SetBasicAuth("client_web", "3v3rm0s")
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	t.Run("Issued With Password Grant", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		hash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+		expectClient(mock, "password refresh_token")
+		mock.ExpectQuery(queryUser).WillReturnRows(
+			sqlmock.NewRows([]string{"user_id", "username", "password"}).
+				AddRow("6ba7b810-9dad-11d1-80b4-00c04fd430c8", "budi", string(hash)))
+		mock.ExpectExec(queryInsertToken).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(queryInsertRefreshToken).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := requestToken(h, url.Values{
+			"grant_type": {"password"},
+			"username":   {"budi@example.com"},
+			"password":   {"secret-password"},
+		}, func(r *http.Request) {
+			r.SetBasicAuth("client_web", "3v3rm0s")
+		})
+
+		var token oauth.TokenResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, token.RefreshToken, 40)
+		assert.NotEqual(t, token.AccessToken, token.RefreshToken)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Rotated On Use", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectClient(mock, "password refresh_token")
+		expectRefreshToken(mock, time.Now().Add(time.Hour), nil)
+		mock.ExpectExec(queryInsertToken).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec(queryUseRefreshToken).WithArgs(sqlmock.AnyArg(), hashed("old-refresh-token")).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(queryInsertRefreshToken).WithArgs(sqlmock.AnyArg(), "family", sqlmock.AnyArg(), "client_web", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", nil, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		w := refresh(h)
+
+		var token oauth.TokenResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, token.RefreshToken, 40)
+		assert.NotEqual(t, "old-refresh-token", token.RefreshToken)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Reuse Revokes Family", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectClient(mock, "refresh_token")
+		expectRefreshToken(mock, time.Now().Add(time.Hour), time.Now().Add(-time.Minute))
+		expectFamilyRevoked(mock)
+
+		w := refresh(h)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, oauth.ErrorCodeInvalidGrant, oauthError(t, w))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Concurrent Reuse Revokes Family", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectClient(mock, "refresh_token")
+		expectRefreshToken(mock, time.Now().Add(time.Hour), nil)
+		mock.ExpectExec(queryInsertToken).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec(queryUseRefreshToken).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+		mock.ExpectExec(queryDeleteAccessTokens).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectFamilyRevoked(mock)
+
+		w := refresh(h)
+
+		assert.Equal(t, oauth.ErrorCodeInvalidGrant, oauthError(t, w))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectClient(mock, "refresh_token")
+		expectRefreshToken(mock, time.Now().Add(-time.Minute), nil)
+
+		w := refresh(h)
+
+		assert.Equal(t, oauth.ErrorCodeInvalidGrant, oauthError(t, w))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func expectAccessToken(mock sqlmock.Sqlmock, token string, clientID string) {
+	mock.ExpectQuery(queryAccessToken).WithArgs(hashed(token), sqlmock.AnyArg()).WillReturnRows(
+		sqlmock.NewRows([]string{"access_token", "client_id", "user_id", "expires", "scope"}).
+			AddRow(hashed(token), clientID, "6ba7b810-9dad-11d1-80b4-00c04fd430c8", time.Now().Add(time.Hour), nil))
+}
+
+func expectNoToken(mock sqlmock.Sqlmock, token string) {
+	mock.ExpectQuery(queryAccessToken).WithArgs(hashed(token), sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"access_token"}))
+	mock.ExpectQuery(queryRefreshToken).WithArgs(hashed(token)).WillReturnRows(sqlmock.NewRows([]string{"refresh_token"}))
+}
+
+func tokenRequest(h handlers.OAuthHandler, handler func(h handlers.OAuthHandler) http.HandlerFunc, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/oauth/introspect", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth("client_web", "3v3rm0s")
+
+	w := httptest.NewRecorder()
+	handler(h).ServeHTTP(w, r)
+	return w
+}
+
+func introspect(h handlers.OAuthHandler) http.HandlerFunc { return h.Introspect }
+
+func revoke(h handlers.OAuthHandler) http.HandlerFunc { return h.Revoke }
+
+func introspection(t *testing.T, w *httptest.ResponseRecorder) oauth.Introspection {
+	var body oauth.Introspection
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body
+}
+
+func TestIntrospect(t *testing.T) {
+	t.Run("Access Token", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectClient(mock, "client_credentials")
+		expectAccessToken(mock, "access-token", "client_app")
+
+		body := introspection(t, tokenRequest(h, introspect, "access-token"))
+
+		assert.True(t, body.Active)
+		assert.Equal(t, "client_app", body.ClientID)
+		assert.Equal(t, "6ba7b810-9dad-11d1-80b4-00c04fd430c8", body.Sub)
+		assert.Equal(t, "Bearer", body.TokenType)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Token", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectClient(mock, "client_credentials")
+		expectNoToken(mock, "unknown")
+
+		w := tokenRequest(h, introspect, "unknown")
+
+		assert.JSONEq(t, `{"active":false}`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("JWT", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		id := uuid.Must(uuid.NewV4())
+		token, _ := jwt.GenerateJWT(id, "budi@example.com", "admin")
+
+		expectClient(mock, "client_credentials")
+		body := introspection(t, tokenRequest(h, introspect, token))
+
+		assert.True(t, body.Active)
+		assert.Equal(t, id.String(), body.Sub)
+		assert.Equal(t, "budi@example.com", body.Username)
+		assert.Equal(t, "admin", body.Role)
+
+		expectClient(mock, "client_credentials")
+		assert.Equal(t, http.StatusOK, tokenRequest(h, revoke, token).Code)
+
+		expectClient(mock, "client_credentials")
+		assert.False(t, introspection(t, tokenRequest(h, introspect, token)).Active)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unauthenticated Client", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		mock.ExpectQuery(queryClient).WillReturnRows(sqlmock.NewRows([]string{"client_id"}))
+
+		w := tokenRequest(h, introspect, "access-token")
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, oauth.ErrorCodeInvalidClient, oauthError(t, w))
+	})
+}
+
+func TestRevoke(t *testing.T) {
+	t.Run("Access Token", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectClient(mock, "client_credentials")
+		expectAccessToken(mock, "access-token", "client_web")
+		mock.ExpectExec(queryDeleteAccessTokens).WithArgs(hashed("access-token")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := tokenRequest(h, revoke, "access-token")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Refresh Token Revokes Family", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectClient(mock, "refresh_token")
+		mock.ExpectQuery(queryAccessToken).WithArgs(hashed("old-refresh-token"), sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"access_token"}))
+		expectRefreshToken(mock, time.Now().Add(time.Hour), nil)
+		expectFamilyRevoked(mock)
+
+		w := tokenRequest(h, revoke, "old-refresh-token")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Ignore Tokens Of Other Clients And Unknown Tokens", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectClient(mock, "client_credentials")
+		expectAccessToken(mock, "access-token", "client_app")
+		expectClient(mock, "client_credentials")
+		expectNoToken(mock, "unknown")
+
+		assert.Equal(t, http.StatusOK, tokenRequest(h, revoke, "access-token").Code)
+		assert.Equal(t, http.StatusOK, tokenRequest(h, revoke, "unknown").Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestTokenManagementRateLimit(t *testing.T) {
+	t.Run("Introspection And Revocation Share A Limit", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		config := &configs.Config{}
+		config.RateLimit.Enabled = true
+		config.RateLimit.Introspection = configs.RateLimitPolicy{Requests: 1, PeriodSeconds: 60}
+		h.RateLimiter = middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(), config)
+		mux := chi.NewRouter()
+		h.Router(mux)
+
+		routed := func(path string) *httptest.ResponseRecorder {
+			return tokenRequest(h, func(handlers.OAuthHandler) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					r.URL.Path = path
+					mux.ServeHTTP(w, r)
+				}
+			}, "unknown")
+		}
+
+		expectClient(mock, "client_credentials")
+		expectNoToken(mock, "unknown")
+		assert.Equal(t, http.StatusOK, routed("/oauth/introspect").Code)
+
+		assert.Equal(t, http.StatusTooManyRequests, routed("/oauth/introspect").Code)
+		assert.Equal(t, http.StatusTooManyRequests, routed("/oauth/revoke").Code)
+		assert.NoError(t, mock.ExpectationsWereMet(), "the client isn't authenticated once limited")
+	})
+}
+
+const (
+	codeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	codeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func authorize(h handlers.OAuthHandler, query url.Values, authenticated bool) *httptest.ResponseRecorder {
+	mux := chi.NewRouter()
+	h.Router(mux)
+
+	r := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	if authenticated {
+		token, _ := jwt.GenerateJWT(uuid.FromStringOrNil("6ba7b810-9dad-11d1-80b4-00c04fd430c8"), "budi@example.com", "user")
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func authorizationQuery(overrides url.Values) url.Values {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"client_spa"},
+		"redirect_uri":          {"https://shop.evermos.com/callback"},
+		"scope":                 {"orders"},
+		"state":                 {"xyz"},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	for name, values := range overrides {
+		query[name] = values
+	}
+	return query
+}
+
+func redirected(t *testing.T, w *httptest.ResponseRecorder) url.Values {
+	assert.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	assert.Equal(t, "https://shop.evermos.com/callback", location.Scheme+"://"+location.Host+location.Path)
+	return location.Query()
+}
+
+func TestAuthorize(t *testing.T) {
+	t.Run("Issue Code", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectPublicClient(mock, "authorization_code refresh_token")
+		mock.ExpectExec(`INSERT INTO oauth_authorization_codes`).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "client_spa", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "https://shop.evermos.com/callback", "orders", codeChallenge, "S256", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		params := redirected(t, authorize(h, authorizationQuery(nil), true))
+
+		assert.Len(t, params.Get("code"), 40)
+		assert.Equal(t, "xyz", params.Get("state"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Scopes Narrowed To Client", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectPublicClient(mock, "authorization_code")
+		mock.ExpectExec(`INSERT INTO oauth_authorization_codes`).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "client_spa", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "https://shop.evermos.com/callback", "orders", codeChallenge, "S256", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		params := redirected(t, authorize(h, authorizationQuery(url.Values{"scope": {"orders admin"}}), true))
+
+		assert.Len(t, params.Get("code"), 40)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Redirect Errors To Client", func(t *testing.T) {
+		for name, test := range map[string]struct {
+			overrides url.Values
+			error     string
+		}{
+			"Missing Challenge": {url.Values{"code_challenge": {""}}, oauth.ErrorCodeInvalidRequest},
+			"Plain Challenge":   {url.Values{"code_challenge_method": {"plain"}}, oauth.ErrorCodeInvalidRequest},
+			"Token Response":    {url.Values{"response_type": {"token"}}, oauth.ErrorCodeUnsupportedResponseType},
+			"Scope Not Allowed": {url.Values{"scope": {"admin"}}, oauth.ErrorCodeInvalidScope},
+		} {
+			h, mock := newOAuthHandler(t)
+			expectPublicClient(mock, "authorization_code")
+
+			params := redirected(t, authorize(h, authorizationQuery(test.overrides), true))
+
+			assert.Equal(t, test.error, params.Get("error"), name)
+			assert.Equal(t, "xyz", params.Get("state"), name)
+			assert.Empty(t, params.Get("code"), name)
+		}
+	})
+
+	t.Run("Grant Type Not Allowed For Client", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectPublicClient(mock, "refresh_token")
+
+		params := redirected(t, authorize(h, authorizationQuery(nil), true))
+
+		assert.Equal(t, oauth.ErrorCodeUnauthorizedClient, params.Get("error"))
+	})
+
+	t.Run("Never Redirect To Unregistered URI", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectPublicClient(mock, "authorization_code")
+
+		w := authorize(h, authorizationQuery(url.Values{"redirect_uri": {"https://attacker.example.com/callback"}}), true)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+		assert.Equal(t, oauth.ErrorCodeInvalidRequest, oauthError(t, w))
+	})
+
+	t.Run("Sign In Page For Unauthenticated User", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectPublicClient(mock, "authorization_code")
+
+		w := authorize(h, authorizationQuery(nil), false)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+		assert.Contains(t, w.Body.String(), "Authorize client_spa")
+		assert.Contains(t, w.Body.String(), "<li>orders</li>")
+		assert.Contains(t, w.Body.String(), `<form method="post">`)
+		assert.NoError(t, mock.ExpectationsWereMet(), "no code is issued before the user signs in")
+	})
+
+	t.Run("Sign In With Password", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		hash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+		expectPublicClient(mock, "authorization_code")
+		mock.ExpectQuery(queryUser).WithArgs("budi@example.com").WillReturnRows(
+			sqlmock.NewRows([]string{"user_id", "username", "password"}).
+				AddRow("6ba7b810-9dad-11d1-80b4-00c04fd430c8", "budi", string(hash)))
+		mock.ExpectExec(`INSERT INTO oauth_authorization_codes`).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "client_spa", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "https://shop.evermos.com/callback", "orders", codeChallenge, "S256", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := signIn(h, authorizationQuery(nil), "budi@example.com", "secret-password")
+
+		params := redirected(t, w)
+		assert.Len(t, params.Get("code"), 40)
+		assert.Equal(t, "xyz", params.Get("state"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Sign In With Wrong Password", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		hash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+		expectPublicClient(mock, "authorization_code")
+		mock.ExpectQuery(queryUser).WithArgs("budi@example.com").WillReturnRows(
+			sqlmock.NewRows([]string{"user_id", "username", "password"}).
+				AddRow("6ba7b810-9dad-11d1-80b4-00c04fd430c8", "budi", string(hash)))
+
+		w := signIn(h, authorizationQuery(nil), "budi@example.com", "wrong-password")
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+		assert.Contains(t, w.Body.String(), "Invalid email or password.")
+		assert.Contains(t, w.Body.String(), `value="budi@example.com"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func signIn(h handlers.OAuthHandler, query url.Values, email string, password string) *httptest.ResponseRecorder {
+	mux := chi.NewRouter()
+	h.Router(mux)
+
+	form := url.Values{"email": {email}, "password": {password}}
+	r := httptest.NewRequest(http.MethodPost, "/oauth/authorize?"+query.Encode(), strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+// expectAuthorizationCode expects the query of the-code, used for
+// first-access-token when used.
+func expectAuthorizationCode(mock sqlmock.Sqlmock, usedAt interface{}) {
+	var accessToken interface{}
+	if usedAt != nil {
+		accessToken = hashed("first-access-token")
+	}
+	mock.ExpectQuery(`FROM\s+oauth_authorization_codes\s+WHERE code = \?`).WithArgs(hashed("the-code")).WillReturnRows(
+		sqlmock.NewRows([]string{"code", "family_id", "access_token", "client_id", "user_id", "redirect_uri", "scope", "code_challenge", "code_challenge_method", "expires", "used_at"}).
+			AddRow(hashed("the-code"), "family", accessToken, "client_spa", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "https://shop.evermos.com/callback", "orders", codeChallenge, "S256", time.Now().Add(time.Minute), usedAt))
+}
+
+func exchangeCode(h handlers.OAuthHandler, verifier string) *httptest.ResponseRecorder {
+	return requestToken(h, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"client_spa"},
+		"code":          {"the-code"},
+		"redirect_uri":  {"https://shop.evermos.com/callback"},
+		"code_verifier": {verifier},
+	}, nil)
+}
+
+func TestAuthorizationCode(t *testing.T) {
+	t.Run("Exchange For Tokens", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectPublicClient(mock, "authorization_code refresh_token")
+		expectAuthorizationCode(mock, nil)
+		mock.ExpectExec(queryInsertToken).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE oauth_authorization_codes\s+SET used_at`).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), hashed("the-code")).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(queryInsertRefreshToken).WithArgs(sqlmock.AnyArg(), "family", sqlmock.AnyArg(), "client_spa", "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "orders", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		w := exchangeCode(h, codeVerifier)
+
+		var token oauth.TokenResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "orders", token.Scope)
+		assert.Len(t, token.RefreshToken, 40)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Wrong Code Verifier", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectPublicClient(mock, "authorization_code")
+		expectAuthorizationCode(mock, nil)
+
+		w := exchangeCode(h, strings.Repeat("a", 43))
+
+		assert.Equal(t, oauth.ErrorCodeInvalidGrant, oauthError(t, w))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Reuse Revokes Tokens", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectPublicClient(mock, "authorization_code refresh_token")
+		expectAuthorizationCode(mock, time.Now().Add(-time.Second))
+		mock.ExpectExec(queryDeleteAccessTokens).WithArgs(hashed("first-access-token")).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectFamilyRevoked(mock)
+
+		w := exchangeCode(h, codeVerifier)
+
+		assert.Equal(t, oauth.ErrorCodeInvalidGrant, oauthError(t, w))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Reuse Revokes Access Token Without Refresh Token", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectPublicClient(mock, "authorization_code")
+		expectAuthorizationCode(mock, time.Now().Add(-time.Second))
+		mock.ExpectExec(queryDeleteAccessTokens).WithArgs(hashed("first-access-token")).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec(queryRevokeRefreshTokens).WithArgs(sqlmock.AnyArg(), "family").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(queryFamilyAccessTokens).WithArgs("family").WillReturnRows(sqlmock.NewRows([]string{"access_token"}))
+		mock.ExpectCommit()
+
+		w := exchangeCode(h, codeVerifier)
+
+		assert.Equal(t, oauth.ErrorCodeInvalidGrant, oauthError(t, w))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Concurrent Reuse Revokes Tokens Of Winner", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectPublicClient(mock, "authorization_code")
+		expectAuthorizationCode(mock, nil)
+		mock.ExpectExec(queryInsertToken).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE oauth_authorization_codes\s+SET used_at`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+		mock.ExpectExec(queryDeleteAccessTokens).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAuthorizationCode(mock, time.Now().Add(-time.Second))
+		mock.ExpectExec(queryDeleteAccessTokens).WithArgs(hashed("first-access-token")).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec(queryRevokeRefreshTokens).WithArgs(sqlmock.AnyArg(), "family").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(queryFamilyAccessTokens).WithArgs("family").WillReturnRows(sqlmock.NewRows([]string{"access_token"}))
+		mock.ExpectCommit()
+
+		w := exchangeCode(h, codeVerifier)
+
+		assert.Equal(t, oauth.ErrorCodeInvalidGrant, oauthError(t, w))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Public Client Without Code", func(t *testing.T) {
+		h, mock := newOAuthHandler(t)
+		expectPublicClient(mock, "authorization_code password")
+
+		w := requestToken(h, url.Values{
+			"grant_type": {"password"},
+			"client_id":  {"client_spa"},
+			"username":   {"budi@example.com"},
+			"password":   {"secret-password"},
+		}, nil)
+
+		assert.Equal(t, oauth.ErrorCodeUnauthorizedClient, oauthError(t, w))
+	})
+}
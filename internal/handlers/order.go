@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"github.com/evermos/boilerplate-go/internal/domain/order"
-	"github.com/evermos/boilerplate-go/shared/jwt"
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
 	"github.com/evermos/boilerplate-go/transport/http/response"
 	"github.com/go-chi/chi"
 	"net/http"
@@ -10,16 +12,17 @@ import (
 )
 
 type OrderHandler struct {
-	OrderService order.OrderService
+	OrderService   order.OrderService
+	Authentication *middleware.Authentication
 }
 
-func ProvideOrderHandler(orderService order.OrderService) OrderHandler {
-	return OrderHandler{OrderService: orderService}
+func ProvideOrderHandler(orderService order.OrderService, authentication *middleware.Authentication) OrderHandler {
+	return OrderHandler{OrderService: orderService, Authentication: authentication}
 }
 
 func (h *OrderHandler) Router(r chi.Router) {
 	r.Route("/order", func(r chi.Router) {
-		r.Use(jwt.AuthMiddleware)
+		r.Use(h.Authentication.Authenticate, middleware.RequireUser)
 		r.Get("/", h.GetAllOrder)
 	})
 }
@@ -52,13 +55,13 @@ func (h *OrderHandler) GetAllOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, ok := r.Context().Value("claims").(*jwt.Claims)
+	userID, ok := auth.UserIDFromContext(r.Context())
 	if !ok {
-		response.WithMessage(w, http.StatusBadRequest, "Unauthorize")
+		response.WithError(w, failure.Unauthorized("user is not authenticated"))
 		return
 	}
 
-	orders, err := h.OrderService.ResolveAllCart(claims.ID, limit, page-1)
+	orders, err := h.OrderService.ResolveAllCart(r.Context(), userID, limit, page-1)
 	if err != nil {
 		response.WithMessage(w, http.StatusInternalServerError, "Failed to fetch orders")
 		response.WithError(w, err)
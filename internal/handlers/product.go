@@ -1,31 +1,36 @@
 package handlers
 
 import (
-	"encoding/json"
 	"github.com/evermos/boilerplate-go/internal/domain/product"
-	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/auth"
 	"github.com/evermos/boilerplate-go/shared/failure"
-	"github.com/evermos/boilerplate-go/shared/jwt"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/evermos/boilerplate-go/transport/http/request"
 	"github.com/evermos/boilerplate-go/transport/http/response"
 	"github.com/go-chi/chi"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type ProductHandler struct {
 	ProductService product.ProductService
+	Authentication *middleware.Authentication
 }
 
-func ProvideProductHandler(productService product.ProductService) ProductHandler {
-	return ProductHandler{ProductService: productService}
+func ProvideProductHandler(productService product.ProductService, authentication *middleware.Authentication) ProductHandler {
+	return ProductHandler{ProductService: productService, Authentication: authentication}
 }
 
 func (h *ProductHandler) Router(r chi.Router) {
 	r.Route("/product", func(r chi.Router) {
-		//r.Use(middleware.ValidateJWTMiddleware)
-		r.Post("/", h.CreateProduct)
-		r.Post("/category", h.CreateCategory)
-		r.Get("/", h.GetAllProduct)
+		r.Group(func(r chi.Router) {
+			r.Use(h.Authentication.Authenticate, middleware.RequireUser)
+			r.Post("/", h.CreateProduct)
+			r.Post("/category", h.CreateCategory)
+		})
+		// listing is a read-only page, so give up on it early
+		r.With(middleware.Timeout(5*time.Second)).Get("/", h.GetAllProduct)
 	})
 }
 
@@ -42,25 +47,18 @@ func (h *ProductHandler) Router(r chi.Router) {
 // @Failure 500 {object} response.Base
 // @Router /v1/product/ [post]
 func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
 	var requestFormat product.ProductRequestFormat
-	err := decoder.Decode(&requestFormat)
+	err := request.Decode(w, r, &requestFormat)
 	if err != nil {
-		response.WithError(w, failure.BadRequest(err))
-		return
-	}
-
-	err = shared.GetValidator().Struct(requestFormat)
-	if err != nil {
-		response.WithError(w, failure.BadRequest(err))
+		response.WithError(w, err)
 		return
 	}
-	claims, ok := r.Context().Value("claims").(*jwt.Claims)
+	userID, ok := auth.UserIDFromContext(r.Context())
 	if !ok {
-		http.Error(w, "Error Claims", http.StatusUnauthorized)
+		response.WithError(w, failure.Unauthorized("user is not authenticated"))
 		return
 	}
-	product, err := h.ProductService.Create(requestFormat, claims.ID)
+	product, err := h.ProductService.Create(r.Context(), requestFormat, userID)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -99,14 +97,14 @@ func (h *ProductHandler) GetAllProduct(w http.ResponseWriter, r *http.Request) {
 
 	var products []product.Product
 	if categoryName == "" {
-		products, err = h.ProductService.ResolveProduct(limit, page-1)
+		products, err = h.ProductService.ResolveProduct(r.Context(), limit, page-1)
 		if err != nil {
 			response.WithMessage(w, http.StatusBadRequest, "Missing Query Param")
 			response.WithError(w, err)
 			return
 		}
 	} else {
-		products, err = h.ProductService.ResolveProductByCategory(limit, page-1, categoryName)
+		products, err = h.ProductService.ResolveProductByCategory(r.Context(), limit, page-1, categoryName)
 		if err != nil {
 			response.WithMessage(w, http.StatusBadRequest, "Missing Param Query / Wrong Category Name")
 			response.WithError(w, err)
@@ -129,25 +127,18 @@ func (h *ProductHandler) GetAllProduct(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} response.Base
 // @Router /v1/product/ [post]
 func (h *ProductHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
 	var requestFormat product.CategoriesRequestFormat
-	err := decoder.Decode(&requestFormat)
+	err := request.Decode(w, r, &requestFormat)
 	if err != nil {
-		response.WithError(w, failure.BadRequest(err))
-		return
-	}
-
-	err = shared.GetValidator().Struct(requestFormat)
-	if err != nil {
-		response.WithError(w, failure.BadRequest(err))
+		response.WithError(w, err)
 		return
 	}
-	claims, ok := r.Context().Value("claims").(*jwt.Claims)
+	userID, ok := auth.UserIDFromContext(r.Context())
 	if !ok {
-		http.Error(w, "Error Claims", http.StatusUnauthorized)
+		response.WithError(w, failure.Unauthorized("user is not authenticated"))
 		return
 	}
-	prodCategory, err := h.ProductService.CreateCategory(requestFormat, claims.ID)
+	prodCategory, err := h.ProductService.CreateCategory(r.Context(), requestFormat, userID)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -0,0 +1,86 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/internal/domain/product"
+	"github.com/evermos/boilerplate-go/internal/handlers"
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/evermos/boilerplate-go/shared/jwt"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/go-chi/chi"
+	"github.com/go-redis/redis"
+	"github.com/gofrs/uuid"
+	"github.com/golang/mock/gomock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+
+	product_mock "github.com/evermos/boilerplate-go/internal/domain/product/mock"
+)
+
+func newProductRouter(t *testing.T, service product.ProductService) chi.Router {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { cache.Close() })
+
+	config := &configs.Config{}
+	config.Auth.Strategies = []string{auth.StrategyJWT}
+	sqlxdb := sqlx.NewDb(db, "mysql")
+	conn := &infras.MySQLConn{Read: sqlxdb, Write: sqlxdb}
+	authentication := middleware.ProvideAuthentication(conn, cache, oauth.NewMySQLTokenStore(sqlxdb), config)
+
+	h := handlers.ProvideProductHandler(service, authentication)
+	r := chi.NewRouter()
+	h.Router(r)
+	return r
+}
+
+func TestCreateProductRequiresUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.Must(uuid.NewV4())
+	token, _ := jwt.GenerateJWT(userID, "budi@example.com", "admin")
+	body := `{"name": "Mugs", "description": "Mugs and cups"}`
+
+	t.Run("Refuse Anonymous Requests", func(t *testing.T) {
+		r := newProductRouter(t, product_mock.NewMockProductService(ctrl))
+
+		for _, path := range []string{"/product/", "/product/category"} {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+		}
+	})
+
+	t.Run("Create As Authenticated User", func(t *testing.T) {
+		service := product_mock.NewMockProductService(ctrl)
+		service.EXPECT().CreateCategory(gomock.Any(), product.CategoriesRequestFormat{Name: "Mugs", Description: "Mugs and cups"}, userID).
+			Return(product.ProductCategories{Name: "Mugs"}, nil)
+		r := newProductRouter(t, service)
+
+		req := httptest.NewRequest(http.MethodPost, "/product/category", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.HeaderAuthorization, "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+}
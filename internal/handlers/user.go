@@ -1,12 +1,10 @@
 package handlers
 
 import (
-	"encoding/json"
 	"github.com/evermos/boilerplate-go/internal/domain/user"
-	"github.com/evermos/boilerplate-go/shared"
-	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/evermos/boilerplate-go/shared/logger"
 	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/evermos/boilerplate-go/transport/http/request"
 	"github.com/evermos/boilerplate-go/transport/http/response"
 	"github.com/go-chi/chi"
 	"github.com/gofrs/uuid"
@@ -16,16 +14,17 @@ import (
 type UserHandler struct {
 	UserService    user.UserService
 	AuthMiddleware *middleware.Authentication
+	RateLimiter    *middleware.RateLimiter
 }
 
-func ProvideUserHandler(userService user.UserService, authMiddleware *middleware.Authentication) UserHandler {
-	return UserHandler{UserService: userService, AuthMiddleware: authMiddleware}
+func ProvideUserHandler(userService user.UserService, authMiddleware *middleware.Authentication, rateLimiter *middleware.RateLimiter) UserHandler {
+	return UserHandler{UserService: userService, AuthMiddleware: authMiddleware, RateLimiter: rateLimiter}
 }
 
 func (h *UserHandler) Router(r chi.Router) {
 	r.Route("/users", func(r chi.Router) {
-		r.Post("/", h.CreateUser)
-		r.Post("/login", h.Login)
+		r.With(h.RateLimiter.Limit(middleware.RateLimitRegister)).Post("/", h.CreateUser)
+		r.With(h.RateLimiter.Limit(middleware.RateLimitLogin)).Post("/login", h.Login)
 	})
 }
 
@@ -39,22 +38,22 @@ func (h *UserHandler) Router(r chi.Router) {
 // @Success 201 {object} response.Base{data=user.UserResponseFormat}
 // @Failure 400 {object} response.Base
 // @Failure 409 {object} response.Base
+// @Failure 429 {object} response.Base
 // @Failure 500 {object} response.Base
 // @Router /v1/users/ [post]
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
 	var requestFormat user.UserRequestFormat
-	err := decoder.Decode(&requestFormat)
+	err := request.Decode(w, r, &requestFormat)
 	if err != nil {
-		response.WithError(w, failure.BadRequest(err))
+		response.WithError(w, err)
 		return
 	}
 	userID, err := uuid.NewV4()
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(r.Context(), err)
 		return
 	}
-	user, err := h.UserService.Create(requestFormat, userID)
+	user, err := h.UserService.Create(r.Context(), requestFormat, userID)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -72,26 +71,20 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} response.Base{data=user.UserResponseFormat}
 // @Failure 400 {object} response.Base
 // @Failure 409 {object} response.Base
+// @Failure 429 {object} response.Base
 // @Failure 500 {object} response.Base
 // @Router /v1/users/login [post]
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
 	var requestFormat user.LoginRequestFormat
-	err := decoder.Decode(&requestFormat)
+	err := request.Decode(w, r, &requestFormat)
 	if err != nil {
-		response.WithError(w, failure.BadRequest(err))
-		return
-	}
-	err = shared.GetValidator().Struct(requestFormat)
-	if err != nil {
-		logger.ErrorWithStack(err)
-		response.WithError(w, failure.BadRequest(err))
+		response.WithError(w, err)
 		return
 	}
 
-	foo, err := h.UserService.Login(requestFormat)
+	foo, err := h.UserService.Login(r.Context(), requestFormat)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(r.Context(), err)
 		response.WithError(w, err)
 		return
 	}
@@ -4,8 +4,16 @@ package main
 //go:generate go run github.com/google/wire/cmd/wire
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/evermos/boilerplate-go/shared/tracing"
+	"github.com/evermos/boilerplate-go/transport/http/response"
 )
 
 var config *configs.Config
@@ -17,19 +25,73 @@ func main() {
 	// Initialize logger
 	logger.InitLogger()
 
-	// Initialize config
+	// Initialize config, reporting every invalid setting at once
+	args, err := configs.Init(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 	config = configs.Get()
 
-	// Set desired log level
+	// Set desired log format
+	logger.SetLogFormat(config)
+
+	// Set desired log level, again whenever the config is reloaded
 	logger.SetLogLevel(config)
+	configs.OnReload(logger.SetLogLevel)
+
+	// Set the format of the error responses, again whenever the config is reloaded
+	response.SetProblemDetails(config)
+	configs.OnReload(response.SetProblemDetails)
+	configs.WatchReload()
+
+	// Run the replay command instead of the server when asked to
+	if len(args) > 0 && args[0] == "replay" {
+		os.Exit(runReplay(args[1:]))
+	}
+
+	// Run the migrate command instead of the server when asked to
+	if len(args) > 0 && args[0] == "migrate" {
+		os.Exit(runMigrate(args[1:]))
+	}
+
+	// Run the clients command instead of the server when asked to
+	if len(args) > 0 && args[0] == "clients" {
+		os.Exit(runClients(args[1:]))
+	}
+
+	// Set up tracing, flushed once the server has shut down
+	shutdownTracing, err := tracing.Init(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
 
 	// Wire everything up
-	http := InitializeService()
+	http, err := InitializeService()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Migrate the database before serving, if enabled
+	migrateOnStartup(config, http.DB.Write)
+
+	// Purge the expired OAuth tokens and codes from MySQL periodically, until
+	// the server shut down. Refresh tokens and codes stay in MySQL whatever the
+	// token store.
+	if config.OAuth.PurgeIntervalSeconds > 0 {
+		purger := oauth.NewPurger(http.DB.Write, time.Duration(config.OAuth.PurgeIntervalSeconds)*time.Second)
+		purger.Start()
+		http.OnShutdown("oauth-purge", purger.Stop)
+	}
 
 	//consumers := InitializeEvent()
 	//
-	//// Start consumers
+	//// Start consumers, and stop them once the server drained its requests
 	//consumers.Start()
+	//http.OnShutdown("consumers", consumers.Stop)
 
 	// Run server
 	http.SetupAndServe()
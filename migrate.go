@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/migrations"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+const migrateUsage = "usage: migrate up [-steps n] | down [-steps n] | status | create [-dir dir] <name>"
+
+// runMigrate runs the migrate command, which applies, reverts, lists or
+// creates the database migrations. It returns the exit code.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	command := args[0]
+	fs := flag.NewFlagSet("migrate "+command, flag.ContinueOnError)
+	steps := fs.Int("steps", 0, "number of migrations to apply or revert, all pending ones for up and one for down if 0")
+	dir := fs.String("dir", "migrations/domain", "directory to create the migration in, for create")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	if command == "create" {
+		if fs.NArg() == 0 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		up, down, err := migrations.Create(*dir, strings.Join(fs.Args(), "_"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(up)
+		fmt.Println(down)
+		return 0
+	}
+
+	migrator, err := newMigrator(config, infras.CreateMySQLWriteConn(*config))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		_, err = migrator.Up(ctx, *steps)
+	case "down":
+		if *steps == 0 {
+			*steps = 1
+		}
+		_, err = migrator.Down(ctx, *steps)
+	case "status":
+		err = printMigrationStatus(ctx, migrator)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("Migration failed")
+		return 1
+	}
+	return 0
+}
+
+// migrateOnStartup applies the pending migrations before the server starts,
+// when enabled.
+func migrateOnStartup(config *configs.Config, db *sqlx.DB) {
+	if !config.DB.MySQL.Migrate.OnStartup {
+		return
+	}
+
+	migrator, err := newMigrator(config, db)
+	if err == nil {
+		_, err = migrator.Up(context.Background(), 0)
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed migrating the database")
+	}
+}
+
+func newMigrator(config *configs.Config, db *sqlx.DB) (*migrations.Migrator, error) {
+	domain, err := migrations.Load(migrations.Domain())
+	if err != nil {
+		return nil, err
+	}
+
+	lockTimeout := time.Duration(config.DB.MySQL.Migrate.LockTimeoutSeconds) * time.Second
+	return migrations.NewMigrator(db, domain, lockTimeout), nil
+}
+
+func printMigrationStatus(ctx context.Context, migrator *migrations.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+	for _, status := range statuses {
+		state := "pending"
+		if status.Dirty {
+			state = "dirty"
+		} else if status.Applied() {
+			state = "applied " + status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, state)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `foo_item`;
+DROP TABLE IF EXISTS `foo`;
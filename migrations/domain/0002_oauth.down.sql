@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `oauth_access_tokens`;
+DROP TABLE IF EXISTS `oauth_clients`;
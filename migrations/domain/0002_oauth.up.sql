@@ -28,4 +28,4 @@ INSERT INTO `oauth_access_tokens`
 (`access_token`, `client_id`, `user_id`, `expires`, `scope`)
 VALUES
 ('00000c708db9bdf1a70d5988a8f321a82970ceb6', 'client_web', '10001', '2024-08-24 20:57:59', NULL),
-('4d1ed8aea16c7212886ecf91241aa2499f053bcf', 'client_web', NULL, '2021-08-24 20:57:59', NULL);
\ No newline at end of file
+('4d1ed8aea16c7212886ecf91241aa2499f053bcf', 'client_web', NULL, '2021-08-24 20:57:59', NULL);
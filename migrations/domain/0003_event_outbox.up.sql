@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `event_outbox` (
+  `entity_id` CHAR(36) NOT NULL,
+  `event_type` VARCHAR(255) NOT NULL,
+  `topic` VARCHAR(255) NOT NULL,
+  `payload` LONGBLOB NOT NULL,
+  `created` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `published` TIMESTAMP NULL DEFAULT NULL,
+  PRIMARY KEY (`entity_id`),
+  INDEX `idx_event_outbox_1` (`event_type`, `created`),
+  INDEX `idx_event_outbox_2` (`created`),
+  INDEX `idx_event_outbox_3` (`published`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
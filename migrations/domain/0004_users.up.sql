@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS `users` (
+  `user_id` CHAR(36) NOT NULL,
+  `username` VARCHAR(255) NOT NULL,
+  `email` VARCHAR(255) NOT NULL,
+  `password` VARCHAR(255) NOT NULL,
+  `role` VARCHAR(20) NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `created_by` CHAR(36) NULL DEFAULT NULL,
+  `updated_at` TIMESTAMP NULL DEFAULT NULL,
+  `updated_by` CHAR(36) NULL DEFAULT NULL,
+  `deleted_at` TIMESTAMP NULL DEFAULT NULL,
+  `deleted_by` CHAR(36) NULL DEFAULT NULL,
+  PRIMARY KEY (`user_id`),
+  UNIQUE `idx_users_1` (`email`),
+  INDEX `idx_users_2` (`username`),
+  INDEX `idx_users_3` (`role`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
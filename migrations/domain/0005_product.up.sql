@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS `product_categories` (
+  `category_id` CHAR(36) NOT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `description` TEXT NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `created_by` CHAR(36) NOT NULL,
+  `updated_at` TIMESTAMP NULL DEFAULT NULL,
+  `updated_by` CHAR(36) NULL DEFAULT NULL,
+  `deleted_at` TIMESTAMP NULL DEFAULT NULL,
+  `deleted_by` CHAR(36) NULL DEFAULT NULL,
+  PRIMARY KEY (`category_id`),
+  UNIQUE `idx_product_categories_1` (`name`),
+  INDEX `idx_product_categories_2` (`deleted_at`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `product` (
+  `product_id` CHAR(36) NOT NULL,
+  `category_id` CHAR(36) NOT NULL,
+  `name` VARCHAR(255) NOT NULL,
+  `description` TEXT NOT NULL,
+  `price` DECIMAL(14,2) NOT NULL,
+  `stock` INT NOT NULL DEFAULT 0,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `created_by` CHAR(36) NOT NULL,
+  `updated_at` TIMESTAMP NULL DEFAULT NULL,
+  `updated_by` CHAR(36) NULL DEFAULT NULL,
+  `deleted_at` TIMESTAMP NULL DEFAULT NULL,
+  `deleted_by` CHAR(36) NULL DEFAULT NULL,
+  PRIMARY KEY (`product_id`),
+  CONSTRAINT `fk_product_category_id` FOREIGN KEY (`category_id`)
+    REFERENCES `product_categories` (`category_id`)
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION,
+  INDEX `idx_product_1` (`name`),
+  INDEX `idx_product_2` (`created_at`),
+  INDEX `idx_product_3` (`deleted_at`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS `carts` (
+  `cart_id` CHAR(36) NOT NULL,
+  `user_id` CHAR(36) NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `created_by` CHAR(36) NOT NULL,
+  `updated_at` TIMESTAMP NULL DEFAULT NULL,
+  `updated_by` CHAR(36) NULL DEFAULT NULL,
+  `deleted_at` TIMESTAMP NULL DEFAULT NULL,
+  `deleted_by` CHAR(36) NULL DEFAULT NULL,
+  PRIMARY KEY (`cart_id`),
+  CONSTRAINT `fk_carts_user_id` FOREIGN KEY (`user_id`)
+    REFERENCES `users` (`user_id`)
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION,
+  INDEX `idx_carts_1` (`deleted_at`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `cart_items` (
+  `cart_item_id` CHAR(36) NOT NULL,
+  `cart_id` CHAR(36) NOT NULL,
+  `product_id` CHAR(36) NOT NULL,
+  `quantity` INT NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `created_by` CHAR(36) NOT NULL,
+  `updated_at` TIMESTAMP NULL DEFAULT NULL,
+  `updated_by` CHAR(36) NULL DEFAULT NULL,
+  `deleted_at` TIMESTAMP NULL DEFAULT NULL,
+  `deleted_by` CHAR(36) NULL DEFAULT NULL,
+  PRIMARY KEY (`cart_item_id`),
+  CONSTRAINT `fk_cart_items_cart_id` FOREIGN KEY (`cart_id`)
+    REFERENCES `carts` (`cart_id`)
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION,
+  CONSTRAINT `fk_cart_items_product_id` FOREIGN KEY (`product_id`)
+    REFERENCES `product` (`product_id`)
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION,
+  UNIQUE `idx_cart_items_1` (`cart_id`, `product_id`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
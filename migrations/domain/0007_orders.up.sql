@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS `orders` (
+  `order_id` CHAR(36) NOT NULL,
+  `user_id` CHAR(36) NOT NULL,
+  `total_amount` DECIMAL(14,2) NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `created_by` CHAR(36) NOT NULL,
+  `updated_at` TIMESTAMP NULL DEFAULT NULL,
+  `updated_by` CHAR(36) NULL DEFAULT NULL,
+  `deleted_at` TIMESTAMP NULL DEFAULT NULL,
+  `deleted_by` CHAR(36) NULL DEFAULT NULL,
+  PRIMARY KEY (`order_id`),
+  CONSTRAINT `fk_orders_user_id` FOREIGN KEY (`user_id`)
+    REFERENCES `users` (`user_id`)
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION,
+  INDEX `idx_orders_1` (`created_at`),
+  INDEX `idx_orders_2` (`deleted_at`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `order_items` (
+  `order_item_id` CHAR(36) NOT NULL,
+  `order_id` CHAR(36) NOT NULL,
+  `product_id` CHAR(36) NOT NULL,
+  `quantity` INT NOT NULL,
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `created_by` CHAR(36) NOT NULL,
+  `updated_at` TIMESTAMP NULL DEFAULT NULL,
+  `updated_by` CHAR(36) NULL DEFAULT NULL,
+  `deleted_at` TIMESTAMP NULL DEFAULT NULL,
+  `deleted_by` CHAR(36) NULL DEFAULT NULL,
+  PRIMARY KEY (`order_item_id`),
+  CONSTRAINT `fk_order_items_order_id` FOREIGN KEY (`order_id`)
+    REFERENCES `orders` (`order_id`)
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION,
+  CONSTRAINT `fk_order_items_product_id` FOREIGN KEY (`product_id`)
+    REFERENCES `product` (`product_id`)
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION,
+  INDEX `idx_order_items_1` (`product_id`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
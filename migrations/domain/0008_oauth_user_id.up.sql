@@ -0,0 +1,2 @@
+ALTER TABLE `oauth_access_tokens`
+    MODIFY `user_id` CHAR(36) NULL;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `oauth_refresh_tokens` (
+    `refresh_token` VARCHAR(40) NOT NULL,
+    `family_id` VARCHAR(40) NOT NULL,
+    `access_token` VARCHAR(40) NOT NULL,
+    `client_id` VARCHAR(32) NOT NULL,
+    `user_id` CHAR(36) NULL,
+    `scope` VARCHAR(2000) NULL,
+    `expires` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `used_at` TIMESTAMP NULL DEFAULT NULL,
+    `revoked_at` TIMESTAMP NULL DEFAULT NULL,
+    PRIMARY KEY (`refresh_token`),
+    INDEX `idx_oauth_refresh_tokens_1` (`family_id`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `oauth_authorization_codes` (
+    `code` VARCHAR(40) NOT NULL,
+    `family_id` VARCHAR(40) NOT NULL,
+    `client_id` VARCHAR(32) NOT NULL,
+    `user_id` CHAR(36) NOT NULL,
+    `redirect_uri` VARCHAR(1000) NULL,
+    `scope` VARCHAR(2000) NULL,
+    `code_challenge` VARCHAR(128) NOT NULL,
+    `code_challenge_method` VARCHAR(10) NOT NULL,
+    `expires` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `used_at` TIMESTAMP NULL DEFAULT NULL,
+    PRIMARY KEY (`code`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
@@ -0,0 +1 @@
+UPDATE `oauth_clients` SET `scope` = 'user' WHERE `client_id` = 'client_web' AND `scope` = 'user foo:read foo:write';
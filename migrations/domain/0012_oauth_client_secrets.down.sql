@@ -0,0 +1,9 @@
+UPDATE `oauth_clients`
+    SET `client_secret` = '3v3rm0s'
+    WHERE `client_id` = 'client_web' AND `client_secret` = '$2a$10$6miVMk2DestAt3R4oz0PlOa2okZ1ljIkcg.t0Zn5sgDIV7vO2d36q';
+
+ALTER TABLE `oauth_clients`
+    DROP `previous_client_secret`,
+    DROP `previous_client_secret_expires`,
+    DROP `created_at`,
+    DROP `disabled_at`;
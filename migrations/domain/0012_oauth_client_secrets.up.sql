@@ -0,0 +1,10 @@
+ALTER TABLE `oauth_clients`
+    MODIFY `client_secret` VARCHAR(100) NOT NULL DEFAULT '',
+    ADD `previous_client_secret` VARCHAR(100) NULL AFTER `client_secret`,
+    ADD `previous_client_secret_expires` TIMESTAMP NULL DEFAULT NULL AFTER `previous_client_secret`,
+    ADD `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ADD `disabled_at` TIMESTAMP NULL DEFAULT NULL;
+
+UPDATE `oauth_clients`
+    SET `client_secret` = '$2a$10$6miVMk2DestAt3R4oz0PlOa2okZ1ljIkcg.t0Zn5sgDIV7vO2d36q'
+    WHERE `client_id` = 'client_web' AND `client_secret` = '3v3rm0s';
@@ -0,0 +1,19 @@
+DELETE FROM `oauth_access_tokens`;
+
+DELETE FROM `oauth_refresh_tokens`;
+
+DELETE FROM `oauth_authorization_codes`;
+
+ALTER TABLE `oauth_access_tokens`
+    MODIFY `access_token` VARCHAR(40) NOT NULL,
+    DROP INDEX `idx_oauth_access_tokens_1`,
+    DROP INDEX `idx_oauth_access_tokens_2`;
+
+ALTER TABLE `oauth_refresh_tokens`
+    MODIFY `refresh_token` VARCHAR(40) NOT NULL,
+    MODIFY `access_token` VARCHAR(40) NOT NULL,
+    DROP INDEX `idx_oauth_refresh_tokens_2`;
+
+ALTER TABLE `oauth_authorization_codes`
+    MODIFY `code` VARCHAR(40) NOT NULL,
+    DROP INDEX `idx_oauth_authorization_codes_1`;
@@ -0,0 +1,20 @@
+ALTER TABLE `oauth_access_tokens`
+    MODIFY `access_token` CHAR(64) NOT NULL,
+    ADD INDEX `idx_oauth_access_tokens_1` (`client_id`),
+    ADD INDEX `idx_oauth_access_tokens_2` (`expires`);
+
+ALTER TABLE `oauth_refresh_tokens`
+    MODIFY `refresh_token` CHAR(64) NOT NULL,
+    MODIFY `access_token` CHAR(64) NOT NULL,
+    ADD INDEX `idx_oauth_refresh_tokens_2` (`expires`);
+
+ALTER TABLE `oauth_authorization_codes`
+    MODIFY `code` CHAR(64) NOT NULL,
+    ADD INDEX `idx_oauth_authorization_codes_1` (`expires`);
+
+UPDATE `oauth_access_tokens` SET `access_token` = SHA2(`access_token`, 256);
+
+UPDATE `oauth_refresh_tokens`
+    SET `refresh_token` = SHA2(`refresh_token`, 256), `access_token` = SHA2(`access_token`, 256);
+
+UPDATE `oauth_authorization_codes` SET `code` = SHA2(`code`, 256);
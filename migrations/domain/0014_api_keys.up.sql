@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS `api_keys` (
+    `key_hash` CHAR(64) NOT NULL,
+    `client_id` VARCHAR(32) NOT NULL,
+    `name` VARCHAR(100) NOT NULL,
+    `scope` VARCHAR(2000) NULL,
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `revoked_at` TIMESTAMP NULL DEFAULT NULL,
+    PRIMARY KEY (`key_hash`),
+    INDEX `idx_api_keys_1` (`client_id`)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
@@ -0,0 +1,2 @@
+ALTER TABLE `oauth_authorization_codes`
+    ADD `access_token` CHAR(64) NULL AFTER `family_id`;
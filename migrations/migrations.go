@@ -0,0 +1,176 @@
+// Package migrations contains the versioned database migrations of the
+// service, embedded in the binary, and the runner applying them.
+//
+// Migrations are pairs of files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql, applied in the order of their versions.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed domain/*.sql
+var embedded embed.FS
+
+// Domain returns the embedded migrations of the domain tables.
+func Domain() fs.FS {
+	domain, err := fs.Sub(embedded, "domain")
+	if err != nil {
+		panic(err)
+	}
+	return domain
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a versioned schema change and the way to undo it.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load reads the migrations in the root directory of the file system, sorted
+// by version. Every version must have both an up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s isn't named <version>_<name>.(up|down).sql", entry.Name())
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, migration.Name, match[2])
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Create writes empty up and down files for a new migration in the directory,
+// versioned after the latest migration there, and returns their paths.
+func Create(dir, name string) (up, down string, err error) {
+	name = strings.ToLower(strings.Join(strings.Fields(name), "_"))
+	if !fileName.MatchString("1_" + name + ".up.sql") {
+		return "", "", fmt.Errorf("migration name %q may only contain letters, digits and underscores", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	var latest int64
+	for _, entry := range entries {
+		if match := fileName.FindStringSubmatch(entry.Name()); match != nil {
+			if version, _ := strconv.ParseInt(match[1], 10, 64); version > latest {
+				latest = version
+			}
+		}
+	}
+
+	prefix := filepath.Join(dir, fmt.Sprintf("%04d_%s", latest+1, name))
+	up, down = prefix+".up.sql", prefix+".down.sql"
+	for _, path := range []string{up, down} {
+		if err = os.WriteFile(path, nil, 0644); err != nil {
+			return "", "", err
+		}
+	}
+
+	return up, down, nil
+}
+
+// splitStatements splits a migration into its statements, which are
+// separated by semicolons outside of quotes and comments.
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+		quote      rune
+	)
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			current.WriteRune(r)
+			if r == '\\' && quote != '`' && i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+			current.WriteRune(r)
+		case r == '#' || (r == '-' && i+2 < len(runes) && runes[i+1] == '-' && (runes[i+2] == ' ' || runes[i+2] == '\t')):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			current.WriteRune('\n')
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 3; i < len(runes) && !(runes[i-1] == '*' && runes[i] == '/'); i++ {
+			}
+			current.WriteRune(' ')
+		case r == ';':
+			if statement := strings.TrimSpace(current.String()); statement != "" {
+				statements = append(statements, statement)
+			}
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if statement := strings.TrimSpace(current.String()); statement != "" {
+		statements = append(statements, statement)
+	}
+	return statements
+}
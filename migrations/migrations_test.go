@@ -0,0 +1,208 @@
+package migrations_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/evermos/boilerplate-go/migrations"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("Embedded Domain Migrations", func(t *testing.T) {
+		loaded, err := migrations.Load(migrations.Domain())
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, loaded)
+		for i, migration := range loaded {
+			assert.Equal(t, int64(i+1), migration.Version)
+			assert.NotEmpty(t, migration.Up)
+			assert.NotEmpty(t, migration.Down)
+		}
+	})
+
+	t.Run("Sort By Version", func(t *testing.T) {
+		loaded, err := migrations.Load(fstest.MapFS{
+			"0010_second.up.sql":   {Data: []byte("CREATE TABLE b (id INT)")},
+			"0010_second.down.sql": {Data: []byte("DROP TABLE b")},
+			"0002_first.up.sql":    {Data: []byte("CREATE TABLE a (id INT)")},
+			"0002_first.down.sql":  {Data: []byte("DROP TABLE a")},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []migrations.Migration{
+			{Version: 2, Name: "first", Up: "CREATE TABLE a (id INT)", Down: "DROP TABLE a"},
+			{Version: 10, Name: "second", Up: "CREATE TABLE b (id INT)", Down: "DROP TABLE b"},
+		}, loaded)
+	})
+
+	t.Run("Missing Down Migration", func(t *testing.T) {
+		_, err := migrations.Load(fstest.MapFS{
+			"0001_first.up.sql": {Data: []byte("CREATE TABLE a (id INT)")},
+		})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid File Name", func(t *testing.T) {
+		_, err := migrations.Load(fstest.MapFS{
+			"01-first.sql": {Data: []byte("CREATE TABLE a (id INT)")},
+		})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "0007_orders.up.sql"), nil, 0644))
+
+	up, down, err := migrations.Create(dir, "Add Product SKU")
+
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0008_add_product_sku.up.sql"), up)
+	assert.Equal(t, filepath.Join(dir, "0008_add_product_sku.down.sql"), down)
+	assert.FileExists(t, up)
+	assert.FileExists(t, down)
+}
+
+var testMigrations = []migrations.Migration{
+	{
+		Version: 1,
+		Name:    "first",
+		Up:      "CREATE TABLE a (id INT);\n-- a comment; with a semicolon\nINSERT INTO a VALUES (1);",
+		Down:    "DROP TABLE a;",
+	},
+	{
+		Version: 2,
+		Name:    "second",
+		Up:      "CREATE TABLE b (name VARCHAR(10) DEFAULT 'x;y');",
+		Down:    "DROP TABLE b;",
+	},
+}
+
+func newMockMigrator(t *testing.T) (*migrations.Migrator, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return migrations.NewMigrator(sqlx.NewDb(db, "mysql"), testMigrations, time.Minute), mock
+}
+
+func expectLock(mock sqlmock.Sqlmock, acquired int64, versions *sqlmock.Rows) {
+	mock.ExpectQuery("SELECT GET_LOCK(?, ?)").WithArgs("schema_migrations", 60).
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(acquired))
+	if acquired != 1 {
+		return
+	}
+	mock.ExpectExec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			dirty TINYINT(1) NOT NULL DEFAULT 0,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (version)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, name, dirty, applied_at FROM schema_migrations ORDER BY version").WillReturnRows(versions)
+}
+
+func versionRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"version", "name", "dirty", "applied_at"})
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("SELECT RELEASE_LOCK(?)").WithArgs("schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestMigrator(t *testing.T) {
+	t.Run("Up Applies Pending Migrations", func(t *testing.T) {
+		migrator, mock := newMockMigrator(t)
+		expectLock(mock, 1, versionRows().AddRow(1, "first", false, time.Now()))
+		mock.ExpectExec("INSERT INTO schema_migrations (version, name, dirty) VALUES (?, ?, 1)").WithArgs(2, "second").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("CREATE TABLE b (name VARCHAR(10) DEFAULT 'x;y')").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("UPDATE schema_migrations SET dirty = 0, applied_at = CURRENT_TIMESTAMP WHERE version = ?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectUnlock(mock)
+
+		applied, err := migrator.Up(context.Background(), 0)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []migrations.Migration{testMigrations[1]}, applied)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Up Splits Statements", func(t *testing.T) {
+		migrator, mock := newMockMigrator(t)
+		expectLock(mock, 1, versionRows())
+		mock.ExpectExec("INSERT INTO schema_migrations (version, name, dirty) VALUES (?, ?, 1)").WithArgs(1, "first").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("CREATE TABLE a (id INT)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO a VALUES (1)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE schema_migrations SET dirty = 0, applied_at = CURRENT_TIMESTAMP WHERE version = ?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectUnlock(mock)
+
+		applied, err := migrator.Up(context.Background(), 1)
+
+		assert.NoError(t, err)
+		assert.Len(t, applied, 1)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Down Reverts Latest Migration", func(t *testing.T) {
+		migrator, mock := newMockMigrator(t)
+		expectLock(mock, 1, versionRows().AddRow(1, "first", false, time.Now()).AddRow(2, "second", false, time.Now()))
+		mock.ExpectExec("UPDATE schema_migrations SET dirty = 1 WHERE version = ?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DROP TABLE b").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("DELETE FROM schema_migrations WHERE version = ?").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectUnlock(mock)
+
+		reverted, err := migrator.Down(context.Background(), 1)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []migrations.Migration{testMigrations[1]}, reverted)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Refuse Dirty Schema", func(t *testing.T) {
+		migrator, mock := newMockMigrator(t)
+		expectLock(mock, 1, versionRows().AddRow(1, "first", true, time.Now()))
+		expectUnlock(mock)
+
+		_, err := migrator.Up(context.Background(), 0)
+
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Lock Timeout", func(t *testing.T) {
+		migrator, mock := newMockMigrator(t)
+		expectLock(mock, 0, nil)
+
+		_, err := migrator.Up(context.Background(), 0)
+
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		migrator, mock := newMockMigrator(t)
+		appliedAt := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+		expectLock(mock, 1, versionRows().AddRow(1, "first", false, appliedAt))
+		expectUnlock(mock)
+
+		statuses, err := migrator.Status(context.Background())
+
+		assert.NoError(t, err)
+		assert.Len(t, statuses, 2)
+		assert.True(t, statuses[0].Applied())
+		assert.Equal(t, appliedAt, *statuses[0].AppliedAt)
+		assert.False(t, statuses[1].Applied())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
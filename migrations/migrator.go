@@ -0,0 +1,241 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// versionTable records the applied migrations.
+	versionTable = "schema_migrations"
+	// lockName is the advisory lock held while migrating.
+	lockName = "schema_migrations"
+)
+
+// Status is a migration and whether it has been applied.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+	// Dirty means the migration failed halfway and the schema has to be
+	// fixed by hand before migrating again.
+	Dirty bool
+}
+
+// Applied checks whether the migration has been applied.
+func (s Status) Applied() bool {
+	return s.AppliedAt != nil
+}
+
+type appliedVersion struct {
+	Version   int64
+	Name      string
+	Dirty     bool
+	AppliedAt time.Time
+}
+
+// Migrator applies and reverts migrations, recording the applied versions in
+// the schema_migrations table. Every run holds a MySQL advisory lock, so
+// instances starting at the same time don't migrate concurrently.
+type Migrator struct {
+	db          *sqlx.DB
+	migrations  []Migration
+	lockTimeout time.Duration
+}
+
+// NewMigrator creates a Migrator. Runs wait up to the lock timeout for other
+// runs to finish.
+func NewMigrator(db *sqlx.DB, migrations []Migration, lockTimeout time.Duration) *Migrator {
+	return &Migrator{
+		db:          db,
+		migrations:  migrations,
+		lockTimeout: lockTimeout,
+	}
+}
+
+// Up applies the pending migrations in order, or only the first steps of them
+// if steps is positive, and returns the applied migrations.
+func (m *Migrator) Up(ctx context.Context, steps int) (applied []Migration, err error) {
+	err = m.locked(ctx, func(conn *sql.Conn, versions map[int64]appliedVersion) error {
+		if err := checkClean(versions); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			if steps > 0 && len(applied) == steps {
+				break
+			}
+
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return
+}
+
+// Down reverts the latest applied migrations, steps of them, and returns the
+// reverted migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) (reverted []Migration, err error) {
+	err = m.locked(ctx, func(conn *sql.Conn, versions map[int64]appliedVersion) error {
+		if err := checkClean(versions); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+
+			if err := m.revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) (statuses []Status, err error) {
+	err = m.locked(ctx, func(conn *sql.Conn, versions map[int64]appliedVersion) error {
+		for _, migration := range m.migrations {
+			status := Status{Migration: migration}
+			if version, ok := versions[migration.Version]; ok {
+				appliedAt := version.AppliedAt
+				status.AppliedAt = &appliedAt
+				status.Dirty = version.Dirty
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return
+}
+
+// locked runs the function holding the advisory lock, with the versions that
+// have been applied.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, versions map[int64]appliedVersion) error) error {
+	// advisory locks belong to a connection, so everything runs on one
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int64(m.lockTimeout.Seconds())).Scan(&acquired)
+	if err != nil {
+		return err
+	}
+	if acquired.Int64 != 1 {
+		return fmt.Errorf("timed out waiting for another migration to finish")
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName); err != nil {
+			log.Warn().Err(err).Msg("Failed releasing the migration lock")
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS `+versionTable+` (
+			version BIGINT NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			dirty TINYINT(1) NOT NULL DEFAULT 0,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (version)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8`)
+	if err != nil {
+		return err
+	}
+
+	versions, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, versions)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]appliedVersion, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, dirty, applied_at FROM "+versionTable+" ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[int64]appliedVersion)
+	for rows.Next() {
+		var version appliedVersion
+		if err := rows.Scan(&version.Version, &version.Name, &version.Dirty, &version.AppliedAt); err != nil {
+			return nil, err
+		}
+		versions[version.Version] = version
+	}
+
+	return versions, rows.Err()
+}
+
+// apply runs an up migration. The version is recorded as dirty first, as
+// MySQL commits DDL statements implicitly and a failing migration can't be
+// rolled back.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	log.Info().Int64("version", migration.Version).Str("name", migration.Name).Msg("Applying migration")
+	_, err := conn.ExecContext(ctx, "INSERT INTO "+versionTable+" (version, name, dirty) VALUES (?, ?, 1)", migration.Version, migration.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := execScript(ctx, conn, migration.Up); err != nil {
+		return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+
+	_, err = conn.ExecContext(ctx, "UPDATE "+versionTable+" SET dirty = 0, applied_at = CURRENT_TIMESTAMP WHERE version = ?", migration.Version)
+	return err
+}
+
+// revert runs a down migration.
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	log.Info().Int64("version", migration.Version).Str("name", migration.Name).Msg("Reverting migration")
+	_, err := conn.ExecContext(ctx, "UPDATE "+versionTable+" SET dirty = 1 WHERE version = ?", migration.Version)
+	if err != nil {
+		return err
+	}
+
+	if err := execScript(ctx, conn, migration.Down); err != nil {
+		return fmt.Errorf("reverting migration %d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+
+	_, err = conn.ExecContext(ctx, "DELETE FROM "+versionTable+" WHERE version = ?", migration.Version)
+	return err
+}
+
+// checkClean refuses to migrate while a migration is dirty.
+func checkClean(versions map[int64]appliedVersion) error {
+	for _, version := range versions {
+		if version.Dirty {
+			return fmt.Errorf("migration %d_%s is dirty, fix the schema and its row in %s by hand", version.Version, version.Name, versionTable)
+		}
+	}
+	return nil
+}
+
+func execScript(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, statement := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event"
+	"github.com/evermos/boilerplate-go/event/consumer"
+	"github.com/evermos/boilerplate-go/event/producer"
+	"github.com/evermos/boilerplate-go/event/replay"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/rs/zerolog/log"
+)
+
+// ReplayDependencies contains what the replay command needs to read and
+// replay events.
+type ReplayDependencies struct {
+	Config    *configs.Config
+	DB        *infras.MySQLConn
+	Consumers event.Consumers
+	// Producer publishes straight to SNS, so replayed events aren't recorded
+	// in the outbox again.
+	Producer *producer.SNSProducer
+}
+
+// replayFlags contains the flags of the replay command.
+type replayFlags struct {
+	source            string
+	queueURL          string
+	file              string
+	visibilityTimeout int64
+	eventTypes        string
+	from              string
+	to                string
+	target            string
+	topic             string
+	fallback          string
+	dryRun            bool
+	progressEvery     int
+}
+
+// runReplay runs the replay command, which redrives events from a dead-letter
+// queue, the outbox table or a JSONL dump into a domain consumer or back to
+// the producer. It returns the exit code.
+func runReplay(args []string) int {
+	f := replayFlags{}
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.StringVar(&f.source, "source", "", "where to read events from: dlq, outbox or jsonl")
+	fs.StringVar(&f.queueURL, "queue-url", "", "URL of the dead-letter queue, for the dlq source")
+	fs.StringVar(&f.file, "file", "-", "path of the JSONL dump, or - for stdin, for the jsonl source")
+	fs.Int64Var(&f.visibilityTimeout, "visibility-timeout", 900, "seconds read messages stay hidden in the dead-letter queue during the run; the ones not replayed are made visible again when it ends")
+	fs.StringVar(&f.eventTypes, "event-type", "", "comma separated event types to replay, all if empty")
+	fs.StringVar(&f.from, "from", "", "replay events at or after this RFC 3339 time")
+	fs.StringVar(&f.to, "to", "", "replay events before this RFC 3339 time")
+	fs.StringVar(&f.target, "target", "consumer", "where to replay events to: consumer or producer")
+	fs.StringVar(&f.topic, "topic", "", "topic to publish to instead of the original one, for the producer target")
+	fs.StringVar(&f.fallback, "fallback-event-type", "", "consumer to use for events without a known event type, for the consumer target")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "list the matching events without replaying them, leaving the dead-letter queue as it was")
+	fs.IntVar(&f.progressEvery, "progress-every", 100, "log the progress every that many events, 0 to disable")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	filter, err := f.filter()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	deps := InitializeReplay()
+
+	source, closeSource, err := f.createSource(deps, filter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer closeSource()
+
+	target, err := f.createTarget(deps)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// stop between events on interrupt, so nothing is left half replayed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	report, err := replay.Run(ctx, source, target, replay.Options{
+		Filter:        filter,
+		DryRun:        f.dryRun,
+		ProgressEvery: f.progressEvery,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Replay stopped")
+		return 1
+	}
+	if report.Failed > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+func (f replayFlags) filter() (filter replay.Filter, err error) {
+	for _, eventType := range strings.Split(f.eventTypes, ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			filter.EventTypes = append(filter.EventTypes, eventType)
+		}
+	}
+
+	if f.from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, f.from); err != nil {
+			return filter, fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+
+	if f.to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, f.to); err != nil {
+			return filter, fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+
+	return
+}
+
+func (f replayFlags) createSource(deps ReplayDependencies, filter replay.Filter) (replay.Source, func(), error) {
+	noop := func() {}
+
+	switch f.source {
+	case "dlq":
+		if f.queueURL == "" {
+			return nil, noop, fmt.Errorf("-queue-url is required for the dlq source")
+		}
+		sess, err := consumer.NewSQSSession(deps.Config)
+		if err != nil {
+			return nil, noop, err
+		}
+		return replay.NewSQSSource(sqs.New(sess), f.queueURL, f.visibilityTimeout), noop, nil
+	case "outbox":
+		return replay.NewOutboxSource(deps.DB.Read, filter), noop, nil
+	case "jsonl":
+		var reader io.ReadCloser = os.Stdin
+		if f.file != "-" {
+			file, err := os.Open(f.file)
+			if err != nil {
+				return nil, noop, err
+			}
+			reader = file
+		}
+		return replay.NewJSONLSource(reader), func() { reader.Close() }, nil
+	default:
+		return nil, noop, fmt.Errorf("unknown -source %q, expected dlq, outbox or jsonl", f.source)
+	}
+}
+
+func (f replayFlags) createTarget(deps ReplayDependencies) (replay.Target, error) {
+	switch f.target {
+	case "consumer":
+		return replay.NewConsumerTarget(deps.Consumers.Processes(), f.fallback), nil
+	case "producer":
+		return replay.NewProducerTarget(deps.Producer, f.topic), nil
+	default:
+		return nil, fmt.Errorf("unknown -target %q, expected consumer or producer", f.target)
+	}
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+)
+
+// HeaderAPIKey is the header carrying the API keys.
+const HeaderAPIKey = "X-API-Key"
+
+// querySelectAPIKey resolves the API keys of the enabled OAuth clients, as
+// disabling a client revokes its keys too.
+const querySelectAPIKey = `
+	SELECT
+		api_keys.client_id,
+		api_keys.scope
+	FROM
+		api_keys
+		JOIN oauth_clients ON oauth_clients.client_id = api_keys.client_id AND oauth_clients.disabled_at IS NULL
+	WHERE
+		api_keys.key_hash = ? AND api_keys.revoked_at IS NULL`
+
+// APIKeyAuthenticator authenticates the long lived API keys of the OAuth
+// clients, kept in api_keys as their SHA-256 hashes.
+type APIKeyAuthenticator struct {
+	db *sqlx.DB
+}
+
+// NewAPIKeyAuthenticator creates an APIKeyAuthenticator.
+func NewAPIKeyAuthenticator(db *sqlx.DB) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{
+		db: db,
+	}
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	key := r.Header.Get(HeaderAPIKey)
+	if key == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	var apiKey struct {
+		ClientID string      `db:"client_id"`
+		Scope    null.String `db:"scope"`
+	}
+	sum := sha256.Sum256([]byte(key))
+	err := a.db.GetContext(r.Context(), &apiKey, querySelectAPIKey, hex.EncodeToString(sum[:]))
+	switch {
+	case err == sql.ErrNoRows:
+		return Principal{}, ErrInvalidCredentials
+	case err != nil:
+		return Principal{}, err
+	}
+
+	return Principal{
+		ClientID: apiKey.ClientID,
+		Scopes:   strings.Fields(apiKey.Scope.String),
+	}, nil
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// The strategies of the Authenticators, as listed in AUTH.STRATEGIES.
+const (
+	StrategyJWT    = "jwt"
+	StrategyOAuth  = "oauth"
+	StrategyAPIKey = "api_key"
+)
+
+// Strategies are the known strategies, in their default order.
+var Strategies = []string{StrategyJWT, StrategyOAuth, StrategyAPIKey}
+
+var (
+	// ErrNoCredentials is returned by an Authenticator for the requests
+	// without the credentials it handles, so that the next one is tried.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned by an Authenticator for the
+	// credentials it handles but rejects, such as expired tokens.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator authenticates requests by one kind of credentials. Other
+// errors than ErrNoCredentials and ErrInvalidCredentials mean the
+// credentials couldn't be checked.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+// AuthenticatorFunc is a function authenticating requests.
+type AuthenticatorFunc func(r *http.Request) (Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (Principal, error) {
+	return f(r)
+}
+
+// Chain tries Authenticators in order, until one handles the credentials of
+// the request.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (Principal, error) {
+	for _, authenticator := range c {
+		principal, err := authenticator.Authenticate(r)
+		if !errors.Is(err, ErrNoCredentials) {
+			return principal, err
+		}
+	}
+
+	return Principal{}, ErrNoCredentials
+}
+
+// BearerToken returns the bearer token of a request, RFC 6750 section 2.1.
+func BearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) {
+		return "", false
+	}
+
+	token := strings.TrimSpace(header[len(prefix):])
+	return token, token != ""
+}
+
+// IsJWT reports whether a bearer token is shaped like a JWT, so the
+// strategies tell JWTs from opaque tokens without checking them.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
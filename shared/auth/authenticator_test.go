@@ -0,0 +1,139 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// strategy authenticates the requests with a header, as a fixed principal.
+func strategy(header string, principal auth.Principal) auth.Authenticator {
+	return auth.AuthenticatorFunc(func(r *http.Request) (auth.Principal, error) {
+		switch r.Header.Get(header) {
+		case "":
+			return auth.Principal{}, auth.ErrNoCredentials
+		case "valid":
+			return principal, nil
+		default:
+			return auth.Principal{}, auth.ErrInvalidCredentials
+		}
+	})
+}
+
+func TestChain(t *testing.T) {
+	first := auth.Principal{ClientID: "first"}
+	second := auth.Principal{ClientID: "second"}
+	chain := auth.Chain{strategy("X-First", first), strategy("X-Second", second)}
+
+	t.Run("First Strategy Handling The Credentials", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Second", "valid")
+
+		principal, err := chain.Authenticate(r)
+
+		assert.NoError(t, err)
+		assert.Equal(t, second, principal)
+	})
+
+	t.Run("Invalid Credentials Stop The Chain", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-First", "forged")
+		r.Header.Set("X-Second", "valid")
+
+		_, err := chain.Authenticate(r)
+
+		assert.True(t, errors.Is(err, auth.ErrInvalidCredentials))
+	})
+
+	t.Run("No Credentials", func(t *testing.T) {
+		_, err := chain.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.True(t, errors.Is(err, auth.ErrNoCredentials))
+	})
+}
+
+func TestPrincipal(t *testing.T) {
+	userID := uuid.Must(uuid.NewV4())
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{
+		UserID:   userID,
+		ClientID: "client_web",
+		Roles:    []string{"admin"},
+		Scopes:   []string{"user", "foo:read"},
+	})
+
+	principal, ok := auth.PrincipalFromContext(ctx)
+	assert.True(t, ok)
+	assert.True(t, principal.HasRole("admin"))
+	assert.False(t, principal.HasRole("user"))
+	assert.True(t, principal.HasScopes("foo:read", "user"))
+	assert.False(t, principal.HasScopes("foo:read", "foo:write"))
+
+	id, ok := auth.UserIDFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, userID, id)
+
+	clientID, ok := auth.ClientIDFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "client_web", clientID)
+
+	_, ok = auth.UserIDFromContext(auth.WithPrincipal(context.Background(), auth.Principal{ClientID: "client_web"}))
+	assert.False(t, ok, "clients aren't users")
+	_, ok = auth.PrincipalFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	newAuthenticator := func(t *testing.T) (*auth.APIKeyAuthenticator, sqlmock.Sqlmock) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		return auth.NewAPIKeyAuthenticator(sqlx.NewDb(db, "mysql")), mock
+	}
+	withKey := func(key string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(auth.HeaderAPIKey, key)
+		return r
+	}
+	// the SHA-256 hash of partner-key
+	const keyHash = "346e50af211b5135824bb2bb58fe0f9e6df228adcf10c58a37fbc46b57baee74"
+
+	t.Run("Key Of Client", func(t *testing.T) {
+		authenticator, mock := newAuthenticator(t)
+		mock.ExpectQuery(`FROM\s+api_keys`).WithArgs(keyHash).WillReturnRows(
+			sqlmock.NewRows([]string{"client_id", "scope"}).AddRow("partner_app", "foo:read"))
+
+		principal, err := authenticator.Authenticate(withKey("partner-key"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, auth.Principal{ClientID: "partner_app", Scopes: []string{"foo:read"}}, principal)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Or Revoked Key", func(t *testing.T) {
+		authenticator, mock := newAuthenticator(t)
+		mock.ExpectQuery(`FROM\s+api_keys`).WillReturnRows(sqlmock.NewRows([]string{"client_id", "scope"}))
+
+		_, err := authenticator.Authenticate(withKey("revoked-key"))
+
+		assert.True(t, errors.Is(err, auth.ErrInvalidCredentials))
+	})
+
+	t.Run("No Key", func(t *testing.T) {
+		authenticator, _ := newAuthenticator(t)
+
+		_, err := authenticator.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.True(t, errors.Is(err, auth.ErrNoCredentials))
+	})
+}
@@ -0,0 +1,80 @@
+// Package auth authenticates requests, whatever their credentials, as a
+// Principal bound to their context.
+package auth
+
+import (
+	"context"
+
+	"github.com/gofrs/uuid"
+)
+
+// Principal is who a request is authenticated as: a user, signed in with a
+// JWT or through an OAuth client, or an OAuth client acting on its own.
+type Principal struct {
+	// UserID is the user, unset for the tokens and API keys of clients.
+	UserID uuid.UUID
+	// ClientID is the OAuth client, unset for JWTs.
+	ClientID string
+	Roles    []string
+	Scopes   []string
+}
+
+// HasUser reports whether the principal is a user.
+func (p Principal) HasUser() bool {
+	return p.UserID != uuid.Nil
+}
+
+// HasRole reports whether the principal holds a role.
+func (p Principal) HasRole(role string) bool {
+	for _, held := range p.Roles {
+		if held == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScopes reports whether the principal holds every scope.
+func (p Principal) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		held := false
+		for _, granted := range p.Scopes {
+			if granted == scope {
+				held = true
+				break
+			}
+		}
+		if !held {
+			return false
+		}
+	}
+	return true
+}
+
+type principalContextKey struct{}
+
+// WithPrincipal binds the principal authenticated by a request to its
+// context.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal authenticated by a request, if
+// any.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(Principal)
+	return principal, ok
+}
+
+// UserIDFromContext returns the user authenticated by a request, if any.
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	principal, _ := PrincipalFromContext(ctx)
+	return principal.UserID, principal.HasUser()
+}
+
+// ClientIDFromContext returns the OAuth client authenticated by a request,
+// if any.
+func ClientIDFromContext(ctx context.Context) (string, bool) {
+	principal, _ := PrincipalFromContext(ctx)
+	return principal.ClientID, principal.ClientID != ""
+}
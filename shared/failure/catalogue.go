@@ -0,0 +1,91 @@
+package failure
+
+import "net/http"
+
+// Code identifies a failure for clients, who may branch on it. Codes are
+// part of the API: add new ones, but never rename or reuse one.
+type Code string
+
+// The generic codes, used by the constructors of this package.
+const (
+	CodeBadRequest           Code = "BAD_REQUEST"
+	CodeInvalidRequest       Code = "INVALID_REQUEST"
+	CodeUnauthorized         Code = "UNAUTHORIZED"
+	CodeForbidden            Code = "FORBIDDEN"
+	CodeInsufficientScope    Code = "INSUFFICIENT_SCOPE"
+	CodeNotFound             Code = "NOT_FOUND"
+	CodeConflict             Code = "CONFLICT"
+	CodeRequestTooLarge      Code = "REQUEST_TOO_LARGE"
+	CodeUnsupportedMediaType Code = "UNSUPPORTED_MEDIA_TYPE"
+	CodeRateLimited          Code = "RATE_LIMITED"
+	CodeInternal             Code = "INTERNAL_ERROR"
+	CodeUnimplemented        Code = "NOT_IMPLEMENTED"
+	CodeTimeout              Code = "TIMEOUT"
+	CodeUnavailable          Code = "SERVICE_UNAVAILABLE"
+)
+
+// The codes of the domains.
+const (
+	CodeCartNotFound       Code = "CART_NOT_FOUND"
+	CodeCartEmpty          Code = "CART_EMPTY"
+	CodeCartAlreadyExists  Code = "CART_ALREADY_EXISTS"
+	CodeCartItemOutOfStock Code = "CART_ITEM_OUT_OF_STOCK"
+
+	CodeFooNotFound               Code = "FOO_NOT_FOUND"
+	CodeFooInvalid                Code = "FOO_INVALID"
+	CodeFooAlreadyExists          Code = "FOO_ALREADY_EXISTS"
+	CodeFooAlreadyDeleted         Code = "FOO_ALREADY_DELETED"
+	CodeFooStatusChangeNotAllowed Code = "FOO_STATUS_CHANGE_NOT_ALLOWED"
+
+	CodeProductNotFound Code = "PRODUCT_NOT_FOUND"
+
+	CodeUserNotFound           Code = "USER_NOT_FOUND"
+	CodeUserAlreadyExists      Code = "USER_ALREADY_EXISTS"
+	CodeUserEmailTaken         Code = "USER_EMAIL_TAKEN"
+	CodeUserInvalidCredentials Code = "USER_INVALID_CREDENTIALS"
+)
+
+// statuses are the HTTP statuses of the codes.
+var statuses = map[Code]int{
+	CodeBadRequest:           http.StatusBadRequest,
+	CodeInvalidRequest:       http.StatusBadRequest,
+	CodeUnauthorized:         http.StatusUnauthorized,
+	CodeForbidden:            http.StatusForbidden,
+	CodeInsufficientScope:    http.StatusForbidden,
+	CodeNotFound:             http.StatusNotFound,
+	CodeConflict:             http.StatusConflict,
+	CodeRequestTooLarge:      http.StatusRequestEntityTooLarge,
+	CodeUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	CodeRateLimited:          http.StatusTooManyRequests,
+	CodeInternal:             http.StatusInternalServerError,
+	CodeUnimplemented:        http.StatusNotImplemented,
+	CodeTimeout:              http.StatusGatewayTimeout,
+	CodeUnavailable:          http.StatusServiceUnavailable,
+
+	CodeCartNotFound:       http.StatusNotFound,
+	CodeCartEmpty:          http.StatusUnprocessableEntity,
+	CodeCartAlreadyExists:  http.StatusConflict,
+	CodeCartItemOutOfStock: http.StatusConflict,
+
+	CodeFooNotFound:               http.StatusNotFound,
+	CodeFooInvalid:                http.StatusBadRequest,
+	CodeFooAlreadyExists:          http.StatusConflict,
+	CodeFooAlreadyDeleted:         http.StatusConflict,
+	CodeFooStatusChangeNotAllowed: http.StatusConflict,
+
+	CodeProductNotFound: http.StatusNotFound,
+
+	CodeUserNotFound:           http.StatusNotFound,
+	CodeUserAlreadyExists:      http.StatusConflict,
+	CodeUserEmailTaken:         http.StatusConflict,
+	CodeUserInvalidCredentials: http.StatusUnauthorized,
+}
+
+// Status returns the HTTP status of a code, 500 Internal Server Error for
+// codes missing from the catalogue.
+func (c Code) Status() int {
+	if status, ok := statuses[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
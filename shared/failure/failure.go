@@ -1,27 +1,89 @@
 package failure
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 )
 
 // Failure is a wrapper for error messages and codes using standard HTTP response codes.
+// Its ErrorCode tells clients which failure of the catalogue it is.
 type Failure struct {
-	Code    int    `json:"code"`
+	Code      int    `json:"code"`
+	ErrorCode Code   `json:"errorCode"`
+	Message   string `json:"message"`
+	// Fields lists the invalid fields of a request, if any.
+	Fields []FieldError `json:"fields,omitempty"`
+	// cause is the error behind the failure. It's logged, but never sent to
+	// clients.
+	cause error
+}
+
+// FieldError describes a field of a request failing a validation rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
 	Message string `json:"message"`
 }
 
-// Error returns the error code and message in a formatted string.
+// New returns a new Failure of a code of the catalogue.
+func New(code Code, msg string) error {
+	return &Failure{
+		Code:      code.Status(),
+		ErrorCode: code,
+		Message:   msg,
+	}
+}
+
+// Wrap returns a new Failure of a code of the catalogue caused by err. The
+// cause is kept for the logs and errors.Is/As, but hidden from clients.
+func Wrap(err error, code Code, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return &Failure{
+		Code:      code.Status(),
+		ErrorCode: code,
+		Message:   msg,
+		cause:     err,
+	}
+}
+
+// Error returns the error code and message in a formatted string, followed
+// by the cause if any.
 func (e *Failure) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %v", e.Public(), e.cause)
+	}
+	return e.Public()
+}
+
+// Public returns the error code and message in a formatted string, without
+// the cause, to be sent to clients.
+func (e *Failure) Public() string {
 	return fmt.Sprintf("%s: %s", http.StatusText(e.Code), e.Message)
 }
 
+// Unwrap returns the cause of the failure.
+func (e *Failure) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether the failure has the same error code as target, so
+// failures match by their code with errors.Is, see HasCode.
+func (e *Failure) Is(target error) bool {
+	t, ok := target.(*Failure)
+	return ok && t.ErrorCode != "" && t.ErrorCode == e.ErrorCode
+}
+
 // BadRequest returns a new Failure with code for bad requests.
 func BadRequest(err error) error {
 	if err != nil {
 		return &Failure{
-			Code:    http.StatusBadRequest,
-			Message: err.Error(),
+			Code:      http.StatusBadRequest,
+			ErrorCode: CodeBadRequest,
+			Message:   err.Error(),
 		}
 	}
 	return nil
@@ -29,59 +91,116 @@ func BadRequest(err error) error {
 
 // BadRequestFromString returns a new Failure with code for bad requests with message set from string.
 func BadRequestFromString(msg string) error {
+	return New(CodeBadRequest, msg)
+}
+
+// InvalidFields returns a new Failure with code for bad requests listing the invalid fields.
+func InvalidFields(fields []FieldError) error {
 	return &Failure{
-		Code:    http.StatusBadRequest,
-		Message: msg,
+		Code:      CodeInvalidRequest.Status(),
+		ErrorCode: CodeInvalidRequest,
+		Message:   "invalid request",
+		Fields:    fields,
 	}
 }
 
 // Unauthorized returns a new Failure with code for unauthorized requests.
 func Unauthorized(msg string) error {
-	return &Failure{
-		Code:    http.StatusUnauthorized,
-		Message: msg,
-	}
+	return New(CodeUnauthorized, msg)
+}
+
+// Forbidden returns a new Failure with code for authenticated requests which aren't allowed.
+func Forbidden(msg string) error {
+	return New(CodeForbidden, msg)
 }
 
-// InternalError returns a new Failure with code for internal error and message derived from an error interface.
+// InsufficientScope returns a new Failure with code for tokens lacking the scopes required by a request.
+func InsufficientScope(msg string) error {
+	return New(CodeInsufficientScope, msg)
+}
+
+// InternalError returns a new Failure with code for internal error caused by an error interface.
+// The cause is hidden from clients.
 func InternalError(err error) error {
-	if err != nil {
-		return &Failure{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
-	}
-	return nil
+	return Wrap(err, CodeInternal, "internal error")
 }
 
 // Unimplemented returns a new Failure with code for unimplemented method.
 func Unimplemented(methodName string) error {
-	return &Failure{
-		Code:    http.StatusNotImplemented,
-		Message: methodName,
-	}
+	return New(CodeUnimplemented, methodName)
 }
 
 // NotFound returns a new Failure with code for entity not found.
 func NotFound(entityName string) error {
-	return &Failure{
-		Code:    http.StatusNotFound,
-		Message: entityName,
-	}
+	return New(CodeNotFound, entityName)
 }
 
 // Conflict returns a new Failure with code for conflict situations.
 func Conflict(operationName string, entityName string, message string) error {
-	return &Failure{
-		Code:    http.StatusConflict,
-		Message: fmt.Sprintf("%s on %s: %s", operationName, entityName, message),
+	return New(CodeConflict, fmt.Sprintf("%s on %s: %s", operationName, entityName, message))
+}
+
+// GatewayTimeout returns a new Failure with code for requests that ran out of time.
+func GatewayTimeout(msg string) error {
+	return New(CodeTimeout, msg)
+}
+
+// ServiceUnavailable returns a new Failure with code for requests depending on an unavailable service.
+func ServiceUnavailable(msg string) error {
+	return New(CodeUnavailable, msg)
+}
+
+// RequestEntityTooLarge returns a new Failure with code for request bodies over the size limit.
+func RequestEntityTooLarge(msg string) error {
+	return New(CodeRequestTooLarge, msg)
+}
+
+// UnsupportedMediaType returns a new Failure with code for request bodies of an unsupported content type.
+func UnsupportedMediaType(msg string) error {
+	return New(CodeUnsupportedMediaType, msg)
+}
+
+// TooManyRequests returns a new Failure with code for clients exceeding their rate limit.
+func TooManyRequests(msg string) error {
+	return New(CodeRateLimited, msg)
+}
+
+// As returns the Failure in the chain of an error, if any.
+func As(err error) (*Failure, bool) {
+	var f *Failure
+	if errors.As(err, &f) {
+		return f, true
 	}
+	return nil, false
 }
 
-// GetCode returns the error code of an error interface.
+// HasCode reports whether the chain of an error holds a failure of a code,
+// including the causes of the failures.
+func HasCode(err error, code Code) bool {
+	return errors.Is(err, &Failure{ErrorCode: code})
+}
+
+// GetCode returns the error code of an error interface, looking through
+// wrapped errors. Errors caused by an expired request deadline are reported
+// as timeouts.
 func GetCode(err error) int {
-	if f, ok := err.(*Failure); ok {
+	if f, ok := As(err); ok {
 		return f.Code
 	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
 	return http.StatusInternalServerError
 }
+
+// GetErrorCode returns the code of the catalogue of an error interface,
+// looking through wrapped errors.
+func GetErrorCode(err error) Code {
+	if f, ok := As(err); ok && f.ErrorCode != "" {
+		return f.ErrorCode
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return CodeTimeout
+	}
+	return CodeInternal
+}
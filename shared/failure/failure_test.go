@@ -0,0 +1,57 @@
+package failure_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailure(t *testing.T) {
+	t.Run("Codes Of Wrapped Failures", func(t *testing.T) {
+		err := fmt.Errorf("failed to fetch orders: %w", failure.New(failure.CodeProductNotFound, "product"))
+
+		assert.Equal(t, http.StatusNotFound, failure.GetCode(err))
+		assert.Equal(t, failure.CodeProductNotFound, failure.GetErrorCode(err))
+	})
+
+	t.Run("Codes Of Other Errors", func(t *testing.T) {
+		assert.Equal(t, http.StatusInternalServerError, failure.GetCode(sql.ErrConnDone))
+		assert.Equal(t, failure.CodeInternal, failure.GetErrorCode(sql.ErrConnDone))
+
+		timeout := fmt.Errorf("query: %w", context.DeadlineExceeded)
+		assert.Equal(t, http.StatusGatewayTimeout, failure.GetCode(timeout))
+		assert.Equal(t, failure.CodeTimeout, failure.GetErrorCode(timeout))
+	})
+
+	t.Run("Hide Cause From Clients", func(t *testing.T) {
+		err := failure.InternalError(errors.New("dial tcp 10.0.0.5:3306: connection refused"))
+
+		f, ok := failure.As(err)
+		assert.True(t, ok)
+		assert.Equal(t, "Internal Server Error: internal error", f.Public())
+		assert.Equal(t, "Internal Server Error: internal error: dial tcp 10.0.0.5:3306: connection refused", err.Error())
+	})
+
+	t.Run("Chain Causes", func(t *testing.T) {
+		notFound := failure.Wrap(sql.ErrNoRows, failure.CodeUserNotFound, "user")
+		err := failure.Wrap(notFound, failure.CodeUserInvalidCredentials, "invalid email or password")
+
+		assert.Equal(t, http.StatusUnauthorized, failure.GetCode(err))
+		assert.True(t, errors.Is(err, sql.ErrNoRows))
+		assert.True(t, failure.HasCode(err, failure.CodeUserInvalidCredentials))
+		assert.True(t, failure.HasCode(err, failure.CodeUserNotFound))
+		assert.False(t, failure.HasCode(err, failure.CodeCartNotFound))
+		assert.Nil(t, failure.Wrap(nil, failure.CodeInternal, "nothing"))
+	})
+
+	t.Run("Statuses Of The Catalogue", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, failure.CodeCartItemOutOfStock.Status())
+		assert.Equal(t, http.StatusInternalServerError, failure.Code("UNKNOWN").Status())
+	})
+}
@@ -0,0 +1,46 @@
+package jwt
+
+import (
+	"net/http"
+
+	"github.com/evermos/boilerplate-go/shared/auth"
+)
+
+// Authenticator authenticates the users by the JWTs issued at login,
+// refusing the revoked ones.
+type Authenticator struct {
+	revocations *RevocationList
+}
+
+// NewAuthenticator creates an Authenticator.
+func NewAuthenticator(revocations *RevocationList) *Authenticator {
+	return &Authenticator{
+		revocations: revocations,
+	}
+}
+
+func (a *Authenticator) Authenticate(r *http.Request) (auth.Principal, error) {
+	token, ok := auth.BearerToken(r)
+	if !ok || !auth.IsJWT(token) {
+		return auth.Principal{}, auth.ErrNoCredentials
+	}
+
+	claims, err := ValidateToken(token)
+	if err != nil {
+		return auth.Principal{}, auth.ErrInvalidCredentials
+	}
+
+	revoked, err := a.revocations.Revoked(r.Context(), token)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	if revoked {
+		return auth.Principal{}, auth.ErrInvalidCredentials
+	}
+
+	principal := auth.Principal{UserID: claims.ID}
+	if claims.Role != "" {
+		principal.Roles = []string{claims.Role}
+	}
+	return principal, nil
+}
@@ -1,13 +1,9 @@
 package jwt
 
 import (
-	"context"
 	"fmt"
 	"github.com/gofrs/uuid"
 	"github.com/golang-jwt/jwt"
-	"github.com/rs/zerolog/log"
-	"net/http"
-	"strings"
 	"time"
 )
 
@@ -38,34 +34,6 @@ func ValidateToken(tokenString string) (*Claims, error) {
 	return nil, fmt.Errorf("JWT is not valid")
 }
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		tokenString := r.Header.Get("Authorization")
-		if tokenString == "" {
-			log.Info().Msg("No authorization header")
-			http.Error(w, "Unauthorized: Token missing", http.StatusUnauthorized)
-			return
-		}
-
-		token := strings.TrimPrefix(tokenString, "Bearer ")
-		if token == "" {
-			log.Info().Msg("Invalid token format")
-			http.Error(w, "Unauthorized: Invalid token format", http.StatusUnauthorized)
-			return
-		}
-
-		claims, err := ValidateToken(token)
-		if err != nil {
-			log.Error().Err(err)
-			http.Error(w, "Unauthorized: Token invalid", http.StatusUnauthorized)
-			return
-		}
-
-		ctx := context.WithValue(r.Context(), "claims", claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
-
 func GenerateJWT(id uuid.UUID, email, role string) (string, error) {
 	expTime := time.Now().Add(60 * time.Minute)
 	claims := &Claims{
@@ -0,0 +1,50 @@
+package jwt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const revocationKeyPrefix = "jwt:revoked:"
+
+// RevocationList keeps the revoked JWTs in Redis until they expire, as JWTs
+// can't be revoked by themselves.
+type RevocationList struct {
+	cache *redis.Client
+}
+
+// NewRevocationList creates a RevocationList.
+func NewRevocationList(cache *redis.Client) *RevocationList {
+	return &RevocationList{cache: cache}
+}
+
+// Revoke revokes a JWT until its expiry. Expired JWTs are invalid anyway.
+func (l *RevocationList) Revoke(ctx context.Context, tokenString string, claims *Claims) error {
+	ttl := time.Until(time.Unix(claims.ExpiresAt, 0))
+	if ttl <= 0 {
+		return nil
+	}
+
+	return l.cache.WithContext(ctx).Set(revocationKey(tokenString), 1, ttl).Err()
+}
+
+// Revoked reports whether a JWT was revoked.
+func (l *RevocationList) Revoked(ctx context.Context, tokenString string) (bool, error) {
+	count, err := l.cache.WithContext(ctx).Exists(revocationKey(tokenString)).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// revocationKey keys the revoked JWTs by their hash, so the list doesn't
+// hold usable tokens.
+func revocationKey(tokenString string) string {
+	sum := sha256.Sum256([]byte(tokenString))
+	return revocationKeyPrefix + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestKey struct{}
+
+// request is what a context knows about the request being served.
+type request struct {
+	id     string
+	userID string
+	route  func() string
+}
+
+// WithRequest binds a request to the context, identified by its request ID.
+// The route is resolved when logging, as it is only known once the request
+// has been routed.
+func WithRequest(ctx context.Context, requestID string, route func() string) context.Context {
+	return context.WithValue(ctx, requestKey{}, request{id: requestID, route: route})
+}
+
+// WithUserID adds the authenticated user to the request bound to the context.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	req, _ := ctx.Value(requestKey{}).(request)
+	req.userID = userID
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+// RequestID returns the ID of the request bound to the context, if any.
+func RequestID(ctx context.Context) string {
+	req, _ := ctx.Value(requestKey{}).(request)
+	return req.id
+}
+
+// Ctx returns a logger carrying the request ID, user ID and route of the
+// request bound to the context, and the trace it is part of, so the logs of
+// one request can be followed.
+func Ctx(ctx context.Context) *zerolog.Logger {
+	req, ok := ctx.Value(requestKey{}).(request)
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !ok && !spanContext.IsValid() {
+		return &log.Logger
+	}
+
+	fields := log.With()
+	if req.id != "" {
+		fields = fields.Str("requestID", req.id)
+	}
+	if req.userID != "" {
+		fields = fields.Str("userID", req.userID)
+	}
+	if req.route != nil {
+		if route := req.route(); route != "" {
+			fields = fields.Str("route", route)
+		}
+	}
+	if spanContext.IsValid() {
+		fields = fields.
+			Str("traceID", spanContext.TraceID().String()).
+			Str("spanID", spanContext.SpanID().String())
+	}
+
+	logger := fields.Logger()
+	return &logger
+}
+
+// ErrorWithStackContext logs an error and its stack trace with the request
+// bound to the context.
+func ErrorWithStackContext(ctx context.Context, err error) {
+	errorWithStack(Ctx(ctx), err)
+}
@@ -1,6 +1,9 @@
 package logger
 
 import (
+	stderrors "errors"
+	"fmt"
+	"io"
 	"os"
 	"time"
 
@@ -10,6 +13,13 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// FormatConsole logs human readable lines, for development.
+	FormatConsole = "console"
+	// FormatJSON logs one JSON object per line, for log collectors.
+	FormatJSON = "json"
+)
+
 // InitLogger initializes the logger
 func InitLogger() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
@@ -22,9 +32,68 @@ func InitLogger() {
 	log.Trace().Msg("Zerolog initialized.")
 }
 
-// ErrorWithStack logs and error and its stack trace with custom formatting.
+// SetLogFormat switches to the log format specified in env var.
+func SetLogFormat(config *configs.Config) {
+	if config.Server.LogFormat != FormatJSON {
+		return
+	}
+
+	zerolog.TimeFieldFormat = time.RFC3339Nano
+	log.Logger = zerolog.New(os.Stdout).With().Timestamp().Logger()
+	log.Trace().Str("format", FormatJSON).Msg("Desired log format detected.")
+}
+
+// SetOutput sends the logs to another writer in the desired log format, such
+// as stderr to keep stdout for the output of a command.
+func SetOutput(config *configs.Config, w io.Writer) {
+	if config.Server.LogFormat == FormatJSON {
+		log.Logger = zerolog.New(w).With().Timestamp().Logger()
+		return
+	}
+
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: w, TimeFormat: time.RFC3339})
+}
+
+// stackTracer is implemented by the errors of github.com/pkg/errors.
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+// ErrorWithStack logs and error and its stack trace as structured fields.
 func ErrorWithStack(err error) {
-	log.Error().Msgf("%+v", errors.WithStack(err))
+	errorWithStack(&log.Logger, err)
+}
+
+func errorWithStack(logger *zerolog.Logger, err error) {
+	logger.Error().Err(err).Strs("stack", stack(err)).Send()
+}
+
+// stack returns the stack trace of where the error was created, if it carries
+// one, or else of where it is logged.
+func stack(err error) []string {
+	var tracer stackTracer
+	for e := err; e != nil; e = stderrors.Unwrap(e) {
+		if t, ok := e.(stackTracer); ok {
+			tracer = t
+		}
+	}
+
+	frames := errors.StackTrace{}
+	if tracer != nil {
+		frames = tracer.StackTrace()
+	} else if t, ok := errors.WithStack(err).(stackTracer); ok {
+		// skip stack, errorWithStack and the exported caller
+		frames = t.StackTrace()
+		if len(frames) > 3 {
+			frames = frames[3:]
+		}
+	}
+
+	lines := make([]string, 0, len(frames))
+	for _, frame := range frames {
+		lines = append(lines, fmt.Sprintf("%n %s:%d", frame, frame, frame))
+	}
+	return lines
 }
 
 // SetLogLevel sets the desired log level specified in env var.
@@ -0,0 +1,86 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	original := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = original })
+	return &buf
+}
+
+func decodeLog(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	entry := make(map[string]interface{})
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestCtx(t *testing.T) {
+	t.Run("Request Fields", func(t *testing.T) {
+		buf := captureLogs(t)
+		ctx := logger.WithRequest(context.Background(), "req-1", func() string { return "/v1/products/{id}" })
+		ctx = logger.WithUserID(ctx, "user-1")
+
+		logger.Ctx(ctx).Info().Msg("hello")
+
+		entry := decodeLog(t, buf)
+		assert.Equal(t, "req-1", entry["requestID"])
+		assert.Equal(t, "user-1", entry["userID"])
+		assert.Equal(t, "/v1/products/{id}", entry["route"])
+		assert.Equal(t, "req-1", logger.RequestID(ctx))
+	})
+
+	t.Run("Without Request", func(t *testing.T) {
+		buf := captureLogs(t)
+
+		logger.Ctx(context.Background()).Info().Msg("hello")
+
+		entry := decodeLog(t, buf)
+		assert.NotContains(t, entry, "requestID")
+		assert.Empty(t, logger.RequestID(context.Background()))
+	})
+}
+
+func TestErrorWithStack(t *testing.T) {
+	t.Run("Stack Of Wrapped Error", func(t *testing.T) {
+		buf := captureLogs(t)
+		err := errors.Wrap(errors.New("failed"), "resolving product")
+
+		logger.ErrorWithStackContext(logger.WithRequest(context.Background(), "req-1", nil), err)
+
+		entry := decodeLog(t, buf)
+		assert.Equal(t, "error", entry["level"])
+		assert.Equal(t, "resolving product: failed", entry["error"])
+		assert.Equal(t, "req-1", entry["requestID"])
+		stack, ok := entry["stack"].([]interface{})
+		assert.True(t, ok)
+		assert.NotEmpty(t, stack)
+		assert.Contains(t, stack[0], "TestErrorWithStack")
+	})
+
+	t.Run("Stack Of Caller", func(t *testing.T) {
+		buf := captureLogs(t)
+
+		logger.ErrorWithStack(context.Canceled)
+
+		entry := decodeLog(t, buf)
+		stack, ok := entry["stack"].([]interface{})
+		assert.True(t, ok)
+		assert.NotEmpty(t, stack)
+		assert.Contains(t, stack[0], "TestErrorWithStack")
+	})
+}
@@ -1,6 +1,8 @@
 package oauth
 
 import (
+	"context"
+
 	"github.com/jmoiron/sqlx"
 )
 
@@ -9,28 +11,35 @@ type GrantType string
 const (
 	ClientCredentials GrantType = "client_credentials"
 	Password          GrantType = "password"
+	RefreshToken      GrantType = "refresh_token"
+	AuthorizationCode GrantType = "authorization_code"
 )
 
 type Token struct {
 	config          Config
-	tokenRepository TokenStore
+	tokenRepository Repository
 }
 
-func New(db *sqlx.DB, config Config) *Token {
+func New(db *sqlx.DB, tokens TokenStore, config Config) *Token {
 	return &Token{
 		config:          config,
-		tokenRepository: NewTokenStore(db),
+		tokenRepository: NewRepository(db, tokens),
 	}
 }
 
 type Config struct {
-	Expiration  int64
-	ClientScope []string
+	Expiration int64
+	// RefreshExpiration is the lifetime of refresh tokens in seconds. Each
+	// rotation issues a refresh token with a new lifetime.
+	RefreshExpiration int64
+	// CodeExpiration is the lifetime of authorization codes in seconds.
+	CodeExpiration int64
+	ClientScope    []string
 }
 
 // Create is function to store NewToken into database
-func (t *Token) Create(credential Credential) (*TokenResponse, error) {
-	grant, err := NewGrant(t.tokenRepository, t.config).Create(credential)
+func (t *Token) Create(ctx context.Context, credential Credential) (*TokenResponse, error) {
+	grant, err := NewGrant(t.tokenRepository, t.config).Create(ctx, credential)
 	if err != nil {
 		return &TokenResponse{}, err
 	}
@@ -39,8 +48,8 @@ func (t *Token) Create(credential Credential) (*TokenResponse, error) {
 }
 
 // ParseWithAccessToken is function to exchange valid token into token info
-func (t *Token) ParseWithAccessToken(accessToken string) (OauthAccessToken, error) {
-	return NewParser(t.tokenRepository).Parse(accessToken)
+func (t *Token) ParseWithAccessToken(ctx context.Context, accessToken string) (OauthAccessToken, error) {
+	return NewParser(t.tokenRepository).Parse(ctx, accessToken)
 }
 
 // ClientScopeAllowed is function that is used to limit the client
@@ -0,0 +1,53 @@
+package oauth
+
+import (
+	"net/http"
+
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/gofrs/uuid"
+)
+
+// Authenticator authenticates the opaque access tokens issued by the token
+// endpoint.
+type Authenticator struct {
+	token *Token
+}
+
+// NewAuthenticator creates an Authenticator resolving the tokens with a
+// Token.
+func NewAuthenticator(token *Token) *Authenticator {
+	return &Authenticator{
+		token: token,
+	}
+}
+
+func (a *Authenticator) Authenticate(r *http.Request) (auth.Principal, error) {
+	token, ok := auth.BearerToken(r)
+	if !ok || auth.IsJWT(token) {
+		return auth.Principal{}, auth.ErrNoCredentials
+	}
+
+	accessToken, err := a.token.ParseWithAccessToken(r.Context(), string(Bearer)+" "+token)
+	if err != nil {
+		if AsError(err).Code == ErrorCodeInvalidToken {
+			return auth.Principal{}, auth.ErrInvalidCredentials
+		}
+		return auth.Principal{}, err
+	}
+
+	if !accessToken.VerifyExpireIn() {
+		return auth.Principal{}, auth.ErrInvalidCredentials
+	}
+
+	return accessToken.Principal(), nil
+}
+
+// Principal returns the principal of an access token: its client, and the
+// user it was issued for, if any.
+func (o *OauthAccessToken) Principal() auth.Principal {
+	return auth.Principal{
+		UserID:   uuid.FromStringOrNil(o.UserID.String),
+		ClientID: o.ClientID,
+		Scopes:   ParseScopes(o.Scope.String),
+	}
+}
@@ -0,0 +1,283 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"regexp"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// ResponseTypeCode is the response type of the authorization code grant.
+const ResponseTypeCode = "code"
+
+// CodeChallengeMethodS256 is the only PKCE transformation supported, as the
+// plain one doesn't protect codes intercepted with their request.
+const CodeChallengeMethodS256 = "S256"
+
+const (
+	ErrorUnsupportedResponseType string = "Response type is not supported"
+	ErrorInvalidRedirectURI      string = "Redirect URI is not registered for the client"
+	ErrorInvalidScope            string = "Scope is not allowed for the client"
+	ErrorInvalidCodeChallenge    string = "code_challenge is required, transformed with S256"
+	ErrorInvalidCode             string = "Invalid authorization code"
+)
+
+// ErrorCodeUnsupportedResponseType is the error of authorization requests for
+// another response than a code, RFC 6749 section 4.1.2.1.
+const ErrorCodeUnsupportedResponseType = "unsupported_response_type"
+
+// pkceValue matches the code verifiers and S256 code challenges, RFC 7636
+// section 4.1.
+var pkceValue = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// AuthorizationRequest is a request of a client for an authorization code of
+// a user, RFC 6749 section 4.1.1, with PKCE, RFC 7636 section 4.3.
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// OauthAuthorizationCode is a single use code exchanged for the tokens of a
+// user. It starts the family of the refresh tokens issued with it, and holds
+// the hash of the access token it was exchanged for once used.
+type OauthAuthorizationCode struct {
+	Code                string      `db:"code"`
+	FamilyID            string      `db:"family_id"`
+	AccessToken         null.String `db:"access_token"`
+	ClientID            string      `db:"client_id"`
+	UserID              string      `db:"user_id"`
+	RedirectURI         null.String `db:"redirect_uri"`
+	Scope               null.String `db:"scope"`
+	CodeChallenge       string      `db:"code_challenge"`
+	CodeChallengeMethod string      `db:"code_challenge_method"`
+	Expires             time.Time   `db:"expires"`
+	UsedAt              null.Time   `db:"used_at"`
+}
+
+// VerifyCodeVerifier reports whether the code verifier of a token request
+// matches the code challenge of the authorization request.
+func (o *OauthAuthorizationCode) VerifyCodeVerifier(codeVerifier string) bool {
+	if !pkceValue.MatchString(codeVerifier) {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(codeVerifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(o.CodeChallenge)) == 1
+}
+
+// Authorization is a valid authorization request of a client, awaiting the
+// user to sign in.
+type Authorization struct {
+	ClientID string
+	// RedirectURI is the URI to redirect the user to with the code or the
+	// error.
+	RedirectURI string
+	// Scope is the scope granted to the client.
+	Scope               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+
+	requestedRedirectURI string
+}
+
+// ValidateAuthorization checks an authorization request before the user is
+// asked to sign in. The redirect URI is left empty when the client or the
+// redirect URI are invalid, as the user must not be redirected then; the
+// other errors are sent to the client at the redirect URI.
+func (t *Token) ValidateAuthorization(ctx context.Context, request AuthorizationRequest) (authorization Authorization, err error) {
+	if request.ClientID == "" {
+		err = NewError(ErrorCodeInvalidRequest, "client_id is required")
+		return
+	}
+
+	client, err := t.tokenRepository.resolveClientByClientID(ctx, request.ClientID)
+	if err != nil {
+		if AsError(err).Code == ErrorCodeInvalidClient {
+			err = NewError(ErrorCodeInvalidRequest, ErrorClientNotFound)
+		}
+		return
+	}
+
+	redirectURI, ok := resolveRedirectURI(client, request.RedirectURI)
+	if !ok {
+		err = NewError(ErrorCodeInvalidRequest, ErrorInvalidRedirectURI)
+		return
+	}
+	authorization.RedirectURI = redirectURI
+
+	scope, scopeErr := client.GrantScope(request.Scope)
+
+	switch {
+	case request.ResponseType != ResponseTypeCode:
+		err = NewError(ErrorCodeUnsupportedResponseType, ErrorUnsupportedResponseType)
+	case !client.AllowsGrantType(AuthorizationCode):
+		err = NewError(ErrorCodeUnauthorizedClient, ErrorUnauthorizedClient)
+	case request.CodeChallengeMethod != CodeChallengeMethodS256 || !pkceValue.MatchString(request.CodeChallenge):
+		err = NewError(ErrorCodeInvalidRequest, ErrorInvalidCodeChallenge)
+	case scopeErr != nil:
+		err = scopeErr
+	}
+	if err != nil {
+		return
+	}
+
+	authorization.ClientID = client.ClientID
+	authorization.Scope = scope
+	authorization.CodeChallenge = request.CodeChallenge
+	authorization.CodeChallengeMethod = request.CodeChallengeMethod
+	authorization.requestedRedirectURI = request.RedirectURI
+	return
+}
+
+// Authorize issues an authorization code of a user for a valid authorization.
+func (t *Token) Authorize(ctx context.Context, authorization Authorization, userID string) (code string, err error) {
+	code, err = generateAccessToken()
+	if err != nil {
+		err = NewError(ErrorCodeServerError, ErrorGenerateAccessToken)
+		return
+	}
+	familyID, err := generateAccessToken()
+	if err != nil {
+		err = NewError(ErrorCodeServerError, ErrorGenerateAccessToken)
+		return
+	}
+
+	err = t.tokenRepository.createAuthorizationCode(ctx, OauthAuthorizationCode{
+		Code:                code,
+		FamilyID:            familyID,
+		ClientID:            authorization.ClientID,
+		UserID:              userID,
+		RedirectURI:         null.NewString(authorization.requestedRedirectURI, authorization.requestedRedirectURI != ""),
+		Scope:               null.NewString(authorization.Scope, authorization.Scope != ""),
+		CodeChallenge:       authorization.CodeChallenge,
+		CodeChallengeMethod: authorization.CodeChallengeMethod,
+		Expires:             time.Now().Add(time.Second * time.Duration(t.config.CodeExpiration)),
+	})
+	return
+}
+
+// AuthenticateUser authenticates a user signing in to authorize a client by
+// their email and password, and returns the ID of the user. Unknown users
+// and wrong passwords fail with invalid_grant alike.
+func (t *Token) AuthenticateUser(ctx context.Context, email string, password string) (userID string, err error) {
+	if email == "" || password == "" {
+		err = NewError(ErrorCodeInvalidRequest, ErrorEmptyCredential)
+		return
+	}
+
+	user, err := t.tokenRepository.resolveUserByEmail(ctx, email)
+	if err != nil {
+		return
+	}
+
+	if !user.ValidCredential(Credential{Password: password}) {
+		err = NewError(ErrorCodeInvalidGrant, ErrorInvalidPassword)
+		return
+	}
+
+	return user.ID, nil
+}
+
+// resolveRedirectURI returns the redirect URI of an authorization request,
+// which must be one of the client, or the only one of the client if omitted.
+func resolveRedirectURI(client OauthClient, requested string) (string, bool) {
+	registered := client.RedirectURIs()
+	if requested == "" {
+		if len(registered) != 1 {
+			return "", false
+		}
+		return registered[0], true
+	}
+
+	for _, uri := range registered {
+		if uri == requested {
+			return uri, true
+		}
+	}
+
+	return "", false
+}
+
+type AuthorizationCodeAuth struct {
+	repository Repository
+	config     Config
+}
+
+// Create exchanges an authorization code and its PKCE code verifier for the
+// tokens of the user. Presenting a used code again revokes the tokens issued
+// with it, RFC 6749 section 4.1.2.
+func (c *AuthorizationCodeAuth) Create(ctx context.Context, client OauthClient, credential Credential) (oauthAccessToken OauthAccessToken, err error) {
+	if credential.Code == "" || credential.CodeVerifier == "" {
+		err = NewError(ErrorCodeInvalidRequest, "code and code_verifier are required")
+		return
+	}
+
+	code, err := c.repository.resolveAuthorizationCode(ctx, credential.Code)
+	if err != nil {
+		return
+	}
+
+	if code.ClientID != client.ClientID {
+		err = NewError(ErrorCodeInvalidGrant, ErrorInvalidCode)
+		return
+	}
+
+	if code.UsedAt.Valid {
+		err = c.revokeFamily(ctx, code)
+		return
+	}
+
+	if !time.Now().Before(code.Expires) || code.RedirectURI.String != credential.RedirectURI || !code.VerifyCodeVerifier(credential.CodeVerifier) {
+		err = NewError(ErrorCodeInvalidGrant, ErrorInvalidCode)
+		return
+	}
+
+	accessToken, err := generateAccessToken()
+	if err != nil {
+		err = NewError(ErrorCodeServerError, ErrorGenerateAccessToken)
+		return
+	}
+
+	oauthAccessToken = new(OauthAccessToken).Generate(accessToken, client.ClientID, &code.UserID, code.Scope.String, c.config)
+
+	var refreshToken *OauthRefreshToken
+	if client.AllowsGrantType(RefreshToken) {
+		generated, generateErr := newRefreshToken(&oauthAccessToken, code.FamilyID, c.config)
+		if generateErr != nil {
+			err = generateErr
+			return
+		}
+		refreshToken = &generated
+	}
+
+	err = c.repository.exchangeAuthorizationCode(ctx, code, oauthAccessToken, refreshToken)
+	if err == errAuthorizationCodeUsed {
+		// the concurrent exchange recorded the access token it issued
+		code, err = c.repository.resolveAuthorizationCode(ctx, credential.Code)
+		if err != nil {
+			return
+		}
+		err = c.revokeFamily(ctx, code)
+		return
+	}
+
+	return
+}
+
+func (c *AuthorizationCodeAuth) revokeFamily(ctx context.Context, reused OauthAuthorizationCode) error {
+	err := c.repository.revokeAuthorizationCode(ctx, reused)
+	if err != nil {
+		return err
+	}
+
+	return NewError(ErrorCodeInvalidGrant, ErrorInvalidCode)
+}
@@ -1,33 +1,28 @@
 package oauth
 
 import (
-	"errors"
+	"context"
 )
 
 type ClientCredentialsAuth struct {
-	tokenStore TokenStore
+	repository Repository
 	config     Config
 }
 
-func (c *ClientCredentialsAuth) Create(credential Credential) (oauthAccessToken OauthAccessToken, err error) {
-	client, err := c.tokenStore.resolveClientByClientID(credential.ClientID)
+func (c *ClientCredentialsAuth) Create(ctx context.Context, client OauthClient, credential Credential) (oauthAccessToken OauthAccessToken, err error) {
+	scope, err := client.GrantScope(credential.Scope)
 	if err != nil {
 		return
 	}
 
-	if !client.VerifyClient(credential) {
-		err = errors.New(ErrorInvalidClient)
-		return
-	}
-
 	accessToken, err := generateAccessToken()
 	if err != nil {
-		err = errors.New(ErrorGenerateAccessToken)
+		err = NewError(ErrorCodeServerError, ErrorGenerateAccessToken)
 		return
 	}
 
-	oauthAccessToken = new(OauthAccessToken).Generate(accessToken, credential.ClientID, nil, true, c.config)
-	err = c.tokenStore.createAccessToken(oauthAccessToken)
+	oauthAccessToken = new(OauthAccessToken).Generate(accessToken, client.ClientID, nil, scope, c.config)
+	err = c.repository.createAccessToken(ctx, oauthAccessToken)
 	if err != nil {
 		return
 	}
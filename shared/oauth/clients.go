@@ -0,0 +1,222 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	ErrorInvalidClientID   string = "client_id must be 1 to 32 letters, digits, '.', '_' or '-'"
+	ErrorInvalidGrantTypes string = "grant_types must be a space separated list of supported grant types"
+	ErrorRedirectRequired  string = "redirect_uri is required for the authorization_code grant"
+	ErrorSecretChanged     string = "The secret of the client changed meanwhile, try again"
+	ErrorInvalidAPIKeyName string = "name of the API key must be 1 to 100 characters"
+	ErrorAPIKeyNotFound    string = "API key does not exist or is already revoked"
+)
+
+// clientIDPattern matches the IDs of the clients, which fit oauth_clients.
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,32}$`)
+
+// ClientRequest describes a new client. Public clients, like single page and
+// mobile apps, get no secret.
+type ClientRequest struct {
+	ClientID    string
+	RedirectURI string
+	GrantTypes  string
+	Scope       string
+	Public      bool
+}
+
+// Clients manages the OAuth clients.
+type Clients struct {
+	repository Repository
+}
+
+func NewClients(db *sqlx.DB, tokens TokenStore) *Clients {
+	return &Clients{
+		repository: NewRepository(db, tokens),
+	}
+}
+
+// Create creates a client, and returns its secret. The secret is stored
+// hashed, so it can't be shown again; it's empty for public clients.
+func (c *Clients) Create(ctx context.Context, request ClientRequest) (client OauthClient, secret string, err error) {
+	err = request.validate()
+	if err != nil {
+		return
+	}
+
+	redirectURI := strings.Join(strings.Fields(request.RedirectURI), " ")
+	scope := strings.Join(ParseScopes(request.Scope), " ")
+	client = OauthClient{
+		ClientID:    request.ClientID,
+		RedirectURI: null.NewString(redirectURI, redirectURI != ""),
+		GrantTypes:  strings.Join(strings.Fields(request.GrantTypes), " "),
+		Scope:       null.NewString(scope, scope != ""),
+	}
+
+	if !request.Public {
+		secret, client.ClientSecret, err = generateClientSecret()
+		if err != nil {
+			return
+		}
+	}
+
+	err = c.repository.createClient(ctx, client)
+	return
+}
+
+// List returns every client, including the disabled ones.
+func (c *Clients) List(ctx context.Context) ([]OauthClient, error) {
+	return c.repository.resolveAllClients(ctx)
+}
+
+// Disable disables a client for good, revoking the tokens issued to it.
+func (c *Clients) Disable(ctx context.Context, clientID string) error {
+	disabled, err := c.repository.disableClient(ctx, clientID)
+	if err != nil {
+		return err
+	}
+
+	if !disabled {
+		return NewError(ErrorCodeInvalidRequest, ErrorClientNotFound)
+	}
+
+	return nil
+}
+
+// RotateSecret replaces the secret of a client, and returns the new one. The
+// former secret keeps working for the overlap, so the client can be
+// redeployed with the new one in the meantime.
+func (c *Clients) RotateSecret(ctx context.Context, clientID string, overlap time.Duration) (secret string, err error) {
+	client, err := c.repository.resolveClientByClientID(ctx, clientID)
+	if err != nil {
+		if AsError(err).Code == ErrorCodeInvalidClient {
+			err = NewError(ErrorCodeInvalidRequest, ErrorClientNotFound)
+		}
+		return
+	}
+
+	if client.Public() {
+		err = NewError(ErrorCodeInvalidRequest, "Public clients have no secret")
+		return
+	}
+
+	previous := client.ClientSecret
+	if !hashedSecret(previous) {
+		previous, err = hashSecret(previous)
+		if err != nil {
+			return
+		}
+	}
+
+	secret, hash, err := generateClientSecret()
+	if err != nil {
+		return
+	}
+
+	rotated, err := c.repository.rotateClientSecret(ctx, client, hash, previous, time.Now().Add(overlap))
+	if err != nil {
+		return "", err
+	}
+
+	if !rotated {
+		return "", NewError(ErrorCodeInvalidRequest, ErrorSecretChanged)
+	}
+
+	return secret, nil
+}
+
+// CreateAPIKey creates an API key of an enabled client, and returns the key.
+// The key is granted the requested scopes the client is allowed, or every
+// scope of the client if none are requested. It is stored hashed, so it
+// can't be shown again.
+func (c *Clients) CreateAPIKey(ctx context.Context, clientID string, name string, scope string) (apiKey APIKey, key string, err error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 100 {
+		err = NewError(ErrorCodeInvalidRequest, ErrorInvalidAPIKeyName)
+		return
+	}
+
+	client, err := c.repository.resolveClientByClientID(ctx, clientID)
+	if err != nil {
+		if AsError(err).Code == ErrorCodeInvalidClient {
+			err = NewError(ErrorCodeInvalidRequest, ErrorClientNotFound)
+		}
+		return
+	}
+
+	scope, err = client.GrantScope(scope)
+	if err != nil {
+		return
+	}
+
+	key, hash, err := generateAPIKey()
+	if err != nil {
+		return
+	}
+
+	apiKey = APIKey{
+		KeyHash:  hash,
+		ClientID: client.ClientID,
+		Name:     name,
+		Scope:    null.NewString(scope, scope != ""),
+	}
+	err = c.repository.createAPIKey(ctx, apiKey)
+	return
+}
+
+// ListAPIKeys returns every API key, including the revoked ones.
+func (c *Clients) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	return c.repository.resolveAllAPIKeys(ctx)
+}
+
+// RevokeAPIKey revokes an API key for good, by the hash listed for it.
+func (c *Clients) RevokeAPIKey(ctx context.Context, keyHash string) error {
+	revoked, err := c.repository.revokeAPIKey(ctx, keyHash)
+	if err != nil {
+		return err
+	}
+
+	if !revoked {
+		return NewError(ErrorCodeInvalidRequest, ErrorAPIKeyNotFound)
+	}
+
+	return nil
+}
+
+func (r ClientRequest) validate() error {
+	if !clientIDPattern.MatchString(r.ClientID) {
+		return NewError(ErrorCodeInvalidRequest, ErrorInvalidClientID)
+	}
+
+	grantTypes := strings.Fields(r.GrantTypes)
+	if len(grantTypes) == 0 {
+		return NewError(ErrorCodeInvalidRequest, ErrorInvalidGrantTypes)
+	}
+
+	for _, grantType := range grantTypes {
+		switch GrantType(grantType) {
+		case ClientCredentials, Password:
+			if r.Public {
+				return NewError(ErrorCodeInvalidRequest, fmt.Sprintf("Public clients may not use the %s grant", grantType))
+			}
+		case AuthorizationCode:
+			if strings.TrimSpace(r.RedirectURI) == "" {
+				return NewError(ErrorCodeInvalidRequest, ErrorRedirectRequired)
+			}
+		case RefreshToken:
+		default:
+			return NewError(ErrorCodeInvalidRequest, ErrorInvalidGrantTypes)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,280 @@
+package oauth_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	queryClient        = `FROM\s+oauth_clients\s+WHERE client_id = \? AND disabled_at IS NULL`
+	queryInsertClient  = `INSERT INTO oauth_clients`
+	queryRotateSecret  = `UPDATE oauth_clients\s+SET client_secret = \?, previous_client_secret = \?`
+	queryDisableClient = `UPDATE oauth_clients\s+SET disabled_at = \?`
+	queryInsertAPIKey  = `INSERT INTO api_keys`
+	queryRevokeAPIKey  = `UPDATE api_keys\s+SET revoked_at = \?\s+WHERE key_hash = \? AND revoked_at IS NULL`
+)
+
+func newClients(t *testing.T) (*oauth.Clients, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	sqlxdb := sqlx.NewDb(db, "mysql")
+	return oauth.NewClients(sqlxdb, oauth.NewMySQLTokenStore(sqlxdb)), mock
+}
+
+// hashOf matches the bcrypt hashes of a secret.
+type hashOf string
+
+func (h hashOf) Match(v driver.Value) bool {
+	hash, ok := v.(string)
+	return ok && bcrypt.CompareHashAndPassword([]byte(hash), []byte(h)) == nil
+}
+
+func TestClientsCreate(t *testing.T) {
+	t.Run("Confidential Client", func(t *testing.T) {
+		clients, mock := newClients(t)
+		mock.ExpectExec(queryInsertClient).
+			WithArgs("partner_app", sqlmock.AnyArg(), nil, "client_credentials", "user foo:read").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		client, secret, err := clients.Create(context.Background(), oauth.ClientRequest{
+			ClientID:   "partner_app",
+			GrantTypes: "client_credentials",
+			Scope:      "user  foo:read user",
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, secret, 43)
+		assert.NotEqual(t, secret, client.ClientSecret, "the secret is stored hashed")
+		assert.True(t, client.VerifyClient(oauth.Credential{ClientID: "partner_app", ClientSecret: secret}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Public Client", func(t *testing.T) {
+		clients, mock := newClients(t)
+		mock.ExpectExec(queryInsertClient).
+			WithArgs("shop_spa", "", "https://shop.evermos.com/callback", "authorization_code refresh_token", nil).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		client, secret, err := clients.Create(context.Background(), oauth.ClientRequest{
+			ClientID:    "shop_spa",
+			GrantTypes:  "authorization_code refresh_token",
+			RedirectURI: "https://shop.evermos.com/callback",
+			Public:      true,
+		})
+
+		assert.NoError(t, err)
+		assert.Empty(t, secret)
+		assert.True(t, client.Public())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid Requests", func(t *testing.T) {
+		clients, _ := newClients(t)
+
+		for name, request := range map[string]oauth.ClientRequest{
+			"Missing ID":            {GrantTypes: "client_credentials"},
+			"ID Too Long":           {ClientID: "a_client_id_longer_than_32_bytes_", GrantTypes: "client_credentials"},
+			"Missing Grant Types":   {ClientID: "partner_app"},
+			"Unknown Grant Type":    {ClientID: "partner_app", GrantTypes: "implicit"},
+			"Public Password Grant": {ClientID: "shop_spa", GrantTypes: "password", Public: true},
+			"Code Without Redirect": {ClientID: "shop_spa", GrantTypes: "authorization_code", Public: true},
+		} {
+			_, _, err := clients.Create(context.Background(), request)
+
+			assert.Equal(t, oauth.ErrorCodeInvalidRequest, oauth.AsError(err).Code, name)
+		}
+	})
+}
+
+func TestClientsRotateSecret(t *testing.T) {
+	t.Run("Previous Secret Kept For Overlap", func(t *testing.T) {
+		clients, mock := newClients(t)
+		mock.ExpectQuery(queryClient).WithArgs("partner_app").WillReturnRows(
+			sqlmock.NewRows([]string{"client_id", "client_secret", "grant_types"}).
+				AddRow("partner_app", "0ld-s3cr3t", "client_credentials"))
+		mock.ExpectExec(queryRotateSecret).
+			WithArgs(sqlmock.AnyArg(), hashOf("0ld-s3cr3t"), sqlmock.AnyArg(), "partner_app", "0ld-s3cr3t").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		secret, err := clients.RotateSecret(context.Background(), "partner_app", time.Hour)
+
+		assert.NoError(t, err)
+		assert.Len(t, secret, 43)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Secret Changed Meanwhile", func(t *testing.T) {
+		clients, mock := newClients(t)
+		mock.ExpectQuery(queryClient).WithArgs("partner_app").WillReturnRows(
+			sqlmock.NewRows([]string{"client_id", "client_secret", "grant_types"}).
+				AddRow("partner_app", "0ld-s3cr3t", "client_credentials"))
+		mock.ExpectExec(queryRotateSecret).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		_, err := clients.RotateSecret(context.Background(), "partner_app", time.Hour)
+
+		assert.Equal(t, oauth.ErrorSecretChanged, err.Error())
+	})
+
+	t.Run("Unknown Client", func(t *testing.T) {
+		clients, mock := newClients(t)
+		mock.ExpectQuery(queryClient).WithArgs("partner_app").WillReturnRows(sqlmock.NewRows([]string{"client_id"}))
+
+		_, err := clients.RotateSecret(context.Background(), "partner_app", time.Hour)
+
+		assert.Equal(t, oauth.ErrorClientNotFound, err.Error())
+	})
+}
+
+func TestClientsDisable(t *testing.T) {
+	t.Run("Revoke Tokens Of Client", func(t *testing.T) {
+		clients, mock := newClients(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(queryDisableClient).WithArgs(sqlmock.AnyArg(), "partner_app").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE oauth_refresh_tokens\s+SET revoked_at = \?\s+WHERE client_id = \?`).WithArgs(sqlmock.AnyArg(), "partner_app").WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+		mock.ExpectExec(`DELETE FROM oauth_access_tokens WHERE client_id = \?`).WithArgs("partner_app").WillReturnResult(sqlmock.NewResult(0, 3))
+
+		err := clients.Disable(context.Background(), "partner_app")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Or Disabled Client", func(t *testing.T) {
+		clients, mock := newClients(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(queryDisableClient).WithArgs(sqlmock.AnyArg(), "partner_app").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := clients.Disable(context.Background(), "partner_app")
+
+		assert.Equal(t, oauth.ErrorClientNotFound, err.Error())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestClientsCreateAPIKey(t *testing.T) {
+	clientRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"client_id", "client_secret", "grant_types", "scope"}).
+			AddRow("partner_app", "s3cr3t", "client_credentials", "foo:read foo:write")
+	}
+
+	t.Run("Key Stored Hashed", func(t *testing.T) {
+		clients, mock := newClients(t)
+		mock.ExpectQuery(queryClient).WithArgs("partner_app").WillReturnRows(clientRows())
+		mock.ExpectExec(queryInsertAPIKey).
+			WithArgs(sqlmock.AnyArg(), "partner_app", "partner", "foo:read").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		apiKey, key, err := clients.CreateAPIKey(context.Background(), "partner_app", " partner ", "foo:read bar:read")
+
+		assert.NoError(t, err)
+		assert.Len(t, key, 43)
+		sum := sha256.Sum256([]byte(key))
+		assert.Equal(t, hex.EncodeToString(sum[:]), apiKey.KeyHash)
+		assert.Equal(t, "foo:read", apiKey.Scope.String)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Every Scope Of Client", func(t *testing.T) {
+		clients, mock := newClients(t)
+		mock.ExpectQuery(queryClient).WithArgs("partner_app").WillReturnRows(clientRows())
+		mock.ExpectExec(queryInsertAPIKey).
+			WithArgs(sqlmock.AnyArg(), "partner_app", "partner", "foo:read foo:write").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		_, _, err := clients.CreateAPIKey(context.Background(), "partner_app", "partner", "")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Scope Not Allowed", func(t *testing.T) {
+		clients, mock := newClients(t)
+		mock.ExpectQuery(queryClient).WithArgs("partner_app").WillReturnRows(clientRows())
+
+		_, _, err := clients.CreateAPIKey(context.Background(), "partner_app", "partner", "bar:read")
+
+		assert.Equal(t, oauth.ErrorCodeInvalidScope, oauth.AsError(err).Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Client", func(t *testing.T) {
+		clients, mock := newClients(t)
+		mock.ExpectQuery(queryClient).WithArgs("partner_app").WillReturnRows(sqlmock.NewRows([]string{"client_id"}))
+
+		_, _, err := clients.CreateAPIKey(context.Background(), "partner_app", "partner", "")
+
+		assert.Equal(t, oauth.ErrorClientNotFound, err.Error())
+	})
+
+	t.Run("Invalid Name", func(t *testing.T) {
+		clients, _ := newClients(t)
+
+		_, _, err := clients.CreateAPIKey(context.Background(), "partner_app", "  ", "")
+
+		assert.Equal(t, oauth.ErrorInvalidAPIKeyName, err.Error())
+	})
+}
+
+func TestClientsRevokeAPIKey(t *testing.T) {
+	t.Run("Revoked", func(t *testing.T) {
+		clients, mock := newClients(t)
+		mock.ExpectExec(queryRevokeAPIKey).WithArgs(sqlmock.AnyArg(), "a1b2").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := clients.RevokeAPIKey(context.Background(), "a1b2")
+
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Or Revoked Key", func(t *testing.T) {
+		clients, mock := newClients(t)
+		mock.ExpectExec(queryRevokeAPIKey).WithArgs(sqlmock.AnyArg(), "a1b2").WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := clients.RevokeAPIKey(context.Background(), "a1b2")
+
+		assert.Equal(t, oauth.ErrorAPIKeyNotFound, err.Error())
+	})
+}
+
+func TestVerifyClient(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("3v3rm0s"), bcrypt.MinCost)
+	credential := oauth.Credential{ClientID: "client_web", ClientSecret: "3v3rm0s"}
+
+	t.Run("Hashed Secret", func(t *testing.T) {
+		client := oauth.OauthClient{ClientID: "client_web", ClientSecret: string(hash)}
+
+		assert.True(t, client.VerifyClient(credential))
+		assert.False(t, client.VerifyClient(oauth.Credential{ClientID: "client_web", ClientSecret: string(hash)}))
+		assert.False(t, client.VerifyClient(oauth.Credential{ClientID: "client_web"}))
+	})
+
+	t.Run("Disabled Client", func(t *testing.T) {
+		client := oauth.OauthClient{ClientID: "client_web", ClientSecret: string(hash)}
+		client.DisabledAt.SetValid(time.Now())
+
+		assert.False(t, client.VerifyClient(credential))
+	})
+
+	t.Run("Public Client", func(t *testing.T) {
+		client := oauth.OauthClient{ClientID: "client_spa"}
+
+		assert.True(t, client.VerifyClient(oauth.Credential{ClientID: "client_spa"}))
+		assert.False(t, client.VerifyClient(oauth.Credential{ClientID: "client_spa", ClientSecret: "guess"}))
+	})
+}
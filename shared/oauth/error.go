@@ -1,11 +1,71 @@
 package oauth
 
+import (
+	"errors"
+	"net/http"
+)
+
+const (
+	ErrorEmptyCredential      string = "Credential can't be empty"
+	ErrorClientNotFound       string = "Client does not exist"
+	ErrorInvalidPassword      string = "Invalid password credential"
+	ErrorInvalidClient        string = "Invalid client credentials"
+	ErrorInvalidToken         string = "Invalid Token"
+	ErrorTokenTypeMismatch    string = "Token type mismatch"
+	ErrorGenerateAccessToken  string = "Error generating access token"
+	ErrorUnsupportedGrantType string = "Grant type is not supported"
+	ErrorUnauthorizedClient   string = "Client is not allowed to use this grant type"
+	ErrorServer               string = "Internal server error"
+	ErrorInvalidRefreshToken  string = "Invalid refresh token"
+)
+
+// The error codes of token responses, RFC 6749 section 5.2.
 const (
-	ErrorEmptyCredential     string = "Credential can't be empty"
-	ErrorClientNotFound      string = "Client does not exist"
-	ErrorInvalidPassword     string = "Invalid password credential"
-	ErrorInvalidClient       string = "Invalid client credentials"
-	ErrorInvalidToken        string = "Invalid Token"
-	ErrorTokenTypeMismatch   string = "Token type mismatch"
-	ErrorGenerateAccessToken string = "Error generating access token"
+	ErrorCodeInvalidRequest       = "invalid_request"
+	ErrorCodeInvalidClient        = "invalid_client"
+	ErrorCodeInvalidGrant         = "invalid_grant"
+	ErrorCodeUnauthorizedClient   = "unauthorized_client"
+	ErrorCodeUnsupportedGrantType = "unsupported_grant_type"
+	ErrorCodeInvalidScope         = "invalid_scope"
+	ErrorCodeServerError          = "server_error"
+	// ErrorCodeInvalidToken is the error of unknown or expired access
+	// tokens, RFC 6750 section 3.1.
+	ErrorCodeInvalidToken = "invalid_token"
 )
+
+// Error is an OAuth error, sent to clients as the body of an error response.
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// NewError returns a new Error with a code of RFC 6749.
+func NewError(code string, description string) *Error {
+	return &Error{Code: code, Description: description}
+}
+
+func (e *Error) Error() string {
+	return e.Description
+}
+
+// Status returns the HTTP status of the error response.
+func (e *Error) Status() int {
+	switch e.Code {
+	case ErrorCodeInvalidClient, ErrorCodeInvalidToken:
+		return http.StatusUnauthorized
+	case ErrorCodeServerError:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// AsError returns the Error of err, or a server_error for any other error,
+// whose message is kept from clients.
+func AsError(err error) *Error {
+	var oauthErr *Error
+	if errors.As(err, &oauthErr) {
+		return oauthErr
+	}
+	return NewError(ErrorCodeServerError, ErrorServer)
+}
@@ -1,25 +1,83 @@
 package oauth
 
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+)
+
 type AuthorizationMethod interface {
-	Create(credential Credential) (OauthAccessToken, error)
+	Create(ctx context.Context, client OauthClient, credential Credential) (OauthAccessToken, error)
 }
 
 type Grant struct {
-	TokenStore TokenStore
+	Repository Repository
 	Config     Config
 }
 
-func NewGrant(tokenStore TokenStore, config Config) *Grant {
+func NewGrant(repository Repository, config Config) *Grant {
 	return &Grant{
-		TokenStore: tokenStore,
+		Repository: repository,
 		Config:     config,
 	}
 }
 
-func (g *Grant) Create(credential Credential) (OauthAccessToken, error) {
+// Create authenticates the client and issues a token with the grant type of
+// the credential, if the client is allowed to use it.
+func (g *Grant) Create(ctx context.Context, credential Credential) (OauthAccessToken, error) {
 	authMap := make(map[GrantType]AuthorizationMethod)
-	authMap[ClientCredentials] = &ClientCredentialsAuth{tokenStore: g.TokenStore, config: g.Config}
-	authMap[Password] = &PasswordAuth{tokenStore: g.TokenStore, config: g.Config}
+	authMap[ClientCredentials] = &ClientCredentialsAuth{repository: g.Repository, config: g.Config}
+	authMap[Password] = &PasswordAuth{repository: g.Repository, config: g.Config}
+	authMap[RefreshToken] = &RefreshTokenAuth{repository: g.Repository, config: g.Config}
+	authMap[AuthorizationCode] = &AuthorizationCodeAuth{repository: g.Repository, config: g.Config}
+
+	method, ok := authMap[credential.GrantType]
+	if !ok {
+		return OauthAccessToken{}, NewError(ErrorCodeUnsupportedGrantType, ErrorUnsupportedGrantType)
+	}
+
+	client, err := g.authenticateClient(ctx, credential)
+	if err != nil {
+		return OauthAccessToken{}, err
+	}
+
+	publicGrant := credential.GrantType == AuthorizationCode || credential.GrantType == RefreshToken
+	if !client.AllowsGrantType(credential.GrantType) || (client.Public() && !publicGrant) {
+		return OauthAccessToken{}, NewError(ErrorCodeUnauthorizedClient, ErrorUnauthorizedClient)
+	}
+
+	return method.Create(ctx, client, credential)
+}
+
+func (g *Grant) authenticateClient(ctx context.Context, credential Credential) (OauthClient, error) {
+	if credential.ClientID == "" {
+		return OauthClient{}, NewError(ErrorCodeInvalidClient, ErrorInvalidClient)
+	}
+
+	client, err := g.Repository.resolveClientByClientID(ctx, credential.ClientID)
+	if err != nil {
+		return OauthClient{}, err
+	}
 
-	return authMap[credential.GrantType].Create(credential)
+	if !client.VerifyClient(credential) {
+		return OauthClient{}, NewError(ErrorCodeInvalidClient, ErrorInvalidClient)
+	}
+
+	if !client.Public() && !hashedSecret(client.ClientSecret) {
+		g.hashClientSecret(ctx, client)
+	}
+
+	return client, nil
+}
+
+// hashClientSecret hashes the secret of a client stored in clear text before
+// secrets were hashed, once the client authenticated with it.
+func (g *Grant) hashClientSecret(ctx context.Context, client OauthClient) {
+	hash, err := hashSecret(client.ClientSecret)
+	if err == nil {
+		err = g.Repository.updateClientSecret(ctx, client.ClientID, client.ClientSecret, hash)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("clientID", client.ClientID).Msg("Failed hashing the secret of the client")
+	}
 }
@@ -0,0 +1,124 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+)
+
+// The hints of the type of a token to introspect or revoke, RFC 7009
+// section 2.1.
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// Introspection is the state of a token, RFC 7662 section 2.2. Inactive
+// tokens only tell they are inactive.
+type Introspection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	// Role is the role of the user of a JWT, an extension of RFC 7662.
+	Role string `json:"role,omitempty"`
+}
+
+// AuthenticateClient authenticates a client by its ID and secret.
+func (t *Token) AuthenticateClient(ctx context.Context, credential Credential) (OauthClient, error) {
+	return NewGrant(t.tokenRepository, t.config).authenticateClient(ctx, credential)
+}
+
+// Introspect returns the state of an access token or a refresh token,
+// looking for the type of the hint first. Unknown tokens are inactive.
+func (t *Token) Introspect(ctx context.Context, token string, hint string) (Introspection, error) {
+	accessToken, refreshToken, err := t.resolve(ctx, token, hint)
+	switch {
+	case err != nil:
+		return Introspection{}, err
+	case accessToken != nil && accessToken.VerifyExpireIn():
+		return Introspection{
+			Active:    true,
+			Scope:     accessToken.Scope.String,
+			ClientID:  accessToken.ClientID,
+			TokenType: string(Bearer),
+			Exp:       accessToken.Expires.Unix(),
+			Sub:       accessToken.UserID.String,
+		}, nil
+	case refreshToken != nil && refreshToken.Usable():
+		return Introspection{
+			Active:    true,
+			Scope:     refreshToken.Scope.String,
+			ClientID:  refreshToken.ClientID,
+			TokenType: TokenTypeHintRefreshToken,
+			Exp:       refreshToken.Expires.Unix(),
+			Sub:       refreshToken.UserID.String,
+		}, nil
+	default:
+		return Introspection{}, nil
+	}
+}
+
+// Revoke revokes an access token or a refresh token issued to a client,
+// RFC 7009. Revoking a refresh token revokes its family and the access
+// tokens issued with it. Unknown tokens and the tokens of other clients are
+// ignored, as their existence isn't disclosed.
+func (t *Token) Revoke(ctx context.Context, client OauthClient, token string, hint string) error {
+	accessToken, refreshToken, err := t.resolve(ctx, token, hint)
+	switch {
+	case err != nil:
+		return err
+	case accessToken != nil && accessToken.ClientID == client.ClientID:
+		return t.tokenRepository.deleteAccessToken(ctx, accessToken.AccessToken)
+	case refreshToken != nil && refreshToken.ClientID == client.ClientID:
+		return t.tokenRepository.revokeRefreshTokenFamily(ctx, refreshToken.FamilyID)
+	default:
+		return nil
+	}
+}
+
+// resolve looks a token up as an access token and as a refresh token, in the
+// order of the hint.
+func (t *Token) resolve(ctx context.Context, token string, hint string) (*OauthAccessToken, *OauthRefreshToken, error) {
+	resolveAccessToken := func() (*OauthAccessToken, error) {
+		accessToken, err := t.tokenRepository.resolveAccessTokenByAccessToken(ctx, token)
+		if err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		return &accessToken, nil
+	}
+	resolveRefreshToken := func() (*OauthRefreshToken, error) {
+		refreshToken, err := t.tokenRepository.resolveRefreshToken(ctx, token)
+		if err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		return &refreshToken, nil
+	}
+
+	if hint == TokenTypeHintRefreshToken {
+		refreshToken, err := resolveRefreshToken()
+		if refreshToken != nil || err != nil {
+			return nil, refreshToken, err
+		}
+		accessToken, err := resolveAccessToken()
+		return accessToken, nil, err
+	}
+
+	accessToken, err := resolveAccessToken()
+	if accessToken != nil || err != nil {
+		return accessToken, nil, err
+	}
+	refreshToken, err := resolveRefreshToken()
+	return nil, refreshToken, err
+}
+
+// ignoreNotFound drops the errors of the token store for unknown tokens.
+func ignoreNotFound(err error) error {
+	var oauthErr *Error
+	if errors.As(err, &oauthErr) {
+		return nil
+	}
+	return err
+}
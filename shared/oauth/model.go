@@ -1,10 +1,13 @@
 package oauth
 
 import (
-	"golang.org/x/crypto/bcrypt"
-	"strconv"
+	"crypto/subtle"
+	"math"
+	"strings"
 	"time"
 
+	"golang.org/x/crypto/bcrypt"
+
 	"github.com/guregu/null"
 )
 
@@ -14,12 +17,6 @@ const (
 	Bearer TokenType = "Bearer"
 )
 
-var scope = struct {
-	User string
-}{
-	"user",
-}
-
 // Credential is
 type Credential struct {
 	GrantType    GrantType
@@ -27,6 +24,12 @@ type Credential struct {
 	ClientSecret string
 	Username     string
 	Password     string
+	// Scope is the space separated scopes requested.
+	Scope        string
+	RefreshToken string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
 }
 
 type OauthAccessToken struct {
@@ -35,16 +38,16 @@ type OauthAccessToken struct {
 	UserID      null.String `json:"userId" db:"user_id"`
 	Expires     time.Time   `json:"expires" db:"expires"`
 	Scope       null.String `json:"scope" db:"scope"`
+	// RefreshToken is the refresh token issued with the access token, if any.
+	RefreshToken string `json:"-" db:"-"`
 }
 
-func (o *OauthAccessToken) Generate(accessToken string, clientID string, userID *int, withScope bool, config Config) OauthAccessToken {
+func (o *OauthAccessToken) Generate(accessToken string, clientID string, userID *string, scope string, config Config) OauthAccessToken {
 	if userID != nil {
-		o.UserID = null.StringFrom(strconv.Itoa(*userID))
+		o.UserID = null.StringFrom(*userID)
 	}
 
-	if withScope {
-		o.Scope = null.StringFrom(scope.User)
-	}
+	o.Scope = null.NewString(scope, scope != "")
 
 	o.ClientID = clientID
 	o.AccessToken = accessToken
@@ -63,49 +66,172 @@ func (o *OauthAccessToken) VerifyExpireIn() bool {
 }
 
 func (o *OauthAccessToken) VerifyUserLoggedIn() bool {
-	if o.UserID.Valid && !o.Scope.Valid {
-		return true
-	}
-	return false
+	return o.UserID.Valid
 }
 
 func (o *OauthAccessToken) toCreateTokenResponse() *TokenResponse {
 	return &TokenResponse{
-		AccessToken: o.AccessToken,
-		ExpiresIn:   o.Expires,
-		TokenType:   string(Bearer),
-		Scope:       scope.User,
+		AccessToken:  o.AccessToken,
+		TokenType:    string(Bearer),
+		ExpiresIn:    int64(math.Round(time.Until(o.Expires).Seconds())),
+		Scope:        o.Scope.String,
+		RefreshToken: o.RefreshToken,
 	}
 }
 
+// OauthRefreshToken is a refresh token, rotated on use. The refresh tokens
+// rotated from the same grant form a family, which is revoked as a whole as
+// soon as one of its used tokens is presented again.
+type OauthRefreshToken struct {
+	RefreshToken string      `json:"refreshToken" db:"refresh_token"`
+	FamilyID     string      `json:"familyId" db:"family_id"`
+	AccessToken  string      `json:"accessToken" db:"access_token"`
+	ClientID     string      `json:"clientId" db:"client_id"`
+	UserID       null.String `json:"userId" db:"user_id"`
+	Scope        null.String `json:"scope" db:"scope"`
+	Expires      time.Time   `json:"expires" db:"expires"`
+	UsedAt       null.Time   `json:"usedAt" db:"used_at"`
+	RevokedAt    null.Time   `json:"revokedAt" db:"revoked_at"`
+}
+
+func (o *OauthRefreshToken) Generate(refreshToken string, familyID string, accessToken OauthAccessToken, config Config) OauthRefreshToken {
+	o.RefreshToken = refreshToken
+	o.FamilyID = familyID
+	o.AccessToken = accessToken.AccessToken
+	o.ClientID = accessToken.ClientID
+	o.UserID = accessToken.UserID
+	o.Scope = accessToken.Scope
+	o.Expires = time.Now().Add(time.Second * time.Duration(config.RefreshExpiration))
+
+	return *o
+}
+
+func (o *OauthRefreshToken) VerifyExpireIn() bool {
+	return time.Now().Before(o.Expires)
+}
+
+// Usable reports whether the refresh token can still be exchanged.
+func (o *OauthRefreshToken) Usable() bool {
+	return !o.UsedAt.Valid && !o.RevokedAt.Valid && o.VerifyExpireIn()
+}
+
+// OauthClient is a client of the OAuth API. Its secrets are bcrypt hashes;
+// after a rotation, the previous secret keeps working until it expires.
 type OauthClient struct {
-	ClientID     string `json:"clientId" db:"client_id"`
-	ClientSecret string `json:"clientSecret" db:"client_secret"`
-	RedirectURI  string `json:"redirectUri" db:"redirect_uri"`
-	GrantTypes   string `json:"grantTypes" db:"grant_types"`
+	ClientID                    string      `json:"clientId" db:"client_id"`
+	ClientSecret                string      `json:"-" db:"client_secret"`
+	PreviousClientSecret        null.String `json:"-" db:"previous_client_secret"`
+	PreviousClientSecretExpires null.Time   `json:"previousClientSecretExpires" db:"previous_client_secret_expires"`
+	RedirectURI                 null.String `json:"redirectUri" db:"redirect_uri"`
+	GrantTypes                  string      `json:"grantTypes" db:"grant_types"`
+	Scope                       null.String `json:"scope" db:"scope"`
+	CreatedAt                   null.Time   `json:"createdAt" db:"created_at"`
+	DisabledAt                  null.Time   `json:"disabledAt" db:"disabled_at"`
+}
+
+// APIKey is a long lived key of a client, for partners calling the API
+// without OAuth. Only its SHA-256 hash is stored.
+type APIKey struct {
+	KeyHash   string      `json:"keyHash" db:"key_hash"`
+	ClientID  string      `json:"clientId" db:"client_id"`
+	Name      string      `json:"name" db:"name"`
+	Scope     null.String `json:"scope" db:"scope"`
+	CreatedAt null.Time   `json:"createdAt" db:"created_at"`
+	RevokedAt null.Time   `json:"revokedAt" db:"revoked_at"`
 }
 
+// VerifyClient reports whether the credential authenticates the client, with
+// its secret or, until the overlap of a rotation ends, its previous secret.
+// Public clients authenticate without a secret.
 func (o *OauthClient) VerifyClient(credential Credential) bool {
-	if o.ClientID != credential.ClientID {
+	if o.ClientID != credential.ClientID || o.DisabledAt.Valid {
 		return false
 	}
 
-	if o.ClientSecret != credential.ClientSecret {
+	if o.Public() {
+		return credential.ClientSecret == ""
+	}
+
+	if credential.ClientSecret == "" {
 		return false
 	}
 
-	return true
+	if verifySecret(o.ClientSecret, credential.ClientSecret) {
+		return true
+	}
+
+	return o.PreviousClientSecret.Valid && time.Now().Before(o.PreviousClientSecretExpires.Time) &&
+		verifySecret(o.PreviousClientSecret.String, credential.ClientSecret)
+}
+
+// verifySecret compares a secret with its stored hash. Secrets stored in
+// clear text before they were hashed are compared in constant time.
+func verifySecret(stored string, secret string) bool {
+	if !hashedSecret(stored) {
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(secret)) == 1
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(stored), []byte(secret)) == nil
+}
+
+// hashedSecret reports whether a stored secret is a bcrypt hash.
+func hashedSecret(stored string) bool {
+	_, err := bcrypt.Cost([]byte(stored))
+	return err == nil
+}
+
+// Public reports whether the client has no secret, like the single page and
+// mobile apps, which can't keep one. Public clients may only get tokens for
+// users with authorization codes and PKCE.
+func (o *OauthClient) Public() bool {
+	return o.ClientSecret == ""
+}
+
+// RedirectURIs returns the space separated redirect URIs of the client.
+func (o *OauthClient) RedirectURIs() []string {
+	return strings.Fields(o.RedirectURI.String)
+}
+
+// GrantScope returns the requested scopes the client is allowed, or every
+// scope of the client if none are requested. It fails with invalid_scope
+// when the client is allowed none of the requested scopes.
+func (o *OauthClient) GrantScope(requested string) (string, error) {
+	if len(ParseScopes(requested)) == 0 {
+		return o.Scope.String, nil
+	}
+
+	scope := IntersectScopes(requested, o.Scope.String)
+	if scope == "" {
+		return "", NewError(ErrorCodeInvalidScope, ErrorInvalidScope)
+	}
+
+	return scope, nil
+}
+
+// AllowsGrantType reports whether the grant type is one of the space
+// separated grant types of the client.
+func (o *OauthClient) AllowsGrantType(grantType GrantType) bool {
+	for _, allowed := range strings.Fields(o.GrantTypes) {
+		if GrantType(allowed) == grantType {
+			return true
+		}
+	}
+
+	return false
 }
 
+// TokenResponse is the successful response of the token endpoint, RFC 6749
+// section 5.1. ExpiresIn is the lifetime of the access token in seconds.
 type TokenResponse struct {
-	AccessToken string
-	ExpiresIn   time.Time
-	TokenType   string
-	Scope       string
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	Scope        string `json:"scope,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
 
 type User struct {
-	ID       int    `json:"id" db:"id"`
+	ID       string `json:"id" db:"user_id"`
 	Username string `json:"username" db:"username"`
 	Password string `json:"password" db:"password"`
 }
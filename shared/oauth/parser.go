@@ -1,21 +1,22 @@
 package oauth
 
 import (
+	"context"
 	"errors"
 	"strings"
 )
 
 type Parser struct {
-	TokenStore TokenStore
+	Repository Repository
 }
 
-func NewParser(tokenStore TokenStore) *Parser {
+func NewParser(repository Repository) *Parser {
 	return &Parser{
-		TokenStore: tokenStore,
+		Repository: repository,
 	}
 }
 
-func (p *Parser) Parse(accessToken string) (accessTokenClient OauthAccessToken, err error) {
+func (p *Parser) Parse(ctx context.Context, accessToken string) (accessTokenClient OauthAccessToken, err error) {
 	valid := p.validToken(accessToken)
 	if !valid {
 		err = errors.New(ErrorEmptyCredential)
@@ -29,7 +30,7 @@ func (p *Parser) Parse(accessToken string) (accessTokenClient OauthAccessToken,
 		return
 	}
 
-	accessTokenClient, err = p.TokenStore.resolveAccessTokenByAccessToken(token[1])
+	accessTokenClient, err = p.Repository.resolveAccessTokenByAccessToken(ctx, token[1])
 	if err != nil {
 		return
 	}
@@ -1,44 +1,55 @@
 package oauth
 
 import (
-	"errors"
+	"context"
 )
 
 type PasswordAuth struct {
-	tokenStore TokenStore
+	repository Repository
 	config     Config
 }
 
-func (c *PasswordAuth) Create(credential Credential) (oauthAccessToken OauthAccessToken, err error) {
-	client, err := c.tokenStore.resolveClientByClientID(credential.ClientID)
-	if err != nil {
+func (c *PasswordAuth) Create(ctx context.Context, client OauthClient, credential Credential) (oauthAccessToken OauthAccessToken, err error) {
+	if credential.Username == "" || credential.Password == "" {
+		err = NewError(ErrorCodeInvalidRequest, ErrorEmptyCredential)
 		return
 	}
 
-	if !client.VerifyClient(credential) {
-		err = errors.New(ErrorInvalidClient)
+	scope, err := client.GrantScope(credential.Scope)
+	if err != nil {
 		return
 	}
 
-	user, err := c.tokenStore.resolveByTelephoneOrEmail(credential.Username)
+	user, err := c.repository.resolveUserByEmail(ctx, credential.Username)
 	if err != nil {
 		return
 	}
 
 	if !user.ValidCredential(credential) {
-		err = errors.New(ErrorInvalidPassword)
+		err = NewError(ErrorCodeInvalidGrant, ErrorInvalidPassword)
 		return
 	}
 
 	accessToken, err := generateAccessToken()
 	if err != nil {
-		err = errors.New(ErrorGenerateAccessToken)
+		err = NewError(ErrorCodeServerError, ErrorGenerateAccessToken)
 		return
 	}
 
-	oauthAccessToken = new(OauthAccessToken).Generate(accessToken, credential.ClientID, &user.ID, false, c.config)
+	oauthAccessToken = new(OauthAccessToken).Generate(accessToken, client.ClientID, &user.ID, scope, c.config)
+
+	// refresh tokens are only issued to the clients allowed to use them
+	if !client.AllowsGrantType(RefreshToken) {
+		err = c.repository.createAccessToken(ctx, oauthAccessToken)
+		return
+	}
+
+	refreshToken, err := newRefreshToken(&oauthAccessToken, "", c.config)
+	if err != nil {
+		return
+	}
 
-	err = c.tokenStore.createAccessToken(oauthAccessToken)
+	err = c.repository.createAccessTokenWithRefreshToken(ctx, oauthAccessToken, refreshToken)
 	if err != nil {
 		return
 	}
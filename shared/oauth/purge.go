@@ -0,0 +1,102 @@
+package oauth
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+// purgeBatchSize bounds the rows each statement deletes, so purging never
+// locks the tables for long.
+const purgeBatchSize = 1000
+
+// purgeQueries delete a batch of the expired rows of each table. Refresh
+// tokens are kept until they expire even once used or revoked, as their
+// reuse revokes their family.
+var purgeQueries = []string{
+	`DELETE FROM oauth_access_tokens WHERE expires < ? LIMIT ?`,
+	`DELETE FROM oauth_refresh_tokens WHERE expires < ? LIMIT ?`,
+	`DELETE FROM oauth_authorization_codes WHERE expires < ? LIMIT ?`,
+}
+
+// Purger periodically deletes the expired access tokens, refresh tokens and
+// authorization codes from MySQL. Every replica may run one.
+type Purger struct {
+	db       *sqlx.DB
+	interval time.Duration
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+// NewPurger creates a Purger purging every interval.
+func NewPurger(db *sqlx.DB, interval time.Duration) *Purger {
+	return &Purger{
+		db:       db,
+		interval: interval,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start purges in the background until stopped.
+func (p *Purger) Start() {
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.quit:
+				return
+			case <-ticker.C:
+			}
+
+			purged, err := p.Purge(context.Background(), time.Now())
+			if err != nil {
+				log.Warn().Err(err).Msg("Failed purging the expired OAuth tokens")
+				continue
+			}
+			log.Debug().Int64("purged", purged).Msg("Purged the expired OAuth tokens")
+		}
+	}()
+}
+
+// Stop stops purging, waiting for the purge in progress, if any.
+func (p *Purger) Stop(ctx context.Context) error {
+	close(p.quit)
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Purge deletes the rows expired before a time, and returns how many.
+func (p *Purger) Purge(ctx context.Context, before time.Time) (purged int64, err error) {
+	for _, query := range purgeQueries {
+		for {
+			result, err := p.db.ExecContext(ctx, query, before, purgeBatchSize)
+			if err != nil {
+				return purged, err
+			}
+
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return purged, err
+			}
+			purged += affected
+
+			if affected < purgeBatchSize {
+				break
+			}
+		}
+	}
+
+	return purged, nil
+}
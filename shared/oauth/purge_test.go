@@ -0,0 +1,47 @@
+package oauth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurge(t *testing.T) {
+	t.Run("Delete Expired Rows In Batches", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		now := time.Now()
+		mock.ExpectExec(`DELETE FROM oauth_access_tokens WHERE expires < \? LIMIT \?`).WithArgs(now, 1000).WillReturnResult(sqlmock.NewResult(0, 1000))
+		mock.ExpectExec(`DELETE FROM oauth_access_tokens WHERE expires < \? LIMIT \?`).WithArgs(now, 1000).WillReturnResult(sqlmock.NewResult(0, 20))
+		mock.ExpectExec(`DELETE FROM oauth_refresh_tokens WHERE expires < \? LIMIT \?`).WithArgs(now, 1000).WillReturnResult(sqlmock.NewResult(0, 5))
+		mock.ExpectExec(`DELETE FROM oauth_authorization_codes WHERE expires < \? LIMIT \?`).WithArgs(now, 1000).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		purged, err := oauth.NewPurger(sqlx.NewDb(db, "mysql"), time.Hour).Purge(context.Background(), now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1025), purged)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		db, _, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		purger := oauth.NewPurger(sqlx.NewDb(db, "mysql"), time.Hour)
+		purger.Start()
+
+		assert.NoError(t, purger.Stop(context.Background()))
+	})
+}
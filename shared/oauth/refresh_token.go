@@ -0,0 +1,104 @@
+package oauth
+
+import (
+	"context"
+)
+
+type RefreshTokenAuth struct {
+	repository Repository
+	config     Config
+}
+
+// Create exchanges a refresh token for a new access token and a new refresh
+// token of the same family. Presenting a used refresh token again revokes
+// its family, as either the client or an attacker holds a stolen token.
+func (c *RefreshTokenAuth) Create(ctx context.Context, client OauthClient, credential Credential) (oauthAccessToken OauthAccessToken, err error) {
+	if credential.RefreshToken == "" {
+		err = NewError(ErrorCodeInvalidRequest, "refresh_token is required")
+		return
+	}
+
+	used, err := c.repository.resolveRefreshToken(ctx, credential.RefreshToken)
+	if err != nil {
+		return
+	}
+
+	if used.ClientID != client.ClientID || used.RevokedAt.Valid {
+		err = NewError(ErrorCodeInvalidGrant, ErrorInvalidRefreshToken)
+		return
+	}
+
+	if used.UsedAt.Valid {
+		err = c.revokeFamily(ctx, used)
+		return
+	}
+
+	if !used.VerifyExpireIn() {
+		err = NewError(ErrorCodeInvalidGrant, ErrorInvalidRefreshToken)
+		return
+	}
+
+	// the access token may narrow the scopes of the family, RFC 6749
+	// section 6, while the scopes of the client may have been narrowed since
+	scope := used.Scope.String
+	if len(ParseScopes(credential.Scope)) > 0 {
+		scope = IntersectScopes(credential.Scope, scope)
+		if scope == "" {
+			err = NewError(ErrorCodeInvalidScope, ErrorInvalidScope)
+			return
+		}
+	}
+	scope = IntersectScopes(scope, client.Scope.String)
+
+	accessToken, err := generateAccessToken()
+	if err != nil {
+		err = NewError(ErrorCodeServerError, ErrorGenerateAccessToken)
+		return
+	}
+
+	oauthAccessToken = new(OauthAccessToken).Generate(accessToken, client.ClientID, nil, scope, c.config)
+	oauthAccessToken.UserID = used.UserID
+
+	refreshToken, err := newRefreshToken(&oauthAccessToken, used.FamilyID, c.config)
+	if err != nil {
+		return
+	}
+	// the refresh token keeps the scopes of the family
+	refreshToken.Scope = used.Scope
+
+	err = c.repository.rotateRefreshToken(ctx, used, oauthAccessToken, refreshToken)
+	if err == errRefreshTokenUsed {
+		err = c.revokeFamily(ctx, used)
+		return
+	}
+
+	return
+}
+
+func (c *RefreshTokenAuth) revokeFamily(ctx context.Context, reused OauthRefreshToken) error {
+	err := c.repository.revokeRefreshTokenFamily(ctx, reused.FamilyID)
+	if err != nil {
+		return err
+	}
+
+	return NewError(ErrorCodeInvalidGrant, ErrorInvalidRefreshToken)
+}
+
+// newRefreshToken generates a refresh token of a family for an access token,
+// starting a new family if familyID is empty.
+func newRefreshToken(accessToken *OauthAccessToken, familyID string, config Config) (OauthRefreshToken, error) {
+	refreshToken, err := generateAccessToken()
+	if err != nil {
+		return OauthRefreshToken{}, NewError(ErrorCodeServerError, ErrorGenerateAccessToken)
+	}
+
+	if familyID == "" {
+		familyID, err = generateAccessToken()
+		if err != nil {
+			return OauthRefreshToken{}, NewError(ErrorCodeServerError, ErrorGenerateAccessToken)
+		}
+	}
+
+	accessToken.RefreshToken = refreshToken
+	return new(OauthRefreshToken).Generate(refreshToken, familyID, *accessToken, config), nil
+}
@@ -0,0 +1,496 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// errRefreshTokenUsed tells a refresh token was used by a concurrent
+	// request.
+	errRefreshTokenUsed = errors.New("refresh token already used")
+	// errAuthorizationCodeUsed tells an authorization code was used by a
+	// concurrent request.
+	errAuthorizationCodeUsed = errors.New("authorization code already used")
+)
+
+// Repository stores the clients, the refresh tokens and the authorization
+// codes in MySQL, and the access tokens in a TokenStore. Tokens and codes are
+// stored as their SHA-256 hashes: the ones resolved hold the hashes, while
+// the ones created hold the tokens until they are stored.
+type Repository struct {
+	db     *infras.MySQLConn
+	tokens TokenStore
+}
+
+const (
+	queryInsertRefreshToken = `INSERT INTO oauth_refresh_tokens (
+			refresh_token,
+			family_id,
+			access_token,
+			client_id,
+			user_id,
+			scope,
+			expires
+		) VALUES (
+			:refresh_token,
+			:family_id,
+			:access_token,
+			:client_id,
+			:user_id,
+			:scope,
+			:expires
+		)`
+
+	querySelectRefreshToken = `SELECT
+			refresh_token,
+			family_id,
+			access_token,
+			client_id,
+			user_id,
+			scope,
+			expires,
+			used_at,
+			revoked_at
+		FROM
+			oauth_refresh_tokens`
+
+	queryUseRefreshToken = `UPDATE oauth_refresh_tokens
+		SET used_at = ?
+		WHERE refresh_token = ? AND used_at IS NULL AND revoked_at IS NULL`
+
+	queryRevokeRefreshTokenFamily = `UPDATE oauth_refresh_tokens
+		SET revoked_at = ?
+		WHERE family_id = ? AND revoked_at IS NULL`
+
+	querySelectAccessTokensOfFamily = `SELECT access_token FROM oauth_refresh_tokens WHERE family_id = ?`
+
+	queryInsertAuthorizationCode = `INSERT INTO oauth_authorization_codes (
+			code,
+			family_id,
+			client_id,
+			user_id,
+			redirect_uri,
+			scope,
+			code_challenge,
+			code_challenge_method,
+			expires
+		) VALUES (
+			:code,
+			:family_id,
+			:client_id,
+			:user_id,
+			:redirect_uri,
+			:scope,
+			:code_challenge,
+			:code_challenge_method,
+			:expires
+		)`
+
+	querySelectAuthorizationCode = `SELECT
+			code,
+			family_id,
+			access_token,
+			client_id,
+			user_id,
+			redirect_uri,
+			scope,
+			code_challenge,
+			code_challenge_method,
+			expires,
+			used_at
+		FROM
+			oauth_authorization_codes`
+
+	queryUseAuthorizationCode = `UPDATE oauth_authorization_codes
+		SET used_at = ?, access_token = ?
+		WHERE code = ? AND used_at IS NULL`
+
+	querySelectClients = `SELECT
+			client_id,
+			client_secret,
+			previous_client_secret,
+			previous_client_secret_expires,
+			redirect_uri,
+			grant_types,
+			scope,
+			created_at,
+			disabled_at
+		FROM 
+			oauth_clients`
+
+	queryInsertClient = `INSERT INTO oauth_clients (
+			client_id,
+			client_secret,
+			redirect_uri,
+			grant_types,
+			scope
+		) VALUES (
+			:client_id,
+			:client_secret,
+			:redirect_uri,
+			:grant_types,
+			:scope
+		)`
+
+	queryUpdateClientSecret = `UPDATE oauth_clients
+		SET client_secret = ?
+		WHERE client_id = ? AND client_secret = ?`
+
+	queryRotateClientSecret = `UPDATE oauth_clients
+		SET client_secret = ?, previous_client_secret = ?, previous_client_secret_expires = ?
+		WHERE client_id = ? AND client_secret = ? AND disabled_at IS NULL`
+
+	queryDisableClient = `UPDATE oauth_clients
+		SET disabled_at = ?
+		WHERE client_id = ? AND disabled_at IS NULL`
+
+	queryInsertAPIKey = `INSERT INTO api_keys (
+			key_hash,
+			client_id,
+			name,
+			scope
+		) VALUES (
+			:key_hash,
+			:client_id,
+			:name,
+			:scope
+		)`
+
+	querySelectAPIKeys = `SELECT
+			key_hash,
+			client_id,
+			name,
+			scope,
+			created_at,
+			revoked_at
+		FROM
+			api_keys
+		ORDER BY client_id, created_at`
+
+	queryRevokeAPIKey = `UPDATE api_keys
+		SET revoked_at = ?
+		WHERE key_hash = ? AND revoked_at IS NULL`
+
+	queryRevokeRefreshTokensOfClient = `UPDATE oauth_refresh_tokens
+		SET revoked_at = ?
+		WHERE client_id = ? AND revoked_at IS NULL`
+
+	querySelectUser = `
+			SELECT
+				user_id,
+				username,
+				password
+			FROM
+				users`
+)
+
+// NewRepository creates a Repository reading and writing through a single
+// connection, joining the transaction of the context like the other
+// repositories.
+func NewRepository(db *sqlx.DB, tokens TokenStore) Repository {
+	return Repository{
+		db:     &infras.MySQLConn{Read: db, Write: db},
+		tokens: tokens,
+	}
+}
+
+func (a *Repository) createAccessToken(ctx context.Context, accessToken OauthAccessToken) error {
+	return a.tokens.CreateAccessToken(ctx, accessToken)
+}
+
+// deleteAccessToken deletes an access token by its hash.
+func (a *Repository) deleteAccessToken(ctx context.Context, hash string) error {
+	return a.tokens.DeleteAccessTokens(ctx, hash)
+}
+
+func (a *Repository) resolveAccessTokenByAccessToken(ctx context.Context, accessToken string) (OauthAccessToken, error) {
+	return a.tokens.ResolveAccessToken(ctx, accessToken)
+}
+
+// resolveAllClients resolves every client, including the disabled ones.
+func (a *Repository) resolveAllClients(ctx context.Context) ([]OauthClient, error) {
+	var clients []OauthClient
+
+	err := a.db.Reader(ctx).SelectContext(ctx, &clients, querySelectClients+" ORDER BY client_id")
+	if err != nil {
+		return []OauthClient{}, err
+	}
+
+	return clients, nil
+}
+
+// resolveClientByClientID resolves an enabled client; disabled clients are
+// unknown to the OAuth endpoints.
+func (a *Repository) resolveClientByClientID(ctx context.Context, clientID string) (client OauthClient, err error) {
+	err = a.db.Reader(ctx).GetContext(ctx, &client, querySelectClients+" WHERE client_id = ? AND disabled_at IS NULL", clientID)
+	switch {
+	case err == sql.ErrNoRows:
+		err = NewError(ErrorCodeInvalidClient, ErrorInvalidClient)
+		return
+	case err != nil:
+		return
+	}
+
+	return
+}
+
+func (a *Repository) createClient(ctx context.Context, client OauthClient) error {
+	_, err := a.db.Writer(ctx).NamedExecContext(ctx, queryInsertClient, client)
+	return err
+}
+
+// updateClientSecret replaces the secret of a client, unless it was changed
+// in the meantime.
+func (a *Repository) updateClientSecret(ctx context.Context, clientID string, stored string, hash string) error {
+	_, err := a.db.Writer(ctx).ExecContext(ctx, queryUpdateClientSecret, hash, clientID, stored)
+	return err
+}
+
+// rotateClientSecret replaces the secret of a client, keeping the former one
+// as previous secret until it expires. It reports false when the secret was
+// changed or the client disabled in the meantime.
+func (a *Repository) rotateClientSecret(ctx context.Context, client OauthClient, hash string, previous string, previousExpires time.Time) (bool, error) {
+	result, err := a.db.Writer(ctx).ExecContext(ctx, queryRotateClientSecret, hash, previous, previousExpires, client.ClientID, client.ClientSecret)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// disableClient disables a client, revoking its refresh tokens and deleting
+// its access tokens. It reports false when the client is missing or already
+// disabled.
+func (a *Repository) disableClient(ctx context.Context, clientID string) (disabled bool, err error) {
+	err = a.db.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		now := time.Now()
+		result, err := tx.ExecContext(ctx, queryDisableClient, now, clientID)
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil || affected == 0 {
+			return err
+		}
+		disabled = true
+
+		_, err = tx.ExecContext(ctx, queryRevokeRefreshTokensOfClient, now, clientID)
+		return err
+	})
+	if err != nil || !disabled {
+		return
+	}
+
+	err = a.tokens.DeleteAccessTokensOfClient(ctx, clientID)
+	return
+}
+
+func (a *Repository) createAPIKey(ctx context.Context, apiKey APIKey) error {
+	_, err := a.db.Writer(ctx).NamedExecContext(ctx, queryInsertAPIKey, apiKey)
+	return err
+}
+
+// resolveAllAPIKeys resolves every API key, including the revoked ones.
+func (a *Repository) resolveAllAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var apiKeys []APIKey
+
+	err := a.db.Reader(ctx).SelectContext(ctx, &apiKeys, querySelectAPIKeys)
+	if err != nil {
+		return []APIKey{}, err
+	}
+
+	return apiKeys, nil
+}
+
+// revokeAPIKey revokes an API key by its hash. It reports false when the key
+// is missing or already revoked.
+func (a *Repository) revokeAPIKey(ctx context.Context, keyHash string) (bool, error) {
+	result, err := a.db.Writer(ctx).ExecContext(ctx, queryRevokeAPIKey, time.Now(), keyHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// resolveUserByEmail resolves the user of the username of a password grant,
+// which is the email of the user as only emails are unique.
+func (a *Repository) resolveUserByEmail(ctx context.Context, email string) (User, error) {
+	var user User
+
+	err := a.db.Reader(ctx).GetContext(ctx, &user, querySelectUser+" WHERE email = ? AND deleted_at IS NULL", email)
+	switch {
+	case err == sql.ErrNoRows:
+		return User{}, NewError(ErrorCodeInvalidGrant, ErrorInvalidPassword)
+	case err != nil:
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// createAccessTokenWithRefreshToken stores an access token and the refresh
+// token issued with it at once.
+func (a *Repository) createAccessTokenWithRefreshToken(ctx context.Context, accessToken OauthAccessToken, refreshToken OauthRefreshToken) error {
+	return a.withAccessToken(ctx, accessToken, func() error {
+		return insertRefreshToken(ctx, a.db.Writer(ctx), refreshToken)
+	})
+}
+
+// rotateRefreshToken marks a refresh token used, and stores the access token
+// and refresh token replacing it at once. It fails with errRefreshTokenUsed
+// when the refresh token was used or revoked in the meantime.
+func (a *Repository) rotateRefreshToken(ctx context.Context, used OauthRefreshToken, accessToken OauthAccessToken, refreshToken OauthRefreshToken) error {
+	return a.withAccessToken(ctx, accessToken, func() error {
+		return a.db.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+			result, err := tx.ExecContext(ctx, queryUseRefreshToken, time.Now(), used.RefreshToken)
+			if err != nil {
+				return err
+			}
+
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if affected == 0 {
+				return errRefreshTokenUsed
+			}
+
+			return insertRefreshToken(ctx, tx, refreshToken)
+		})
+	})
+}
+
+// revokeRefreshTokenFamily revokes every refresh token of a family, and
+// deletes the access tokens issued with them.
+func (a *Repository) revokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	var accessTokens []string
+	err := a.db.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, queryRevokeRefreshTokenFamily, time.Now(), familyID)
+		if err != nil {
+			return err
+		}
+
+		return tx.SelectContext(ctx, &accessTokens, querySelectAccessTokensOfFamily, familyID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.tokens.DeleteAccessTokens(ctx, accessTokens...)
+}
+
+// revokeAuthorizationCode revokes the tokens issued for an authorization code:
+// the access token it was exchanged for, which has no refresh token when the
+// client isn't allowed them, and the family of refresh tokens it started.
+func (a *Repository) revokeAuthorizationCode(ctx context.Context, code OauthAuthorizationCode) error {
+	if code.AccessToken.Valid {
+		err := a.tokens.DeleteAccessTokens(ctx, code.AccessToken.String)
+		if err != nil {
+			return err
+		}
+	}
+
+	return a.revokeRefreshTokenFamily(ctx, code.FamilyID)
+}
+
+func (a *Repository) resolveRefreshToken(ctx context.Context, refreshToken string) (oauthRefreshToken OauthRefreshToken, err error) {
+	err = a.db.Reader(ctx).GetContext(ctx, &oauthRefreshToken, querySelectRefreshToken+" WHERE refresh_token = ?", hashToken(refreshToken))
+	switch {
+	case err == sql.ErrNoRows:
+		err = NewError(ErrorCodeInvalidGrant, ErrorInvalidRefreshToken)
+		return
+	case err != nil:
+		return
+	}
+
+	return
+}
+
+func (a *Repository) createAuthorizationCode(ctx context.Context, code OauthAuthorizationCode) error {
+	code.Code = hashToken(code.Code)
+	_, err := a.db.Writer(ctx).NamedExecContext(ctx, queryInsertAuthorizationCode, code)
+	return err
+}
+
+func (a *Repository) resolveAuthorizationCode(ctx context.Context, code string) (authorizationCode OauthAuthorizationCode, err error) {
+	err = a.db.Reader(ctx).GetContext(ctx, &authorizationCode, querySelectAuthorizationCode+" WHERE code = ?", hashToken(code))
+	switch {
+	case err == sql.ErrNoRows:
+		err = NewError(ErrorCodeInvalidGrant, ErrorInvalidCode)
+		return
+	case err != nil:
+		return
+	}
+
+	return
+}
+
+// exchangeAuthorizationCode marks an authorization code used with the access
+// token issued for it, and stores the access token and refresh token, if any,
+// at once. It fails with errAuthorizationCodeUsed when the code was used in
+// the meantime.
+func (a *Repository) exchangeAuthorizationCode(ctx context.Context, code OauthAuthorizationCode, accessToken OauthAccessToken, refreshToken *OauthRefreshToken) error {
+	return a.withAccessToken(ctx, accessToken, func() error {
+		return a.db.WithTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+			result, err := tx.ExecContext(ctx, queryUseAuthorizationCode, time.Now(), hashToken(accessToken.AccessToken), code.Code)
+			if err != nil {
+				return err
+			}
+
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if affected == 0 {
+				return errAuthorizationCodeUsed
+			}
+
+			if refreshToken == nil {
+				return nil
+			}
+
+			return insertRefreshToken(ctx, tx, *refreshToken)
+		})
+	})
+}
+
+// withAccessToken stores an access token before the rows issued with it, and
+// deletes it again when storing them fails, so a failure never uses up a
+// refresh token or a code without issuing the access token.
+func (a *Repository) withAccessToken(ctx context.Context, accessToken OauthAccessToken, fn func() error) error {
+	err := a.tokens.CreateAccessToken(ctx, accessToken)
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	if err != nil {
+		if deleteErr := a.tokens.DeleteAccessTokens(ctx, hashToken(accessToken.AccessToken)); deleteErr != nil {
+			logger.Ctx(ctx).Error().Err(deleteErr).Str("clientId", accessToken.ClientID).
+				Msg("Failed deleting the access token of a failed issuance, it stays valid until it expires.")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// insertRefreshToken stores a refresh token, with the hashes of the refresh
+// token and of its access token.
+func insertRefreshToken(ctx context.Context, db sqlx.ExtContext, refreshToken OauthRefreshToken) error {
+	refreshToken.RefreshToken = hashToken(refreshToken.RefreshToken)
+	refreshToken.AccessToken = hashToken(refreshToken.AccessToken)
+	_, err := sqlx.NamedExecContext(ctx, db, queryInsertRefreshToken, refreshToken)
+	return err
+}
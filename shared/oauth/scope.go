@@ -0,0 +1,60 @@
+package oauth
+
+import "strings"
+
+// The scopes of the API. Each client is allowed some of them, listed in the
+// scope column of oauth_clients.
+const (
+	ScopeUser     = "user"
+	ScopeFooRead  = "foo:read"
+	ScopeFooWrite = "foo:write"
+)
+
+// ParseScopes returns the distinct scopes of a space separated scope.
+func ParseScopes(scope string) []string {
+	var scopes []string
+	seen := make(map[string]bool)
+	for _, s := range strings.Fields(scope) {
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+
+	return scopes
+}
+
+// IntersectScopes returns the scopes of a space separated scope that are
+// also in allowed.
+func IntersectScopes(scope string, allowed string) string {
+	allowedScopes := make(map[string]bool)
+	for _, s := range ParseScopes(allowed) {
+		allowedScopes[s] = true
+	}
+
+	var scopes []string
+	for _, s := range ParseScopes(scope) {
+		if allowedScopes[s] {
+			scopes = append(scopes, s)
+		}
+	}
+
+	return strings.Join(scopes, " ")
+}
+
+// HasScopes reports whether a space separated scope holds every required
+// scope.
+func HasScopes(scope string, required ...string) bool {
+	granted := make(map[string]bool)
+	for _, s := range ParseScopes(scope) {
+		granted[s] = true
+	}
+
+	for _, s := range required {
+		if !granted[s] {
+			return false
+		}
+	}
+
+	return true
+}
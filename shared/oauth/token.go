@@ -3,10 +3,13 @@ package oauth
 import (
 	"crypto/rand"
 	"crypto/sha512"
+	"encoding/base64"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // Create Access Token is method to generate unique access_token
@@ -32,3 +35,36 @@ func generateAccessToken() (string, error) {
 
 	return string(accessToken[0:40]), nil
 }
+
+// generateClientSecret generates a secret of a client, shown once to its
+// owner and stored as a bcrypt hash.
+func generateClientSecret() (secret string, hash string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return
+	}
+
+	secret = base64.RawURLEncoding.EncodeToString(b)
+	hash, err = hashSecret(secret)
+	return
+}
+
+// generateAPIKey generates an API key, shown once to its owner and stored
+// as its SHA-256 hash.
+func generateAPIKey() (key string, hash string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return
+	}
+
+	key = base64.RawURLEncoding.EncodeToString(b)
+	hash = hashToken(key)
+	return
+}
+
+func hashSecret(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	return string(hash), err
+}
@@ -1,14 +1,58 @@
 package oauth
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
-	"errors"
+	"encoding/hex"
+	"time"
 
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/go-redis/redis"
 	"github.com/jmoiron/sqlx"
 )
 
-type TokenStore struct {
-	db *sqlx.DB
+// The kinds of TokenStore.
+const (
+	TokenStoreMySQL = "mysql"
+	TokenStoreRedis = "redis"
+)
+
+// TokenStore stores the access tokens until they expire. Tokens are stored
+// as their SHA-256 hashes, so a leak of the store doesn't expose live
+// tokens.
+type TokenStore interface {
+	// CreateAccessToken stores an access token.
+	CreateAccessToken(ctx context.Context, accessToken OauthAccessToken) error
+	// ResolveAccessToken resolves an access token, holding the hash of the
+	// token. Unknown and expired tokens fail with invalid_token.
+	ResolveAccessToken(ctx context.Context, accessToken string) (OauthAccessToken, error)
+	// DeleteAccessTokens deletes access tokens by their hashes, as the
+	// refresh tokens issued with them refer to them.
+	DeleteAccessTokens(ctx context.Context, hashes ...string) error
+	// DeleteAccessTokensOfClient deletes the access tokens of a client.
+	DeleteAccessTokensOfClient(ctx context.Context, clientID string) error
+}
+
+// ProvideTokenStore is the provider for TokenStore, keeping the access
+// tokens in MySQL unless configured otherwise.
+func ProvideTokenStore(db *infras.MySQLConn, cache *redis.Client, config *configs.Config) TokenStore {
+	return NewTokenStore(config.OAuth.TokenStore, db.Write, cache)
+}
+
+// NewTokenStore creates a TokenStore of a kind, mysql or redis.
+func NewTokenStore(kind string, db *sqlx.DB, cache *redis.Client) TokenStore {
+	if kind == TokenStoreRedis {
+		return NewRedisTokenStore(cache)
+	}
+	return NewMySQLTokenStore(db)
+}
+
+// hashToken returns the hash a token or a code is stored as.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 const (
@@ -35,49 +79,35 @@ const (
 		FROM
 			oauth_access_tokens`
 
-	querySelectClients = `SELECT
-			client_id,
-			client_secret,
-			redirect_uri,
-			grant_types
-		FROM 
-			oauth_clients`
-
-	querySelectUser = `
-			SELECT
-				id,
-				username,
-				password
-			FROM
-				user`
+	queryDeleteAccessTokens = `DELETE FROM oauth_access_tokens WHERE access_token IN (?)`
+
+	queryDeleteAccessTokensOfClient = `DELETE FROM oauth_access_tokens WHERE client_id = ?`
 )
 
-func NewTokenStore(db *sqlx.DB) TokenStore {
-	return TokenStore{
-		db: db,
-	}
+// MySQLTokenStore keeps the access tokens in oauth_access_tokens, where the
+// expired ones are left to the Purger and never resolved.
+type MySQLTokenStore struct {
+	db *sqlx.DB
 }
 
-func (a *TokenStore) createAccessToken(accessToken OauthAccessToken) error {
-	stmt, err := a.db.PrepareNamed(queryInsertAccessToken)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	_, err = stmt.Exec(accessToken)
-	if err != nil {
-		return err
+// NewMySQLTokenStore creates a MySQLTokenStore.
+func NewMySQLTokenStore(db *sqlx.DB) *MySQLTokenStore {
+	return &MySQLTokenStore{
+		db: db,
 	}
+}
 
-	return nil
+func (s *MySQLTokenStore) CreateAccessToken(ctx context.Context, accessToken OauthAccessToken) error {
+	accessToken.AccessToken = hashToken(accessToken.AccessToken)
+	_, err := s.db.NamedExecContext(ctx, queryInsertAccessToken, accessToken)
+	return err
 }
 
-func (a *TokenStore) resolveAccessTokenByAccessToken(accessToken string) (oauthAccessToken OauthAccessToken, err error) {
-	err = a.db.Get(&oauthAccessToken, querySelectAccessToken+" WHERE access_token = ?", accessToken)
+func (s *MySQLTokenStore) ResolveAccessToken(ctx context.Context, accessToken string) (oauthAccessToken OauthAccessToken, err error) {
+	err = s.db.GetContext(ctx, &oauthAccessToken, querySelectAccessToken+" WHERE access_token = ? AND expires > ?", hashToken(accessToken), time.Now())
 	switch {
 	case err == sql.ErrNoRows:
-		err = errors.New(ErrorClientNotFound)
+		err = NewError(ErrorCodeInvalidToken, ErrorInvalidToken)
 		return
 	case err != nil:
 		return
@@ -86,40 +116,21 @@ func (a *TokenStore) resolveAccessTokenByAccessToken(accessToken string) (oauthA
 	return
 }
 
-func (a *TokenStore) resolveAllClients(db *sqlx.DB) ([]OauthClient, error) {
-	var clients []OauthClient
-
-	err := a.db.Get(&clients, querySelectClients)
-	if err != nil {
-		return []OauthClient{}, err
+func (s *MySQLTokenStore) DeleteAccessTokens(ctx context.Context, hashes ...string) error {
+	if len(hashes) == 0 {
+		return nil
 	}
 
-	return clients, nil
-}
-
-func (a *TokenStore) resolveClientByClientID(clientID string) (client OauthClient, err error) {
-	err = a.db.Get(&client, querySelectClients+" WHERE client_id = ?", clientID)
-	switch {
-	case err == sql.ErrNoRows:
-		err = errors.New(ErrorClientNotFound)
-		return
-	case err != nil:
-		return
+	query, args, err := sqlx.In(queryDeleteAccessTokens, hashes)
+	if err != nil {
+		return err
 	}
 
-	return
+	_, err = s.db.ExecContext(ctx, s.db.Rebind(query), args...)
+	return err
 }
 
-func (a *TokenStore) resolveByTelephoneOrEmail(username string) (User, error) {
-	var user User
-
-	err := a.db.Get(&user, querySelectUser+" WHERE telephone = ? OR  email = ?", username, username)
-	switch {
-	case err == sql.ErrNoRows:
-		return User{}, errors.New(ErrorClientNotFound)
-	case err != nil:
-		return User{}, err
-	}
-
-	return user, nil
+func (s *MySQLTokenStore) DeleteAccessTokensOfClient(ctx context.Context, clientID string) error {
+	_, err := s.db.ExecContext(ctx, queryDeleteAccessTokensOfClient, clientID)
+	return err
 }
@@ -0,0 +1,96 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	accessTokenKey = "oauth:access_token:"
+	// clientAccessTokensKey keys the hashes of the access tokens of a client,
+	// scored by their expiry so the expired ones can be dropped.
+	clientAccessTokensKey = "oauth:client_access_tokens:"
+)
+
+// RedisTokenStore keeps the access tokens in Redis, each expiring with the
+// token.
+type RedisTokenStore struct {
+	client *redis.Client
+}
+
+// NewRedisTokenStore creates a RedisTokenStore.
+func NewRedisTokenStore(client *redis.Client) *RedisTokenStore {
+	return &RedisTokenStore{
+		client: client,
+	}
+}
+
+func (s *RedisTokenStore) CreateAccessToken(ctx context.Context, accessToken OauthAccessToken) error {
+	ttl := time.Until(accessToken.Expires)
+	if ttl <= 0 {
+		return nil
+	}
+
+	hash := hashToken(accessToken.AccessToken)
+	accessToken.AccessToken = hash
+	value, err := json.Marshal(accessToken)
+	if err != nil {
+		return err
+	}
+
+	clientKey := clientAccessTokensKey + accessToken.ClientID
+	_, err = s.client.WithContext(ctx).TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Set(accessTokenKey+hash, value, ttl)
+		pipe.ZRemRangeByScore(clientKey, "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+		pipe.ZAdd(clientKey, redis.Z{Score: float64(accessToken.Expires.Unix()), Member: hash})
+		pipe.Expire(clientKey, ttl)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisTokenStore) ResolveAccessToken(ctx context.Context, accessToken string) (oauthAccessToken OauthAccessToken, err error) {
+	value, err := s.client.WithContext(ctx).Get(accessTokenKey + hashToken(accessToken)).Bytes()
+	switch {
+	case err == redis.Nil:
+		err = NewError(ErrorCodeInvalidToken, ErrorInvalidToken)
+		return
+	case err != nil:
+		return
+	}
+
+	err = json.Unmarshal(value, &oauthAccessToken)
+	return
+}
+
+func (s *RedisTokenStore) DeleteAccessTokens(ctx context.Context, hashes ...string) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(hashes))
+	for i, hash := range hashes {
+		keys[i] = accessTokenKey + hash
+	}
+
+	return s.client.WithContext(ctx).Del(keys...).Err()
+}
+
+func (s *RedisTokenStore) DeleteAccessTokensOfClient(ctx context.Context, clientID string) error {
+	clientKey := clientAccessTokensKey + clientID
+	hashes, err := s.client.WithContext(ctx).ZRange(clientKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	err = s.DeleteAccessTokens(ctx, hashes...)
+	if err != nil {
+		return err
+	}
+
+	return s.client.WithContext(ctx).Del(clientKey).Err()
+}
@@ -0,0 +1,117 @@
+package oauth_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/go-redis/redis"
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// hashed returns the SHA-256 hash a token is stored as.
+func hashed(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRedisTokenStore(t *testing.T) (*oauth.RedisTokenStore, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return oauth.NewRedisTokenStore(client), server
+}
+
+func accessToken(token string, clientID string, ttl time.Duration) oauth.OauthAccessToken {
+	return oauth.OauthAccessToken{
+		AccessToken: token,
+		ClientID:    clientID,
+		UserID:      null.StringFrom("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+		Expires:     time.Now().Add(ttl).Truncate(time.Second),
+		Scope:       null.StringFrom("user"),
+	}
+}
+
+func TestRedisTokenStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Stored Hashed Until Expiry", func(t *testing.T) {
+		store, server := newRedisTokenStore(t)
+
+		assert.NoError(t, store.CreateAccessToken(ctx, accessToken("access-token", "client_web", time.Hour)))
+
+		assert.False(t, server.Exists("oauth:access_token:access-token"))
+		assert.InDelta(t, time.Hour, server.TTL("oauth:access_token:"+hashed("access-token")), float64(time.Second))
+
+		resolved, err := store.ResolveAccessToken(ctx, "access-token")
+		assert.NoError(t, err)
+		assert.Equal(t, hashed("access-token"), resolved.AccessToken)
+		assert.Equal(t, "client_web", resolved.ClientID)
+		assert.Equal(t, "user", resolved.Scope.String)
+
+		server.FastForward(time.Hour)
+		_, err = store.ResolveAccessToken(ctx, "access-token")
+		assert.Equal(t, oauth.ErrorCodeInvalidToken, oauth.AsError(err).Code)
+	})
+
+	t.Run("Unknown Token", func(t *testing.T) {
+		store, _ := newRedisTokenStore(t)
+
+		_, err := store.ResolveAccessToken(ctx, "unknown")
+
+		assert.Equal(t, oauth.ErrorCodeInvalidToken, oauth.AsError(err).Code)
+	})
+
+	t.Run("Delete By Hash", func(t *testing.T) {
+		store, _ := newRedisTokenStore(t)
+		assert.NoError(t, store.CreateAccessToken(ctx, accessToken("access-token", "client_web", time.Hour)))
+
+		assert.NoError(t, store.DeleteAccessTokens(ctx, hashed("access-token")))
+
+		_, err := store.ResolveAccessToken(ctx, "access-token")
+		assert.Equal(t, oauth.ErrorCodeInvalidToken, oauth.AsError(err).Code)
+	})
+
+	t.Run("Delete Tokens Of Client", func(t *testing.T) {
+		store, _ := newRedisTokenStore(t)
+		assert.NoError(t, store.CreateAccessToken(ctx, accessToken("first-token", "client_web", time.Hour)))
+		assert.NoError(t, store.CreateAccessToken(ctx, accessToken("second-token", "client_web", time.Hour)))
+		assert.NoError(t, store.CreateAccessToken(ctx, accessToken("other-token", "client_app", time.Hour)))
+
+		assert.NoError(t, store.DeleteAccessTokensOfClient(ctx, "client_web"))
+
+		for _, token := range []string{"first-token", "second-token"} {
+			_, err := store.ResolveAccessToken(ctx, token)
+			assert.Equal(t, oauth.ErrorCodeInvalidToken, oauth.AsError(err).Code, token)
+		}
+		_, err := store.ResolveAccessToken(ctx, "other-token")
+		assert.NoError(t, err)
+	})
+}
+
+func TestMySQLTokenStore(t *testing.T) {
+	t.Run("Expired Token", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		store := oauth.NewMySQLTokenStore(sqlx.NewDb(db, "mysql"))
+		mock.ExpectQuery(`FROM\s+oauth_access_tokens WHERE access_token = \? AND expires > \?`).
+			WithArgs(hashed("access-token"), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"access_token"}))
+
+		_, err = store.ResolveAccessToken(context.Background(), "access-token")
+
+		assert.Equal(t, oauth.ErrorCodeInvalidToken, oauth.AsError(err).Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
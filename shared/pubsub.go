@@ -1,40 +1,121 @@
 package shared
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/evermos/boilerplate-go/shared/tracing"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrPubSubStopped is returned when publishing to a PubSub that has been stopped.
+	ErrPubSubStopped = errors.New("pubsub: stopped")
+	// ErrBufferFull is returned by TryPublish when the message buffer has no free slot.
+	ErrBufferFull = errors.New("pubsub: message buffer is full")
+	// ErrUnknownTopic is passed to the error handler when a message has no registered subscriber.
+	ErrUnknownTopic = errors.New("pubsub: unknown topic")
+	// ErrProcessPanic is passed to the error handler when a Process function panics.
+	ErrProcessPanic = errors.New("pubsub: process panicked")
 )
 
 type message struct {
 	topic   string
 	payload []byte
+	// trace is the trace context the message was published in.
+	trace map[string]string
 }
 
 type Consumer struct {
 	message     chan message
 	messagePool chan chan message
-	runner      map[string]TopicRunner
+	pubsub      *PubSub
 }
 
 type Process func(message []byte) error
 
+// ErrorHandler receives messages which could not be processed, either because
+// nobody subscribes to their topic or because they couldn't be dead-lettered.
+type ErrorHandler func(topic string, payload []byte, err error)
+
+func defaultErrorHandler(topic string, payload []byte, err error) {
+	log.Error().Err(err).Str("topic", topic).Msg("Failed processing pubsub message")
+}
+
+// DeadLetter is a message whose processing still failed after the final retry.
+type DeadLetter struct {
+	ID       string
+	Topic    string
+	Pattern  string
+	Payload  []byte
+	Err      error
+	Attempts int
+	FailedAt time.Time
+}
+
+// DeadLetterSink stores dead letters so they can be inspected or redriven later.
+type DeadLetterSink interface {
+	Send(letter DeadLetter) error
+}
+
+// DeadLetterFunc adapts an ordinary function to a DeadLetterSink.
+type DeadLetterFunc func(letter DeadLetter) error
+
+// Send calls f(letter).
+func (f DeadLetterFunc) Send(letter DeadLetter) error {
+	return f(letter)
+}
+
 type consumerConfig struct {
 	MaxRetry           int
+	BaseDelayRetry     time.Duration
 	MaxDelayRetry      time.Duration
+	RetryJitter        bool
 	AsynchronousThread bool
+	ConsumerGroup      string
+	SubscriberName     string
 }
 
+// SetMaxRetry sets the total number of attempts made to process a message.
 func SetMaxRetry(maxRetry int) func(*consumerConfig) {
 	return func(cc *consumerConfig) {
 		cc.MaxRetry = maxRetry
 	}
 }
 
+// SetBaseDelayRetry sets the delay before the first retry. Every following
+// retry doubles it until MaxDelayRetry is reached.
+func SetBaseDelayRetry(baseDelay time.Duration) func(*consumerConfig) {
+	return func(cc *consumerConfig) {
+		cc.BaseDelayRetry = baseDelay
+	}
+}
+
+// SetMaxDelayRetry sets the ceiling of the delay between retries. Without a
+// base delay, it is used as a fixed delay.
 func SetMaxDelayRetry(maxDelay time.Duration) func(*consumerConfig) {
 	return func(cc *consumerConfig) {
 		cc.MaxDelayRetry = maxDelay
 	}
 }
 
+// SetRetryJitter enables randomizing the delay between retries, so subscribers
+// failing at the same time don't retry in lockstep. Enabled by default.
+func SetRetryJitter(jitter bool) func(*consumerConfig) {
+	return func(cc *consumerConfig) {
+		cc.RetryJitter = jitter
+	}
+}
+
 // SetAsynchronousThread if enabled process will synchronously process by total flight
 func SetAsynchronousThread(sync bool) func(*consumerConfig) {
 	return func(cc *consumerConfig) {
@@ -45,16 +126,55 @@ func SetAsynchronousThread(sync bool) func(*consumerConfig) {
 func defaultConsumerConfig() consumerConfig {
 	return consumerConfig{
 		MaxRetry:           0,
+		BaseDelayRetry:     0 * time.Second,
 		MaxDelayRetry:      0 * time.Second,
+		RetryJitter:        true,
 		AsynchronousThread: false,
 	}
 }
 
-func consumer(messagePol chan chan message, subscribers map[string]TopicRunner) Consumer {
+// attempts returns how many times a message is processed before giving up.
+func (cc consumerConfig) attempts() int {
+	if cc.MaxRetry < 1 {
+		return 1
+	}
+	return cc.MaxRetry
+}
+
+// retryDelay returns the delay before the given retry, counting from zero.
+func (cc consumerConfig) retryDelay(retry int) time.Duration {
+	delay := cc.BaseDelayRetry
+	if delay <= 0 {
+		delay = cc.MaxDelayRetry
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	for i := 0; i < retry && delay < math.MaxInt64/2; i++ {
+		if cc.MaxDelayRetry > 0 && delay >= cc.MaxDelayRetry {
+			break
+		}
+		delay *= 2
+	}
+
+	if cc.MaxDelayRetry > 0 && delay > cc.MaxDelayRetry {
+		delay = cc.MaxDelayRetry
+	}
+
+	if cc.RetryJitter {
+		half := delay / 2
+		delay = half + time.Duration(rand.Int63n(int64(delay-half)+1))
+	}
+
+	return delay
+}
+
+func consumer(p *PubSub) Consumer {
 	return Consumer{
 		message:     make(chan message),
-		messagePool: messagePol,
-		runner:      subscribers,
+		messagePool: p.messagePool,
+		pubsub:      p,
 	}
 }
 
@@ -63,124 +183,437 @@ func (c Consumer) consume() {
 		for {
 			// starts out empty
 			// send the response to dispatcher
-			c.messagePool <- c.message
+			select {
+			case c.messagePool <- c.message:
+			case <-c.pubsub.terminate:
+				return
+			}
 
 			// read the response
-			msg := <-c.message
+			var msg message
+			select {
+			case msg = <-c.message:
+			case <-c.pubsub.terminate:
+				return
+			}
+			pubsubPending.WithLabelValues(msg.topic).Dec()
 
-			runner := c.runner[msg.topic]
-			if runner.consumerConfig.AsynchronousThread {
-				go runner.backoff(func() error {
-					return runner.Process(msg.payload)
-				})
+			runners := c.pubsub.runnersFor(msg.topic)
+			if len(runners) == 0 {
+				c.pubsub.config.ErrorHandler(msg.topic, msg.payload, fmt.Errorf("%w: %s", ErrUnknownTopic, msg.topic))
+				c.pubsub.inflight.Done()
 				continue
 			}
 
-			// if enabled process concurrent will handle by max flight
-			runner.backoff(func() error {
-				return runner.Process(msg.payload)
-			})
+			// every subscriber holds its own share of the message, so it stays
+			// in flight until the slowest one is done
+			c.pubsub.inflight.Add(len(runners) - 1)
+			for _, runner := range runners {
+				if runner.consumerConfig.AsynchronousThread {
+					go c.handle(runner, msg)
+					continue
+				}
+
+				// if enabled process concurrent will handle by max flight
+				c.handle(runner, msg)
+			}
 		}
 	}()
 }
 
+func (c Consumer) handle(runner TopicRunner, msg message) {
+	defer c.pubsub.inflight.Done()
+
+	err := runner.process("pubsub", msg.topic, msg.payload, msg.trace)
+	if err == nil {
+		return
+	}
+
+	if !c.pubsub.config.deadLetter(DeadLetter{
+		Topic:    msg.topic,
+		Pattern:  runner.pattern,
+		Payload:  msg.payload,
+		Err:      err,
+		Attempts: runner.consumerConfig.attempts(),
+		FailedAt: time.Now(),
+	}) {
+		c.pubsub.config.ErrorHandler(msg.topic, msg.payload, err)
+	}
+}
+
+// Broker is the publish/subscribe API shared by the in-process PubSub and the
+// Redis Streams-backed RedisPubSub.
+type Broker interface {
+	Publish(topic string, payload []byte) error
+	PublishContext(ctx context.Context, topic string, payload []byte) error
+	SubscriberRegistry(topicListener string, pr Process, opts ...func(*consumerConfig)) error
+	Start()
+	Stop(ctx context.Context) error
+}
+
 type PubSub struct {
 	message     chan message
 	messagePool chan chan message
 	max         int
-	topics      map[string]TopicRunner
+	runners     []TopicRunner
+	config      pubsubConfig
+
+	mu        sync.RWMutex
+	started   bool
+	stopped   bool
+	inflight  sync.WaitGroup
+	quit      chan struct{}
+	terminate chan struct{}
 }
 
 type pubsubConfig struct {
-	MessageBuffer int
+	MessageBuffer  int
+	ErrorHandler   ErrorHandler
+	DeadLetterSink DeadLetterSink
+
+	// The following only apply to RedisPubSub.
+	StreamPrefix  string
+	StreamMaxLen  int64
+	ConsumerName  string
+	ReadBlock     time.Duration
+	ClaimMinIdle  time.Duration
+	ClaimInterval time.Duration
+	MaxDeliveries int64
 }
 
 func defaultPubsubConfig() pubsubConfig {
 	return pubsubConfig{
 		MessageBuffer: 0,
+		ErrorHandler:  defaultErrorHandler,
+		StreamPrefix:  "pubsub:",
+		StreamMaxLen:  10000,
+		ConsumerName:  defaultConsumerName(),
+		ReadBlock:     2 * time.Second,
+		ClaimMinIdle:  time.Minute,
+		ClaimInterval: 30 * time.Second,
+		MaxDeliveries: 5,
 	}
 }
 
+// deadLetter hands the letter to the dead-letter sink, reporting whether the
+// sink took it. Failures of the sink itself go to the error handler.
+func (pc pubsubConfig) deadLetter(letter DeadLetter) bool {
+	if pc.DeadLetterSink == nil {
+		return false
+	}
+
+	if err := pc.DeadLetterSink.Send(letter); err != nil {
+		pc.ErrorHandler(letter.Topic, letter.Payload, fmt.Errorf("dead-lettering failed: %v: %w", err, letter.Err))
+		return false
+	}
+
+	return true
+}
+
 func SetMessageBuffer(maxBuffer int) func(*pubsubConfig) {
 	return func(pc *pubsubConfig) {
 		pc.MessageBuffer = maxBuffer
 	}
 }
 
+// SetErrorHandler sets the handler for messages with an unknown topic, and
+// for failed messages when no dead-letter sink is set or the sink fails.
+func SetErrorHandler(handler ErrorHandler) func(*pubsubConfig) {
+	return func(pc *pubsubConfig) {
+		pc.ErrorHandler = handler
+	}
+}
+
+// SetDeadLetterSink sets where messages go once a subscriber exhausted its retries.
+func SetDeadLetterSink(sink DeadLetterSink) func(*pubsubConfig) {
+	return func(pc *pubsubConfig) {
+		pc.DeadLetterSink = sink
+	}
+}
+
 type TopicRunner struct {
 	Process        Process
+	pattern        string
 	consumerConfig consumerConfig
 }
 
+// matches reports whether the topic matches the runner's pattern. Patterns are
+// split by dots, and a "*" segment matches exactly one topic segment, so
+// "order.*" matches "order.created" but neither "order" nor "order.item.added".
+func (r TopicRunner) matches(topic string) bool {
+	if r.pattern == topic {
+		return true
+	}
+	if !strings.Contains(r.pattern, "*") {
+		return false
+	}
+
+	patternSegments := strings.Split(r.pattern, ".")
+	topicSegments := strings.Split(topic, ".")
+	if len(patternSegments) != len(topicSegments) {
+		return false
+	}
+
+	for i, segment := range patternSegments {
+		if segment != "*" && segment != topicSegments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// run executes Process, turning a panic into an error so that a single bad
+// message can't take the consumer goroutine down with it.
+func (r TopicRunner) run(payload []byte) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("%w: %v", ErrProcessPanic, rec)
+		}
+	}()
+
+	return r.Process(payload)
+}
+
+// process runs Process on a message, retrying it as configured, in a consumer
+// span continuing the trace the message was published in.
+func (r TopicRunner) process(system, topic string, payload []byte, traceAttributes map[string]string) error {
+	ctx := tracing.ExtractAttributes(context.Background(), traceAttributes)
+	_, span := tracing.Tracer().Start(ctx, topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String(system),
+			semconv.MessagingDestinationKey.String(topic),
+			semconv.MessagingOperationProcess,
+			attribute.String("messaging.subscription", r.pattern),
+		),
+	)
+
+	attempts := 0
+	err := r.backoff(func() error {
+		attempts++
+		if attempts > 1 {
+			span.AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempts)))
+		}
+		return r.run(payload)
+	})
+
+	span.SetAttributes(attribute.Int("messaging.attempts", attempts))
+	tracing.End(span, err)
+	countProcessed(system, topic, r.pattern, attempts, err)
+	return err
+}
+
 func (r TopicRunner) backoff(exec func() error) error {
 	var err error
 
-	if r.consumerConfig.MaxRetry == 0 {
-		return exec()
-	}
+	attempts := r.consumerConfig.attempts()
+	for retry := 0; retry < attempts; retry++ {
+		if retry > 0 {
+			time.Sleep(r.consumerConfig.retryDelay(retry - 1))
+		}
 
-	counter := 0
-	for counter < r.consumerConfig.MaxRetry {
 		err = exec()
 		if err == nil {
-			return err
+			return nil
 		}
-
-		counter++
-		time.Sleep(r.consumerConfig.MaxDelayRetry)
 	}
 
 	return err
 }
 
 // max is a total process could be handle
-func New(maxFlight int, opts ...func(*pubsubConfig)) PubSub {
+func New(maxFlight int, opts ...func(*pubsubConfig)) *PubSub {
 	config := defaultPubsubConfig()
 	for _, opt := range opts {
 		opt(&config)
 	}
 
-	return PubSub{
+	return &PubSub{
 		message:     make(chan message, config.MessageBuffer),
 		messagePool: make(chan chan message),
 		max:         maxFlight,
-		topics:      make(map[string]TopicRunner),
+		config:      config,
+		quit:        make(chan struct{}),
+		terminate:   make(chan struct{}),
+	}
+}
+
+// Publish sends a message to the topic, blocking until there is room in the buffer.
+func (p *PubSub) Publish(topic string, payload []byte) error {
+	return p.PublishContext(context.Background(), topic, payload)
+}
+
+// PublishContext sends a message to the topic, blocking until there is room in
+// the buffer, the context is done or the PubSub is stopped.
+func (p *PubSub) PublishContext(ctx context.Context, topic string, payload []byte) error {
+	if err := p.acquire(); err != nil {
+		return err
+	}
+
+	// counted before sending, so the consumer can't take it off first
+	pending := pubsubPending.WithLabelValues(topic)
+	pending.Inc()
+
+	select {
+	case p.message <- message{topic: topic, payload: payload, trace: tracing.InjectAttributes(ctx)}:
+		return nil
+	case <-ctx.Done():
+		pending.Dec()
+		p.inflight.Done()
+		return ctx.Err()
+	case <-p.quit:
+		pending.Dec()
+		p.inflight.Done()
+		return ErrPubSubStopped
+	}
+}
+
+// TryPublish sends a message to the topic without blocking. It returns
+// ErrBufferFull when the message can't be accepted right away.
+func (p *PubSub) TryPublish(topic string, payload []byte) error {
+	if err := p.acquire(); err != nil {
+		return err
+	}
+
+	pending := pubsubPending.WithLabelValues(topic)
+	pending.Inc()
+
+	select {
+	case p.message <- message{topic: topic, payload: payload}:
+		return nil
+	default:
+		pending.Dec()
+		p.inflight.Done()
+		return ErrBufferFull
 	}
 }
 
-func (p PubSub) Publish(topic string, payload []byte) {
-	p.message <- message{
-		topic:   topic,
-		payload: payload,
+// acquire registers a message as in flight, unless the PubSub is stopped.
+func (p *PubSub) acquire() error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.stopped {
+		return ErrPubSubStopped
 	}
+	p.inflight.Add(1)
+
+	return nil
 }
 
-func (p PubSub) SubscriberRegistry(topicListener string, pr Process, opts ...func(*consumerConfig)) {
+// SubscriberRegistry adds a subscriber to the topic. A topic can have many
+// subscribers, each receiving its own copy of every message and retrying it
+// with its own settings. The topic may be a pattern such as "order.*".
+func (p *PubSub) SubscriberRegistry(topicListener string, pr Process, opts ...func(*consumerConfig)) error {
 	cfg := defaultConsumerConfig()
 
 	for _, opt := range opts {
 		opt(&cfg)
 	}
 
-	p.topics[topicListener] = TopicRunner{
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.runners = append(p.runners, TopicRunner{
 		Process:        pr,
+		pattern:        topicListener,
 		consumerConfig: cfg,
+	})
+
+	return nil
+}
+
+// runnersFor returns the subscribers of the topic.
+func (p *PubSub) runnersFor(topic string) (runners []TopicRunner) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, runner := range p.runners {
+		if runner.matches(topic) {
+			runners = append(runners, runner)
+		}
 	}
+
+	return
 }
 
-func (p PubSub) Start() {
+func (p *PubSub) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.started || p.stopped {
+		return
+	}
+	p.started = true
+
 	for i := 0; i < p.max; i++ {
-		consumer := consumer(p.messagePool, p.topics)
+		consumer := consumer(p)
 		consumer.consume()
 	}
 
 	go p.dispatch()
 }
 
-func (p PubSub) dispatch() {
+// Stop stops accepting new messages and waits until every accepted message has
+// been processed. If the context is done first, the remaining messages keep
+// draining in the background and the context error is returned.
+func (p *PubSub) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return nil
+	}
+	p.stopped = true
+	started := p.started
+	close(p.quit)
+	p.mu.Unlock()
+
+	if !started {
+		p.discardBuffered()
+		close(p.terminate)
+		return nil
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		p.inflight.Wait()
+		close(p.terminate)
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// discardBuffered hands messages that were published but never dispatched to
+// the error handler, since nothing is going to consume them.
+func (p *PubSub) discardBuffered() {
+	for {
+		select {
+		case msg := <-p.message:
+			pubsubPending.WithLabelValues(msg.topic).Dec()
+			p.config.ErrorHandler(msg.topic, msg.payload, ErrPubSubStopped)
+			p.inflight.Done()
+		default:
+			return
+		}
+	}
+}
+
+func (p *PubSub) dispatch() {
 	for {
 		// waiting from p.Message from instantiate
-		msg := <-p.message
+		var msg message
+		select {
+		case msg = <-p.message:
+		case <-p.terminate:
+			return
+		}
 
 		// read the response from consume
 		response := <-p.messagePool
@@ -0,0 +1,34 @@
+package shared
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	pubsubPending = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pubsub_messages_pending",
+		Help: "Number of messages published to the in-process PubSub and waiting to be dispatched, by topic.",
+	}, []string{"topic"})
+	pubsubProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pubsub_messages_processed_total",
+		Help: "Number of messages processed by subscribers, by outcome.",
+	}, []string{"broker", "topic", "subscription", "outcome"})
+	pubsubRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pubsub_retries_total",
+		Help: "Number of times subscribers retried processing a message.",
+	}, []string{"broker", "topic", "subscription"})
+)
+
+// countProcessed records the outcome of a subscriber processing a message.
+func countProcessed(broker, topic, subscription string, attempts int, err error) {
+	if attempts > 1 {
+		pubsubRetries.WithLabelValues(broker, topic, subscription).Add(float64(attempts - 1))
+	}
+
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	pubsubProcessed.WithLabelValues(broker, topic, subscription, outcome).Inc()
+}
@@ -1,12 +1,21 @@
 package shared_test
 
 import (
+	"context"
 	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/evermos/boilerplate-go/shared"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestPubSub(t *testing.T) {
@@ -80,4 +89,209 @@ func TestPubSub(t *testing.T) {
 		time.Sleep(3 * time.Second)
 		assert.Equal(t, 1000, counter)
 	})
+	t.Run("Stop Drains In Flight Messages", func(t *testing.T) {
+		var processed int32
+		pubsub := shared.New(2, shared.SetMessageBuffer(10))
+		pubsub.SubscriberRegistry("test", func(message []byte) error {
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&processed, 1)
+			return nil
+		})
+		pubsub.Start()
+
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, pubsub.Publish("test", []byte("test")))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, pubsub.Stop(ctx))
+		assert.Equal(t, int32(10), atomic.LoadInt32(&processed))
+		assert.Equal(t, shared.ErrPubSubStopped, pubsub.Publish("test", []byte("test")))
+	})
+
+	t.Run("Publish Context Cancelled", func(t *testing.T) {
+		pubsub := shared.New(1)
+		pubsub.SubscriberRegistry("test", func(message []byte) error {
+			return nil
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := pubsub.PublishContext(ctx, "test", []byte("test"))
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+
+	t.Run("Try Publish Buffer Full", func(t *testing.T) {
+		pubsub := shared.New(1, shared.SetMessageBuffer(1))
+		pubsub.SubscriberRegistry("test", func(message []byte) error {
+			return nil
+		})
+
+		assert.NoError(t, pubsub.TryPublish("test", []byte("first")))
+		assert.Equal(t, shared.ErrBufferFull, pubsub.TryPublish("test", []byte("second")))
+	})
+
+	t.Run("Recover Panic And Unknown Topic", func(t *testing.T) {
+		var mu sync.Mutex
+		failures := map[string]error{}
+		pubsub := shared.New(1, shared.SetMessageBuffer(10), shared.SetErrorHandler(func(topic string, payload []byte, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failures[topic] = err
+		}))
+		pubsub.SubscriberRegistry("panic", func(message []byte) error {
+			panic("boom")
+		})
+		pubsub.Start()
+
+		assert.NoError(t, pubsub.Publish("panic", []byte("test")))
+		assert.NoError(t, pubsub.Publish("unknown", []byte("test")))
+		assert.NoError(t, pubsub.Stop(context.Background()))
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.True(t, errors.Is(failures["panic"], shared.ErrProcessPanic))
+		assert.True(t, errors.Is(failures["unknown"], shared.ErrUnknownTopic))
+	})
+	t.Run("Fan Out To Every Subscriber", func(t *testing.T) {
+		var first, second int32
+		pubsub := shared.New(1, shared.SetMessageBuffer(10))
+		pubsub.SubscriberRegistry("test", func(message []byte) error {
+			atomic.AddInt32(&first, 1)
+			return nil
+		})
+		pubsub.SubscriberRegistry("test", func(message []byte) error {
+			atomic.AddInt32(&second, 1)
+			return nil
+		}, shared.SetAsynchronousThread(true))
+		pubsub.Start()
+
+		assert.NoError(t, pubsub.Publish("test", []byte("test")))
+		assert.NoError(t, pubsub.Stop(context.Background()))
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&first))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&second))
+	})
+
+	t.Run("Wildcard Topic", func(t *testing.T) {
+		var mu sync.Mutex
+		var received []string
+		pubsub := shared.New(1, shared.SetMessageBuffer(10), shared.SetErrorHandler(func(topic string, payload []byte, err error) {}))
+		pubsub.SubscriberRegistry("order.*", func(message []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, string(message))
+			return nil
+		})
+		pubsub.Start()
+
+		assert.NoError(t, pubsub.Publish("order.created", []byte("order.created")))
+		assert.NoError(t, pubsub.Publish("order.item.added", []byte("order.item.added")))
+		assert.NoError(t, pubsub.Publish("orders.created", []byte("orders.created")))
+		assert.NoError(t, pubsub.Publish("order.paid", []byte("order.paid")))
+		assert.NoError(t, pubsub.Stop(context.Background()))
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"order.created", "order.paid"}, received)
+	})
+
+	t.Run("Dead Letter After Exponential Backoff", func(t *testing.T) {
+		var letters []shared.DeadLetter
+		pubsub := shared.New(1, shared.SetMessageBuffer(10), shared.SetDeadLetterSink(shared.DeadLetterFunc(func(letter shared.DeadLetter) error {
+			letters = append(letters, letter)
+			return nil
+		})))
+		pubsub.SubscriberRegistry("order.*", func(message []byte) error {
+			return errors.New("error test retry")
+		}, shared.SetMaxRetry(4), shared.SetBaseDelayRetry(10*time.Millisecond), shared.SetMaxDelayRetry(20*time.Millisecond))
+		pubsub.Start()
+
+		start := time.Now()
+		assert.NoError(t, pubsub.Publish("order.created", []byte("test")))
+		assert.NoError(t, pubsub.Stop(context.Background()))
+
+		// delays are 10ms, 20ms and 20ms, each jittered down to half at most
+		assert.True(t, time.Since(start) >= 25*time.Millisecond)
+		assert.Len(t, letters, 1)
+		assert.Equal(t, "order.created", letters[0].Topic)
+		assert.Equal(t, "order.*", letters[0].Pattern)
+		assert.Equal(t, 4, letters[0].Attempts)
+		assert.EqualError(t, letters[0].Err, "error test retry")
+	})
+
+	t.Run("Trace Dispatch", func(t *testing.T) {
+		exporter := tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		attempts := 0
+		pubsub := shared.New(1, shared.SetMessageBuffer(10), shared.SetErrorHandler(func(string, []byte, error) {}))
+		pubsub.SubscriberRegistry("order.*", func(message []byte) error {
+			attempts++
+			if attempts == 1 {
+				return errors.New("error test retry")
+			}
+			return nil
+		}, shared.SetMaxRetry(2))
+		pubsub.Start()
+		ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+
+		assert.NoError(t, pubsub.PublishContext(ctx, "order.created", []byte("Testing")))
+		parent.End()
+		assert.NoError(t, pubsub.Stop(context.Background()))
+
+		spans := exporter.GetSpans()
+		if assert.Len(t, spans, 2) {
+			process := spans[0]
+			if process.Name != "order.created process" {
+				process = spans[1]
+			}
+			assert.Equal(t, "order.created process", process.Name)
+			assert.Equal(t, parent.SpanContext().TraceID(), process.SpanContext.TraceID())
+			assert.Equal(t, parent.SpanContext().SpanID(), process.Parent.SpanID())
+			assert.Contains(t, process.Attributes, attribute.Int("messaging.attempts", 2))
+			assert.Len(t, process.Events, 1)
+		}
+	})
+
+	t.Run("Count Retries And Outcomes", func(t *testing.T) {
+		pubsub := shared.New(1, shared.SetMessageBuffer(10), shared.SetErrorHandler(func(string, []byte, error) {}))
+		pubsub.SubscriberRegistry("metrics", func(message []byte) error {
+			return errors.New("error test retry")
+		}, shared.SetMaxRetry(3))
+		pubsub.Start()
+
+		assert.NoError(t, pubsub.Publish("metrics", []byte("Testing")))
+		assert.NoError(t, pubsub.Stop(context.Background()))
+
+		labels := map[string]string{"broker": "pubsub", "topic": "metrics", "subscription": "metrics"}
+		assert.Equal(t, float64(2), counterValue(t, "pubsub_retries_total", labels))
+		labels["outcome"] = "failure"
+		assert.Equal(t, float64(1), counterValue(t, "pubsub_messages_processed_total", labels))
+	})
+}
+
+// counterValue returns the value of a counter in the default registry.
+func counterValue(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
 }
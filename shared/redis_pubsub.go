@@ -0,0 +1,453 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared/tracing"
+	"github.com/go-redis/redis"
+	"github.com/rs/zerolog/log"
+)
+
+const redisPayloadField = "payload"
+
+// ErrMaxDeliveries is passed to the error handler when a Redis stream entry
+// has been delivered too many times without being processed.
+var ErrMaxDeliveries = errors.New("pubsub: maximum deliveries exceeded")
+
+// ErrPatternNotSupported is returned when subscribing a RedisPubSub to a
+// pattern, as streams are addressed by key.
+var ErrPatternNotSupported = errors.New("pubsub: pattern subscriptions are not supported by RedisPubSub")
+
+// SetStreamPrefix sets the prefix of the Redis stream key of every topic.
+func SetStreamPrefix(prefix string) func(*pubsubConfig) {
+	return func(pc *pubsubConfig) {
+		pc.StreamPrefix = prefix
+	}
+}
+
+// SetStreamMaxLen sets the approximate length streams are trimmed to on
+// publish. Zero disables trimming.
+func SetStreamMaxLen(maxLen int64) func(*pubsubConfig) {
+	return func(pc *pubsubConfig) {
+		pc.StreamMaxLen = maxLen
+	}
+}
+
+// SetConsumerName sets the name this process uses inside the consumer groups.
+// It must be unique among the replicas and stable across restarts.
+func SetConsumerName(name string) func(*pubsubConfig) {
+	return func(pc *pubsubConfig) {
+		pc.ConsumerName = name
+	}
+}
+
+// SetReadBlock sets how long a read waits for new entries before checking
+// whether the consumer has been stopped.
+func SetReadBlock(block time.Duration) func(*pubsubConfig) {
+	return func(pc *pubsubConfig) {
+		pc.ReadBlock = block
+	}
+}
+
+// SetClaimMinIdle sets how long an entry must stay unacknowledged before
+// it is reclaimed from the consumer that received it.
+func SetClaimMinIdle(minIdle time.Duration) func(*pubsubConfig) {
+	return func(pc *pubsubConfig) {
+		pc.ClaimMinIdle = minIdle
+	}
+}
+
+// SetClaimInterval sets how often pending entries are checked for reclaiming.
+func SetClaimInterval(interval time.Duration) func(*pubsubConfig) {
+	return func(pc *pubsubConfig) {
+		pc.ClaimInterval = interval
+	}
+}
+
+// SetMaxDeliveries sets how many times an entry is delivered before it is
+// acknowledged and handed to the error handler. Zero means no limit.
+func SetMaxDeliveries(maxDeliveries int64) func(*pubsubConfig) {
+	return func(pc *pubsubConfig) {
+		pc.MaxDeliveries = maxDeliveries
+	}
+}
+
+// SetConsumerGroup sets the Redis consumer group of a subscriber. Replicas
+// sharing a group split the messages between them, while every group gets
+// its own copy.
+func SetConsumerGroup(group string) func(*consumerConfig) {
+	return func(cc *consumerConfig) {
+		cc.ConsumerGroup = group
+	}
+}
+
+// SetSubscriberName names a subscriber of a RedisPubSub. Its consumer group is
+// derived from the name, so the group and its backlog stay with the subscriber
+// however the subscribers are ordered.
+func SetSubscriberName(name string) func(*consumerConfig) {
+	return func(cc *consumerConfig) {
+		cc.SubscriberName = name
+	}
+}
+
+func defaultConsumerName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "consumer"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+type redisSubscription struct {
+	stream string
+	topic  string
+	group  string
+	runner TopicRunner
+}
+
+// RedisPubSub is a PubSub backed by Redis Streams. Messages survive restarts
+// and are shared between every replica in the same consumer group.
+type RedisPubSub struct {
+	client *redis.Client
+	group  string
+	max    int
+	config pubsubConfig
+
+	mu            sync.Mutex
+	subscriptions []redisSubscription
+	started       bool
+	stopped       bool
+	quit          chan struct{}
+	workers       sync.WaitGroup
+}
+
+// NewRedisPubSub creates a RedisPubSub. The group names the consumer group
+// subscribers join by default, and maxFlight is the number of messages each
+// subscriber processes concurrently.
+func NewRedisPubSub(client *redis.Client, group string, maxFlight int, opts ...func(*pubsubConfig)) *RedisPubSub {
+	config := defaultPubsubConfig()
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return &RedisPubSub{
+		client: client,
+		group:  group,
+		max:    maxFlight,
+		config: config,
+		quit:   make(chan struct{}),
+	}
+}
+
+// Publish appends a message to the topic's stream.
+func (r *RedisPubSub) Publish(topic string, payload []byte) error {
+	return r.PublishContext(context.Background(), topic, payload)
+}
+
+// PublishContext appends a message to the topic's stream, trimming the stream
+// to its configured length.
+func (r *RedisPubSub) PublishContext(ctx context.Context, topic string, payload []byte) error {
+	r.mu.Lock()
+	stopped := r.stopped
+	r.mu.Unlock()
+
+	if stopped {
+		return ErrPubSubStopped
+	}
+
+	// the trace context travels in the other fields of the entry
+	values := map[string]interface{}{redisPayloadField: payload}
+	for name, value := range tracing.InjectAttributes(ctx) {
+		values[name] = value
+	}
+
+	return r.client.WithContext(ctx).XAdd(&redis.XAddArgs{
+		Stream:       r.stream(topic),
+		MaxLenApprox: r.config.StreamMaxLen,
+		Values:       values,
+	}).Err()
+}
+
+// SubscriberRegistry adds a subscriber to the topic. Every subscriber of a
+// topic reads through its own consumer group, so each gets every message.
+// The group is the one set with SetConsumerGroup, or is derived from the name
+// set with SetSubscriberName; a single unnamed subscriber per topic uses the
+// default group. Streams are addressed by key, so patterns such as "order.*"
+// fail with ErrPatternNotSupported.
+func (r *RedisPubSub) SubscriberRegistry(topicListener string, pr Process, opts ...func(*consumerConfig)) error {
+	if strings.Contains(topicListener, "*") {
+		return fmt.Errorf("%w: %q", ErrPatternNotSupported, topicListener)
+	}
+
+	cfg := defaultConsumerConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	group := cfg.ConsumerGroup
+	if group == "" {
+		group = r.group
+		if cfg.SubscriberName != "" {
+			group = r.group + "." + cfg.SubscriberName
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, sub := range r.subscriptions {
+		if sub.topic == topicListener && sub.group == group {
+			return fmt.Errorf("pubsub: topic %q already has a subscriber in group %q, name it with SetSubscriberName", topicListener, group)
+		}
+	}
+
+	r.subscriptions = append(r.subscriptions, redisSubscription{
+		stream: r.stream(topicListener),
+		topic:  topicListener,
+		group:  group,
+		runner: TopicRunner{
+			Process:        pr,
+			pattern:        topicListener,
+			consumerConfig: cfg,
+		},
+	})
+
+	return nil
+}
+
+// Start creates the consumer groups and starts reading and reclaiming.
+func (r *RedisPubSub) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.started || r.stopped {
+		return
+	}
+	r.started = true
+
+	for _, sub := range r.subscriptions {
+		r.workers.Add(1)
+		go r.subscribe(sub)
+	}
+}
+
+// subscribe creates the subscription's consumer group, retrying with the
+// backoff of its runner until Redis accepts it, then starts reading and
+// reclaiming.
+func (r *RedisPubSub) subscribe(sub redisSubscription) {
+	defer r.workers.Done()
+
+	for retry := 0; ; retry++ {
+		err := r.createGroup(sub)
+		if err == nil {
+			break
+		}
+
+		delay := sub.runner.consumerConfig.retryDelay(retry)
+		if delay <= 0 {
+			delay = r.config.ReadBlock
+		}
+		log.Error().Err(err).Str("stream", sub.stream).Str("group", sub.group).Dur("retryIn", delay).Msg("Failed creating consumer group")
+		r.sleep(delay)
+
+		select {
+		case <-r.quit:
+			return
+		default:
+		}
+	}
+
+	for i := 0; i < r.max; i++ {
+		r.workers.Add(1)
+		go r.read(sub, fmt.Sprintf("%s-%d", r.config.ConsumerName, i))
+	}
+
+	r.workers.Add(1)
+	go r.reclaim(sub, r.config.ConsumerName+"-reclaim")
+}
+
+// Stop stops reading new entries and waits until the entries being processed
+// are done. Unacknowledged entries stay pending and are reclaimed later.
+func (r *RedisPubSub) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	if r.stopped {
+		r.mu.Unlock()
+		return nil
+	}
+	r.stopped = true
+	close(r.quit)
+	r.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		r.workers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *RedisPubSub) stream(topic string) string {
+	return r.config.StreamPrefix + topic
+}
+
+// createGroup creates the subscription's consumer group, starting from the
+// beginning of the stream so messages published before the first deploy of
+// a subscriber aren't lost.
+func (r *RedisPubSub) createGroup(sub redisSubscription) error {
+	err := r.client.XGroupCreateMkStream(sub.stream, sub.group, "0").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+func (r *RedisPubSub) read(sub redisSubscription, consumerName string) {
+	defer r.workers.Done()
+
+	for {
+		select {
+		case <-r.quit:
+			return
+		default:
+		}
+
+		streams, err := r.client.XReadGroup(&redis.XReadGroupArgs{
+			Group:    sub.group,
+			Consumer: consumerName,
+			Streams:  []string{sub.stream, ">"},
+			Count:    1,
+			Block:    r.config.ReadBlock,
+		}).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			log.Error().Err(err).Str("stream", sub.stream).Str("group", sub.group).Msg("Failed reading stream")
+			r.sleep(r.config.ReadBlock)
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				r.process(sub, msg, 1)
+			}
+		}
+	}
+}
+
+// reclaim periodically claims entries that another consumer received but
+// never acknowledged, usually because it crashed, and processes them again.
+func (r *RedisPubSub) reclaim(sub redisSubscription, consumerName string) {
+	defer r.workers.Done()
+
+	ticker := time.NewTicker(r.config.ClaimInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.quit:
+			return
+		case <-ticker.C:
+		}
+
+		pending, err := r.client.XPendingExt(&redis.XPendingExtArgs{
+			Stream: sub.stream,
+			Group:  sub.group,
+			Start:  "-",
+			End:    "+",
+			Count:  100,
+		}).Result()
+		if err != nil {
+			log.Error().Err(err).Str("stream", sub.stream).Str("group", sub.group).Msg("Failed listing pending entries")
+			continue
+		}
+
+		for _, entry := range pending {
+			if entry.Idle < r.config.ClaimMinIdle {
+				continue
+			}
+
+			claimed, err := r.client.XClaim(&redis.XClaimArgs{
+				Stream:   sub.stream,
+				Group:    sub.group,
+				Consumer: consumerName,
+				MinIdle:  r.config.ClaimMinIdle,
+				Messages: []string{entry.Id},
+			}).Result()
+			if err != nil {
+				log.Error().Err(err).Str("stream", sub.stream).Str("id", entry.Id).Msg("Failed claiming pending entry")
+				continue
+			}
+
+			for _, msg := range claimed {
+				r.process(sub, msg, entry.RetryCount+1)
+			}
+		}
+	}
+}
+
+// process runs the subscriber on an entry and acknowledges it once it was
+// processed, dead-lettered, or delivered too many times.
+func (r *RedisPubSub) process(sub redisSubscription, msg redis.XMessage, deliveries int64) {
+	payload := []byte(fmt.Sprint(msg.Values[redisPayloadField]))
+
+	traceAttributes := make(map[string]string)
+	for name, value := range msg.Values {
+		if name != redisPayloadField {
+			traceAttributes[name] = fmt.Sprint(value)
+		}
+	}
+
+	err := sub.runner.process("redis", sub.topic, payload, traceAttributes)
+	if err == nil {
+		r.ack(sub, msg.ID)
+		return
+	}
+
+	if r.config.deadLetter(DeadLetter{
+		ID:       msg.ID,
+		Topic:    sub.topic,
+		Pattern:  sub.runner.pattern,
+		Payload:  payload,
+		Err:      err,
+		Attempts: sub.runner.consumerConfig.attempts(),
+		FailedAt: time.Now(),
+	}) {
+		r.ack(sub, msg.ID)
+		return
+	}
+
+	if r.config.MaxDeliveries > 0 && deliveries >= r.config.MaxDeliveries {
+		r.config.ErrorHandler(sub.topic, payload, fmt.Errorf("%w: %v", ErrMaxDeliveries, err))
+		r.ack(sub, msg.ID)
+		return
+	}
+
+	// leave the entry pending so it gets reclaimed and retried later
+	r.config.ErrorHandler(sub.topic, payload, err)
+}
+
+func (r *RedisPubSub) ack(sub redisSubscription, id string) {
+	if err := r.client.XAck(sub.stream, sub.group, id).Err(); err != nil {
+		log.Error().Err(err).Str("stream", sub.stream).Str("id", id).Msg("Failed acknowledging entry")
+	}
+}
+
+func (r *RedisPubSub) sleep(d time.Duration) {
+	select {
+	case <-r.quit:
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,163 @@
+package shared_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRedisClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	return server, redis.NewClient(&redis.Options{Addr: server.Addr()})
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(3 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRedisPubSub(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		_, client := newRedisClient(t)
+		var actual atomic.Value
+		pubsub := shared.NewRedisPubSub(client, "test-group", 1, shared.SetReadBlock(50*time.Millisecond))
+		pubsub.SubscriberRegistry("test", func(message []byte) error {
+			actual.Store(string(message))
+			return nil
+		})
+		pubsub.Start()
+
+		assert.NoError(t, pubsub.Publish("test", []byte("Testing")))
+		waitFor(t, func() bool { return actual.Load() != nil })
+		assert.NoError(t, pubsub.Stop(context.Background()))
+
+		assert.Equal(t, "Testing", actual.Load())
+		pending, err := client.XPending("pubsub:test", "test-group").Result()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), pending.Count)
+	})
+
+	t.Run("Retry Creating Consumer Group", func(t *testing.T) {
+		server, client := newRedisClient(t)
+		var actual atomic.Value
+		pubsub := shared.NewRedisPubSub(client, "test-group", 1, shared.SetReadBlock(50*time.Millisecond))
+		pubsub.SubscriberRegistry("test", func(message []byte) error {
+			actual.Store(string(message))
+			return nil
+		})
+		server.SetError("LOADING Redis is loading the dataset in memory")
+		pubsub.Start()
+
+		time.Sleep(100 * time.Millisecond)
+		server.SetError("")
+		assert.NoError(t, pubsub.Publish("test", []byte("Testing")))
+		waitFor(t, func() bool { return actual.Load() != nil })
+		assert.NoError(t, pubsub.Stop(context.Background()))
+
+		assert.Equal(t, "Testing", actual.Load())
+	})
+
+	t.Run("Fan Out To Every Subscriber", func(t *testing.T) {
+		_, client := newRedisClient(t)
+		var first, second int32
+		pubsub := shared.NewRedisPubSub(client, "test-group", 1, shared.SetReadBlock(50*time.Millisecond))
+		pubsub.SubscriberRegistry("test", func(message []byte) error {
+			atomic.AddInt32(&first, 1)
+			return nil
+		})
+		assert.NoError(t, pubsub.SubscriberRegistry("test", func(message []byte) error {
+			atomic.AddInt32(&second, 1)
+			return nil
+		}, shared.SetSubscriberName("audit")))
+		pubsub.Start()
+
+		assert.NoError(t, pubsub.Publish("test", []byte("test")))
+		waitFor(t, func() bool { return atomic.LoadInt32(&first) == 1 && atomic.LoadInt32(&second) == 1 })
+		assert.NoError(t, pubsub.Stop(context.Background()))
+
+		for _, group := range []string{"test-group", "test-group.audit"} {
+			assert.NoError(t, client.XPending("pubsub:test", group).Err(), group)
+		}
+	})
+
+	t.Run("Unnamed Subscribers Of A Topic", func(t *testing.T) {
+		_, client := newRedisClient(t)
+		pubsub := shared.NewRedisPubSub(client, "test-group", 1)
+		process := func(message []byte) error { return nil }
+
+		assert.NoError(t, pubsub.SubscriberRegistry("test", process))
+		assert.Error(t, pubsub.SubscriberRegistry("test", process))
+	})
+
+	t.Run("Pattern", func(t *testing.T) {
+		_, client := newRedisClient(t)
+		pubsub := shared.NewRedisPubSub(client, "test-group", 1)
+
+		err := pubsub.SubscriberRegistry("order.*", func(message []byte) error { return nil })
+
+		assert.ErrorIs(t, err, shared.ErrPatternNotSupported)
+	})
+
+	t.Run("Reclaim From Crashed Consumer", func(t *testing.T) {
+		_, client := newRedisClient(t)
+		assert.NoError(t, client.XGroupCreateMkStream("pubsub:test", "test-group", "0").Err())
+		assert.NoError(t, client.XAdd(&redis.XAddArgs{
+			Stream: "pubsub:test",
+			Values: map[string]interface{}{"payload": "orphaned"},
+		}).Err())
+		// a consumer reads the entry and dies before acknowledging it
+		assert.NoError(t, client.XReadGroup(&redis.XReadGroupArgs{
+			Group:    "test-group",
+			Consumer: "crashed",
+			Streams:  []string{"pubsub:test", ">"},
+		}).Err())
+
+		var actual atomic.Value
+		pubsub := shared.NewRedisPubSub(client, "test-group", 1,
+			shared.SetReadBlock(50*time.Millisecond),
+			shared.SetClaimMinIdle(time.Millisecond),
+			shared.SetClaimInterval(20*time.Millisecond))
+		pubsub.SubscriberRegistry("test", func(message []byte) error {
+			actual.Store(string(message))
+			return nil
+		})
+		pubsub.Start()
+
+		waitFor(t, func() bool { return actual.Load() != nil })
+		assert.NoError(t, pubsub.Stop(context.Background()))
+
+		assert.Equal(t, "orphaned", actual.Load())
+		pending, err := client.XPending("pubsub:test", "test-group").Result()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), pending.Count)
+	})
+
+	t.Run("Trim Stream", func(t *testing.T) {
+		_, client := newRedisClient(t)
+		pubsub := shared.NewRedisPubSub(client, "test-group", 1, shared.SetStreamMaxLen(3))
+
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, pubsub.Publish("test", []byte("test")))
+		}
+
+		length, err := client.XLen("pubsub:test").Result()
+		assert.NoError(t, err)
+		assert.True(t, length <= 3)
+	})
+}
@@ -0,0 +1,110 @@
+// Package tracing sets up OpenTelemetry tracing and propagates the trace
+// context across services, through HTTP headers and message attributes.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ExporterNone disables exporting spans.
+	ExporterNone = "none"
+	// ExporterStdout writes spans to stdout, for development.
+	ExporterStdout = "stdout"
+	// ExporterOTLP sends spans to an OpenTelemetry collector over OTLP/HTTP.
+	ExporterOTLP = "otlp"
+
+	instrumentationName = "github.com/evermos/boilerplate-go"
+)
+
+// Tracer returns the tracer of the service. It follows the global tracer
+// provider, so it can be obtained before Init is called.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Init installs the global tracer provider and the W3C trace context
+// propagator, and returns a function flushing the remaining spans on
+// shutdown. Spans are only recorded when an exporter is configured.
+func Init(config *configs.Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	tracingConfig := config.Tracing
+	var exporter sdktrace.SpanExporter
+	switch tracingConfig.Exporter {
+	case ExporterNone, "":
+		log.Info().Msg("Tracing is disabled.")
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(tracingConfig.Endpoint)}
+		if tracingConfig.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		err = fmt.Errorf("unknown trace exporter %q", tracingConfig.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(config.App.Name),
+			semconv.ServiceVersionKey.String(config.App.Revision),
+			semconv.DeploymentEnvironmentKey.String(config.Server.Env),
+		)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(tracingConfig.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.Info().
+		Str("exporter", tracingConfig.Exporter).
+		Float64("sampleRatio", tracingConfig.SampleRatio).
+		Msg("Tracing is enabled.")
+	return provider.Shutdown, nil
+}
+
+// InjectAttributes returns the trace context of the context as message
+// attributes, to be published along with a message.
+func InjectAttributes(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier
+}
+
+// ExtractAttributes returns a context continuing the trace carried in the
+// attributes of a received message.
+func ExtractAttributes(ctx context.Context, attributes map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(attributes))
+}
+
+// End records the error, if any, on the span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
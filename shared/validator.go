@@ -1,21 +1,69 @@
 package shared
 
 import (
+	"reflect"
+	"strings"
 	"sync"
 
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	idTranslations "github.com/go-playground/validator/v10/translations/id"
 	"github.com/rs/zerolog/log"
 )
 
+// DefaultLocale is the locale of the validation messages when the client
+// doesn't ask for a supported one.
+const DefaultLocale = "en"
+
 var once sync.Once
 var v *validator.Validate
+var translator *ut.UniversalTranslator
 
 // GetValidator is responsible for returning a single instance of the validator.
+// Fields are named after their JSON names in the validation errors.
 func GetValidator() *validator.Validate {
 	once.Do(func() {
 		log.Info().Msg("Validator initialized.")
 		v = validator.New()
+		v.RegisterTagNameFunc(jsonFieldName)
+
+		english, indonesian := en.New(), id.New()
+		translator = ut.New(english, english, indonesian)
+		registerTranslations(english.Locale(), enTranslations.RegisterDefaultTranslations)
+		registerTranslations(indonesian.Locale(), idTranslations.RegisterDefaultTranslations)
 	})
 
 	return v
 }
+
+// GetTranslator returns the translator of the validation messages for the
+// first supported locale, such as en or id, or for the default locale.
+func GetTranslator(locales ...string) ut.Translator {
+	GetValidator()
+	trans, _ := translator.FindTranslator(append(locales, DefaultLocale)...)
+	return trans
+}
+
+func registerTranslations(locale string, register func(*validator.Validate, ut.Translator) error) {
+	trans, _ := translator.GetTranslator(locale)
+	if err := register(v, trans); err != nil {
+		log.Error().Err(err).Str("locale", locale).Msg("Failed registering validation messages.")
+	}
+}
+
+// jsonFieldName names a field after its JSON name, or its Go name if it
+// has none.
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	default:
+		return name
+	}
+}
@@ -1,23 +1,30 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/docs"
+	"github.com/evermos/boilerplate-go/event/producer"
 	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/shared/logger"
+	httpMiddleware "github.com/evermos/boilerplate-go/transport/http/middleware"
 	"github.com/evermos/boilerplate-go/transport/http/response"
 	"github.com/evermos/boilerplate-go/transport/http/router"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
+	"github.com/go-redis/redis"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog/log"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
@@ -37,40 +44,138 @@ const (
 	ServerStateInCleanupPeriod
 )
 
+// readinessTimeout bounds how long the readiness checks may take together.
+const readinessTimeout = 3 * time.Second
+
 // HTTP is the HTTP server.
 type HTTP struct {
 	Config *configs.Config
 	DB     *infras.MySQLConn
 	Router router.Router
-	State  ServerState
 	mux    *chi.Mux
+	server *http.Server
+	// metricsServer serves the metrics on their own port, kept internal.
+	metricsServer *http.Server
+	// cors holds the CORS middleware, replaced when the config is reloaded.
+	cors atomic.Value
+	// state holds the ServerState, set on SIGTERM while requests read it.
+	state int32
+
+	checks  []namedFunc
+	closers []namedFunc
+	done    chan struct{}
 }
 
-// ProvideHTTP is the provider for HTTP.
-func ProvideHTTP(db *infras.MySQLConn, config *configs.Config, router router.Router) *HTTP {
-	return &HTTP{
+// namedFunc is a readiness check or a resource closed on shutdown.
+type namedFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// ProvideHTTP is the provider for HTTP. The server is only ready while the
+// write database, Redis and the enabled event backends are reachable, and
+// closes the database and Redis connections once it shut down.
+func ProvideHTTP(db *infras.MySQLConn, cache *redis.Client, eventProducer producer.Producer, config *configs.Config, router router.Router) *HTTP {
+	h := &HTTP{
 		DB:     db,
 		Config: config,
 		Router: router,
+		done:   make(chan struct{}),
+	}
+
+	h.AddReadinessCheck("mysql", db.Write.PingContext)
+	h.AddReadinessCheck("redis", func(ctx context.Context) error {
+		return cache.WithContext(ctx).Ping().Err()
+	})
+	if pinger, ok := eventProducer.(interface{ Ping(context.Context) error }); ok && config.Event.Producer.SNS.Topics.FooCreated.Enabled {
+		h.AddReadinessCheck("sns", pinger.Ping)
 	}
+
+	h.OnShutdown("mysql", func(context.Context) error { return db.Close() })
+	h.OnShutdown("redis", func(context.Context) error { return cache.Close() })
+
+	return h
 }
 
-// SetupAndServe sets up the server and gets it up and running.
+// State returns the state of the server.
+func (h *HTTP) State() ServerState {
+	return ServerState(atomic.LoadInt32(&h.state))
+}
+
+// SetState sets the state of the server.
+func (h *HTTP) SetState(state ServerState) {
+	atomic.StoreInt32(&h.state, int32(state))
+}
+
+// AddReadinessCheck adds a check the server must pass to be ready.
+func (h *HTTP) AddReadinessCheck(name string, check func(ctx context.Context) error) {
+	h.checks = append(h.checks, namedFunc{name: name, fn: check})
+}
+
+// OnShutdown registers a resource to close once the server is shut down and
+// its requests have been drained. Resources are closed in the reverse order
+// of their registration, like deferred calls, so a resource registered after
+// the ones it uses is closed before them.
+func (h *HTTP) OnShutdown(name string, close func(ctx context.Context) error) {
+	h.closers = append(h.closers, namedFunc{name: name, fn: close})
+}
+
+// SetupAndServe sets up the server and gets it up and running. It returns
+// once the server has been shut down.
 func (h *HTTP) SetupAndServe() {
 	h.mux = chi.NewRouter()
 	h.setupMiddleware()
 	h.setupSwaggerDocs()
 	h.setupRoutes()
+
+	serverConfig := h.Config.Server
+	h.server = &http.Server{
+		Addr:         ":" + serverConfig.Port,
+		Handler:      h.mux,
+		ReadTimeout:  time.Duration(serverConfig.ReadTimeoutSeconds) * time.Second,
+		WriteTimeout: time.Duration(serverConfig.WriteTimeoutSeconds) * time.Second,
+		IdleTimeout:  time.Duration(serverConfig.IdleTimeoutSeconds) * time.Second,
+	}
+
 	h.setupGracefulShutdown()
-	h.State = ServerStateReady
+	h.serveMetrics()
+	h.SetState(ServerStateReady)
 
 	h.logServerInfo()
 
-	log.Info().Str("port", h.Config.Server.Port).Msg("Starting up HTTP server.")
+	log.Info().Str("port", serverConfig.Port).Msg("Starting up HTTP server.")
 
-	err := http.ListenAndServe(":"+h.Config.Server.Port, h.mux)
-	if err != nil {
+	err := h.server.ListenAndServe()
+	if err != http.ErrServerClosed {
 		logger.ErrorWithStack(err)
+		h.closeResources(context.Background())
+		return
+	}
+
+	<-h.done
+}
+
+// Shutdown stops accepting connections and waits for the requests being
+// served to finish, then closes the registered resources.
+func (h *HTTP) Shutdown(ctx context.Context) (err error) {
+	if h.server != nil {
+		if err = h.server.Shutdown(ctx); err != nil {
+			log.Warn().Err(err).Msg("Failed draining every connection.")
+		}
+	}
+
+	h.closeResources(ctx)
+	return
+}
+
+func (h *HTTP) closeResources(ctx context.Context) {
+	for i := len(h.closers) - 1; i >= 0; i-- {
+		closer := h.closers[i]
+		if err := closer.fn(ctx); err != nil {
+			log.Error().Err(err).Str("resource", closer.name).Msg("Failed closing resource.")
+			continue
+		}
+		log.Info().Str("resource", closer.name).Msg("Closed resource.")
 	}
 }
 
@@ -86,46 +191,86 @@ func (h *HTTP) setupSwaggerDocs() {
 
 func (h *HTTP) setupRoutes() {
 	h.mux.Get("/health", h.HealthCheck)
+	h.mux.Get("/livez", h.Liveness)
+	h.mux.Get("/readyz", h.Readiness)
 	h.Router.SetupRoutes(h.mux)
 }
 
+// serveMetrics serves the metrics on the metrics port, which unlike the API
+// isn't meant to be exposed publicly.
+func (h *HTTP) serveMetrics() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	h.metricsServer = &http.Server{
+		Addr:         ":" + h.Config.Server.MetricsPort,
+		Handler:      mux,
+		ReadTimeout:  time.Duration(h.Config.Server.ReadTimeoutSeconds) * time.Second,
+		WriteTimeout: time.Duration(h.Config.Server.WriteTimeoutSeconds) * time.Second,
+	}
+	h.OnShutdown("metrics", h.metricsServer.Shutdown)
+
+	go func() {
+		log.Info().Str("port", h.Config.Server.MetricsPort).Msg("Serving metrics.")
+		if err := h.metricsServer.ListenAndServe(); err != http.ErrServerClosed {
+			log.Error().Err(err).Msg("Failed serving metrics.")
+		}
+	}()
+}
+
 func (h *HTTP) setupGracefulShutdown() {
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGTERM)
 	go h.respondToSigterm(done)
 }
 
+// respondToSigterm keeps serving through the grace period while failing the
+// readiness checks, so load balancers stop sending requests, then drains the
+// requests and closes the resources within the cleanup period.
 func (h *HTTP) respondToSigterm(done chan os.Signal) {
 	<-done
-	defer os.Exit(0)
+	defer close(h.done)
 
 	shutdownConfig := h.Config.Server.Shutdown
 
 	log.Info().Msg("Received SIGTERM.")
 	log.Info().Int64("seconds", shutdownConfig.GracePeriodSeconds).Msg("Entering grace period.")
-	h.State = ServerStateInGracePeriod
+	h.SetState(ServerStateInGracePeriod)
 	time.Sleep(time.Duration(shutdownConfig.GracePeriodSeconds) * time.Second)
 
 	log.Info().Int64("seconds", shutdownConfig.CleanupPeriodSeconds).Msg("Entering cleanup period.")
-	h.State = ServerStateInCleanupPeriod
-	time.Sleep(time.Duration(shutdownConfig.CleanupPeriodSeconds) * time.Second)
+	h.SetState(ServerStateInCleanupPeriod)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(shutdownConfig.CleanupPeriodSeconds)*time.Second)
+	defer cancel()
+	_ = h.Shutdown(ctx)
 
 	log.Info().Msg("Cleaning up completed. Shutting down now.")
 }
 
 func (h *HTTP) setupMiddleware() {
-	h.mux.Use(middleware.Logger)
+	h.mux.Use(httpMiddleware.RequestID)
+	h.mux.Use(httpMiddleware.Tracing)
+	h.mux.Use(httpMiddleware.Metrics)
+	h.mux.Use(httpMiddleware.Logger)
 	h.mux.Use(middleware.Recoverer)
 	h.mux.Use(h.serverStateMiddleware)
 	h.setupCORS()
+	h.setupTimeout()
+	h.mux.Use(httpMiddleware.DBSession)
+}
+
+func (h *HTTP) setupTimeout() {
+	timeout := h.Config.Server.RequestTimeoutSeconds
+	if timeout > 0 {
+		h.mux.Use(httpMiddleware.Timeout(time.Duration(timeout) * time.Second))
+	}
 }
 
 func (h *HTTP) logServerInfo() {
-	h.logCORSConfigInfo()
+	h.logCORSConfigInfo(h.Config)
 }
 
-func (h *HTTP) logCORSConfigInfo() {
-	corsConfig := h.Config.App.CORS
+func (h *HTTP) logCORSConfigInfo(config *configs.Config) {
+	corsConfig := config.App.CORS
 	corsHeaderInfo := "CORS Header"
 	if corsConfig.Enable {
 		log.Info().Msg("CORS Headers and Handlers are enabled.")
@@ -141,7 +286,7 @@ func (h *HTTP) logCORSConfigInfo() {
 
 func (h *HTTP) serverStateMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch h.State {
+		switch h.State() {
 		case ServerStateReady:
 			// Server is ready to serve, don't do anything.
 			next.ServeHTTP(w, r)
@@ -158,34 +303,120 @@ func (h *HTTP) serverStateMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// setupCORS sets up the CORS headers and handlers, which follow the config
+// when it is reloaded.
 func (h *HTTP) setupCORS() {
-	corsConfig := h.Config.App.CORS
-	if corsConfig.Enable {
-		h.mux.Use(cors.Handler(cors.Options{
-			AllowCredentials: corsConfig.AllowCredentials,
-			AllowedHeaders:   corsConfig.AllowedHeaders,
-			AllowedMethods:   corsConfig.AllowedMethods,
-			AllowedOrigins:   corsConfig.AllowedOrigins,
-			MaxAge:           corsConfig.MaxAgeSeconds,
-		}))
+	h.applyCORS(h.Config)
+	configs.OnReload(func(config *configs.Config) {
+		h.applyCORS(config)
+		h.logCORSConfigInfo(config)
+	})
+
+	h.mux.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.cors.Load().(func(http.Handler) http.Handler)(next).ServeHTTP(w, r)
+		})
+	})
+}
+
+func (h *HTTP) applyCORS(config *configs.Config) {
+	corsConfig := config.App.CORS
+	if !corsConfig.Enable {
+		h.cors.Store(func(next http.Handler) http.Handler { return next })
+		return
 	}
+
+	h.cors.Store(cors.Handler(cors.Options{
+		AllowCredentials: corsConfig.AllowCredentials,
+		AllowedHeaders:   corsConfig.AllowedHeaders,
+		AllowedMethods:   corsConfig.AllowedMethods,
+		AllowedOrigins:   corsConfig.AllowedOrigins,
+		MaxAge:           corsConfig.MaxAgeSeconds,
+	}))
 }
 
 // HealthCheck performs a health check on the server. Usually required by
-// Kubernetes to check if the service is healthy.
+// Kubernetes to check if the service is healthy. It is a liveness check, so
+// it doesn't check any dependency; the dependencies are checked by /readyz.
 // @Summary Health Check
 // @Description Health Check Endpoint
 // @Tags service
 // @Produce json
 // @Accept json
 // @Success 200 {object} response.Base
-// @Failure 503 {object} response.Base
 // @Router /health [get]
 func (h *HTTP) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	if err := h.DB.Read.Ping(); err != nil {
-		logger.ErrorWithStack(err)
-		response.WithUnhealthy(w)
+	h.Liveness(w, r)
+}
+
+// Liveness reports whether the server is alive. It doesn't check any
+// dependency, so an unreachable database doesn't get the server restarted.
+// @Summary Liveness Check
+// @Description Liveness Endpoint
+// @Tags service
+// @Produce json
+// @Accept json
+// @Success 200 {object} response.Base
+// @Router /livez [get]
+func (h *HTTP) Liveness(w http.ResponseWriter, r *http.Request) {
+	response.WithMessage(w, http.StatusOK, "OK")
+}
+
+// Readiness reports whether the server can take requests: it isn't shutting
+// down and its dependencies are reachable. The result of every check is
+// returned; why a check failed is only logged.
+// @Summary Readiness Check
+// @Description Readiness Endpoint
+// @Tags service
+// @Produce json
+// @Accept json
+// @Success 200 {object} response.Base
+// @Failure 503 {object} response.Base
+// @Router /readyz [get]
+func (h *HTTP) Readiness(w http.ResponseWriter, r *http.Request) {
+	if h.State() != ServerStateReady {
+		response.WithPreparingShutdown(w)
 		return
 	}
-	response.WithMessage(w, http.StatusOK, "OK")
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	results, ready := h.checkReadiness(ctx)
+	if !ready {
+		response.WithJSON(w, http.StatusServiceUnavailable, results)
+		return
+	}
+	response.WithJSON(w, http.StatusOK, results)
+}
+
+// checkReadiness runs the readiness checks concurrently.
+func (h *HTTP) checkReadiness(ctx context.Context) (results map[string]string, ready bool) {
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	results = make(map[string]string, len(h.checks))
+	ready = true
+
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func(check namedFunc) {
+			defer wg.Done()
+			err := check.fn(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logger.Ctx(ctx).Warn().Err(err).Str("check", check.name).Msg("Readiness check failed.")
+				results[check.name] = "FAILED"
+				ready = false
+				return
+			}
+			results[check.name] = "OK"
+		}(check)
+	}
+
+	wg.Wait()
+	return
 }
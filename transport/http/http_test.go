@@ -0,0 +1,89 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/configs"
+	transport "github.com/evermos/boilerplate-go/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbes(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	unreachable := func(context.Context) error { return errors.New("connection refused") }
+
+	probe := func(handler http.HandlerFunc) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		var body map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	t.Run("Ready When Every Check Passes", func(t *testing.T) {
+		h := &transport.HTTP{Config: &configs.Config{}}
+		h.SetState(transport.ServerStateReady)
+		h.AddReadinessCheck("mysql", ok)
+		h.AddReadinessCheck("redis", ok)
+
+		code, body := probe(h.Readiness)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, map[string]interface{}{"mysql": "OK", "redis": "OK"}, body["data"])
+	})
+
+	t.Run("Not Ready When A Check Fails", func(t *testing.T) {
+		h := &transport.HTTP{Config: &configs.Config{}}
+		h.SetState(transport.ServerStateReady)
+		h.AddReadinessCheck("mysql", ok)
+		h.AddReadinessCheck("redis", unreachable)
+
+		code, body := probe(h.Readiness)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, map[string]interface{}{"mysql": "OK", "redis": "FAILED"}, body["data"])
+
+		code, _ = probe(h.Liveness)
+		assert.Equal(t, http.StatusOK, code, "a failing dependency must not fail liveness")
+
+		code, _ = probe(h.HealthCheck)
+		assert.Equal(t, http.StatusOK, code, "a failing dependency must not fail the health check")
+	})
+
+	t.Run("Not Ready During Grace Period", func(t *testing.T) {
+		h := &transport.HTTP{Config: &configs.Config{}}
+		h.SetState(transport.ServerStateInGracePeriod)
+		h.AddReadinessCheck("mysql", ok)
+
+		code, _ := probe(h.Readiness)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+
+		code, _ = probe(h.Liveness)
+		assert.Equal(t, http.StatusOK, code)
+	})
+}
+
+func TestShutdown(t *testing.T) {
+	t.Run("Close Resources In Reverse Order", func(t *testing.T) {
+		var closed []string
+		closer := func(name string, err error) func(context.Context) error {
+			return func(context.Context) error {
+				closed = append(closed, name)
+				return err
+			}
+		}
+
+		h := &transport.HTTP{Config: &configs.Config{}}
+		h.OnShutdown("mysql", closer("mysql", nil))
+		h.OnShutdown("redis", closer("redis", errors.New("already closed")))
+		h.OnShutdown("consumers", closer("consumers", nil))
+
+		err := h.Shutdown(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"consumers", "redis", "mysql"}, closed, "a failing closer must not stop the others")
+	})
+}
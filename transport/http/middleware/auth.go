@@ -2,144 +2,144 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
-	"github.com/evermos/boilerplate-go/shared/failure"
-	"github.com/evermos/boilerplate-go/shared/jwt"
+	"errors"
+	"fmt"
 	"net/http"
 
+	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/jwt"
+	"github.com/evermos/boilerplate-go/shared/logger"
 	"github.com/evermos/boilerplate-go/shared/oauth"
 	"github.com/evermos/boilerplate-go/transport/http/response"
+	"github.com/go-redis/redis"
 )
 
+// Authentication authenticates the requests with the strategies of
+// AUTH.STRATEGIES, or with OAuth access tokens only.
 type Authentication struct {
-	db *infras.MySQLConn
+	authenticator auth.Authenticator
+	oauth         *oauth.Authenticator
 }
 
 const (
 	HeaderAuthorization = "Authorization"
 )
 
-func ProvideAuthentication(db *infras.MySQLConn) *Authentication {
+// ProvideAuthentication is the provider for Authentication, trying the
+// strategies in the order of AUTH.STRATEGIES.
+func ProvideAuthentication(db *infras.MySQLConn, cache *redis.Client, tokens oauth.TokenStore, config *configs.Config) *Authentication {
+	oauthAuthenticator := oauth.NewAuthenticator(oauth.New(db.Read, tokens, oauth.Config{}))
+	strategies := map[string]auth.Authenticator{
+		auth.StrategyJWT:    jwt.NewAuthenticator(jwt.NewRevocationList(cache)),
+		auth.StrategyOAuth:  oauthAuthenticator,
+		auth.StrategyAPIKey: auth.NewAPIKeyAuthenticator(db.Read),
+	}
+
+	chain := auth.Chain{}
+	for _, strategy := range config.Auth.Strategies {
+		chain = append(chain, strategies[strategy])
+	}
+
 	return &Authentication{
-		db: db,
+		authenticator: chain,
+		oauth:         oauthAuthenticator,
 	}
 }
 
-type Response struct {
-	Data jwt.Claims `json:"data"`
+// Authenticate lets through the requests authenticated by any of the
+// configured strategies.
+func (a *Authentication) Authenticate(next http.Handler) http.Handler {
+	return Authenticate(a.authenticator)(next)
 }
 
-func ValidateJWTMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		tokenString := r.Header.Get(HeaderAuthorization)[(len("Bearer ")):]
-
-		newReq, err := http.NewRequest("GET", "http://localhost:8081/v1/users/validate-auth", nil)
-		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
+// Authenticate lets through the requests authenticated by an Authenticator,
+// binding their Principal to the context. The requests without credentials
+// or with invalid ones are refused with 401, RFC 6750 section 3.
+func Authenticate(authenticator auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticator.Authenticate(r)
+			switch {
+			case errors.Is(err, auth.ErrNoCredentials):
+				w.Header().Set("WWW-Authenticate", string(oauth.Bearer))
+				response.WithError(w, failure.Unauthorized("credentials required"))
+				return
+			case errors.Is(err, auth.ErrInvalidCredentials):
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`%s error="invalid_token"`, oauth.Bearer))
+				response.WithError(w, failure.Unauthorized("invalid credentials"))
+				return
+			case err != nil:
+				logger.ErrorWithStackContext(r.Context(), err)
+				response.WithError(w, failure.ServiceUnavailable("authentication failed"))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
+		})
+	}
+}
 
-		newReq.Header.Set("Authorization", "Bearer "+tokenString)
+// withPrincipal binds a principal to a context, and its user to the logs.
+func withPrincipal(ctx context.Context, principal auth.Principal) context.Context {
+	ctx = auth.WithPrincipal(ctx, principal)
+	if principal.HasUser() {
+		ctx = logger.WithUserID(ctx, principal.UserID.String())
+	}
+	return ctx
+}
 
-		client := http.Client{}
-		resp, err := client.Do(newReq)
-		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-		decoder := json.NewDecoder(resp.Body)
-		var responseObject Response
-		err = decoder.Decode(&responseObject)
-		if err != nil {
-			response.WithError(w, failure.BadRequest(err))
+// RequireUser lets through the requests authenticated as a user. It must
+// follow a middleware authenticating the request.
+func RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := auth.UserIDFromContext(r.Context()); !ok {
+			response.WithError(w, failure.Unauthorized("the request must be authenticated as a user"))
 			return
 		}
-
-		ctx := context.WithValue(r.Context(), "claims", responseObject.Data)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r)
 	})
 }
 
+// CheckRole lets through the requests authenticated as an admin. It must
+// follow a middleware authenticating the request; other principals are
+// refused with 403.
 func CheckRole(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		resp, ok := r.Context().Value("claims").(jwt.Claims)
+		principal, ok := auth.PrincipalFromContext(r.Context())
 		if !ok {
 			response.WithError(w, failure.Unauthorized("Unauthorized"))
 			return
 		}
-		if resp.Role != "admin" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		if !principal.HasRole("admin") {
+			response.WithError(w, failure.Forbidden("the request requires the admin role"))
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// ClientCredential lets through the requests with an OAuth access token.
 func (a *Authentication) ClientCredential(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		accessToken := r.Header.Get(HeaderAuthorization)
-		token := oauth.New(a.db.Read, oauth.Config{})
-
-		parseToken, err := token.ParseWithAccessToken(accessToken)
-		if err != nil {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
-			return
-		}
-
-		if !parseToken.VerifyExpireIn() {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
+	return Authenticate(a.oauth)(next)
 }
 
+// ClientCredentialWithQueryParameter lets through the requests with an OAuth
+// access token in the token and token_type query parameters, for the
+// clients which can't set headers.
 func (a *Authentication) ClientCredentialWithQueryParameter(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return Authenticate(auth.AuthenticatorFunc(func(r *http.Request) (auth.Principal, error) {
 		params := r.URL.Query()
-		token := params.Get("token")
-		tokenType := params.Get("token_type")
-		accessToken := tokenType + " " + token
-
-		auth := oauth.New(a.db.Read, oauth.Config{})
-		parseToken, err := auth.ParseWithAccessToken(accessToken)
-		if err != nil {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
-			return
-		}
-
-		if !parseToken.VerifyExpireIn() {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
+		clone := r.Clone(r.Context())
+		clone.Header.Set(HeaderAuthorization, params.Get("token_type")+" "+params.Get("token"))
+		return a.oauth.Authenticate(clone)
+	}))(next)
 }
 
+// Password lets through the requests with an OAuth access token issued for
+// a user.
 func (a *Authentication) Password(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		accessToken := r.Header.Get(HeaderAuthorization)
-		token := oauth.New(a.db.Read, oauth.Config{})
-
-		parseToken, err := token.ParseWithAccessToken(accessToken)
-		if err != nil {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
-			return
-		}
-
-		if !parseToken.VerifyExpireIn() {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
-			return
-		}
-
-		if !parseToken.VerifyUserLoggedIn() {
-			response.WithMessage(w, http.StatusUnauthorized, oauth.ErrorInvalidPassword)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
+	return a.ClientCredential(RequireUser(next))
 }
@@ -0,0 +1,201 @@
+package middleware_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/evermos/boilerplate-go/shared/jwt"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/go-redis/redis"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+const queryAccessToken = `FROM\s+oauth_access_tokens\s+WHERE access_token = \?`
+
+func newAuthentication(t *testing.T, strategies ...string) (*middleware.Authentication, sqlmock.Sqlmock, *jwt.RevocationList) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cache := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { cache.Close() })
+
+	config := &configs.Config{}
+	config.Auth.Strategies = strategies
+	sqlxdb := sqlx.NewDb(db, "mysql")
+	conn := &infras.MySQLConn{Read: sqlxdb, Write: sqlxdb}
+	return middleware.ProvideAuthentication(conn, cache, oauth.NewMySQLTokenStore(sqlxdb), config), mock, jwt.NewRevocationList(cache)
+}
+
+// authenticate serves a request through a middleware, and returns the
+// principal the handler got, if any.
+func authenticate(mw func(http.Handler) http.Handler, prepare func(r *http.Request)) (*httptest.ResponseRecorder, *auth.Principal) {
+	var principal *auth.Principal
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := auth.PrincipalFromContext(r.Context()); ok {
+			principal = &p
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/cart/1", nil)
+	if prepare != nil {
+		prepare(r)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w, principal
+}
+
+func bearer(token string) func(r *http.Request) {
+	return func(r *http.Request) {
+		r.Header.Set(middleware.HeaderAuthorization, "Bearer "+token)
+	}
+}
+
+func expectAccessToken(mock sqlmock.Sqlmock, token string, userID interface{}) {
+	sum := sha256.Sum256([]byte(token))
+	mock.ExpectQuery(queryAccessToken).WithArgs(hex.EncodeToString(sum[:]), sqlmock.AnyArg()).WillReturnRows(
+		sqlmock.NewRows([]string{"access_token", "client_id", "user_id", "expires", "scope"}).
+			AddRow(hex.EncodeToString(sum[:]), "client_web", userID, time.Now().Add(time.Hour), "user foo:read"))
+}
+
+func TestAuthenticate(t *testing.T) {
+	userID := uuid.Must(uuid.NewV4())
+	token, _ := jwt.GenerateJWT(userID, "budi@example.com", "admin")
+
+	t.Run("JWT", func(t *testing.T) {
+		authentication, _, _ := newAuthentication(t, auth.Strategies...)
+
+		w, principal := authenticate(authentication.Authenticate, bearer(token))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		if assert.NotNil(t, principal) {
+			assert.Equal(t, userID, principal.UserID)
+			assert.Equal(t, []string{"admin"}, principal.Roles)
+		}
+	})
+
+	t.Run("Revoked JWT", func(t *testing.T) {
+		authentication, _, revocations := newAuthentication(t, auth.Strategies...)
+		claims, _ := jwt.ValidateToken(token)
+		assert.NoError(t, revocations.Revoke(httptest.NewRequest(http.MethodGet, "/", nil).Context(), token, claims))
+
+		w, principal := authenticate(authentication.Authenticate, bearer(token))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Nil(t, principal)
+	})
+
+	t.Run("OAuth Access Token", func(t *testing.T) {
+		authentication, mock, _ := newAuthentication(t, auth.Strategies...)
+		expectAccessToken(mock, "access-token", userID.String())
+
+		w, principal := authenticate(authentication.Authenticate, bearer("access-token"))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		if assert.NotNil(t, principal) {
+			assert.Equal(t, userID, principal.UserID)
+			assert.Equal(t, "client_web", principal.ClientID)
+			assert.Equal(t, []string{"user", "foo:read"}, principal.Scopes)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("API Key", func(t *testing.T) {
+		authentication, mock, _ := newAuthentication(t, auth.Strategies...)
+		mock.ExpectQuery(`FROM\s+api_keys`).WillReturnRows(
+			sqlmock.NewRows([]string{"client_id", "scope"}).AddRow("partner_app", "foo:read"))
+
+		w, principal := authenticate(authentication.Authenticate, func(r *http.Request) {
+			r.Header.Set(auth.HeaderAPIKey, "partner-key")
+		})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		if assert.NotNil(t, principal) {
+			assert.Equal(t, "partner_app", principal.ClientID)
+			assert.False(t, principal.HasUser())
+		}
+	})
+
+	t.Run("Strategy Not Configured", func(t *testing.T) {
+		authentication, _, _ := newAuthentication(t, auth.StrategyOAuth)
+
+		w, _ := authenticate(authentication.Authenticate, bearer(token))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("Unknown Token", func(t *testing.T) {
+		authentication, mock, _ := newAuthentication(t, auth.Strategies...)
+		mock.ExpectQuery(queryAccessToken).WillReturnRows(sqlmock.NewRows([]string{"access_token"}))
+
+		w, _ := authenticate(authentication.Authenticate, bearer("unknown"))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("Token Store Unavailable", func(t *testing.T) {
+		authentication, mock, _ := newAuthentication(t, auth.Strategies...)
+		mock.ExpectQuery(queryAccessToken).WillReturnError(errors.New("connection refused"))
+
+		w, _ := authenticate(authentication.Authenticate, bearer("access-token"))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+}
+
+func TestPasswordRequiresUser(t *testing.T) {
+	authentication, mock, _ := newAuthentication(t, auth.Strategies...)
+	expectAccessToken(mock, "client-token", nil)
+
+	w, _ := authenticate(authentication.Password, bearer("client-token"))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckRole(t *testing.T) {
+	as := func(roles ...string) func(r *http.Request) {
+		return func(r *http.Request) {
+			*r = *r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{UserID: uuid.Must(uuid.NewV4()), Roles: roles}))
+		}
+	}
+
+	t.Run("Admin", func(t *testing.T) {
+		w, _ := authenticate(middleware.CheckRole, as("admin"))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Other Role", func(t *testing.T) {
+		w, _ := authenticate(middleware.CheckRole, as("user"))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), `"FORBIDDEN"`)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		w, _ := authenticate(middleware.CheckRole, nil)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/evermos/boilerplate-go/transport/http/response"
+	"github.com/gofrs/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Introspector validates bearer tokens with a remote RFC 7662 introspection
+// endpoint, caching the active tokens for a short while. The cache holds at
+// most cacheSize tokens, evicting the least recently used.
+type Introspector struct {
+	url          string
+	clientID     string
+	clientSecret string
+	client       *http.Client
+	cacheTTL     time.Duration
+	cacheSize    int
+
+	mu sync.Mutex
+	// cache indexes the elements of lru, ordered from the most recently used.
+	cache map[string]*list.Element
+	lru   *list.List
+}
+
+type cachedIntrospection struct {
+	key           string
+	introspection oauth.Introspection
+	expires       time.Time
+}
+
+// ProvideIntrospector is the provider for Introspector.
+func ProvideIntrospector(config *configs.Config) *Introspector {
+	introspectionConfig := config.OAuth.Introspection
+	return &Introspector{
+		url:          introspectionConfig.URL,
+		clientID:     introspectionConfig.ClientID,
+		clientSecret: introspectionConfig.ClientSecret,
+		client:       &http.Client{Timeout: time.Duration(introspectionConfig.TimeoutSeconds) * time.Second},
+		cacheTTL:     time.Duration(introspectionConfig.CacheTTLSeconds) * time.Second,
+		cacheSize:    introspectionConfig.CacheSize,
+		cache:        make(map[string]*list.Element),
+		lru:          list.New(),
+	}
+}
+
+// ValidateJWTMiddleware lets through the requests with an active bearer
+// token, binding the Principal of its user and its client to the context.
+func (i *Introspector) ValidateJWTMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get(HeaderAuthorization), string(oauth.Bearer)+" ")
+		if token == "" || token == r.Header.Get(HeaderAuthorization) {
+			response.WithError(w, failure.Unauthorized("bearer token required"))
+			return
+		}
+
+		introspection, err := i.Introspect(r.Context(), token)
+		if err != nil {
+			logger.ErrorWithStackContext(r.Context(), err)
+			response.WithError(w, failure.ServiceUnavailable("token introspection failed"))
+			return
+		}
+
+		if !introspection.Active {
+			response.WithError(w, failure.Unauthorized("invalid token"))
+			return
+		}
+
+		principal := auth.Principal{
+			UserID:   uuid.FromStringOrNil(introspection.Sub),
+			ClientID: introspection.ClientID,
+			Scopes:   oauth.ParseScopes(introspection.Scope),
+		}
+		if introspection.Role != "" {
+			principal.Roles = []string{introspection.Role}
+		}
+
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
+	})
+}
+
+// Introspect returns the state of a token, from the cache if it was
+// introspected lately. Active tokens are cached no longer than they last;
+// inactive ones aren't cached, so unknown tokens can't fill the cache.
+func (i *Introspector) Introspect(ctx context.Context, token string) (oauth.Introspection, error) {
+	key := introspectionKey(token)
+	now := time.Now()
+	if introspection, ok := i.cached(key, now); ok {
+		return introspection, nil
+	}
+
+	introspection, err := i.introspect(ctx, token)
+	if err != nil {
+		return oauth.Introspection{}, err
+	}
+
+	if !introspection.Active {
+		return introspection, nil
+	}
+
+	expires := now.Add(i.cacheTTL)
+	if introspection.Exp > 0 && time.Unix(introspection.Exp, 0).Before(expires) {
+		expires = time.Unix(introspection.Exp, 0)
+	}
+	i.store(cachedIntrospection{key: key, introspection: introspection, expires: expires})
+
+	return introspection, nil
+}
+
+func (i *Introspector) introspect(ctx context.Context, token string) (introspection oauth.Introspection, err error) {
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(i.clientID), url.QueryEscape(i.clientSecret))
+	req.Header.Set(HeaderRequestID, logger.RequestID(ctx))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("introspection responded %s", resp.Status)
+		return
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&introspection)
+	return
+}
+
+func (i *Introspector) cached(key string, now time.Time) (oauth.Introspection, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	element, ok := i.cache[key]
+	if !ok {
+		return oauth.Introspection{}, false
+	}
+
+	cached := element.Value.(cachedIntrospection)
+	if !now.Before(cached.expires) {
+		i.remove(element)
+		return oauth.Introspection{}, false
+	}
+
+	i.lru.MoveToFront(element)
+	return cached.introspection, true
+}
+
+func (i *Introspector) store(cached cachedIntrospection) {
+	if i.cacheTTL <= 0 || i.cacheSize <= 0 {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if element, ok := i.cache[cached.key]; ok {
+		element.Value = cached
+		i.lru.MoveToFront(element)
+		return
+	}
+
+	i.cache[cached.key] = i.lru.PushFront(cached)
+	for i.lru.Len() > i.cacheSize {
+		i.remove(i.lru.Back())
+	}
+}
+
+func (i *Introspector) remove(element *list.Element) {
+	i.lru.Remove(element)
+	delete(i.cache, element.Value.(cachedIntrospection).key)
+}
+
+// introspectionKey keys the cache by the hash of the tokens, so the cache
+// doesn't hold usable tokens.
+func introspectionKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,152 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+// newIntrospectionServer serves the introspections of the tokens, counting
+// the calls.
+func newIntrospectionServer(t *testing.T, introspections map[string]oauth.Introspection) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if clientID, clientSecret, _ := r.BasicAuth(); clientID != "resource_server" || clientSecret != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(introspections[r.PostFormValue("token")])
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func introspectionConfig(url string) *configs.Config {
+	config := &configs.Config{}
+	config.OAuth.Introspection.URL = url
+	config.OAuth.Introspection.ClientID = "resource_server"
+	config.OAuth.Introspection.ClientSecret = "s3cr3t"
+	config.OAuth.Introspection.CacheTTLSeconds = 30
+	config.OAuth.Introspection.CacheSize = 100
+	config.OAuth.Introspection.TimeoutSeconds = 1
+	return config
+}
+
+func newIntrospector(url string) *middleware.Introspector {
+	return middleware.ProvideIntrospector(introspectionConfig(url))
+}
+
+func introspectRequest(introspector *middleware.Introspector, authorization string) (*httptest.ResponseRecorder, *auth.Principal) {
+	var principal *auth.Principal
+	handler := introspector.ValidateJWTMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := auth.PrincipalFromContext(r.Context()); ok {
+			principal = &p
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/product/", nil)
+	if authorization != "" {
+		r.Header.Set(middleware.HeaderAuthorization, authorization)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w, principal
+}
+
+func TestIntrospector(t *testing.T) {
+	active := oauth.Introspection{
+		Active:   true,
+		Sub:      "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		Username: "budi@example.com",
+		Role:     "admin",
+		Exp:      time.Now().Add(time.Hour).Unix(),
+	}
+
+	t.Run("Active Token", func(t *testing.T) {
+		server, calls := newIntrospectionServer(t, map[string]oauth.Introspection{"valid": active})
+		introspector := newIntrospector(server.URL)
+
+		w, principal := introspectRequest(introspector, "Bearer valid")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		if assert.NotNil(t, principal) {
+			assert.Equal(t, active.Sub, principal.UserID.String())
+			assert.Equal(t, []string{"admin"}, principal.Roles)
+		}
+
+		w, _ = introspectRequest(introspector, "Bearer valid")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls), "the second introspection is cached")
+	})
+
+	t.Run("Inactive Token", func(t *testing.T) {
+		server, calls := newIntrospectionServer(t, nil)
+		introspector := newIntrospector(server.URL)
+
+		w, _ := introspectRequest(introspector, "Bearer revoked")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w, _ = introspectRequest(introspector, "Bearer revoked")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, int32(2), atomic.LoadInt32(calls), "inactive tokens aren't cached")
+	})
+
+	t.Run("Evict Least Recently Used", func(t *testing.T) {
+		server, calls := newIntrospectionServer(t, map[string]oauth.Introspection{"first": active, "second": active, "third": active})
+		config := introspectionConfig(server.URL)
+		config.OAuth.Introspection.CacheSize = 2
+		introspector := middleware.ProvideIntrospector(config)
+
+		introspectRequest(introspector, "Bearer first")
+		introspectRequest(introspector, "Bearer second")
+		introspectRequest(introspector, "Bearer first")
+		introspectRequest(introspector, "Bearer third")
+		assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+
+		introspectRequest(introspector, "Bearer first")
+		assert.Equal(t, int32(3), atomic.LoadInt32(calls), "the recently used token stays cached")
+		introspectRequest(introspector, "Bearer second")
+		assert.Equal(t, int32(4), atomic.LoadInt32(calls), "the least recently used token was evicted")
+	})
+
+	t.Run("Expired Before Cache TTL", func(t *testing.T) {
+		expiring := active
+		expiring.Exp = time.Now().Add(-time.Second).Unix()
+		server, calls := newIntrospectionServer(t, map[string]oauth.Introspection{"expiring": expiring})
+		introspector := newIntrospector(server.URL)
+
+		introspectRequest(introspector, "Bearer expiring")
+		introspectRequest(introspector, "Bearer expiring")
+
+		assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	})
+
+	t.Run("Missing Bearer Token", func(t *testing.T) {
+		introspector := newIntrospector("http://127.0.0.1:1/oauth/introspect")
+
+		for _, authorization := range []string{"", "Basic Y2xpZW50OnNlY3JldA==", "Bearer "} {
+			w, _ := introspectRequest(introspector, authorization)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, authorization)
+		}
+	})
+
+	t.Run("Introspection Unavailable", func(t *testing.T) {
+		server, _ := newIntrospectionServer(t, nil)
+		server.Close()
+
+		w, _ := introspectRequest(newIntrospector(server.URL), "Bearer valid")
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+}
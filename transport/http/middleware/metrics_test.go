@@ -0,0 +1,83 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// metricValue returns the value of the counter or histogram sample count with
+// the labels, from the default registry.
+func metricValue(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if value, ok := labels[label.GetName()]; ok && value != label.GetValue() {
+					continue metrics
+				}
+			}
+			if metric.GetHistogram() != nil {
+				return float64(metric.GetHistogram().GetSampleCount())
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(middleware.Metrics)
+	router.Get("/metrics-test/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "id") == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	serve := func(path string) {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	t.Run("Label By Route", func(t *testing.T) {
+		labels := map[string]string{"method": "GET", "route": "/metrics-test/{id}", "status": "200"}
+		before := metricValue(t, "http_requests_total", labels)
+
+		serve("/metrics-test/1")
+		serve("/metrics-test/2")
+
+		assert.Equal(t, before+2, metricValue(t, "http_requests_total", labels))
+		assert.NotZero(t, metricValue(t, "http_request_duration_seconds", map[string]string{"method": "GET", "route": "/metrics-test/{id}"}))
+	})
+
+	t.Run("Count Errors", func(t *testing.T) {
+		labels := map[string]string{"method": "GET", "route": "/metrics-test/{id}", "status": "500"}
+		before := metricValue(t, "http_requests_total", labels)
+
+		serve("/metrics-test/fail")
+
+		assert.Equal(t, before+1, metricValue(t, "http_requests_total", labels))
+	})
+
+	t.Run("Unmatched Route", func(t *testing.T) {
+		labels := map[string]string{"method": "GET", "route": "unmatched", "status": "404"}
+		before := metricValue(t, "http_requests_total", labels)
+
+		serve("/unknown/path")
+
+		assert.Equal(t, before+1, metricValue(t, "http_requests_total", labels))
+	})
+}
@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/transport/http/response"
+	"github.com/go-redis/redis"
+)
+
+// The routes with their own rate limit policy. Any other route is limited
+// by the default policy.
+const (
+	RateLimitLogin    = "login"
+	RateLimitRegister = "register"
+	RateLimitCheckout = "checkout"
+	// RateLimitIntrospection is shared by token introspection and
+	// revocation.
+	RateLimitIntrospection = "introspection"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRateLimitPolicy    = "RateLimit-Policy"
+	HeaderRetryAfter         = "Retry-After"
+	HeaderForwardedFor       = "X-Forwarded-For"
+)
+
+// RateLimit allows bursts of Requests, refilled evenly over Period.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// RateLimitFromPolicy converts a configured policy.
+func RateLimitFromPolicy(policy configs.RateLimitPolicy) RateLimit {
+	return RateLimit{
+		Requests: policy.Requests,
+		Period:   time.Duration(policy.PeriodSeconds) * time.Second,
+	}
+}
+
+// perSecond is the rate the bucket refills at.
+func (l RateLimit) perSecond() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// decide tells the outcome of a take leaving the given tokens in the bucket.
+func (l RateLimit) decide(allowed bool, tokens float64) (decision RateLimitDecision) {
+	decision.Allowed = allowed
+	decision.Remaining = int(math.Floor(tokens))
+	decision.Reset = seconds((float64(l.Requests) - tokens) / l.perSecond())
+	if !allowed {
+		decision.RetryAfter = seconds((1 - tokens) / l.perSecond())
+	}
+	return
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
+
+// RateLimitDecision is the outcome of taking a token from a bucket.
+type RateLimitDecision struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, when this
+	// one wasn't.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the token buckets.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitDecision, error)
+}
+
+// RateLimiter limits the requests of each client with token buckets. Clients
+// are identified by their JWT user, their OAuth client, or their address
+// when anonymous.
+type RateLimiter struct {
+	store             RateLimitStore
+	fallback          RateLimitStore
+	enabled           bool
+	trustForwardedFor bool
+	policies          map[string]RateLimit
+	defaultPolicy     RateLimit
+}
+
+// ProvideRateLimiter is the provider for RateLimiter, keeping the buckets in
+// Redis unless configured otherwise.
+func ProvideRateLimiter(cache *redis.Client, config *configs.Config) *RateLimiter {
+	if config.RateLimit.Store == "memory" {
+		return NewRateLimiter(NewMemoryRateLimitStore(), config)
+	}
+	return NewRateLimiter(NewRedisRateLimitStore(cache), config)
+}
+
+// NewRateLimiter creates a RateLimiter keeping the buckets in the given
+// store, falling back to memory while the store fails.
+func NewRateLimiter(store RateLimitStore, config *configs.Config) *RateLimiter {
+	rateLimitConfig := config.RateLimit
+	return &RateLimiter{
+		store:             store,
+		fallback:          NewMemoryRateLimitStore(),
+		enabled:           rateLimitConfig.Enabled,
+		trustForwardedFor: rateLimitConfig.TrustForwardedFor,
+		policies: map[string]RateLimit{
+			RateLimitLogin:         RateLimitFromPolicy(rateLimitConfig.Login),
+			RateLimitRegister:      RateLimitFromPolicy(rateLimitConfig.Register),
+			RateLimitCheckout:      RateLimitFromPolicy(rateLimitConfig.Checkout),
+			RateLimitIntrospection: RateLimitFromPolicy(rateLimitConfig.Introspection),
+		},
+		defaultPolicy: RateLimitFromPolicy(rateLimitConfig.Default),
+	}
+}
+
+// Limit limits the requests to a route by the route's policy. Routes sharing
+// a name share their buckets. The limit is advertised in the RateLimit-*
+// headers, and the requests over it are refused with 429 Too Many Requests.
+func (l *RateLimiter) Limit(route string) func(http.Handler) http.Handler {
+	limit, ok := l.policies[route]
+	if !ok {
+		limit = l.defaultPolicy
+	}
+
+	return func(next http.Handler) http.Handler {
+		if !l.enabled || limit.Requests <= 0 || limit.Period <= 0 {
+			return next
+		}
+
+		policy := strconv.Itoa(limit.Requests) + ";w=" + strconv.Itoa(int(limit.Period.Seconds()))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			decision := l.take(r.Context(), "ratelimit:"+route+":"+l.identify(r), limit)
+
+			header := w.Header()
+			header.Set(HeaderRateLimitLimit, strconv.Itoa(limit.Requests))
+			header.Set(HeaderRateLimitRemaining, strconv.Itoa(decision.Remaining))
+			header.Set(HeaderRateLimitReset, ceilSeconds(decision.Reset))
+			header.Set(HeaderRateLimitPolicy, policy)
+
+			if !decision.Allowed {
+				header.Set(HeaderRetryAfter, ceilSeconds(decision.RetryAfter))
+				response.WithError(w, failure.TooManyRequests("rate limit exceeded, retry later"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (l *RateLimiter) take(ctx context.Context, key string, limit RateLimit) RateLimitDecision {
+	decision, err := l.store.Take(ctx, key, limit)
+	if err == nil {
+		return decision
+	}
+
+	logger.Ctx(ctx).Warn().Err(err).Msg("Rate limit store failed, limiting in memory.")
+	decision, _ = l.fallback.Take(ctx, key, limit)
+	return decision
+}
+
+// identify names the client making the request.
+func (l *RateLimiter) identify(r *http.Request) string {
+	ctx := r.Context()
+	if userID, ok := auth.UserIDFromContext(ctx); ok {
+		return "user:" + userID.String()
+	}
+	if clientID, ok := auth.ClientIDFromContext(ctx); ok {
+		return "client:" + clientID
+	}
+	return "ip:" + l.clientIP(r)
+}
+
+// clientIP is the address of the peer, or the last one appended to
+// X-Forwarded-For by the load balancer when trusted. The addresses before
+// it are set by the client, who may forge them.
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if l.trustForwardedFor {
+		forwarded := r.Header.Values(HeaderForwardedFor)
+		if len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
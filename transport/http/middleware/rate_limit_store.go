@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// MemoryRateLimitStore keeps the token buckets in memory, so each replica
+// limits the clients on its own.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// memorySweepInterval is how often the full buckets are dropped.
+const memorySweepInterval = time.Minute
+
+// NewMemoryRateLimitStore creates a MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Take takes a token from the bucket of the key.
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitDecision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updated: now}
+		s.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(limit.Requests), b.tokens+math.Max(0, elapsed)*limit.perSecond())
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	decision := limit.decide(allowed, b.tokens)
+	b.full = now.Add(decision.Reset)
+	return decision, nil
+}
+
+// sweep drops the buckets that have refilled, as a new bucket starts full.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// takeScript refills the bucket for the time elapsed since it was last
+// taken from, then takes a token if there is one. The bucket expires once
+// it would be full again.
+var takeScript = redis.NewScript(`
+local requests = tonumber(ARGV[1])
+local per_ms = requests / tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(state[1]) or requests
+local updated = tonumber(state[2]) or now
+
+tokens = math.min(requests, tokens + math.max(0, now - updated) * per_ms)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "updated", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil((requests - tokens) / per_ms) + 1)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisRateLimitStore keeps the token buckets in Redis, shared by every
+// replica.
+type RedisRateLimitStore struct {
+	client *redis.Client
+}
+
+// NewRedisRateLimitStore creates a RedisRateLimitStore.
+func NewRedisRateLimitStore(client *redis.Client) *RedisRateLimitStore {
+	return &RedisRateLimitStore{client: client}
+}
+
+// Take takes a token from the bucket of the key.
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (decision RateLimitDecision, err error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	period := limit.Period.Milliseconds()
+
+	result, err := takeScript.Run(s.client.WithContext(ctx), []string{key}, limit.Requests, period, now).Result()
+	if err != nil {
+		return
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return decision, fmt.Errorf("unexpected rate limit script result %v", result)
+	}
+	allowed, _ := values[0].(int64)
+	remaining, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(remaining, 64)
+	if err != nil {
+		return
+	}
+
+	return limit.decide(allowed == 1, tokens), nil
+}
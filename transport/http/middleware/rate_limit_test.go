@@ -0,0 +1,177 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/go-redis/redis"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func rateLimitConfig() *configs.Config {
+	config := &configs.Config{}
+	config.RateLimit.Enabled = true
+	config.RateLimit.Default = configs.RateLimitPolicy{Requests: 10, PeriodSeconds: 60}
+	config.RateLimit.Login = configs.RateLimitPolicy{Requests: 2, PeriodSeconds: 60}
+	return config
+}
+
+func limitedHandler(limiter *middleware.RateLimiter, route string) http.Handler {
+	return limiter.Limit(route)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func request(handler http.Handler, remoteAddr string, setup func(r *http.Request) *http.Request) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/v1/users/login", nil)
+	r.RemoteAddr = remoteAddr
+	if setup != nil {
+		r = setup(r)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func newRedisStore(t *testing.T) (*miniredis.Miniredis, *middleware.RedisRateLimitStore) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, middleware.NewRedisRateLimitStore(client)
+}
+
+func TestRateLimiter(t *testing.T) {
+	stores := map[string]func(t *testing.T) middleware.RateLimitStore{
+		"Memory": func(t *testing.T) middleware.RateLimitStore {
+			return middleware.NewMemoryRateLimitStore()
+		},
+		"Redis": func(t *testing.T) middleware.RateLimitStore {
+			_, store := newRedisStore(t)
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name+" Refuses Requests Over The Limit", func(t *testing.T) {
+			handler := limitedHandler(middleware.NewRateLimiter(newStore(t), rateLimitConfig()), middleware.RateLimitLogin)
+
+			first := request(handler, "10.0.0.1:5000", nil)
+			assert.Equal(t, http.StatusOK, first.Code)
+			assert.Equal(t, "2", first.Header().Get(middleware.HeaderRateLimitLimit))
+			assert.Equal(t, "1", first.Header().Get(middleware.HeaderRateLimitRemaining))
+			assert.Equal(t, "30", first.Header().Get(middleware.HeaderRateLimitReset))
+			assert.Equal(t, "2;w=60", first.Header().Get(middleware.HeaderRateLimitPolicy))
+			assert.Empty(t, first.Header().Get(middleware.HeaderRetryAfter))
+
+			assert.Equal(t, http.StatusOK, request(handler, "10.0.0.1:5001", nil).Code)
+
+			refused := request(handler, "10.0.0.1:5002", nil)
+			assert.Equal(t, http.StatusTooManyRequests, refused.Code)
+			assert.Equal(t, "0", refused.Header().Get(middleware.HeaderRateLimitRemaining))
+			assert.Equal(t, "30", refused.Header().Get(middleware.HeaderRetryAfter))
+
+			var body map[string]interface{}
+			assert.NoError(t, json.Unmarshal(refused.Body.Bytes(), &body))
+			assert.Contains(t, body["error"], "rate limit exceeded")
+
+			assert.Equal(t, http.StatusOK, request(handler, "10.0.0.2:5000", nil).Code, "other clients have their own bucket")
+		})
+	}
+
+	t.Run("Key By User And Client", func(t *testing.T) {
+		handler := limitedHandler(middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(), rateLimitConfig()), middleware.RateLimitLogin)
+		asUser := func(id uuid.UUID) func(r *http.Request) *http.Request {
+			return func(r *http.Request) *http.Request {
+				return r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{UserID: id}))
+			}
+		}
+		asClient := func(r *http.Request) *http.Request {
+			return r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{ClientID: "mobile"}))
+		}
+
+		user := uuid.Must(uuid.NewV4())
+		assert.Equal(t, http.StatusOK, request(handler, "10.0.0.1:5000", asUser(user)).Code)
+		assert.Equal(t, http.StatusOK, request(handler, "10.0.0.2:5000", asUser(user)).Code)
+		assert.Equal(t, http.StatusTooManyRequests, request(handler, "10.0.0.3:5000", asUser(user)).Code, "a user is limited across addresses")
+
+		assert.Equal(t, http.StatusOK, request(handler, "10.0.0.1:5000", asClient).Code)
+		assert.Equal(t, http.StatusOK, request(handler, "10.0.0.1:5000", nil).Code)
+		assert.Equal(t, http.StatusOK, request(handler, "10.0.0.1:5000", asClient).Code)
+		assert.Equal(t, http.StatusTooManyRequests, request(handler, "10.0.0.1:5000", asClient).Code)
+	})
+
+	t.Run("Separate Buckets Per Route", func(t *testing.T) {
+		limiter := middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(), rateLimitConfig())
+		login := limitedHandler(limiter, middleware.RateLimitLogin)
+		search := limitedHandler(limiter, "search")
+
+		request(login, "10.0.0.1:5000", nil)
+		request(login, "10.0.0.1:5000", nil)
+		assert.Equal(t, http.StatusTooManyRequests, request(login, "10.0.0.1:5000", nil).Code)
+
+		allowed := request(search, "10.0.0.1:5000", nil)
+		assert.Equal(t, http.StatusOK, allowed.Code)
+		assert.Equal(t, "10", allowed.Header().Get(middleware.HeaderRateLimitLimit), "routes without a policy use the default one")
+	})
+
+	t.Run("Shared By Replicas In Redis", func(t *testing.T) {
+		_, store := newRedisStore(t)
+		first := limitedHandler(middleware.NewRateLimiter(store, rateLimitConfig()), middleware.RateLimitLogin)
+		second := limitedHandler(middleware.NewRateLimiter(store, rateLimitConfig()), middleware.RateLimitLogin)
+
+		assert.Equal(t, http.StatusOK, request(first, "10.0.0.1:5000", nil).Code)
+		assert.Equal(t, http.StatusOK, request(second, "10.0.0.1:5000", nil).Code)
+		assert.Equal(t, http.StatusTooManyRequests, request(first, "10.0.0.1:5000", nil).Code)
+	})
+
+	t.Run("Fall Back To Memory", func(t *testing.T) {
+		server, store := newRedisStore(t)
+		server.Close()
+		handler := limitedHandler(middleware.NewRateLimiter(store, rateLimitConfig()), middleware.RateLimitLogin)
+
+		assert.Equal(t, http.StatusOK, request(handler, "10.0.0.1:5000", nil).Code)
+		assert.Equal(t, http.StatusOK, request(handler, "10.0.0.1:5000", nil).Code)
+		assert.Equal(t, http.StatusTooManyRequests, request(handler, "10.0.0.1:5000", nil).Code)
+	})
+
+	t.Run("Trust Forwarded For", func(t *testing.T) {
+		config := rateLimitConfig()
+		config.RateLimit.TrustForwardedFor = true
+		handler := limitedHandler(middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(), config), middleware.RateLimitLogin)
+		forwardedFor := func(chain string) func(r *http.Request) *http.Request {
+			return func(r *http.Request) *http.Request {
+				r.Header.Set(middleware.HeaderForwardedFor, chain)
+				return r
+			}
+		}
+
+		assert.Equal(t, http.StatusOK, request(handler, "10.0.0.100:5000", forwardedFor("1.1.1.1, 203.0.113.7")).Code)
+		assert.Equal(t, http.StatusOK, request(handler, "10.0.0.100:5000", forwardedFor("2.2.2.2, 203.0.113.7")).Code)
+		assert.Equal(t, http.StatusTooManyRequests, request(handler, "10.0.0.101:5000", forwardedFor("3.3.3.3, 203.0.113.7")).Code, "forged addresses must not get a new bucket")
+		assert.Equal(t, http.StatusOK, request(handler, "10.0.0.100:5000", forwardedFor("203.0.113.8")).Code)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		config := rateLimitConfig()
+		config.RateLimit.Enabled = false
+		handler := limitedHandler(middleware.NewRateLimiter(middleware.NewMemoryRateLimitStore(), config), middleware.RateLimitLogin)
+
+		for i := 0; i < 3; i++ {
+			w := request(handler, "10.0.0.1:5000", nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Empty(t, w.Header().Get(middleware.HeaderRateLimitLimit))
+		}
+	})
+}
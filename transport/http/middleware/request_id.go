@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+	"unicode"
+
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	// HeaderRequestID carries the ID correlating the logs and events of a
+	// request across services.
+	HeaderRequestID = "X-Request-ID"
+
+	maxRequestIDLength = 128
+)
+
+// RequestID binds the request to its context for logging. The ID is taken
+// from the X-Request-ID header, or generated when it is missing or invalid,
+// and echoed in the response.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(HeaderRequestID)
+		if !validRequestID(requestID) {
+			requestID = uuid.Must(uuid.NewV4()).String()
+		}
+		w.Header().Set(HeaderRequestID, requestID)
+
+		ctx := r.Context()
+		ctx = logger.WithRequest(ctx, requestID, func() string {
+			if rctx := chi.RouteContext(ctx); rctx != nil {
+				return rctx.RoutePattern()
+			}
+			return ""
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Logger logs every request once served, with its request ID.
+func Logger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		logger.Ctx(r.Context()).Info().
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", ww.Status()).
+			Int("bytes", ww.BytesWritten()).
+			Dur("duration", time.Since(start)).
+			Msg("Served request")
+	})
+}
@@ -0,0 +1,55 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	serve := func(requestID string) (string, string) {
+		var seen string
+		handler := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = logger.RequestID(r.Context())
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if requestID != "" {
+			r.Header.Set(middleware.HeaderRequestID, requestID)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return seen, w.Header().Get(middleware.HeaderRequestID)
+	}
+
+	t.Run("Accept Incoming ID", func(t *testing.T) {
+		seen, echoed := serve("upstream-123")
+
+		assert.Equal(t, "upstream-123", seen)
+		assert.Equal(t, "upstream-123", echoed)
+	})
+
+	t.Run("Generate Missing ID", func(t *testing.T) {
+		seen, echoed := serve("")
+
+		_, err := uuid.FromString(seen)
+		assert.NoError(t, err)
+		assert.Equal(t, seen, echoed)
+	})
+
+	t.Run("Replace Invalid ID", func(t *testing.T) {
+		for _, invalid := range []string{strings.Repeat("a", 129), "bad\x01id"} {
+			seen, echoed := serve(invalid)
+
+			assert.NotEqual(t, invalid, seen)
+			assert.Equal(t, seen, echoed)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/evermos/boilerplate-go/transport/http/response"
+)
+
+// RequireScopes lets through the requests whose token holds every scope
+// required. It must follow a middleware authenticating the token; the other
+// requests are refused with insufficient_scope, RFC 6750 section 3.1.
+func RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	required := strings.Join(scopes, " ")
+	challenge := fmt.Sprintf(`%s error="insufficient_scope", scope="%s"`, oauth.Bearer, required)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ := auth.PrincipalFromContext(r.Context())
+			if !principal.HasScopes(scopes...) {
+				w.Header().Set("WWW-Authenticate", challenge)
+				response.WithError(w, failure.InsufficientScope("the token requires the scopes "+required))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/shared/auth"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func requireScopes(ctx context.Context, scopes ...string) *httptest.ResponseRecorder {
+	handler := middleware.RequireScopes(scopes...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/v1/foobarbaz/foo/1", nil).WithContext(ctx))
+	return w
+}
+
+func TestRequireScopes(t *testing.T) {
+	t.Run("Granted Scopes", func(t *testing.T) {
+		ctx := auth.WithPrincipal(context.Background(), auth.Principal{ClientID: "client_web", Scopes: []string{"user", "foo:read", "foo:write"}})
+
+		w := requireScopes(ctx, "foo:read", "foo:write")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Insufficient Scope", func(t *testing.T) {
+		w := requireScopes(auth.WithPrincipal(context.Background(), auth.Principal{Scopes: []string{"user", "foo:read"}}), "foo:write")
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, `Bearer error="insufficient_scope", scope="foo:write"`, w.Header().Get("WWW-Authenticate"))
+		assert.Contains(t, w.Body.String(), "INSUFFICIENT_SCOPE")
+	})
+
+	t.Run("Unauthenticated Request", func(t *testing.T) {
+		w := requireScopes(context.Background(), "foo:read")
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
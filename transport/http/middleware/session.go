@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/evermos/boilerplate-go/infras"
+)
+
+// DBSession starts a read-your-writes database session for every request,
+// so the reads made after a write in the same request see that write.
+func DBSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(infras.WithSession(r.Context())))
+	})
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/transport/http/response"
+)
+
+// Timeout sets a deadline on the request context, so the queries and calls
+// made for the request are cancelled once it passes. It can be used for the
+// whole server or per route; the shortest deadline wins when nested.
+// Requests that time out without writing a response get a 504.
+func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{ResponseWriter: w}
+			next.ServeHTTP(tw, r.WithContext(ctx))
+
+			if !tw.wroteHeader && ctx.Err() == context.DeadlineExceeded {
+				response.WithError(w, failure.GatewayTimeout("request timed out"))
+			}
+		})
+	}
+}
+
+// timeoutWriter records whether the handler has written a response. It keeps
+// the Flusher and Hijacker of the underlying writer available to handlers.
+type timeoutWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.wroteHeader = true
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.wroteHeader = true
+	return tw.ResponseWriter.Write(b)
+}
+
+// Flush sends the response written so far, when the underlying writer can.
+func (tw *timeoutWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		tw.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Hijack hands the connection over to the handler, when the underlying
+// writer can.
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := tw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("middleware: response writer doesn't support hijacking")
+	}
+
+	tw.wroteHeader = true
+	return h.Hijack()
+}
@@ -0,0 +1,78 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTraceExporter() *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return exporter
+}
+
+func TestTracing(t *testing.T) {
+	newRouter := func() *chi.Mux {
+		router := chi.NewRouter()
+		router.Use(middleware.Tracing)
+		router.Get("/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+			assert.True(t, trace.SpanContextFromContext(r.Context()).IsValid())
+		})
+		router.Get("/fail", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+		return router
+	}
+
+	t.Run("Span Named After Route", func(t *testing.T) {
+		exporter := newTraceExporter()
+
+		newRouter().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products/42", nil))
+
+		spans := exporter.GetSpans()
+		if assert.Len(t, spans, 1) {
+			assert.Equal(t, "GET /products/{id}", spans[0].Name)
+			assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind)
+			assert.Contains(t, spans[0].Attributes, attribute.String("http.route", "/products/{id}"))
+			assert.Contains(t, spans[0].Attributes, attribute.Int("http.status_code", http.StatusOK))
+		}
+	})
+
+	t.Run("Continue Trace Of Caller", func(t *testing.T) {
+		exporter := newTraceExporter()
+		r := httptest.NewRequest(http.MethodGet, "/products/42", nil)
+		r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		newRouter().ServeHTTP(httptest.NewRecorder(), r)
+
+		spans := exporter.GetSpans()
+		if assert.Len(t, spans, 1) {
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID().String())
+			assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID().String())
+		}
+	})
+
+	t.Run("Server Error", func(t *testing.T) {
+		exporter := newTraceExporter()
+
+		newRouter().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+		spans := exporter.GetSpans()
+		if assert.Len(t, spans, 1) {
+			assert.Equal(t, codes.Error, spans[0].Status.Code)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	// MaxBodyBytes is the size limit of the request bodies read by Decode.
+	MaxBodyBytes = 1 << 20
+
+	HeaderAcceptLanguage = "Accept-Language"
+	HeaderContentType    = "Content-Type"
+)
+
+// Decode reads the JSON body of a request into v and validates it. The body
+// must be application/json, at most MaxBodyBytes long and hold a single
+// object without unknown fields. The invalid fields are listed in the
+// returned failure.
+func Decode(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if !isJSON(r.Header.Get(HeaderContentType)) {
+		return failure.UnsupportedMediaType("request body must be application/json")
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return failure.BadRequestFromString("request body must hold a single JSON object")
+	}
+
+	return Validate(r, v)
+}
+
+// Validate validates v by its validate tags. The messages of the invalid
+// fields are in the first language of the request's Accept-Language
+// supported, or in English.
+func Validate(r *http.Request, v interface{}) error {
+	err := shared.GetValidator().Struct(v)
+	if err == nil {
+		return nil
+	}
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return failure.BadRequest(err)
+	}
+
+	trans := shared.GetTranslator(languages(r.Header.Get(HeaderAcceptLanguage))...)
+	fields := make([]failure.FieldError, 0, len(invalid))
+	for _, fieldErr := range invalid {
+		fields = append(fields, failure.FieldError{
+			Field:   fieldPath(fieldErr.Namespace()),
+			Rule:    fieldErr.Tag(),
+			Message: fieldErr.Translate(trans),
+		})
+	}
+	return failure.InvalidFields(fields)
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// decodeError explains why a body couldn't be decoded.
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return failure.BadRequestFromString("request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return failure.BadRequestFromString("request body is malformed JSON")
+	case errors.As(err, &syntaxErr):
+		return failure.BadRequestFromString(fmt.Sprintf("request body is malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return failure.InvalidFields([]failure.FieldError{{
+			Field:   field,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s must be a %s", field, typeErr.Type),
+		}})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return failure.InvalidFields([]failure.FieldError{{
+			Field:   field,
+			Rule:    "unknown",
+			Message: fmt.Sprintf("%s is not a known field", field),
+		}})
+	case err.Error() == "http: request body too large":
+		return failure.RequestEntityTooLarge(fmt.Sprintf("request body must not be larger than %d bytes", MaxBodyBytes))
+	default:
+		return failure.BadRequest(err)
+	}
+}
+
+// fieldPath is the path of a field from the validated struct, such as
+// items[0].sku.
+func fieldPath(namespace string) string {
+	parts := strings.SplitN(namespace, ".", 2)
+	return parts[len(parts)-1]
+}
+
+// languages lists the base languages of an Accept-Language header in the
+// order listed, such as id for id-ID.
+func languages(acceptLanguage string) (result []string) {
+	for _, tag := range strings.Split(acceptLanguage, ",") {
+		tag = strings.TrimSpace(strings.SplitN(tag, ";", 2)[0])
+		tag = strings.SplitN(tag, "-", 2)[0]
+		if tag != "" && tag != "*" {
+			result = append(result, strings.ToLower(tag))
+		}
+	}
+	return
+}
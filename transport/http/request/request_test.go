@@ -0,0 +1,130 @@
+package request_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/transport/http/request"
+	"github.com/evermos/boilerplate-go/transport/http/response"
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	SKU      string `json:"sku" validate:"required"`
+	Quantity int64  `json:"quantity" validate:"min=1"`
+}
+
+type order struct {
+	Email string `json:"email" validate:"required,email"`
+	Items []item `json:"items" validate:"required,dive"`
+}
+
+func decode(body, contentType, language string) (order, error) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set(request.HeaderContentType, contentType)
+	}
+	if language != "" {
+		r.Header.Set(request.HeaderAcceptLanguage, language)
+	}
+
+	var requestFormat order
+	err := request.Decode(httptest.NewRecorder(), r, &requestFormat)
+	return requestFormat, err
+}
+
+func fields(t *testing.T, err error) []failure.FieldError {
+	f, ok := err.(*failure.Failure)
+	if !assert.True(t, ok, "expected a failure, got %v", err) {
+		return nil
+	}
+	return f.Fields
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		requestFormat, err := decode(`{"email":"a@example.com","items":[{"sku":"A-1","quantity":2}]}`, "application/json; charset=utf-8", "")
+
+		assert.NoError(t, err)
+		assert.Equal(t, order{Email: "a@example.com", Items: []item{{SKU: "A-1", Quantity: 2}}}, requestFormat)
+	})
+
+	t.Run("Invalid Fields", func(t *testing.T) {
+		_, err := decode(`{"email":"not-an-email","items":[{"sku":"","quantity":0}]}`, "application/json", "")
+
+		assert.Equal(t, http.StatusBadRequest, failure.GetCode(err))
+		assert.Equal(t, []failure.FieldError{
+			{Field: "email", Rule: "email", Message: "email must be a valid email address"},
+			{Field: "items[0].sku", Rule: "required", Message: "sku is a required field"},
+			{Field: "items[0].quantity", Rule: "min", Message: "quantity must be 1 or greater"},
+		}, fields(t, err))
+	})
+
+	t.Run("Translated Messages", func(t *testing.T) {
+		_, err := decode(`{"items":[{"sku":"A-1","quantity":1}]}`, "application/json", "id-ID,en;q=0.8")
+
+		assert.Equal(t, []failure.FieldError{
+			{Field: "email", Rule: "required", Message: "email wajib diisi"},
+		}, fields(t, err))
+	})
+
+	t.Run("Unknown Field", func(t *testing.T) {
+		_, err := decode(`{"email":"a@example.com","admin":true}`, "application/json", "")
+
+		assert.Equal(t, []failure.FieldError{
+			{Field: "admin", Rule: "unknown", Message: "admin is not a known field"},
+		}, fields(t, err))
+	})
+
+	t.Run("Wrong Type", func(t *testing.T) {
+		_, err := decode(`{"email":"a@example.com","items":[{"sku":"A-1","quantity":"two"}]}`, "application/json", "")
+
+		invalid := fields(t, err)
+		if assert.Len(t, invalid, 1) {
+			assert.Equal(t, "type", invalid[0].Rule)
+			assert.Contains(t, invalid[0].Field, "quantity")
+			assert.Contains(t, invalid[0].Message, "must be a int64")
+		}
+	})
+
+	t.Run("Malformed Bodies", func(t *testing.T) {
+		for name, body := range map[string]string{
+			"Empty":     ``,
+			"Truncated": `{"email":`,
+			"Syntax":    `{"email" "a@example.com"}`,
+			"Trailing":  `{"email":"a@example.com"} {}`,
+		} {
+			_, err := decode(body, "application/json", "")
+			assert.Equal(t, http.StatusBadRequest, failure.GetCode(err), name)
+		}
+	})
+
+	t.Run("Unsupported Content Type", func(t *testing.T) {
+		for _, contentType := range []string{"", "text/plain", "application/x-www-form-urlencoded"} {
+			_, err := decode(`{}`, contentType, "")
+			assert.Equal(t, http.StatusUnsupportedMediaType, failure.GetCode(err), contentType)
+		}
+	})
+
+	t.Run("Body Too Large", func(t *testing.T) {
+		body := `{"email":"` + strings.Repeat("a", request.MaxBodyBytes) + `"}`
+		_, err := decode(body, "application/json", "")
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, failure.GetCode(err))
+	})
+
+	t.Run("Listed In Response", func(t *testing.T) {
+		_, err := decode(`{"email":"a@example.com"}`, "application/json", "")
+		w := httptest.NewRecorder()
+		response.WithError(w, err)
+
+		var body response.Base
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, []failure.FieldError{{Field: "items", Rule: "required", Message: "items is a required field"}}, body.Errors)
+	})
+}
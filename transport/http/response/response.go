@@ -3,16 +3,47 @@ package response
 import (
 	"encoding/json"
 	"net/http"
+	"sync/atomic"
 
+	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/evermos/boilerplate-go/shared/logger"
 )
 
+// ContentTypeProblem is the content type of the problem details responses.
+const ContentTypeProblem = "application/problem+json"
+
+// problemDetails tells whether errors are sent as problem details.
+var problemDetails int32
+
 // Base is the base object of all responses
 type Base struct {
-	Data    *interface{} `json:"data,omitempty"`
-	Error   *string      `json:"error,omitempty"`
-	Message *string      `json:"message,omitempty"`
+	Data      *interface{}         `json:"data,omitempty"`
+	Error     *string              `json:"error,omitempty"`
+	ErrorCode failure.Code         `json:"errorCode,omitempty"`
+	Errors    []failure.FieldError `json:"errors,omitempty"`
+	Message   *string              `json:"message,omitempty"`
+}
+
+// Problem is an error response following RFC 7807, extended with the code
+// of the failure and the invalid fields of the request.
+type Problem struct {
+	Type   string               `json:"type"`
+	Title  string               `json:"title"`
+	Status int                  `json:"status"`
+	Detail string               `json:"detail,omitempty"`
+	Code   failure.Code         `json:"code"`
+	Errors []failure.FieldError `json:"errors,omitempty"`
+}
+
+// SetProblemDetails sets whether errors are sent as problem details, in
+// application/problem+json, instead of Base.
+func SetProblemDetails(config *configs.Config) {
+	var enabled int32
+	if config.App.ProblemDetails {
+		enabled = 1
+	}
+	atomic.StoreInt32(&problemDetails, enabled)
 }
 
 // NoContent sends a response without any content
@@ -30,11 +61,34 @@ func WithJSON(w http.ResponseWriter, code int, jsonPayload interface{}) {
 	respond(w, code, Base{Data: &jsonPayload})
 }
 
-// WithError sends a response with an error message
+// WithError sends a response with an error message and code, and the
+// invalid fields of the request if any. The causes of failures and the
+// messages of other errors are kept from clients.
 func WithError(w http.ResponseWriter, err error) {
 	code := failure.GetCode(err)
-	errMsg := err.Error()
-	respond(w, code, Base{Error: &errMsg})
+	errMsg := http.StatusText(code)
+	var fields []failure.FieldError
+	if f, ok := failure.As(err); ok {
+		errMsg = f.Public()
+		fields = f.Fields
+	}
+
+	if atomic.LoadInt32(&problemDetails) == 1 {
+		problem := Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(code),
+			Status: code,
+			Code:   failure.GetErrorCode(err),
+			Errors: fields,
+		}
+		if f, ok := failure.As(err); ok {
+			problem.Detail = f.Message
+		}
+		respondWithContentType(w, code, ContentTypeProblem, problem)
+		return
+	}
+
+	respond(w, code, Base{Error: &errMsg, ErrorCode: failure.GetErrorCode(err), Errors: fields})
 }
 
 // WithPreparingShutdown sends a default response for when the server is preparing to shut down
@@ -48,8 +102,12 @@ func WithUnhealthy(w http.ResponseWriter) {
 }
 
 func respond(w http.ResponseWriter, code int, payload interface{}) {
+	respondWithContentType(w, code, "application/json", payload)
+}
+
+func respondWithContentType(w http.ResponseWriter, code int, contentType string, payload interface{}) {
 	response, _ := json.Marshal(payload)
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(code)
 	_, err := w.Write(response)
 	if err != nil {
@@ -0,0 +1,65 @@
+package response_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/transport/http/response"
+	"github.com/stretchr/testify/assert"
+)
+
+func problemDetails(t *testing.T, enabled bool) {
+	config := &configs.Config{}
+	config.App.ProblemDetails = enabled
+	response.SetProblemDetails(config)
+	t.Cleanup(func() { response.SetProblemDetails(&configs.Config{}) })
+}
+
+func TestWithError(t *testing.T) {
+	outOfStock := failure.New(failure.CodeCartItemOutOfStock, "product 'Mug' is out of stock")
+
+	t.Run("Base", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		response.WithError(w, outOfStock)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"error":"Conflict: product 'Mug' is out of stock","errorCode":"CART_ITEM_OUT_OF_STOCK"}`, w.Body.String())
+	})
+
+	t.Run("Hide Causes And Other Errors", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		response.WithError(w, failure.InternalError(errors.New("dial tcp 10.0.0.5:3306: connection refused")))
+		assert.JSONEq(t, `{"error":"Internal Server Error: internal error","errorCode":"INTERNAL_ERROR"}`, w.Body.String())
+
+		w = httptest.NewRecorder()
+		response.WithError(w, errors.New("Error 1146: Table 'shop.carts' doesn't exist"))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"error":"Internal Server Error","errorCode":"INTERNAL_ERROR"}`, w.Body.String())
+	})
+
+	t.Run("Problem Details", func(t *testing.T) {
+		problemDetails(t, true)
+
+		w := httptest.NewRecorder()
+		response.WithError(w, failure.InvalidFields([]failure.FieldError{{Field: "email", Rule: "required", Message: "email is a required field"}}))
+
+		var problem response.Problem
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, response.ContentTypeProblem, w.Header().Get("Content-Type"))
+		assert.Equal(t, response.Problem{
+			Type:   "about:blank",
+			Title:  "Bad Request",
+			Status: http.StatusBadRequest,
+			Detail: "invalid request",
+			Code:   failure.CodeInvalidRequest,
+			Errors: []failure.FieldError{{Field: "email", Rule: "required", Message: "email is a required field"}},
+		}, problem)
+	})
+}
@@ -12,6 +12,7 @@ type DomainHandlers struct {
 	ProductHandler   handlers.ProductHandler
 	CartHandler      handlers.CartHandler
 	OrderHandler     handlers.OrderHandler
+	OAuthHandler     handlers.OAuthHandler
 }
 
 // Router is the router struct containing handlers.
@@ -35,4 +36,7 @@ func (r *Router) SetupRoutes(mux *chi.Mux) {
 		r.DomainHandlers.CartHandler.Router(rc)
 		r.DomainHandlers.OrderHandler.Router(rc)
 	})
+
+	// the authorization server follows the paths of RFC 6749, unversioned
+	r.DomainHandlers.OAuthHandler.Router(mux)
 }
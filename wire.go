@@ -5,6 +5,8 @@ package main
 
 import (
 	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event"
+	fooBarBazEvent "github.com/evermos/boilerplate-go/event/domain/foobarbaz"
 	"github.com/evermos/boilerplate-go/event/producer"
 	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/internal/domain/cart"
@@ -13,6 +15,7 @@ import (
 	"github.com/evermos/boilerplate-go/internal/domain/product"
 	"github.com/evermos/boilerplate-go/internal/domain/user"
 	"github.com/evermos/boilerplate-go/internal/handlers"
+	"github.com/evermos/boilerplate-go/shared/oauth"
 	"github.com/evermos/boilerplate-go/transport/http"
 	"github.com/evermos/boilerplate-go/transport/http/middleware"
 	"github.com/evermos/boilerplate-go/transport/http/router"
@@ -27,6 +30,8 @@ var configurations = wire.NewSet(
 // Wiring for persistences.
 var persistences = wire.NewSet(
 	infras.ProvideMySQLConn,
+	infras.ProvideRedis,
+	wire.Bind(new(infras.Transactor), new(*infras.MySQLConn)),
 )
 
 // Wiring for domain FooBarBaz.
@@ -39,7 +44,8 @@ var domainFooBarBaz = wire.NewSet(
 	wire.Bind(new(foobarbaz.FooRepository), new(*foobarbaz.FooRepositoryMySQL)),
 	// Producer interface and implementation
 	producer.NewSNSProducer,
-	wire.Bind(new(producer.Producer), new(*producer.SNSProducer)),
+	producer.ProvideOutbox,
+	wire.Bind(new(producer.Producer), new(*producer.Outbox)),
 )
 
 var domainUser = wire.NewSet(
@@ -52,9 +58,11 @@ var domainProduct = wire.NewSet(
 	//ProductService interface and implement
 	product.ProvideProductServiceImpl,
 	wire.Bind(new(product.ProductService), new(*product.ProductServiceImpl)),
-	//ProductRepository interface and implement
+	//ProductRepository interface and implement, cached in Redis. Bind
+	//*product.ProductRepositoryMySQL instead to read products from MySQL only.
 	product.ProvideProductRepository,
-	wire.Bind(new(product.ProductRepository), new(*product.ProductRepositoryMySQL)),
+	product.ProvideProductRepositoryRedis,
+	wire.Bind(new(product.ProductRepository), new(*product.ProductRepositoryRedis)),
 )
 var domainCart = wire.NewSet(
 	//CartService interface and implement
@@ -83,28 +91,32 @@ var domains = wire.NewSet(
 )
 
 var authMiddleware = wire.NewSet(
+	oauth.ProvideTokenStore,
 	middleware.ProvideAuthentication,
+	middleware.ProvideRateLimiter,
+	middleware.ProvideIntrospector,
 )
 
 // Wiring for HTTP routing.
 var routing = wire.NewSet(
-	wire.Struct(new(router.DomainHandlers), "FooBarBazHandler", "UserHandler", "ProductHandler", "CartHandler", "OrderHandler"),
+	wire.Struct(new(router.DomainHandlers), "FooBarBazHandler", "UserHandler", "ProductHandler", "CartHandler", "OrderHandler", "OAuthHandler"),
 	handlers.ProvideFooBarBazHandler,
 	handlers.ProvideUserHandler,
 	handlers.ProvideProductHandler,
 	handlers.ProvideCartHandler,
 	handlers.ProvideOrderHandler,
+	handlers.ProvideOAuthHandler,
 	router.ProvideRouter,
 )
 
 // Wiring for all domains event consumer.
-//var evco = wire.NewSet(
-//	wire.Struct(new(event.Consumers), "FooBarBaz"),
-//	fooBarBazEvent.ProvideConsumerImpl,
-//)
+var evco = wire.NewSet(
+	wire.Struct(new(event.Consumers), "FooBarBaz"),
+	fooBarBazEvent.ProvideConsumerImpl,
+)
 
 // Wiring for everything.
-func InitializeService() *http.HTTP {
+func InitializeService() (*http.HTTP, error) {
 	wire.Build(
 		// configurations
 		configurations,
@@ -118,7 +130,7 @@ func InitializeService() *http.HTTP {
 		routing,
 		// selected transport layer
 		http.ProvideHTTP)
-	return &http.HTTP{}
+	return &http.HTTP{}, nil
 }
 
 // Wiring the event needs.
@@ -135,3 +147,20 @@ func InitializeService() *http.HTTP {
 //
 //	return event.Consumers{}
 //}
+
+// Wiring for the replay command.
+func InitializeReplay() ReplayDependencies {
+	wire.Build(
+		// configurations
+		configurations,
+		// persistences
+		persistences,
+		// domains
+		domains,
+		// event consumer
+		evco,
+		// replay dependencies
+		wire.Struct(new(ReplayDependencies), "Config", "DB", "Consumers", "Producer"))
+
+	return ReplayDependencies{}
+}